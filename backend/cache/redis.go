@@ -74,6 +74,10 @@ func (r *RedisClient) Increment(key string) (int64, error) {
 	return r.client.Incr(r.ctx, key).Result()
 }
 
+func (r *RedisClient) IncrementBy(key string, value int64) (int64, error) {
+	return r.client.IncrBy(r.ctx, key, value).Result()
+}
+
 func (r *RedisClient) SetExpiration(key string, expiration time.Duration) error {
 	return r.client.Expire(r.ctx, key, expiration).Err()
 }
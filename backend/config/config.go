@@ -1,6 +1,7 @@
 package config
 
 import (
+	"net/url"
 	"os"
 	"strings"
 )
@@ -16,10 +17,28 @@ type Config struct {
 	RedisURL       string
 	RedisPassword  string
 	RedisDB        int
+	// The fake payment gateway is off unless enabled, and never registered in
+	// production. It needs its own webhook secret.
+	FakePaymentsEnabled bool
+	FakePaymentSecret   string
+	// Hosts work proof files are downloaded from for fingerprinting
+	ProofFileHosts string
+	// Base URL the API is reachable at, used in links to its own routes
+	PublicURL string
+	// Uploaded files are kept on local disk or in an S3-compatible bucket
+	StorageBackend       string
+	StorageLocalDir      string
+	StorageSigningSecret string
+	S3Endpoint           string
+	S3Region             string
+	S3Bucket             string
+	S3AccessKey          string
+	S3SecretKey          string
+	S3UsePathStyle       bool
 }
 
 func New() *Config {
-	return &Config{
+	cfg := &Config{
 		DatabaseURL:    getEnv("DATABASE_URL", "postgres://localhost/microjob_db?sslmode=disable"),
 		JWTSecret:      getEnv("JWT_SECRET", "your-secret-key"),
 		SupabaseURL:    getEnv("NEXT_PUBLIC_SUPABASE_URL", ""),
@@ -30,7 +49,42 @@ func New() *Config {
 		RedisURL:       getEnv("REDIS_URL", "localhost:6379"),
 		RedisPassword:  getEnv("REDIS_PASSWORD", ""),
 		RedisDB:        getEnvInt("REDIS_DB", 0),
+
+		FakePaymentsEnabled: getEnv("FAKE_PAYMENTS_ENABLED", "false") == "true",
+		FakePaymentSecret:   getEnv("FAKE_PAYMENT_SECRET", ""),
+
+		PublicURL:       getEnv("PUBLIC_API_URL", "http://localhost:8080"),
+		StorageBackend:  getEnv("STORAGE_BACKEND", "local"),
+		StorageLocalDir: getEnv("STORAGE_LOCAL_DIR", "./uploads"),
+		S3Endpoint:      getEnv("S3_ENDPOINT", ""),
+		S3Region:        getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:        getEnv("S3_BUCKET", ""),
+		S3AccessKey:     getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:     getEnv("S3_SECRET_KEY", ""),
+		S3UsePathStyle:  getEnv("S3_USE_PATH_STYLE", "true") == "true",
+	}
+	cfg.StorageSigningSecret = getEnv("STORAGE_SIGNING_SECRET", cfg.JWTSecret)
+	cfg.ProofFileHosts = getEnv("PROOF_FILE_HOSTS", hostOf(cfg.SupabaseURL))
+	return cfg
+}
+
+// ProofFileHostList splits ProofFileHosts into host names.
+func (c *Config) ProofFileHostList() []string {
+	var hosts []string
+	for _, host := range strings.Split(c.ProofFileHosts, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
 	}
+	return u.Hostname()
 }
 
 func getEnv(key, defaultValue string) string {
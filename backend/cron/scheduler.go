@@ -9,23 +9,43 @@ import (
 )
 
 type CronScheduler struct {
-	cron                *cron.Cron
-	reservationService  *services.ReservationService
-	workProofService    *services.WorkProofService
-	walletService       *services.WalletService
-	adminService        *services.AdminService
+	cron                  *cron.Cron
+	reservationService    *services.ReservationService
+	workProofService      *services.WorkProofService
+	walletService         *services.WalletService
+	adminService          *services.AdminService
+	idempotencyService    *services.IdempotencyService
+	escrowService         *services.EscrowService
+	webhookService        *services.WebhookService
+	reconciliationService *services.ReconciliationService
+	chatService           *services.ChatService
+	refundService         *services.RefundService
+	fingerprintService    *services.FingerprintService
+	imageService          *services.ImageService
 }
 
 func NewCronScheduler(reservationService *services.ReservationService, workProofService *services.WorkProofService,
-	walletService *services.WalletService, adminService *services.AdminService) *CronScheduler {
+	walletService *services.WalletService, adminService *services.AdminService,
+	idempotencyService *services.IdempotencyService, escrowService *services.EscrowService,
+	webhookService *services.WebhookService, reconciliationService *services.ReconciliationService,
+	chatService *services.ChatService, refundService *services.RefundService,
+	fingerprintService *services.FingerprintService, imageService *services.ImageService) *CronScheduler {
 	c := cron.New(cron.WithSeconds())
-	
+
 	return &CronScheduler{
-		cron:               c,
-		reservationService: reservationService,
-		workProofService:   workProofService,
-		walletService:      walletService,
-		adminService:       adminService,
+		cron:                  c,
+		reservationService:    reservationService,
+		workProofService:      workProofService,
+		walletService:         walletService,
+		adminService:          adminService,
+		idempotencyService:    idempotencyService,
+		escrowService:         escrowService,
+		webhookService:        webhookService,
+		reconciliationService: reconciliationService,
+		chatService:           chatService,
+		refundService:         refundService,
+		fingerprintService:    fingerprintService,
+		imageService:          imageService,
 	}
 }
 
@@ -37,10 +57,34 @@ func (cs *CronScheduler) Start() {
 	
 	// Process work proof timeouts every 10 minutes
 	cs.cron.AddFunc("0 */10 * * * *", cs.processWorkProofTimeouts)
+
+	// Retry payment webhooks that failed or were never picked up every 30 seconds
+	cs.cron.AddFunc("*/30 * * * * *", cs.processWebhookEvents)
+
+	// Release earnings whose hold period has ended every 15 minutes
+	cs.cron.AddFunc("0 */15 * * * *", cs.releaseMaturedEarnings)
+
+	// Refund escrow of cancelled and expired jobs every 30 minutes
+	cs.cron.AddFunc("0 */30 * * * *", cs.refundExpiredEscrows)
+
+	// Expire unanswered chat money transfers every 10 minutes
+	cs.cron.AddFunc("0 */10 * * * *", cs.expireChatMoneyTransfers)
+
+	// Collect debts left by refunds from incoming money every 15 minutes
+	cs.cron.AddFunc("0 */15 * * * *", cs.collectWalletDebts)
+
+	// Fingerprint new proof files and flag reused ones every 2 minutes
+	cs.cron.AddFunc("0 */2 * * * *", cs.fingerprintWorkProofs)
+
+	// Make thumbnails of uploaded images and strip their location every 20 seconds
+	cs.cron.AddFunc("*/20 * * * * *", cs.processUploadedImages)
 	
 	// Cleanup old data daily at 2 AM
 	cs.cron.AddFunc("0 0 2 * * *", cs.dailyCleanup)
 	
+	// Compare wallets with their transaction history daily at 4 AM
+	cs.cron.AddFunc("0 0 4 * * *", cs.reconcileWallets)
+
 	// Generate reports weekly on Sunday at 3 AM
 	cs.cron.AddFunc("0 0 3 * * 0", cs.weeklyReports)
 
@@ -92,6 +136,128 @@ func (cs *CronScheduler) processWorkProofTimeouts() {
 	}
 }
 
+func (cs *CronScheduler) releaseMaturedEarnings() {
+	log.Println("[CRON] Releasing matured pending earnings...")
+
+	released, err := cs.walletService.ReleaseMaturedEarnings()
+	if err != nil {
+		log.Printf("[CRON] Error releasing pending earnings: %v", err)
+		return
+	}
+
+	if released > 0 {
+		log.Printf("[CRON] Released %d earning holds to available balance", released)
+	} else {
+		log.Println("[CRON] No matured earning holds found")
+	}
+}
+
+func (cs *CronScheduler) processWebhookEvents() {
+	processed, err := cs.webhookService.ProcessDueEvents()
+	if err != nil {
+		log.Printf("[CRON] Error processing payment webhooks: %v", err)
+		return
+	}
+
+	// Runs often, so stay quiet when there was nothing to do
+	if processed > 0 {
+		log.Printf("[CRON] Processed %d payment webhooks", processed)
+	}
+}
+
+func (cs *CronScheduler) refundExpiredEscrows() {
+	log.Println("[CRON] Refunding escrow of expired jobs...")
+
+	refunded, err := cs.escrowService.RefundExpiredJobs()
+	if err != nil {
+		log.Printf("[CRON] Error refunding expired escrows: %v", err)
+		return
+	}
+
+	if refunded > 0 {
+		log.Printf("[CRON] Refunded escrow for %d jobs", refunded)
+	} else {
+		log.Println("[CRON] No expired escrows found")
+	}
+}
+
+func (cs *CronScheduler) expireChatMoneyTransfers() {
+	log.Println("[CRON] Expiring unanswered chat money transfers...")
+
+	expired, err := cs.chatService.ExpireTransfers()
+	if err != nil {
+		log.Printf("[CRON] Error expiring chat money transfers: %v", err)
+		return
+	}
+
+	if expired > 0 {
+		log.Printf("[CRON] Expired %d chat money transfers", expired)
+	} else {
+		log.Println("[CRON] No unanswered chat money transfers to expire")
+	}
+}
+
+func (cs *CronScheduler) collectWalletDebts() {
+	log.Println("[CRON] Collecting outstanding wallet debts...")
+
+	collected, err := cs.refundService.CollectDebts()
+	if err != nil {
+		log.Printf("[CRON] Error collecting wallet debts: %v", err)
+		return
+	}
+
+	if collected > 0 {
+		log.Printf("[CRON] Collected payments towards %d wallet debts", collected)
+	} else {
+		log.Println("[CRON] No wallet debts could be collected")
+	}
+}
+
+func (cs *CronScheduler) fingerprintWorkProofs() {
+	processed, err := cs.fingerprintService.FingerprintPending()
+	if err != nil {
+		log.Printf("[CRON] Error fingerprinting work proofs: %v", err)
+		return
+	}
+
+	if processed > 0 {
+		log.Printf("[CRON] Fingerprinted %d work proofs", processed)
+	}
+}
+
+func (cs *CronScheduler) processUploadedImages() {
+	processed, err := cs.imageService.ProcessPending()
+	if err != nil {
+		log.Printf("[CRON] Error processing uploaded images: %v", err)
+		return
+	}
+
+	if processed > 0 {
+		log.Printf("[CRON] Processed %d uploaded images", processed)
+	}
+}
+
+func (cs *CronScheduler) reconcileWallets() {
+	log.Println("[CRON] Reconciling wallets...")
+
+	// Scheduled runs only report; corrections need an admin to confirm them
+	run, err := cs.reconciliationService.Reconcile("", "")
+	if err != nil {
+		log.Printf("[CRON] Error reconciling wallets: %v", err)
+		return
+	}
+
+	if run.DiscrepancyCount > 0 {
+		log.Printf("[CRON] Reconciliation run %s found %d discrepancies across %d wallets",
+			run.ID, run.DiscrepancyCount, run.WalletsChecked)
+	} else {
+		log.Printf("[CRON] All %d wallets match their transaction history", run.WalletsChecked)
+	}
+	if run.UnclassifiedCount > 0 {
+		log.Printf("[CRON] %d transactions of unknown type were left out of reconciliation", run.UnclassifiedCount)
+	}
+}
+
 func (cs *CronScheduler) dailyCleanup() {
 	log.Println("[CRON] Starting daily cleanup...")
 	
@@ -123,6 +289,14 @@ func (cs *CronScheduler) dailyCleanup() {
 	} else {
 		log.Println("[CRON] Successfully cleaned up old violations")
 	}
+
+	// Remove idempotency keys whose replay window has passed
+	_, err = cs.idempotencyService.CleanupExpiredKeys()
+	if err != nil {
+		log.Printf("[CRON] Error cleaning up idempotency keys: %v", err)
+	} else {
+		log.Println("[CRON] Successfully cleaned up expired idempotency keys")
+	}
 	
 	log.Println("[CRON] Daily cleanup completed")
 }
@@ -33,6 +33,34 @@ func (db *DB) RunMigrations() error {
 		createUserFavoritesTable,
 		createJobReservationsTable,
 		createWorkProofsTable,
+		createLedgerAccountsTable,
+		createLedgerEntriesTable,
+		createLedgerLinesTable,
+		createLedgerGuards,
+		alterWalletTransactionsForLedger,
+		backfillLedgerOpeningBalances,
+		createIdempotencyKeysTable,
+		createJobEscrowsTable,
+		createEarningHoldsTable,
+		createWithdrawalTables,
+		createDepositsTable,
+		createPaymentWebhookEventsTable,
+		createWalletReconciliationTables,
+		backfillEscrowReleaseSpend,
+		alterAdminFeeSettingsForFeeEngine,
+		alterJobEscrowsForFees,
+		createMultiCurrencyTables,
+		createChatMoneyTransfersTable,
+		createAdminAlertsTable,
+		createWalletRefundTables,
+		createWorkProofEventsTable,
+		createWorkProofSubmissionsTable,
+		createWorkProofDisputesTables,
+		createJobApprovalPoliciesTables,
+		createWorkProofFingerprintTables,
+		createFilesTable,
+		createFileImageTables,
+		alterJobsForPricing,
 		createIndexes,
 	}
 
@@ -415,6 +443,669 @@ CREATE TABLE IF NOT EXISTS work_proofs (
     rejected_at TIMESTAMP WITH TIME ZONE
 );`
 
+const createLedgerAccountsTable = `
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    account_type VARCHAR(30) NOT NULL,
+    owner_id UUID,
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    balance DECIMAL(14,2) NOT NULL DEFAULT 0.00,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+ALTER TABLE ledger_accounts ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+DROP INDEX IF EXISTS idx_ledger_accounts_type_owner;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ledger_accounts_type_owner_currency
+    ON ledger_accounts(account_type, COALESCE(owner_id::text, ''), currency);`
+
+const createLedgerEntriesTable = `
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    entry_type VARCHAR(50) NOT NULL,
+    description TEXT,
+    reference_id UUID,
+    reference_type VARCHAR(50),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);`
+
+const createLedgerLinesTable = `
+CREATE TABLE IF NOT EXISTS ledger_lines (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    entry_id UUID NOT NULL REFERENCES ledger_entries(id),
+    account_id UUID NOT NULL REFERENCES ledger_accounts(id),
+    amount DECIMAL(14,2) NOT NULL CHECK (amount <> 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);`
+
+// Journal entries are append-only and every entry must balance, in each
+// currency it touches, when its transaction commits.
+const createLedgerGuards = `
+CREATE OR REPLACE FUNCTION ledger_reject_mutation() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'ledger records are immutable (% on %)', TG_OP, TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS ledger_entries_immutable ON ledger_entries;
+CREATE TRIGGER ledger_entries_immutable BEFORE UPDATE OR DELETE ON ledger_entries
+    FOR EACH ROW EXECUTE FUNCTION ledger_reject_mutation();
+
+DROP TRIGGER IF EXISTS ledger_lines_immutable ON ledger_lines;
+CREATE TRIGGER ledger_lines_immutable BEFORE UPDATE OR DELETE ON ledger_lines
+    FOR EACH ROW EXECUTE FUNCTION ledger_reject_mutation();
+
+CREATE OR REPLACE FUNCTION ledger_check_entry_balanced() RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1
+        FROM ledger_lines l
+        JOIN ledger_accounts a ON a.id = l.account_id
+        WHERE l.entry_id = NEW.entry_id
+        GROUP BY a.currency
+        HAVING SUM(l.amount) <> 0
+    ) THEN
+        RAISE EXCEPTION 'ledger entry % is not balanced', NEW.entry_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS ledger_lines_balanced ON ledger_lines;
+CREATE CONSTRAINT TRIGGER ledger_lines_balanced AFTER INSERT ON ledger_lines
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION ledger_check_entry_balanced();`
+
+const alterWalletTransactionsForLedger = `
+ALTER TABLE wallet_transactions ADD COLUMN IF NOT EXISTS user_id UUID REFERENCES users(id);
+ALTER TABLE wallet_transactions ADD COLUMN IF NOT EXISTS balance_type VARCHAR(20) DEFAULT 'deposit';
+ALTER TABLE wallet_transactions ADD COLUMN IF NOT EXISTS ledger_entry_id UUID REFERENCES ledger_entries(id);
+ALTER TABLE wallet_transactions ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW();
+ALTER TABLE wallet_transactions ALTER COLUMN type TYPE VARCHAR(50);`
+
+// Wallets that predate the ledger get an opening entry funded from the external account.
+const backfillLedgerOpeningBalances = `
+INSERT INTO ledger_accounts (account_type, owner_id)
+SELECT t.account_type, w.user_id
+FROM wallets w
+CROSS JOIN (VALUES ('user_available'), ('user_pending')) AS t(account_type)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO ledger_accounts (account_type, owner_id) VALUES ('external', NULL) ON CONFLICT DO NOTHING;
+
+WITH opening AS (
+    INSERT INTO ledger_entries (entry_type, description, reference_id, reference_type)
+    SELECT 'opening_balance', 'Opening balance carried over from wallets', w.id, 'wallet'
+    FROM wallets w
+    WHERE (w.balance <> 0 OR w.pending_balance <> 0)
+      AND NOT EXISTS (
+          SELECT 1 FROM ledger_entries e
+          WHERE e.entry_type = 'opening_balance' AND e.reference_id = w.id
+      )
+    RETURNING id, reference_id
+)
+INSERT INTO ledger_lines (entry_id, account_id, amount)
+SELECT o.id, a.id, x.amount
+FROM opening o
+JOIN wallets w ON w.id = o.reference_id
+CROSS JOIN LATERAL (VALUES
+    ('user_available', w.user_id, w.balance),
+    ('user_pending', w.user_id, w.pending_balance),
+    ('external', NULL::uuid, -(w.balance + w.pending_balance))
+) AS x(account_type, owner_id, amount)
+JOIN ledger_accounts a ON a.account_type = x.account_type AND a.owner_id IS NOT DISTINCT FROM x.owner_id
+    AND a.currency = 'USD'
+WHERE x.amount <> 0;
+
+UPDATE ledger_accounts a
+SET balance = s.total, updated_at = NOW()
+FROM (SELECT account_id, SUM(amount) AS total FROM ledger_lines GROUP BY account_id) s
+WHERE a.id = s.account_id AND a.balance <> s.total;`
+
+const createIdempotencyKeysTable = `
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_method VARCHAR(10) NOT NULL,
+    request_path VARCHAR(255) NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'processing',
+    response_status INTEGER,
+    response_body TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    completed_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    UNIQUE(user_id, idempotency_key)
+);`
+
+const createJobEscrowsTable = `
+CREATE TABLE IF NOT EXISTS job_escrows (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    job_id UUID NOT NULL UNIQUE,
+    employer_id UUID NOT NULL REFERENCES users(id),
+    amount_per_worker DECIMAL(12,2) NOT NULL,
+    workers_count INTEGER NOT NULL DEFAULT 1,
+    funded_amount DECIMAL(12,2) NOT NULL,
+    released_amount DECIMAL(12,2) NOT NULL DEFAULT 0.00,
+    refunded_amount DECIMAL(12,2) NOT NULL DEFAULT 0.00,
+    status VARCHAR(20) NOT NULL DEFAULT 'funded',
+    ledger_entry_id UUID REFERENCES ledger_entries(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    closed_at TIMESTAMP WITH TIME ZONE,
+    CHECK (released_amount + refunded_amount <= funded_amount)
+);`
+
+const createEarningHoldsTable = `
+CREATE TABLE IF NOT EXISTS earning_holds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    transaction_id UUID REFERENCES wallet_transactions(id),
+    amount DECIMAL(12,2) NOT NULL CHECK (amount > 0),
+    reference_id UUID,
+    reference_type VARCHAR(50),
+    status VARCHAR(20) NOT NULL DEFAULT 'held',
+    release_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    released_at TIMESTAMP WITH TIME ZONE,
+    released_by UUID REFERENCES users(id),
+    ledger_entry_id UUID REFERENCES ledger_entries(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Pending balances earned before holds were tracked are released on the next run
+INSERT INTO earning_holds (user_id, amount, status, release_at)
+SELECT w.user_id, w.pending_balance, 'held', NOW()
+FROM wallets w
+WHERE w.pending_balance > 0
+  AND NOT EXISTS (SELECT 1 FROM earning_holds h WHERE h.user_id = w.user_id);`
+
+const createWithdrawalTables = `
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS locked_balance DECIMAL(12,2) DEFAULT 0.00;
+
+CREATE TABLE IF NOT EXISTS payout_batches (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    status VARCHAR(20) NOT NULL DEFAULT 'exported',
+    withdrawal_count INTEGER NOT NULL DEFAULT 0,
+    total_amount DECIMAL(14,2) NOT NULL DEFAULT 0.00,
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS withdrawal_requests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id),
+    amount DECIMAL(12,2) NOT NULL CHECK (amount > 0),
+    fee_amount DECIMAL(12,2) NOT NULL DEFAULT 0.00,
+    net_amount DECIMAL(12,2) NOT NULL,
+    payout_method VARCHAR(50) NOT NULL,
+    payout_details TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    batch_id UUID REFERENCES payout_batches(id),
+    reviewed_by UUID REFERENCES users(id),
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    rejection_reason TEXT,
+    payout_reference VARCHAR(255),
+    failure_reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    completed_at TIMESTAMP WITH TIME ZONE
+);`
+
+const createDepositsTable = `
+CREATE TABLE IF NOT EXISTS deposits (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id),
+    gateway VARCHAR(50) NOT NULL,
+    amount DECIMAL(12,2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    provider_reference VARCHAR(255),
+    payment_url TEXT,
+    transaction_id UUID REFERENCES wallet_transactions(id),
+    failure_reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_deposits_gateway_reference
+    ON deposits(gateway, provider_reference) WHERE provider_reference IS NOT NULL;`
+
+// Raw provider webhooks, stored before processing so a failed event can be
+// retried and a redelivered one recognised by its provider event ID.
+const createPaymentWebhookEventsTable = `
+CREATE TABLE IF NOT EXISTS payment_webhook_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    provider VARCHAR(50) NOT NULL,
+    event_id VARCHAR(255) NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    provider_reference VARCHAR(255) NOT NULL,
+    amount DECIMAL(12,2) NOT NULL DEFAULT 0.00,
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    payload TEXT NOT NULL,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    last_error TEXT,
+    received_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    processed_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE(provider, event_id)
+);
+
+-- Last line of defence against a deposit being credited twice
+CREATE UNIQUE INDEX IF NOT EXISTS idx_wallet_transactions_deposit_credit
+    ON wallet_transactions(reference_id) WHERE type = 'deposit' AND reference_type = 'deposit';`
+
+const createWalletReconciliationTables = `
+CREATE TABLE IF NOT EXISTS wallet_reconciliation_runs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    requested_by UUID REFERENCES users(id),
+    user_id UUID REFERENCES users(id),
+    status VARCHAR(20) NOT NULL DEFAULT 'running',
+    wallets_checked INTEGER NOT NULL DEFAULT 0,
+    discrepancy_count INTEGER NOT NULL DEFAULT 0,
+    unclassified_count INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    started_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS wallet_discrepancies (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    run_id UUID NOT NULL REFERENCES wallet_reconciliation_runs(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id),
+    field VARCHAR(20) NOT NULL,
+    recorded_amount DECIMAL(14,2) NOT NULL,
+    expected_amount DECIMAL(14,2) NOT NULL,
+    difference DECIMAL(14,2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    corrected_by UUID REFERENCES users(id),
+    corrected_at TIMESTAMP WITH TIME ZONE,
+    ledger_entry_id UUID REFERENCES ledger_entries(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);`
+
+// Escrow payouts now leave an escrow_release row on the employer's wallet,
+// which reconciliation counts towards total_spent. Payouts made before that
+// get their row from the ledger.
+const backfillEscrowReleaseSpend = `
+INSERT INTO wallet_transactions (wallet_id, user_id, type, amount, description, reference_id,
+    reference_type, balance_type, status, ledger_entry_id, created_at, updated_at)
+SELECT w.id, w.user_id, 'escrow_release', -l.amount, e.description, e.reference_id,
+    e.reference_type, 'deposit', 'completed', e.id, e.created_at, e.created_at
+FROM ledger_entries e
+JOIN ledger_lines l ON l.entry_id = e.id
+JOIN ledger_accounts a ON a.id = l.account_id AND a.account_type = 'escrow'
+JOIN job_escrows je ON je.job_id = a.owner_id
+JOIN wallets w ON w.user_id = je.employer_id
+WHERE e.entry_type = 'escrow_release'
+  AND NOT EXISTS (
+      SELECT 1 FROM wallet_transactions t WHERE t.ledger_entry_id = e.id AND t.type = 'escrow_release'
+  );`
+
+// Fee settings gain tiered brackets and the side that bears the fee. Job payouts
+// used to be configured in platform_fee_settings; the latest row there seeds
+// the job_payout fee so existing settings carry over.
+const alterAdminFeeSettingsForFeeEngine = `
+ALTER TABLE admin_fee_settings ADD COLUMN IF NOT EXISTS tiers JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE admin_fee_settings ADD COLUMN IF NOT EXISTS charged_to VARCHAR(10) NOT NULL DEFAULT 'payee';
+
+INSERT INTO admin_fee_settings (fee_type, fee_percentage, fee_fixed, minimum_fee, maximum_fee, is_active)
+SELECT 'job_payout', percentage, fixed_fee, minimum_fee, NULLIF(maximum_fee, 0), enabled
+FROM platform_fee_settings
+ORDER BY updated_at DESC
+LIMIT 1
+ON CONFLICT (fee_type) DO NOTHING;`
+
+// Escrows keep the payout fee they were funded with. Older escrows that were
+// funded with an employer-borne fee get it back from the funded amount; the
+// rest are left without one and priced at the current fee when released.
+const alterJobEscrowsForFees = `
+ALTER TABLE job_escrows ADD COLUMN IF NOT EXISTS fee_per_worker DECIMAL(12,2);
+ALTER TABLE job_escrows ADD COLUMN IF NOT EXISTS fee_charged_to VARCHAR(10);
+UPDATE job_escrows
+SET fee_per_worker = funded_amount / workers_count - amount_per_worker, fee_charged_to = 'payer'
+WHERE fee_charged_to IS NULL AND funded_amount > amount_per_worker * workers_count;`
+
+// Amounts that predate multi-currency wallets are all in USD, which the new
+// currency columns default to. Balances in USD stay on the wallets row; every
+// other currency gets a wallet_balances row.
+const createMultiCurrencyTables = `
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    currency VARCHAR(3) NOT NULL,
+    rate DECIMAL(20,8) NOT NULL CHECK (rate > 0),
+    set_by UUID REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS wallet_balances (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    currency VARCHAR(3) NOT NULL,
+    balance DECIMAL(12,2) NOT NULL DEFAULT 0.00,
+    pending_balance DECIMAL(12,2) NOT NULL DEFAULT 0.00,
+    locked_balance DECIMAL(12,2) NOT NULL DEFAULT 0.00,
+    total_earned DECIMAL(12,2) NOT NULL DEFAULT 0.00,
+    total_spent DECIMAL(12,2) NOT NULL DEFAULT 0.00,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(user_id, currency)
+);
+
+CREATE TABLE IF NOT EXISTS currency_conversions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id),
+    from_currency VARCHAR(3) NOT NULL,
+    to_currency VARCHAR(3) NOT NULL,
+    amount DECIMAL(12,2) NOT NULL CHECK (amount > 0),
+    fee_amount DECIMAL(12,2) NOT NULL DEFAULT 0.00,
+    converted_amount DECIMAL(12,2) NOT NULL CHECK (converted_amount > 0),
+    from_rate DECIMAL(20,8) NOT NULL,
+    to_rate DECIMAL(20,8) NOT NULL,
+    ledger_entry_id UUID REFERENCES ledger_entries(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+ALTER TABLE wallet_transactions ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE earning_holds ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE job_escrows ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE withdrawal_requests ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE payout_batches ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE microjobs ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE IF EXISTS jobs ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE marketplace_items ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'USD';`
+
+const createChatMoneyTransfersTable = `
+CREATE TABLE IF NOT EXISTS chat_money_transfers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    chat_id UUID NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    sender_id UUID NOT NULL REFERENCES users(id),
+    receiver_id UUID NOT NULL REFERENCES users(id),
+    amount DECIMAL(12,2) NOT NULL CHECK (amount > 0),
+    commission_amount DECIMAL(12,2) NOT NULL DEFAULT 0.00,
+    net_amount DECIMAL(12,2) NOT NULL,
+    message TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'requested',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Transfers used to complete immediately; the request flow records every
+-- answer, dispute and refund on the row itself
+ALTER TABLE chat_money_transfers ADD COLUMN IF NOT EXISTS requested_by UUID REFERENCES users(id);
+ALTER TABLE chat_money_transfers ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE chat_money_transfers ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE chat_money_transfers ADD COLUMN IF NOT EXISTS responded_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE chat_money_transfers ADD COLUMN IF NOT EXISTS decline_reason TEXT;
+ALTER TABLE chat_money_transfers ADD COLUMN IF NOT EXISTS refundable_until TIMESTAMP WITH TIME ZONE;
+ALTER TABLE chat_money_transfers ADD COLUMN IF NOT EXISTS disputed_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE chat_money_transfers ADD COLUMN IF NOT EXISTS dispute_reason TEXT;
+ALTER TABLE chat_money_transfers ADD COLUMN IF NOT EXISTS resolved_by UUID REFERENCES users(id);
+ALTER TABLE chat_money_transfers ADD COLUMN IF NOT EXISTS resolved_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE chat_money_transfers ADD COLUMN IF NOT EXISTS resolution_note TEXT;
+ALTER TABLE chat_money_transfers ADD COLUMN IF NOT EXISTS refunded_by UUID REFERENCES users(id);
+ALTER TABLE chat_money_transfers ADD COLUMN IF NOT EXISTS refunded_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE chat_money_transfers ADD COLUMN IF NOT EXISTS ledger_entry_id UUID REFERENCES ledger_entries(id);
+ALTER TABLE chat_money_transfers ADD COLUMN IF NOT EXISTS refund_ledger_entry_id UUID REFERENCES ledger_entries(id);
+UPDATE chat_money_transfers SET requested_by = sender_id WHERE requested_by IS NULL;`
+
+const createAdminAlertsTable = `
+CREATE TABLE IF NOT EXISTS admin_alerts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    alert_type VARCHAR(50) NOT NULL,
+    severity VARCHAR(20) NOT NULL DEFAULT 'warning' CHECK (severity IN ('warning', 'critical')),
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    message TEXT NOT NULL,
+    details JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'acknowledged')),
+    acknowledged_by UUID REFERENCES users(id),
+    acknowledged_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+`
+
+const createWalletRefundTables = `
+CREATE TABLE IF NOT EXISTS wallet_refunds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    original_transaction_id UUID NOT NULL REFERENCES wallet_transactions(id),
+    payer_id UUID NOT NULL REFERENCES users(id),
+    payee_id UUID NOT NULL REFERENCES users(id),
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    amount DECIMAL(12,2) NOT NULL CHECK (amount > 0),
+    from_available DECIMAL(12,2) NOT NULL DEFAULT 0.00,
+    from_pending DECIMAL(12,2) NOT NULL DEFAULT 0.00,
+    as_debt DECIMAL(12,2) NOT NULL DEFAULT 0.00,
+    reason TEXT NOT NULL,
+    refunded_by UUID NOT NULL REFERENCES users(id),
+    ledger_entry_id UUID REFERENCES ledger_entries(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CHECK (from_available + from_pending + as_debt = amount)
+);
+
+CREATE TABLE IF NOT EXISTS wallet_debts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    refund_id UUID REFERENCES wallet_refunds(id),
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    amount DECIMAL(12,2) NOT NULL CHECK (amount > 0),
+    outstanding_amount DECIMAL(12,2) NOT NULL CHECK (outstanding_amount >= 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'outstanding' CHECK (status IN ('outstanding', 'settled')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    settled_at TIMESTAMP WITH TIME ZONE
+);
+`
+
+// Columns the review flow reads and writes, plus the history of every status
+// change a work proof goes through
+const createWorkProofEventsTable = `
+ALTER TABLE work_proofs ADD COLUMN IF NOT EXISTS employer_id UUID REFERENCES users(id);
+ALTER TABLE work_proofs ADD COLUMN IF NOT EXISTS submission_text TEXT;
+ALTER TABLE work_proofs ADD COLUMN IF NOT EXISTS proof_files JSONB DEFAULT '[]';
+ALTER TABLE work_proofs ADD COLUMN IF NOT EXISTS proof_links JSONB DEFAULT '[]';
+ALTER TABLE work_proofs ADD COLUMN IF NOT EXISTS screenshots JSONB DEFAULT '[]';
+ALTER TABLE work_proofs ADD COLUMN IF NOT EXISTS attachments JSONB DEFAULT '[]';
+ALTER TABLE work_proofs ADD COLUMN IF NOT EXISTS submitted_at TIMESTAMP WITH TIME ZONE DEFAULT NOW();
+ALTER TABLE work_proofs ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE work_proofs ADD COLUMN IF NOT EXISTS review_feedback TEXT;
+ALTER TABLE work_proofs ADD COLUMN IF NOT EXISTS submission_number INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE work_proofs ADD COLUMN IF NOT EXISTS revision_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE work_proofs ADD COLUMN IF NOT EXISTS revision_deadline TIMESTAMP WITH TIME ZONE;
+ALTER TABLE work_proofs ADD COLUMN IF NOT EXISTS rejection_deadline TIMESTAMP WITH TIME ZONE;
+ALTER TABLE work_proofs ADD COLUMN IF NOT EXISTS worker_response VARCHAR(20);
+ALTER TABLE work_proofs ADD COLUMN IF NOT EXISTS worker_response_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE work_proofs ADD COLUMN IF NOT EXISTS dispute_reason TEXT;
+ALTER TABLE work_proofs ADD COLUMN IF NOT EXISTS dispute_evidence TEXT;
+ALTER TABLE work_proofs ADD COLUMN IF NOT EXISTS dispute_requested_action TEXT;
+ALTER TABLE work_proofs ALTER COLUMN status SET DEFAULT 'submitted';
+
+CREATE TABLE IF NOT EXISTS work_proof_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    work_proof_id UUID NOT NULL REFERENCES work_proofs(id) ON DELETE CASCADE,
+    action VARCHAR(30) NOT NULL,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    actor_id UUID REFERENCES users(id),
+    actor_role VARCHAR(20) NOT NULL CHECK (actor_role IN ('worker', 'employer', 'system')),
+    reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+`
+
+// Every version of a work proof's content. Proofs submitted before versions
+// were kept get their current content as their first version.
+const createWorkProofSubmissionsTable = `
+CREATE TABLE IF NOT EXISTS work_proof_submissions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    work_proof_id UUID NOT NULL REFERENCES work_proofs(id) ON DELETE CASCADE,
+    submission_number INTEGER NOT NULL,
+    previous_submission_id UUID REFERENCES work_proof_submissions(id),
+    description TEXT NOT NULL,
+    submission_text TEXT,
+    proof_files JSONB DEFAULT '[]',
+    proof_links JSONB DEFAULT '[]',
+    screenshots JSONB DEFAULT '[]',
+    attachments JSONB DEFAULT '[]',
+    submitted_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(work_proof_id, submission_number)
+);
+
+INSERT INTO work_proof_submissions (work_proof_id, submission_number, description, submission_text,
+    proof_files, proof_links, screenshots, attachments, submitted_at)
+SELECT id, submission_number, description, submission_text, COALESCE(proof_files, '[]'),
+    COALESCE(proof_links, '[]'), COALESCE(screenshots, '[]'), COALESCE(attachments, '[]'),
+    COALESCE(submitted_at, created_at)
+FROM work_proofs wp
+WHERE NOT EXISTS (SELECT 1 FROM work_proof_submissions s WHERE s.work_proof_id = wp.id);
+`
+
+// Disputes over rejected work proofs and the messages and evidence both sides
+// add to them. Admins act on proofs too once disputes exist.
+const createWorkProofDisputesTables = `
+CREATE TABLE IF NOT EXISTS work_proof_disputes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    work_proof_id UUID NOT NULL UNIQUE REFERENCES work_proofs(id) ON DELETE CASCADE,
+    job_id UUID NOT NULL,
+    worker_id UUID NOT NULL REFERENCES users(id),
+    employer_id UUID NOT NULL REFERENCES users(id),
+    reason TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    requested_action TEXT,
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    amount DECIMAL(12,2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    priority VARCHAR(10) NOT NULL DEFAULT 'medium',
+    resolution VARCHAR(20),
+    resolved_amount DECIMAL(12,2),
+    admin_id UUID REFERENCES users(id),
+    admin_notes TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    resolved_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS work_proof_dispute_messages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    dispute_id UUID NOT NULL REFERENCES work_proof_disputes(id) ON DELETE CASCADE,
+    sender_id UUID NOT NULL REFERENCES users(id),
+    sender_role VARCHAR(20) NOT NULL,
+    message TEXT NOT NULL,
+    evidence JSONB DEFAULT '[]',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+ALTER TABLE work_proof_events DROP CONSTRAINT IF EXISTS work_proof_events_actor_role_check;
+ALTER TABLE work_proof_events ADD CONSTRAINT work_proof_events_actor_role_check
+    CHECK (actor_role IN ('worker', 'employer', 'system', 'admin'));
+`
+
+// Per-job auto-approval rules, and the rule that decided each proof
+const createJobApprovalPoliciesTables = `
+CREATE TABLE IF NOT EXISTS job_approval_policies (
+    job_id UUID PRIMARY KEY,
+    never_auto_approve BOOLEAN NOT NULL DEFAULT FALSE,
+    min_worker_rating DECIMAL(3,2) CHECK (min_worker_rating BETWEEN 0 AND 5),
+    min_screenshots INTEGER NOT NULL DEFAULT 0 CHECK (min_screenshots >= 0),
+    min_links INTEGER NOT NULL DEFAULT 0 CHECK (min_links >= 0),
+    sample_review_percent INTEGER CHECK (sample_review_percent BETWEEN 0 AND 100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS work_proof_policy_decisions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    work_proof_id UUID NOT NULL REFERENCES work_proofs(id) ON DELETE CASCADE,
+    stage VARCHAR(20) NOT NULL, -- 'submission' or 'sweep'
+    rule VARCHAR(30) NOT NULL,
+    auto_approved BOOLEAN NOT NULL,
+    detail TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+`
+
+const createWorkProofFingerprintTables = `
+ALTER TABLE work_proofs ADD COLUMN IF NOT EXISTS fingerprinted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE work_proofs ADD COLUMN IF NOT EXISTS possible_duplicate BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS work_proof_fingerprints (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    work_proof_id UUID NOT NULL REFERENCES work_proofs(id) ON DELETE CASCADE,
+    submission_number INTEGER NOT NULL,
+    file_url TEXT NOT NULL,
+    sha256 CHAR(64) NOT NULL,
+    phash BIGINT, -- difference hash, images only
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(work_proof_id, submission_number, file_url)
+);
+
+CREATE TABLE IF NOT EXISTS work_proof_duplicates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    work_proof_id UUID NOT NULL REFERENCES work_proofs(id) ON DELETE CASCADE,
+    submission_number INTEGER NOT NULL,
+    file_url TEXT NOT NULL,
+    matched_work_proof_id UUID NOT NULL REFERENCES work_proofs(id) ON DELETE CASCADE,
+    matched_file_url TEXT NOT NULL,
+    match_type VARCHAR(20) NOT NULL CHECK (match_type IN ('exact', 'perceptual')),
+    distance INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(work_proof_id, submission_number, file_url, matched_work_proof_id, matched_file_url)
+);
+`
+
+const createFilesTable = `
+CREATE TABLE IF NOT EXISTS files (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    context_type VARCHAR(20) NOT NULL CHECK (context_type IN ('chat', 'job', 'support_ticket', 'marketplace')),
+    context_id UUID,
+    original_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL CHECK (size > 0),
+    sha256 CHAR(64) NOT NULL,
+    storage_backend VARCHAR(20) NOT NULL,
+    storage_key TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+`
+
+const createFileImageTables = `
+CREATE TABLE IF NOT EXISTS file_images (
+    file_id UUID PRIMARY KEY REFERENCES files(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'processing' CHECK (status IN ('processing', 'processed', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 1,
+    width INTEGER,
+    height INTEGER,
+    captured_at TIMESTAMP, -- camera local time, EXIF has no zone
+    location_removed BOOLEAN NOT NULL DEFAULT FALSE,
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS file_thumbnails (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    file_id UUID NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    size VARCHAR(10) NOT NULL CHECK (size IN ('small', 'medium', 'large')),
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    storage_key TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(file_id, size)
+);
+`
+
+// Jobs keep the itemized price they were posted at. Jobs posted before
+// pricing moved to the server have no quote.
+const alterJobsForPricing = `
+ALTER TABLE microjobs ADD COLUMN IF NOT EXISTS required_screenshots INTEGER NOT NULL DEFAULT 0 CHECK (required_screenshots >= 0);
+ALTER TABLE microjobs ADD COLUMN IF NOT EXISTS price_quote JSONB;
+ALTER TABLE IF EXISTS jobs ADD COLUMN IF NOT EXISTS required_screenshots INTEGER NOT NULL DEFAULT 0 CHECK (required_screenshots >= 0);
+ALTER TABLE IF EXISTS jobs ADD COLUMN IF NOT EXISTS price_quote JSONB;`
+
 const createIndexes = `
 CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
 CREATE INDEX IF NOT EXISTS idx_users_username ON users(username);
@@ -437,4 +1128,47 @@ CREATE INDEX IF NOT EXISTS idx_work_proofs_status ON work_proofs(status);
 CREATE INDEX IF NOT EXISTS idx_job_reservations_job_id ON job_reservations(job_id);
 CREATE INDEX IF NOT EXISTS idx_job_reservations_user_id ON job_reservations(user_id);
 CREATE INDEX IF NOT EXISTS idx_job_reservations_expires_at ON job_reservations(expires_at);
+CREATE INDEX IF NOT EXISTS idx_ledger_accounts_owner_id ON ledger_accounts(owner_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_reference ON ledger_entries(reference_type, reference_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_lines_entry_id ON ledger_lines(entry_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_lines_account_id ON ledger_lines(account_id);
+CREATE INDEX IF NOT EXISTS idx_wallet_transactions_ledger_entry_id ON wallet_transactions(ledger_entry_id);
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+CREATE INDEX IF NOT EXISTS idx_job_escrows_employer_id ON job_escrows(employer_id);
+CREATE INDEX IF NOT EXISTS idx_job_escrows_open ON job_escrows(job_id) WHERE closed_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_earning_holds_user_id ON earning_holds(user_id);
+CREATE INDEX IF NOT EXISTS idx_earning_holds_release_at ON earning_holds(release_at) WHERE status = 'held';
+CREATE INDEX IF NOT EXISTS idx_withdrawal_requests_user_id ON withdrawal_requests(user_id);
+CREATE INDEX IF NOT EXISTS idx_withdrawal_requests_status ON withdrawal_requests(status);
+CREATE INDEX IF NOT EXISTS idx_withdrawal_requests_batch_id ON withdrawal_requests(batch_id);
+CREATE INDEX IF NOT EXISTS idx_deposits_user_id ON deposits(user_id);
+CREATE INDEX IF NOT EXISTS idx_payment_webhook_events_due ON payment_webhook_events(next_attempt_at) WHERE status IN ('pending', 'processing');
+CREATE INDEX IF NOT EXISTS idx_payment_webhook_events_reference ON payment_webhook_events(provider, provider_reference);
+CREATE INDEX IF NOT EXISTS idx_wallet_reconciliation_runs_started_at ON wallet_reconciliation_runs(started_at);
+CREATE INDEX IF NOT EXISTS idx_wallet_discrepancies_run_id ON wallet_discrepancies(run_id);
+CREATE INDEX IF NOT EXISTS idx_wallet_discrepancies_user_id ON wallet_discrepancies(user_id);
+CREATE INDEX IF NOT EXISTS idx_wallet_transactions_wallet_created ON wallet_transactions(wallet_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_exchange_rates_currency_created ON exchange_rates(currency, created_at);
+CREATE INDEX IF NOT EXISTS idx_wallet_balances_user_id ON wallet_balances(user_id);
+CREATE INDEX IF NOT EXISTS idx_currency_conversions_user_id ON currency_conversions(user_id);
+CREATE INDEX IF NOT EXISTS idx_chat_money_transfers_chat_id ON chat_money_transfers(chat_id);
+CREATE INDEX IF NOT EXISTS idx_chat_money_transfers_status ON chat_money_transfers(status);
+CREATE INDEX IF NOT EXISTS idx_chat_money_transfers_expires_at ON chat_money_transfers(expires_at) WHERE status = 'requested';
+CREATE INDEX IF NOT EXISTS idx_admin_alerts_status_created ON admin_alerts(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_admin_alerts_user_type ON admin_alerts(user_id, alert_type) WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS idx_wallet_transactions_user_type_created ON wallet_transactions(user_id, type, created_at);
+CREATE INDEX IF NOT EXISTS idx_wallet_refunds_original ON wallet_refunds(original_transaction_id);
+CREATE INDEX IF NOT EXISTS idx_wallet_debts_outstanding ON wallet_debts(user_id, currency) WHERE status = 'outstanding';
+CREATE INDEX IF NOT EXISTS idx_work_proof_events_work_proof_id ON work_proof_events(work_proof_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_work_proof_disputes_status ON work_proof_disputes(status, priority, created_at);
+CREATE INDEX IF NOT EXISTS idx_work_proof_dispute_messages_dispute_id ON work_proof_dispute_messages(dispute_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_work_proofs_employer_status_submitted ON work_proofs(employer_id, status, submitted_at);
+CREATE INDEX IF NOT EXISTS idx_work_proof_policy_decisions_work_proof_id ON work_proof_policy_decisions(work_proof_id);
+CREATE INDEX IF NOT EXISTS idx_work_proof_fingerprints_sha256 ON work_proof_fingerprints(sha256);
+CREATE INDEX IF NOT EXISTS idx_work_proofs_unfingerprinted ON work_proofs(submitted_at) WHERE fingerprinted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_work_proofs_possible_duplicate ON work_proofs(submitted_at) WHERE possible_duplicate;
+CREATE INDEX IF NOT EXISTS idx_files_context ON files(context_type, context_id);
+CREATE INDEX IF NOT EXISTS idx_files_owner_id ON files(owner_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_files_created_at ON files(created_at);
+CREATE INDEX IF NOT EXISTS idx_file_images_processing ON file_images(updated_at) WHERE status = 'processing';
 `
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"microjob-backend/models"
+	"microjob-backend/money"
 )
 
 // User queries
@@ -67,12 +68,12 @@ func (db *DB) CreateUser(user *models.User) error {
 // Wallet queries
 func (db *DB) GetWalletByUserID(userID string) (*models.Wallet, error) {
 	query := `
-		SELECT id, user_id, balance, pending_balance, total_earned, total_spent, created_at, updated_at
+		SELECT id, user_id, balance, pending_balance, locked_balance, total_earned, total_spent, created_at, updated_at
 		FROM wallets WHERE user_id = $1`
 	
 	var wallet models.Wallet
 	err := db.QueryRow(query, userID).Scan(
-		&wallet.ID, &wallet.UserID, &wallet.Balance, &wallet.PendingBalance,
+		&wallet.ID, &wallet.UserID, &wallet.Balance, &wallet.PendingBalance, &wallet.LockedBalance,
 		&wallet.TotalEarned, &wallet.TotalSpent, &wallet.CreatedAt, &wallet.UpdatedAt,
 	)
 	
@@ -94,7 +95,7 @@ func (db *DB) CreateWallet(wallet *models.Wallet) error {
 		&wallet.CreatedAt, &wallet.UpdatedAt)
 }
 
-func (db *DB) UpdateWalletBalance(userID string, amount float64, transactionType string) error {
+func (db *DB) UpdateWalletBalance(userID string, amount money.Money, transactionType string) error {
 	return db.WithTransaction(func(tx *sql.Tx) error {
 		// Update wallet balance
 		_, err := tx.Exec(`
@@ -2,57 +2,33 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"microjob-backend/models"
+	"microjob-backend/money"
 	"microjob-backend/services"
 )
 
 type AdminHandler struct {
-	adminService *services.AdminService
+	adminService  *services.AdminService
+	walletService *services.WalletService
 }
 
-func NewAdminHandler(adminService *services.AdminService) *AdminHandler {
-	return &AdminHandler{adminService: adminService}
+func NewAdminHandler(adminService *services.AdminService, walletService *services.WalletService) *AdminHandler {
+	return &AdminHandler{
+		adminService:  adminService,
+		walletService: walletService,
+	}
 }
 
 // Platform Fee Settings
 func (ah *AdminHandler) GetPlatformFeeSettings(c *fiber.Ctx) error {
 	settings, err := ah.adminService.GetPlatformFeeSettings()
 	if err != nil {
-		// Return default settings on error
-		defaultSettings := &models.PlatformFeeSettings{
-			ID:         "default",
-			Enabled:    true,
-			Percentage: 5.0,
-			FixedFee:   0.0,
-			MinimumFee: 0.0,
-			MaximumFee: 0,
-			CreatedAt:  time.Now(),
-			UpdatedAt:  time.Now(),
-		}
-		
-		// Map to frontend format
-		response := map[string]interface{}{
-			"settings": map[string]interface{}{
-				"id":           defaultSettings.ID,
-				"enabled":      defaultSettings.Enabled,
-				"percentage":   defaultSettings.Percentage,
-				"fixed_fee":    defaultSettings.FixedFee,
-				"minimum_fee":  defaultSettings.MinimumFee,
-				"maximum_fee":  defaultSettings.MaximumFee,
-				"isActive":     defaultSettings.Enabled,
-				"feePercentage": defaultSettings.Percentage,
-				"feeFixed":     defaultSettings.FixedFee,
-				"minimumFee":   defaultSettings.MinimumFee,
-				"maximumFee":   defaultSettings.MaximumFee,
-				"created_at":   defaultSettings.CreatedAt,
-				"updated_at":   defaultSettings.UpdatedAt,
-			},
-		}
-		return c.JSON(response)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch platform fee settings"})
 	}
 
 	// Map to frontend format
@@ -92,30 +68,27 @@ func (ah *AdminHandler) UpdatePlatformFeeSettings(c *fiber.Ctx) error {
 	}
 	
 	if feePercentage, ok := body["feePercentage"].(float64); ok {
-		updateData.Percentage = feePercentage
+		updateData.Percentage = money.RateFromFloat(feePercentage)
 	} else {
-		updateData.Percentage = 5.0
+		updateData.Percentage = money.Rate(500)
 	}
 	
 	if feeFixed, ok := body["feeFixed"].(float64); ok {
-		updateData.FixedFee = feeFixed
-	} else {
-		updateData.FixedFee = 0.0
+		updateData.FixedFee = money.FromFloat(feeFixed, money.DefaultCurrency)
 	}
 	
 	if minimumFee, ok := body["minimumFee"].(float64); ok {
-		updateData.MinimumFee = minimumFee
-	} else {
-		updateData.MinimumFee = 0.0
+		updateData.MinimumFee = money.FromFloat(minimumFee, money.DefaultCurrency)
 	}
 	
 	if maximumFee, ok := body["maximumFee"].(float64); ok {
-		updateData.MaximumFee = maximumFee
-	} else {
-		updateData.MaximumFee = 0
+		updateData.MaximumFee = money.FromFloat(maximumFee, money.DefaultCurrency)
 	}
 
 	settings, err := ah.adminService.UpdatePlatformFeeSettings(updateData)
+	if errors.Is(err, services.ErrInvalidFeeSetting) {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update platform fee settings"})
 	}
@@ -233,22 +206,46 @@ func (ah *AdminHandler) UpdateCommissionSettings(c *fiber.Ctx) error {
 	}
 
 	if feePercentage, ok := body.Settings["feePercentage"].(float64); ok {
-		feeSetting.FeePercentage = feePercentage
+		feeSetting.FeePercentage = money.RateFromFloat(feePercentage)
 	}
 	if feeFixed, ok := body.Settings["feeFixed"].(float64); ok {
-		feeSetting.FeeFixed = feeFixed
+		feeSetting.FeeFixed = money.FromFloat(feeFixed, money.DefaultCurrency)
 	}
 	if minimumFee, ok := body.Settings["minimumFee"].(float64); ok {
-		feeSetting.MinimumFee = minimumFee
+		feeSetting.MinimumFee = money.FromFloat(minimumFee, money.DefaultCurrency)
 	}
 	if maximumFee, ok := body.Settings["maximumFee"].(float64); ok {
-		feeSetting.MaximumFee = &maximumFee
+		maximum := money.FromFloat(maximumFee, money.DefaultCurrency)
+		feeSetting.MaximumFee = &maximum
 	}
 	if isActive, ok := body.Settings["isActive"].(bool); ok {
 		feeSetting.IsActive = isActive
 	}
+	if chargedTo, ok := body.Settings["chargedTo"].(string); ok {
+		feeSetting.ChargedTo = chargedTo
+	}
+	if tiers, ok := body.Settings["tiers"].([]interface{}); ok {
+		for _, value := range tiers {
+			bracket, ok := value.(map[string]interface{})
+			if !ok {
+				return c.Status(400).JSON(fiber.Map{"error": "Invalid fee bracket"})
+			}
+			tier := models.FeeTier{}
+			if percentage, ok := bracket["percentage"].(float64); ok {
+				tier.Percentage = money.RateFromFloat(percentage)
+			}
+			if upTo, ok := bracket["upTo"].(float64); ok {
+				limit := money.FromFloat(upTo, money.DefaultCurrency)
+				tier.UpTo = &limit
+			}
+			feeSetting.Tiers = append(feeSetting.Tiers, tier)
+		}
+	}
 
 	data, err := ah.adminService.UpsertFeeSetting(feeSetting)
+	if errors.Is(err, services.ErrInvalidFeeSetting) {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update commission settings"})
 	}
@@ -378,6 +375,130 @@ func (ah *AdminHandler) UpdateReservationSettings(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"success": true})
 }
 
+// Earnings Hold Settings
+func (ah *AdminHandler) GetEarningsHoldSettings(c *fiber.Ctx) error {
+	settings, err := ah.adminService.GetEarningsHoldSettings()
+	if err != nil {
+		return c.JSON(fiber.Map{"holdPeriodHours": services.DefaultEarningsHoldHours})
+	}
+
+	return c.JSON(settings)
+}
+
+func (ah *AdminHandler) UpdateEarningsHoldSettings(c *fiber.Ctx) error {
+	// Check admin authorization
+	userType := c.Locals("userType").(string)
+	if userType != "admin" {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin access required"})
+	}
+
+	var settings map[string]interface{}
+	if err := c.BodyParser(&settings); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid settings data"})
+	}
+
+	hours, ok := settings["holdPeriodHours"].(float64)
+	if !ok || hours < 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "holdPeriodHours must be a non-negative number"})
+	}
+
+	settings["updated_at"] = time.Now().Format(time.RFC3339)
+
+	err := ah.adminService.UpdateEarningsHoldSettings(settings)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save settings"})
+	}
+
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// Withdrawal Settings
+func (ah *AdminHandler) GetWithdrawalSettings(c *fiber.Ctx) error {
+	settings, err := ah.adminService.GetWithdrawalSettings()
+	if err != nil {
+		return c.JSON(fiber.Map{"minimumAmount": services.DefaultMinimumWithdrawal})
+	}
+
+	return c.JSON(settings)
+}
+
+func (ah *AdminHandler) UpdateWithdrawalSettings(c *fiber.Ctx) error {
+	// Check admin authorization
+	userType := c.Locals("userType").(string)
+	if userType != "admin" {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin access required"})
+	}
+
+	var settings map[string]interface{}
+	if err := c.BodyParser(&settings); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid settings data"})
+	}
+
+	minimum, ok := settings["minimumAmount"].(float64)
+	if !ok || minimum <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "minimumAmount must be a positive number"})
+	}
+
+	settings["updated_at"] = time.Now().Format(time.RFC3339)
+
+	err := ah.adminService.UpdateWithdrawalSettings(settings)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save settings"})
+	}
+
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// Earning Holds
+func (ah *AdminHandler) GetEarningHolds(c *fiber.Ctx) error {
+	holds, err := ah.walletService.GetEarningHolds(c.Params("userId"), c.Query("status"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch earning holds"})
+	}
+
+	var totalHeld money.Money
+	for _, hold := range holds {
+		if hold.Status == "held" {
+			totalHeld = totalHeld.Add(hold.Amount)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"holds":     holds,
+		"totalHeld": totalHeld,
+	})
+}
+
+func (ah *AdminHandler) ReleaseEarningHolds(c *fiber.Ctx) error {
+	adminID := c.Locals("userID").(string)
+
+	var body struct {
+		HoldID string `json:"holdId"` // empty releases every hold of the user
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request data"})
+		}
+	}
+
+	released, amounts, err := ah.walletService.ForceReleaseEarnings(c.Params("userId"), body.HoldID, adminID)
+	switch {
+	case err == services.ErrHoldNotFound:
+		return c.Status(404).JSON(fiber.Map{"error": "No held earnings found"})
+	case err == services.ErrInsufficientBalance:
+		return c.Status(409).JSON(fiber.Map{"error": "Pending balance does not cover the held earnings"})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to release earnings"})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":  true,
+		"released": released,
+		"amount":   amounts[money.DefaultCurrency],
+		"amounts":  amounts, // by currency
+	})
+}
+
 // Support Pricing
 func (ah *AdminHandler) GetSupportPricing(c *fiber.Ctx) error {
 	pricing, err := ah.adminService.GetSupportPricing()
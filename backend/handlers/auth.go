@@ -104,10 +104,6 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		wallet := &models.Wallet{
 			ID:             uuid.New().String(),
 			UserID:         user.ID,
-			Balance:        0,
-			PendingBalance: 0,
-			TotalEarned:    0,
-			TotalSpent:     0,
 		}
 		h.db.CreateWallet(wallet)
 	}
@@ -187,10 +183,6 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	wallet := &models.Wallet{
 		ID:             uuid.New().String(),
 		UserID:         user.ID,
-		Balance:        0,
-		PendingBalance: 0,
-		TotalEarned:    0,
-		TotalSpent:     0,
 	}
 	h.db.CreateWallet(wallet)
 
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"microjob-backend/models"
+	"microjob-backend/money"
+	"microjob-backend/services"
+)
+
+type ChatHandler struct {
+	chatService *services.ChatService
+}
+
+func NewChatHandler(chatService *services.ChatService) *ChatHandler {
+	return &ChatHandler{chatService: chatService}
+}
+
+// Send Money offers money to the other party, who accepts or declines it
+func (ch *ChatHandler) SendMoneyTransfer(c *fiber.Ctx) error {
+	return ch.createTransfer(c, ch.chatService.SendTransfer)
+}
+
+// Request Money asks the other party to pay
+func (ch *ChatHandler) RequestMoneyTransfer(c *fiber.Ctx) error {
+	return ch.createTransfer(c, ch.chatService.RequestTransfer)
+}
+
+type createTransferFunc func(userID, counterpartyID, chatID string, amount money.Money, message string) (*models.ChatMoneyTransfer, error)
+
+func (ch *ChatHandler) createTransfer(c *fiber.Ctx, create createTransferFunc) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var body struct {
+		ChatID         string      `json:"chatId"`
+		CounterpartyID string      `json:"counterpartyId"`
+		Amount         money.Money `json:"amount"`
+		Currency       string      `json:"currency"`
+		Message        string      `json:"message"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	amount, err := amountIn(body.Amount, body.Currency)
+	if err != nil || body.ChatID == "" || body.CounterpartyID == "" || !amount.IsPositive() {
+		return c.Status(400).JSON(fiber.Map{"error": "Missing required fields"})
+	}
+
+	transfer, err := create(userID, body.CounterpartyID, body.ChatID, amount, body.Message)
+	if err != nil {
+		return transferError(c, err, "Failed to create money transfer")
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"success":  true,
+		"transfer": transfer,
+	})
+}
+
+// Accept Money Transfer
+func (ch *ChatHandler) AcceptMoneyTransfer(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	transfer, err := ch.chatService.AcceptTransfer(c.Params("id"), userID)
+	if err != nil {
+		return transferError(c, err, "Failed to accept money transfer")
+	}
+
+	return c.JSON(fiber.Map{"success": true, "transfer": transfer})
+}
+
+// Decline Money Transfer
+func (ch *ChatHandler) DeclineMoneyTransfer(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	c.BodyParser(&body)
+
+	transfer, err := ch.chatService.DeclineTransfer(c.Params("id"), userID, body.Reason)
+	if err != nil {
+		return transferError(c, err, "Failed to decline money transfer")
+	}
+
+	return c.JSON(fiber.Map{"success": true, "transfer": transfer})
+}
+
+// Cancel Money Transfer
+func (ch *ChatHandler) CancelMoneyTransfer(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	transfer, err := ch.chatService.CancelTransfer(c.Params("id"), userID)
+	if err != nil {
+		return transferError(c, err, "Failed to cancel money transfer")
+	}
+
+	return c.JSON(fiber.Map{"success": true, "transfer": transfer})
+}
+
+// Dispute Money Transfer
+func (ch *ChatHandler) DisputeMoneyTransfer(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Reason == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "A reason is required"})
+	}
+
+	transfer, err := ch.chatService.DisputeTransfer(c.Params("id"), userID, body.Reason)
+	if err != nil {
+		return transferError(c, err, "Failed to dispute money transfer")
+	}
+
+	return c.JSON(fiber.Map{"success": true, "transfer": transfer})
+}
+
+// Refund Money Transfer
+func (ch *ChatHandler) RefundMoneyTransfer(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	c.BodyParser(&body)
+
+	transfer, err := ch.chatService.RefundTransfer(c.Params("id"), userID, body.Reason)
+	if err != nil {
+		return transferError(c, err, "Failed to refund money transfer")
+	}
+
+	return c.JSON(fiber.Map{"success": true, "transfer": transfer})
+}
+
+// Get Chat Money Transfers
+func (ch *ChatHandler) GetMoneyTransfers(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	page, limit := pagination(c)
+	transfers, err := ch.chatService.GetChatTransfers(c.Params("chatId"), userID, limit, (page-1)*limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch money transfers"})
+	}
+
+	return c.JSON(fiber.Map{
+		"transfers": transfers,
+		"page":      page,
+		"limit":     limit,
+	})
+}
+
+// Get Money Transfer
+func (ch *ChatHandler) GetMoneyTransfer(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	transfer, err := ch.chatService.GetTransfer(c.Params("id"), userID)
+	if err != nil {
+		return transferError(c, err, "Failed to fetch money transfer")
+	}
+
+	return c.JSON(transfer)
+}
+
+// Admin Money Transfer Queue
+func (ch *ChatHandler) GetMoneyTransferQueue(c *fiber.Ctx) error {
+	page, limit := pagination(c)
+	transfers, total, err := ch.chatService.GetTransfers(c.Query("status", "disputed"), limit, (page-1)*limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch money transfers"})
+	}
+
+	return c.JSON(fiber.Map{
+		"transfers": transfers,
+		"total":     total,
+		"page":      page,
+		"limit":     limit,
+	})
+}
+
+// Admin Resolve Money Transfer Dispute
+func (ch *ChatHandler) ResolveMoneyTransferDispute(c *fiber.Ctx) error {
+	adminID := c.Locals("userID").(string)
+
+	var body struct {
+		Refund bool   `json:"refund"`
+		Note   string `json:"note"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request data"})
+	}
+
+	transfer, err := ch.chatService.ResolveDispute(c.Params("id"), adminID, body.Refund, body.Note)
+	if err != nil {
+		return transferError(c, err, "Failed to resolve dispute")
+	}
+
+	return c.JSON(fiber.Map{"success": true, "transfer": transfer})
+}
+
+func transferError(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case err == services.ErrTransferNotFound:
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	case err == services.ErrTransferNotParticipant, err == services.ErrTransferOwnRequest, err == services.ErrTransferNotInChat,
+		errors.Is(err, services.ErrVelocityLimitExceeded):
+		return c.Status(403).JSON(fiber.Map{"error": err.Error()})
+	case err == services.ErrTransferInvalidStatus, err == services.ErrTransferWindowClosed,
+		err == services.ErrTransferToSelf, errors.Is(err, services.ErrUnsupportedCurrency),
+		errors.Is(err, services.ErrFeeExceedsAmount):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case err == services.ErrInsufficientBalance:
+		return c.Status(400).JSON(fiber.Map{"error": "Insufficient balance"})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": message})
+}
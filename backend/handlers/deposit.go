@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"microjob-backend/money"
+	"microjob-backend/payments"
+	"microjob-backend/services"
+)
+
+type DepositHandler struct {
+	depositService *services.DepositService
+}
+
+func NewDepositHandler(depositService *services.DepositService) *DepositHandler {
+	return &DepositHandler{depositService: depositService}
+}
+
+func (dh *DepositHandler) GetGateways(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"gateways": dh.depositService.GetGateways()})
+}
+
+// Create Deposit
+func (dh *DepositHandler) CreateDeposit(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var body struct {
+		Gateway   string      `json:"gateway"`
+		Amount    money.Money `json:"amount"`
+		Currency  string      `json:"currency"`
+		ReturnURL string      `json:"returnUrl"`
+		CancelURL string      `json:"cancelUrl"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request data"})
+	}
+
+	amount, err := amountIn(body.Amount, body.Currency)
+	if err != nil || body.Gateway == "" || !amount.IsPositive() {
+		return c.Status(400).JSON(fiber.Map{"error": "Gateway and a positive amount are required"})
+	}
+
+	deposit, err := dh.depositService.CreateDeposit(c.UserContext(), userID, body.Gateway, amount,
+		body.ReturnURL, body.CancelURL)
+	if err == payments.ErrUnknownGateway {
+		return c.Status(400).JSON(fiber.Map{"error": "Unsupported payment gateway"})
+	}
+	if errors.Is(err, services.ErrUnsupportedCurrency) {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(502).JSON(fiber.Map{"error": "Failed to start payment"})
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"success":    true,
+		"deposit":    deposit,
+		"paymentUrl": deposit.PaymentURL,
+	})
+}
+
+// Get User Deposits
+func (dh *DepositHandler) GetDeposits(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	page, limit := pagination(c)
+	deposits, err := dh.depositService.GetUserDeposits(userID, limit, (page-1)*limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch deposits"})
+	}
+
+	return c.JSON(fiber.Map{
+		"deposits": deposits,
+		"page":     page,
+		"limit":    limit,
+	})
+}
+
+func (dh *DepositHandler) GetDeposit(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	deposit, err := dh.depositService.GetDeposit(c.Params("id"))
+	if err == services.ErrDepositNotFound {
+		return c.Status(404).JSON(fiber.Map{"error": "Deposit not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch deposit"})
+	}
+	if deposit.UserID != userID {
+		return c.Status(403).JSON(fiber.Map{"error": "Access denied"})
+	}
+
+	return c.JSON(deposit)
+}
+
+// Admin Deposit Refund
+func (dh *DepositHandler) RefundDeposit(c *fiber.Ctx) error {
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Reason == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Refund reason is required"})
+	}
+
+	deposit, err := dh.depositService.RefundDeposit(c.UserContext(), c.Params("id"), body.Reason)
+	switch {
+	case err == services.ErrDepositNotFound:
+		return c.Status(404).JSON(fiber.Map{"error": "Deposit not found"})
+	case err == services.ErrDepositNotRefundable:
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case err == services.ErrInsufficientBalance:
+		return c.Status(400).JSON(fiber.Map{"error": "User balance no longer covers the deposit"})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to refund deposit"})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"deposit": deposit,
+	})
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"microjob-backend/money"
+	"microjob-backend/services"
+)
+
+type DisputeHandler struct {
+	disputeService *services.DisputeService
+}
+
+func NewDisputeHandler(disputeService *services.DisputeService) *DisputeHandler {
+	return &DisputeHandler{disputeService: disputeService}
+}
+
+// Open Work Proof Dispute
+func (dh *DisputeHandler) OpenDispute(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var body struct {
+		Reason          string   `json:"reason"`
+		Description     string   `json:"description"`
+		RequestedAction string   `json:"requestedAction"`
+		Evidence        []string `json:"evidence"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Reason == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "A reason is required"})
+	}
+
+	dispute, err := dh.disputeService.OpenDispute(c.Params("id"), userID, body.Reason, body.Description,
+		body.RequestedAction, body.Evidence)
+	if err != nil {
+		return disputeError(c, err, "Failed to open dispute")
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"success": true,
+		"dispute": dispute,
+	})
+}
+
+// Get Dispute
+func (dh *DisputeHandler) GetDispute(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	dispute, err := dh.disputeService.GetDispute(c.Params("id"), userID, false)
+	if err != nil {
+		return disputeError(c, err, "Failed to fetch dispute")
+	}
+
+	return c.JSON(dispute)
+}
+
+// Add Dispute Message
+func (dh *DisputeHandler) AddMessage(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	return dh.addMessage(c, userID, "")
+}
+
+// Admin Add Dispute Message
+func (dh *DisputeHandler) AdminAddMessage(c *fiber.Ctx) error {
+	return dh.addMessage(c, c.Locals("userID").(string), services.WorkProofActorAdmin)
+}
+
+func (dh *DisputeHandler) addMessage(c *fiber.Ctx, senderID, role string) error {
+	var body struct {
+		Message  string   `json:"message"`
+		Evidence []string `json:"evidence"`
+	}
+	if err := c.BodyParser(&body); err != nil || (body.Message == "" && len(body.Evidence) == 0) {
+		return c.Status(400).JSON(fiber.Map{"error": "A message or evidence is required"})
+	}
+
+	msg, err := dh.disputeService.AddMessage(c.Params("id"), senderID, role, body.Message, body.Evidence)
+	if err != nil {
+		return disputeError(c, err, "Failed to add message")
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"success": true,
+		"message": msg,
+	})
+}
+
+// Admin Dispute Queue
+func (dh *DisputeHandler) GetDisputeQueue(c *fiber.Ctx) error {
+	page, limit := pagination(c)
+	disputes, total, err := dh.disputeService.GetDisputes(c.Query("status", "open"), c.Query("priority"), limit,
+		(page-1)*limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch disputes"})
+	}
+
+	return c.JSON(fiber.Map{
+		"disputes": disputes,
+		"total":    total,
+		"page":     page,
+		"limit":    limit,
+	})
+}
+
+// Admin Get Dispute
+func (dh *DisputeHandler) AdminGetDispute(c *fiber.Ctx) error {
+	dispute, err := dh.disputeService.GetDispute(c.Params("id"), "", true)
+	if err != nil {
+		return disputeError(c, err, "Failed to fetch dispute")
+	}
+
+	return c.JSON(dispute)
+}
+
+// Admin Take Dispute
+func (dh *DisputeHandler) TakeDispute(c *fiber.Ctx) error {
+	adminID := c.Locals("userID").(string)
+
+	dispute, err := dh.disputeService.TakeDispute(c.Params("id"), adminID)
+	if err != nil {
+		return disputeError(c, err, "Failed to take dispute")
+	}
+
+	return c.JSON(fiber.Map{"success": true, "dispute": dispute})
+}
+
+// Admin Escalate Dispute
+func (dh *DisputeHandler) EscalateDispute(c *fiber.Ctx) error {
+	adminID := c.Locals("userID").(string)
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Reason == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "A reason is required"})
+	}
+
+	dispute, err := dh.disputeService.EscalateDispute(c.Params("id"), adminID, body.Reason)
+	if err != nil {
+		return disputeError(c, err, "Failed to escalate dispute")
+	}
+
+	return c.JSON(fiber.Map{"success": true, "dispute": dispute})
+}
+
+// Admin Resolve Dispute
+func (dh *DisputeHandler) ResolveDispute(c *fiber.Ctx) error {
+	adminID := c.Locals("userID").(string)
+
+	var body struct {
+		Decision string      `json:"decision"`
+		Amount   money.Money `json:"amount"` // partial payments only; omitted to pay half
+		Notes    string      `json:"notes"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request data"})
+	}
+
+	dispute, err := dh.disputeService.ResolveDispute(c.Params("id"), adminID, body.Decision, body.Amount, body.Notes)
+	if err != nil {
+		return disputeError(c, err, "Failed to resolve dispute")
+	}
+
+	return c.JSON(fiber.Map{"success": true, "dispute": dispute})
+}
+
+func disputeError(c *fiber.Ctx, err error, message string) error {
+	if status, ok := workProofErrorStatus(err); ok {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	switch err {
+	case services.ErrDisputeNotFound:
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	case services.ErrDisputeClosed, services.ErrDisputeWindowClosed:
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	case services.ErrInvalidDisputeDecision, services.ErrInvalidPartialAmount:
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": message})
+}
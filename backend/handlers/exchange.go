@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"microjob-backend/money"
+	"microjob-backend/services"
+)
+
+type ExchangeHandler struct {
+	exchangeService *services.ExchangeService
+}
+
+func NewExchangeHandler(exchangeService *services.ExchangeService) *ExchangeHandler {
+	return &ExchangeHandler{exchangeService: exchangeService}
+}
+
+// amountIn puts an amount read from a JSON body, which is always parsed with
+// two decimal places, into currency. Amounts with more decimals than the
+// currency allows are rejected rather than rounded.
+func amountIn(amount money.Money, currency string) (money.Money, error) {
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+	converted := amount.WithCurrency(currency)
+	if converted.WithCurrency(amount.Currency) != amount {
+		return money.Money{}, money.ErrTooPrecise
+	}
+	return converted, nil
+}
+
+// Get Wallet Balances in every currency
+func (eh *ExchangeHandler) GetBalances(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	balances, err := eh.exchangeService.GetBalances(userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get balances"})
+	}
+
+	return c.JSON(fiber.Map{"balances": balances})
+}
+
+// Get Exchange Rates
+func (eh *ExchangeHandler) GetExchangeRates(c *fiber.Ctx) error {
+	rates, err := eh.exchangeService.GetRates()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get exchange rates"})
+	}
+
+	return c.JSON(fiber.Map{
+		"base":  money.DefaultCurrency,
+		"rates": rates,
+	})
+}
+
+// Quote Conversion
+func (eh *ExchangeHandler) QuoteConversion(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	from := strings.ToUpper(c.Query("from", money.DefaultCurrency))
+	amount, err := money.Parse(c.Query("amount"), from)
+	if err != nil || !amount.IsPositive() {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid amount"})
+	}
+
+	quote, err := eh.exchangeService.QuoteConversion(userID, amount, c.Query("to"))
+	if err == services.ErrConversionTooSmall {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error(), "quote": quote})
+	}
+	if err != nil {
+		return conversionError(c, err, "Failed to quote conversion")
+	}
+
+	return c.JSON(quote)
+}
+
+// Convert Currency
+func (eh *ExchangeHandler) Convert(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var body struct {
+		Amount money.Money `json:"amount"`
+		From   string      `json:"from"`
+		To     string      `json:"to"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request data"})
+	}
+
+	amount, err := amountIn(body.Amount, body.From)
+	if err != nil || !amount.IsPositive() || body.To == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "A positive amount and a target currency are required"})
+	}
+
+	conversion, err := eh.exchangeService.Convert(userID, amount, body.To)
+	if err == services.ErrInsufficientBalance {
+		return c.Status(400).JSON(fiber.Map{"error": "Insufficient balance"})
+	}
+	if err != nil {
+		return conversionError(c, err, "Failed to convert currency")
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"success":    true,
+		"conversion": conversion,
+	})
+}
+
+func conversionError(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, services.ErrUnsupportedCurrency),
+		err == services.ErrSameCurrency,
+		err == services.ErrConversionTooSmall:
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": message})
+}
+
+// Get Currency Conversions
+func (eh *ExchangeHandler) GetConversions(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	page, limit := pagination(c)
+	conversions, err := eh.exchangeService.GetConversions(userID, limit, (page-1)*limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get conversions"})
+	}
+
+	return c.JSON(fiber.Map{
+		"conversions": conversions,
+		"page":        page,
+		"limit":       limit,
+	})
+}
+
+// Admin Set Exchange Rate
+func (eh *ExchangeHandler) SetExchangeRate(c *fiber.Ctx) error {
+	adminID := c.Locals("userID").(string)
+
+	var body struct {
+		Currency string             `json:"currency"`
+		Rate     money.ExchangeRate `json:"rate"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request data"})
+	}
+
+	rate, err := eh.exchangeService.SetRate(body.Currency, body.Rate, adminID)
+	if errors.Is(err, services.ErrInvalidExchangeRate) || errors.Is(err, services.ErrUnsupportedCurrency) {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to set exchange rate"})
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"success": true,
+		"rate":    rate,
+	})
+}
+
+// Admin Exchange Rate History
+func (eh *ExchangeHandler) GetRateHistory(c *fiber.Ctx) error {
+	page, limit := pagination(c)
+	rates, err := eh.exchangeService.GetRateHistory(c.Params("currency"), limit, (page-1)*limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get exchange rate history"})
+	}
+
+	return c.JSON(fiber.Map{
+		"currency": c.Params("currency"),
+		"rates":    rates,
+		"page":     page,
+		"limit":    limit,
+	})
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"microjob-backend/models"
+	"microjob-backend/money"
+	"microjob-backend/services"
+)
+
+type FeeHandler struct {
+	fees *services.FeeEngine
+}
+
+func NewFeeHandler(fees *services.FeeEngine) *FeeHandler {
+	return &FeeHandler{fees: fees}
+}
+
+// Quote Fee shows the itemized fee before the user commits to a transaction
+func (fh *FeeHandler) QuoteFee(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	transactionType := c.Query("type")
+	if !services.IsFeeTransactionType(transactionType) {
+		return c.Status(400).JSON(fiber.Map{"error": "Unknown transaction type", "types": services.FeeTransactionTypes})
+	}
+
+	currency := strings.ToUpper(c.Query("currency", money.DefaultCurrency))
+	amount, err := money.Parse(c.Query("amount"), currency)
+	if err != nil || !amount.IsPositive() {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid amount"})
+	}
+
+	breakdown, err := fh.fees.Calculate(transactionType, amount, userID, c.Query("payeeId"))
+	if err == services.ErrFeeExceedsAmount {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error(), "fee": breakdown})
+	}
+	if errors.Is(err, services.ErrUnsupportedCurrency) {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to calculate fee"})
+	}
+
+	return c.JSON(breakdown)
+}
+
+// Simulate Fees replays recent transactions through proposed fee settings
+// without saving them
+func (fh *FeeHandler) SimulateFees(c *fiber.Ctx) error {
+	var body struct {
+		Days        int                      `json:"days"`
+		TopUsers    int                      `json:"topUsers"`
+		FeeSettings []models.AdminFeeSetting `json:"feeSettings"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if len(body.FeeSettings) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "No fee settings to simulate"})
+	}
+	if body.Days > 365 {
+		return c.Status(400).JSON(fiber.Map{"error": "Simulations cover at most 365 days"})
+	}
+	if body.TopUsers < 1 || body.TopUsers > 100 {
+		body.TopUsers = 10
+	}
+
+	simulation, err := fh.fees.Simulate(body.FeeSettings, body.Days, body.TopUsers)
+	if errors.Is(err, services.ErrInvalidFeeSetting) {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to simulate fees"})
+	}
+
+	return c.JSON(simulation)
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"microjob-backend/services"
+	"microjob-backend/storage"
+)
+
+type FileHandler struct {
+	fileService *services.FileService
+	store       storage.Storage
+}
+
+func NewFileHandler(fileService *services.FileService, store storage.Storage) *FileHandler {
+	return &FileHandler{fileService: fileService, store: store}
+}
+
+// Upload File
+func (fh *FileHandler) UploadFile(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "A file is required"})
+	}
+	content, err := header.Open()
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Failed to read uploaded file"})
+	}
+	defer content.Close()
+
+	file, err := fh.fileService.Upload(c.UserContext(), userID, c.FormValue("context"), c.FormValue("contextId"),
+		header.Filename, content, header.Size)
+	if err != nil {
+		return fileError(c, err, "Failed to upload file")
+	}
+
+	return c.Status(201).JSON(fiber.Map{"file": file})
+}
+
+// Get File
+func (fh *FileHandler) GetFile(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	file, err := fh.fileService.GetFile(c.Params("id"), userID, false)
+	if err != nil {
+		return fileError(c, err, "Failed to fetch file")
+	}
+
+	return c.JSON(file)
+}
+
+// Get File Download URL
+func (fh *FileHandler) GetDownloadURL(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	return fh.downloadURL(c, userID, false)
+}
+
+// Admin Get File Download URL
+func (fh *FileHandler) AdminGetDownloadURL(c *fiber.Ctx) error {
+	return fh.downloadURL(c, "", true)
+}
+
+func (fh *FileHandler) downloadURL(c *fiber.Ctx, userID string, isAdmin bool) error {
+	url, expiresAt, err := fh.fileService.SignedURL(c.UserContext(), c.Params("id"), userID, isAdmin)
+	if err != nil {
+		return fileError(c, err, "Failed to create download URL")
+	}
+
+	return c.JSON(fiber.Map{
+		"url":       url,
+		"expiresAt": expiresAt,
+	})
+}
+
+// Serve File Content
+//
+// Signed download URLs of the local storage backend point here. The
+// signature stands in for authentication, as browsers follow these links
+// without the API token.
+func (fh *FileHandler) ServeContent(c *fiber.Ctx) error {
+	local, ok := fh.store.(*storage.LocalStorage)
+	if !ok {
+		return c.Status(404).JSON(fiber.Map{"error": "Not found"})
+	}
+
+	key := c.Params("*")
+	err := local.Verify(key, c.Query("expires"), c.Query("signature"), time.Now())
+	if errors.Is(err, storage.ErrURLExpired) {
+		return c.Status(410).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(403).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	file, err := fh.fileService.GetFileByKey(key)
+	if err != nil {
+		return fileError(c, err, "Failed to fetch file")
+	}
+	content, err := local.Open(c.UserContext(), key)
+	if err != nil {
+		return fileError(c, err, "Failed to read file")
+	}
+
+	disposition := "attachment"
+	if strings.HasPrefix(file.ContentType, "image/") {
+		disposition = "inline"
+	}
+	c.Set(fiber.HeaderContentType, file.ContentType)
+	c.Set(fiber.HeaderContentDisposition, mime.FormatMediaType(disposition, map[string]string{"filename": file.OriginalName}))
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	c.Set(fiber.HeaderCacheControl, "private, max-age=300")
+	return c.SendStream(content, int(file.Size))
+}
+
+func fileError(c *fiber.Ctx, err error, message string) error {
+	switch err {
+	case services.ErrFileNotFound, storage.ErrNotFound:
+		return c.Status(404).JSON(fiber.Map{"error": "File not found"})
+	case services.ErrFileForbidden:
+		return c.Status(403).JSON(fiber.Map{"error": err.Error()})
+	case services.ErrFileTooLarge:
+		return c.Status(413).JSON(fiber.Map{"error": err.Error()})
+	case services.ErrFileEmpty, services.ErrUnsupportedFileType, services.ErrInvalidFileContext:
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": message})
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"database/sql"
+
+	"github.com/gofiber/fiber/v2"
+	"microjob-backend/services"
+)
+
+type FingerprintHandler struct {
+	fingerprintService *services.FingerprintService
+	workProofService   *services.WorkProofService
+}
+
+func NewFingerprintHandler(fingerprintService *services.FingerprintService, workProofService *services.WorkProofService) *FingerprintHandler {
+	return &FingerprintHandler{
+		fingerprintService: fingerprintService,
+		workProofService:   workProofService,
+	}
+}
+
+// Get Work Proof Duplicates
+func (fh *FingerprintHandler) GetWorkProofDuplicates(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	proof, err := fh.workProofService.GetWorkProofByID(c.Params("id"))
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(fiber.Map{"error": "Work proof not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch work proof"})
+	}
+	// The matches point at other workers' proofs, so only the employer sees them
+	if proof.EmployerID != userID {
+		return c.Status(403).JSON(fiber.Map{"error": services.ErrWorkProofForbidden.Error()})
+	}
+
+	return fh.duplicates(c, proof.ID, proof.PossibleDuplicate)
+}
+
+// Admin Get Work Proof Duplicates
+func (fh *FingerprintHandler) AdminGetWorkProofDuplicates(c *fiber.Ctx) error {
+	proof, err := fh.workProofService.GetWorkProofByID(c.Params("id"))
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(fiber.Map{"error": "Work proof not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch work proof"})
+	}
+
+	return fh.duplicates(c, proof.ID, proof.PossibleDuplicate)
+}
+
+func (fh *FingerprintHandler) duplicates(c *fiber.Ctx, proofID string, possibleDuplicate bool) error {
+	duplicates, err := fh.fingerprintService.GetDuplicates(proofID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch duplicate matches"})
+	}
+
+	return c.JSON(fiber.Map{
+		"possibleDuplicate": possibleDuplicate,
+		"duplicates":        duplicates,
+	})
+}
+
+// Admin Get Flagged Work Proofs
+func (fh *FingerprintHandler) GetFlaggedWorkProofs(c *fiber.Ctx) error {
+	page, limit := pagination(c)
+	duplicates, total, err := fh.fingerprintService.GetFlaggedProofs(limit, (page-1)*limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch flagged work proofs"})
+	}
+
+	return c.JSON(fiber.Map{
+		"duplicates": duplicates,
+		"total":      total,
+		"page":       page,
+		"limit":      limit,
+	})
+}
@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"log"
 	"strconv"
 	"time"
 
@@ -10,6 +13,7 @@ import (
 	"microjob-backend/config"
 	"microjob-backend/database"
 	"microjob-backend/models"
+	"microjob-backend/money"
 	"microjob-backend/services"
 )
 
@@ -21,17 +25,21 @@ type JobHandler struct {
 	walletService      *services.WalletService
 	workProofService   *services.WorkProofService
 	reservationService *services.ReservationService
+	imageService       *services.ImageService
+	jobPricingService  *services.JobPricingService
 }
 
-func NewJobHandler(db *database.DB, cfg *config.Config, cacheService *services.CacheService) *JobHandler {
+func NewJobHandler(db *database.DB, cfg *config.Config, cacheService *services.CacheService, imageService *services.ImageService) *JobHandler {
 	return &JobHandler{
 		db:                 db,
 		cfg:                cfg,
 		cacheService:       cacheService,
 		jobService:         services.NewJobService(db),
 		walletService:      services.NewWalletService(db),
-		workProofService:   services.NewWorkProofService(db),
+		workProofService:   services.NewWorkProofService(db.DB, services.NewAdminService(db.DB)),
 		reservationService: services.NewReservationService(db),
+		imageService:       imageService,
+		jobPricingService:  services.NewJobPricingService(db.DB),
 	}
 }
 
@@ -319,17 +327,21 @@ func (jh *JobHandler) CreateJob(c *fiber.Ctx) error {
 	}
 
 	var jobData struct {
-		Title                   string  `json:"title"`
-		Description             string  `json:"description"`
-		CategoryID              string  `json:"categoryId"`
-		SubcategoryID           *string `json:"subcategoryId"`
-		BudgetMin               float64 `json:"budgetMin"`
-		BudgetMax               float64 `json:"budgetMax"`
-		Deadline                *string `json:"deadline"`
-		ApprovalType            string  `json:"approvalType"`
-		InstantApprovalEnabled  bool    `json:"instantApprovalEnabled"`
-		ManualApprovalDays      int     `json:"manualApprovalDays"`
-		RequiredWorkers         int     `json:"requiredWorkers"`
+		Title                  string      `json:"title"`
+		Description            string      `json:"description"`
+		CategoryID             string      `json:"categoryId"`
+		SubcategoryID          *string     `json:"subcategoryId"`
+		BudgetMin              money.Money `json:"budgetMin"`
+		BudgetMax              money.Money `json:"budgetMax"`
+		Currency               string      `json:"currency"`
+		Deadline               *string     `json:"deadline"`
+		ApprovalType           string      `json:"approvalType"`
+		InstantApprovalEnabled bool        `json:"instantApprovalEnabled"`
+		ManualApprovalDays     int         `json:"manualApprovalDays"`
+		RequiredWorkers        int         `json:"requiredWorkers"`
+		RequiredScreenshots    int         `json:"requiredScreenshots"`
+		Boosts                 []string    `json:"boosts"`
+		TotalCost              money.Money `json:"totalCost"` // as shown to the employer
 	}
 
 	if err := c.BodyParser(&jobData); err != nil {
@@ -340,6 +352,30 @@ func (jh *JobHandler) CreateJob(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Missing required fields"})
 	}
 
+	budgetMin, err := amountIn(jobData.BudgetMin, jobData.Currency)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid minimum budget"})
+	}
+	budgetMax, err := amountIn(jobData.BudgetMax, jobData.Currency)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid maximum budget"})
+	}
+	totalCost, err := amountIn(jobData.TotalCost, jobData.Currency)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid total cost"})
+	}
+
+	// The employer pays what the server quotes; a total worked out by the
+	// client from stale or altered prices is turned away with the quote
+	quote, err := jh.jobPricingService.Quote(userID, budgetMax, jobData.RequiredWorkers,
+		jobData.RequiredScreenshots, jobData.Boosts)
+	if err != nil {
+		return jobPricingError(c, err, "Failed to price job")
+	}
+	if err := services.CheckJobTotal(quote, totalCost); err != nil {
+		return c.Status(409).JSON(fiber.Map{"error": err.Error(), "quote": quote})
+	}
+
 	job := &models.Job{
 		ID:                     uuid.New().String(),
 		UserID:                 userID,
@@ -347,13 +383,16 @@ func (jh *JobHandler) CreateJob(c *fiber.Ctx) error {
 		Description:            jobData.Description,
 		CategoryID:             jobData.CategoryID,
 		SubcategoryID:          jobData.SubcategoryID,
-		BudgetMin:              jobData.BudgetMin,
-		BudgetMax:              jobData.BudgetMax,
+		BudgetMin:              budgetMin,
+		BudgetMax:              budgetMax,
+		Currency:               budgetMax.Currency,
 		Status:                 "open",
 		ApprovalType:           jobData.ApprovalType,
 		InstantApprovalEnabled: jobData.InstantApprovalEnabled,
 		ManualApprovalDays:     jobData.ManualApprovalDays,
-		RequiredWorkers:        jobData.RequiredWorkers,
+		RequiredWorkers:        quote.RequiredWorkers,
+		RequiredScreenshots:    quote.RequiredScreenshots,
+		PriceQuote:             quote,
 		CreatedAt:              time.Now(),
 		UpdatedAt:              time.Now(),
 	}
@@ -364,7 +403,13 @@ func (jh *JobHandler) CreateJob(c *fiber.Ctx) error {
 		}
 	}
 
-	err := jh.jobService.CreateJob(job)
+	err = jh.jobService.CreateJob(job)
+	if err == services.ErrInsufficientBalance {
+		return c.Status(400).JSON(fiber.Map{"error": "Insufficient balance to fund the job budget"})
+	}
+	if errors.Is(err, services.ErrUnsupportedCurrency) {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to create job"})
 	}
@@ -375,6 +420,113 @@ func (jh *JobHandler) CreateJob(c *fiber.Ctx) error {
 	})
 }
 
+// Cancel Job
+func (jh *JobHandler) CancelJob(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	jobID := c.Params("id")
+	if jobID == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Job ID required"})
+	}
+
+	refunded, err := jh.jobService.CancelJob(jobID, userID)
+	switch {
+	case err == sql.ErrNoRows:
+		return c.Status(404).JSON(fiber.Map{"error": "Job not found"})
+	case err == services.ErrNotJobOwner:
+		return c.Status(403).JSON(fiber.Map{"error": err.Error()})
+	case err == services.ErrJobNotCancellable, err == services.ErrJobHasPendingReviews:
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to cancel job"})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":        true,
+		"refundedAmount": refunded,
+	})
+}
+
+// Get Job Escrow
+func (jh *JobHandler) GetJobEscrow(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	escrow, err := jh.jobService.GetJobEscrow(c.Params("id"))
+	if err == services.ErrNoEscrow {
+		return c.Status(404).JSON(fiber.Map{"error": "Job has no escrow"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch escrow"})
+	}
+	if escrow.EmployerID != userID {
+		return c.Status(403).JSON(fiber.Map{"error": "Access denied"})
+	}
+
+	return c.JSON(fiber.Map{
+		"escrow":    escrow,
+		"remaining": escrow.Remaining(),
+	})
+}
+
+// Get Job Approval Policy
+func (jh *JobHandler) GetApprovalPolicy(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	job, err := jh.jobService.GetJobByID(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Job not found"})
+	}
+	if job.UserID != userID {
+		return c.Status(403).JSON(fiber.Map{"error": "Access denied"})
+	}
+
+	policy, err := jh.workProofService.GetApprovalPolicy(job.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch approval policy"})
+	}
+
+	return c.JSON(policy)
+}
+
+// Update Job Approval Policy
+func (jh *JobHandler) UpdateApprovalPolicy(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var policy models.JobApprovalPolicy
+	if err := c.BodyParser(&policy); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	err := jh.workProofService.SetApprovalPolicy(c.Params("id"), userID, &policy)
+	switch {
+	case err == services.ErrInvalidApprovalPolicy:
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case err == services.ErrNotJobOwner:
+		return c.Status(403).JSON(fiber.Map{"error": err.Error()})
+	case err == sql.ErrNoRows:
+		return c.Status(404).JSON(fiber.Map{"error": "Job not found"})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save approval policy"})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"policy":  policy,
+	})
+}
+
 // Apply to Job
 func (jh *JobHandler) ApplyToJob(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(string)
@@ -388,9 +540,9 @@ func (jh *JobHandler) ApplyToJob(c *fiber.Ctx) error {
 	}
 
 	var applicationData struct {
-		CoverLetter           string `json:"coverLetter"`
-		ProposedBudget        float64 `json:"proposedBudget"`
-		EstimatedCompletion   string `json:"estimatedCompletion"`
+		CoverLetter         string       `json:"coverLetter"`
+		ProposedBudget      *money.Money `json:"proposedBudget"`
+		EstimatedCompletion string       `json:"estimatedCompletion"`
 	}
 
 	if err := c.BodyParser(&applicationData); err != nil {
@@ -476,16 +628,15 @@ func (jh *JobHandler) SubmitWorkProof(c *fiber.Ctx) error {
 	}
 
 	var workProofData struct {
-		JobID            string   `json:"jobId"`
-		ApplicationID    string   `json:"applicationId"`
-		Title            string   `json:"title"`
-		Description      string   `json:"description"`
-		SubmissionText   string   `json:"submissionText"`
-		ProofFiles       []string `json:"proofFiles"`
-		ProofLinks       []string `json:"proofLinks"`
-		Screenshots      []string `json:"screenshots"`
-		Attachments      []string `json:"attachments"`
-		PaymentAmount    float64  `json:"paymentAmount"`
+		JobID          string   `json:"jobId"`
+		ApplicationID  string   `json:"applicationId"`
+		Title          string   `json:"title"`
+		Description    string   `json:"description"`
+		SubmissionText string   `json:"submissionText"`
+		ProofFiles     []string `json:"proofFiles"`
+		ProofLinks     []string `json:"proofLinks"`
+		Screenshots    []string `json:"screenshots"`
+		Attachments    []string `json:"attachments"`
 	}
 
 	if err := c.BodyParser(&workProofData); err != nil {
@@ -513,36 +664,26 @@ func (jh *JobHandler) SubmitWorkProof(c *fiber.Ctx) error {
 		Attachments:      workProofData.Attachments,
 		Status:           "submitted",
 		SubmittedAt:      time.Now(),
-		PaymentAmount:    workProofData.PaymentAmount,
 		SubmissionNumber: 1,
 		CreatedAt:        time.Now(),
 		UpdatedAt:        time.Now(),
 	}
 
-	// Check for instant approval
-	if job.ApprovalType == "instant" && job.InstantApprovalEnabled {
-		workProof.Status = "auto_approved"
-		workProof.ReviewedAt = &workProof.SubmittedAt
-		
-		// Process instant payment
-		err = jh.walletService.ProcessPayment(
-			job.UserID,
-			userID,
-			workProofData.PaymentAmount,
-			"Instant payment for: "+workProofData.Title,
-			workProof.ID,
-			"work_proof_payment",
-		)
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to process instant payment"})
-		}
+	// The payment is set from the job's budget, and the job's approval policy
+	// may approve and pay the proof as part of the submission
+	err = jh.workProofService.CreateWorkProof(workProof, jh.walletService)
+	if status, ok := workProofErrorStatus(err); ok {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
 	}
-
-	err = jh.workProofService.CreateWorkProof(workProof)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to submit work proof"})
 	}
 
+	// Screenshots are processed in the background, so they show as pending
+	if err := jh.imageService.AttachWorkProofImages(c.UserContext(), workProof); err != nil {
+		log.Printf("Failed to load images of work proof %s: %v", workProof.ID, err)
+	}
+
 	return c.Status(201).JSON(fiber.Map{
 		"success":   true,
 		"workProof": workProof,
@@ -557,9 +698,9 @@ func (jh *JobHandler) ApproveWorkProof(c *fiber.Ctx) error {
 	}
 
 	var body struct {
-		ProofID     string  `json:"proofId"`
-		ReviewNotes string  `json:"reviewNotes"`
-		TipAmount   float64 `json:"tipAmount"`
+		ProofID     string      `json:"proofId"`
+		ReviewNotes string      `json:"reviewNotes"`
+		TipAmount   money.Money `json:"tipAmount"`
 	}
 
 	if err := c.BodyParser(&body); err != nil {
@@ -570,7 +711,10 @@ func (jh *JobHandler) ApproveWorkProof(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Missing proof ID"})
 	}
 
-	err := jh.workProofService.ApproveWorkProof(body.ProofID, body.ReviewNotes, jh.walletService)
+	err := jh.workProofService.ApproveWorkProof(body.ProofID, userID, body.ReviewNotes, jh.walletService)
+	if status, ok := workProofErrorStatus(err); ok {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error":   "Failed to approve work proof",
@@ -583,9 +727,10 @@ func (jh *JobHandler) ApproveWorkProof(c *fiber.Ctx) error {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch approved proof"})
 	}
 
-	message := "Work approved! Payment of $" + strconv.FormatFloat(approvedProof.PaymentAmount, 'f', 2, 64) + " released to worker."
+	message := "Work approved! Payment of " + approvedProof.PaymentAmount.String() + " " +
+		approvedProof.PaymentAmount.Currency + " released to worker."
 	
-	if body.TipAmount > 0 {
+	if body.TipAmount.IsPositive() {
 		err = jh.walletService.ProcessPayment(
 			userID,
 			approvedProof.WorkerID,
@@ -600,7 +745,7 @@ func (jh *JobHandler) ApproveWorkProof(c *fiber.Ctx) error {
 				"proof": approvedProof,
 			})
 		}
-		message += " + $" + strconv.FormatFloat(body.TipAmount, 'f', 2, 64) + " tip"
+		message += " + " + body.TipAmount.String() + " " + body.TipAmount.Currency + " tip"
 	}
 
 	return c.JSON(fiber.Map{
@@ -612,6 +757,11 @@ func (jh *JobHandler) ApproveWorkProof(c *fiber.Ctx) error {
 
 // Reject Work Proof
 func (jh *JobHandler) RejectWorkProof(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
 	var body struct {
 		ProofID         string `json:"proofId"`
 		RejectionReason string `json:"rejectionReason"`
@@ -626,7 +776,10 @@ func (jh *JobHandler) RejectWorkProof(c *fiber.Ctx) error {
 		body.TimeoutHours = 24
 	}
 
-	err := jh.workProofService.RejectWorkProof(body.ProofID, body.RejectionReason, body.TimeoutHours)
+	err := jh.workProofService.RejectWorkProof(body.ProofID, userID, body.RejectionReason, body.TimeoutHours)
+	if status, ok := workProofErrorStatus(err); ok {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to reject work proof"})
 	}
@@ -639,6 +792,11 @@ func (jh *JobHandler) RejectWorkProof(c *fiber.Ctx) error {
 
 // Request Revision
 func (jh *JobHandler) RequestRevision(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
 	var body struct {
 		ProofID       string `json:"proofId"`
 		RevisionNotes string `json:"revisionNotes"`
@@ -653,7 +811,10 @@ func (jh *JobHandler) RequestRevision(c *fiber.Ctx) error {
 		body.TimeoutHours = 24
 	}
 
-	err := jh.workProofService.RequestRevision(body.ProofID, body.RevisionNotes, body.TimeoutHours)
+	err := jh.workProofService.RequestRevision(body.ProofID, userID, body.RevisionNotes, body.TimeoutHours)
+	if status, ok := workProofErrorStatus(err); ok {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -664,18 +825,257 @@ func (jh *JobHandler) RequestRevision(c *fiber.Ctx) error {
 	})
 }
 
+// Bulk Review Work Proofs
+func (jh *JobHandler) BulkReviewWorkProofs(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var body struct {
+		Action       string   `json:"action"` // "approve", "reject", "request_revision"
+		ProofIDs     []string `json:"proofIds"`
+		Notes        string   `json:"notes"`
+		TimeoutHours int      `json:"timeoutHours"`
+		Filter       *struct {
+			JobID          string `json:"jobId"`
+			OlderThanHours int    `json:"olderThanHours"`
+		} `json:"filter"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if (len(body.ProofIDs) == 0) == (body.Filter == nil) {
+		return c.Status(400).JSON(fiber.Map{"error": "Provide either proofIds or a filter"})
+	}
+
+	if body.TimeoutHours <= 0 {
+		body.TimeoutHours = 24
+	}
+
+	proofIDs := body.ProofIDs
+	more := false
+	if body.Filter != nil {
+		filter := services.WorkProofReviewFilter{JobID: body.Filter.JobID}
+		if body.Filter.OlderThanHours > 0 {
+			before := time.Now().Add(-time.Duration(body.Filter.OlderThanHours) * time.Hour)
+			filter.SubmittedBefore = &before
+		}
+
+		var err error
+		proofIDs, more, err = jh.workProofService.FindReviewableProofs(userID, filter)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to find work proofs"})
+		}
+	}
+
+	results, err := jh.workProofService.BulkReview(userID, body.Action, proofIDs, body.Notes, body.TimeoutHours,
+		jh.walletService)
+	if err == services.ErrInvalidReviewAction || err == services.ErrTooManyProofs {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to review work proofs"})
+	}
+
+	succeeded := 0
+	for _, result := range results {
+		if result.Success {
+			succeeded++
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"results":   results,
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+		"more":      more,
+	})
+}
+
+// Resubmit Work Proof
+func (jh *JobHandler) ResubmitWorkProof(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var body struct {
+		Description    string   `json:"description"`
+		SubmissionText string   `json:"submissionText"`
+		ProofFiles     []string `json:"proofFiles"`
+		ProofLinks     []string `json:"proofLinks"`
+		Screenshots    []string `json:"screenshots"`
+		Attachments    []string `json:"attachments"`
+	}
+
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if body.Description == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Description is required"})
+	}
+
+	submission := &models.WorkProofSubmission{
+		Description:    body.Description,
+		SubmissionText: body.SubmissionText,
+		ProofFiles:     body.ProofFiles,
+		ProofLinks:     body.ProofLinks,
+		Screenshots:    body.Screenshots,
+		Attachments:    body.Attachments,
+	}
+
+	err := jh.workProofService.ResubmitWorkProof(c.Params("id"), userID, submission)
+	if status, ok := workProofErrorStatus(err); ok {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to resubmit work proof"})
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"success":    true,
+		"submission": submission,
+	})
+}
+
+// Get Work Proof Submissions
+func (jh *JobHandler) GetWorkProofSubmissions(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	proof, err := jh.workProofService.GetWorkProofByID(c.Params("id"))
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(fiber.Map{"error": "Work proof not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch work proof"})
+	}
+	if proof.WorkerID != userID && proof.EmployerID != userID {
+		return c.Status(403).JSON(fiber.Map{"error": services.ErrWorkProofForbidden.Error()})
+	}
+
+	submissions, err := jh.workProofService.GetWorkProofSubmissions(proof.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch work proof submissions"})
+	}
+
+	return c.JSON(fiber.Map{"submissions": submissions})
+}
+
+// Accept Work Proof Rejection
+func (jh *JobHandler) AcceptWorkProofRejection(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	c.BodyParser(&body)
+
+	err := jh.workProofService.AcceptRejection(c.Params("id"), userID, body.Reason)
+	if status, ok := workProofErrorStatus(err); ok {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to accept rejection"})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Rejection accepted",
+	})
+}
+
+// Cancel Work Proof Revision
+func (jh *JobHandler) CancelWorkProofRevision(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	c.BodyParser(&body)
+
+	err := jh.workProofService.CancelRevision(c.Params("id"), userID, body.Reason)
+	if status, ok := workProofErrorStatus(err); ok {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to cancel revision"})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Revision cancelled",
+	})
+}
+
+// Get Work Proof Events
+func (jh *JobHandler) GetWorkProofEvents(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	proof, err := jh.workProofService.GetWorkProofByID(c.Params("id"))
+	if err == sql.ErrNoRows {
+		return c.Status(404).JSON(fiber.Map{"error": "Work proof not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch work proof"})
+	}
+	if proof.WorkerID != userID && proof.EmployerID != userID {
+		return c.Status(403).JSON(fiber.Map{"error": services.ErrWorkProofForbidden.Error()})
+	}
+
+	events, err := jh.workProofService.GetWorkProofEvents(proof.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch work proof history"})
+	}
+
+	return c.JSON(fiber.Map{"events": events})
+}
+
 // Get Work Proofs for Job
 func (jh *JobHandler) GetWorkProofs(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
 	jobID := c.Params("id")
 	if jobID == "" {
 		return c.Status(400).JSON(fiber.Map{"error": "Job ID required"})
 	}
 
+	job, err := jh.jobService.GetJobByID(jobID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Job not found"})
+	}
+	if job.UserID != userID {
+		return c.Status(403).JSON(fiber.Map{"error": services.ErrNotJobOwner.Error()})
+	}
+
 	workProofs, err := jh.workProofService.GetWorkProofsByJobID(jobID)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch work proofs"})
 	}
 
+	for i := range workProofs {
+		if err := jh.imageService.AttachWorkProofImages(c.UserContext(), &workProofs[i]); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch work proof images"})
+		}
+	}
+
 	return c.JSON(fiber.Map{"workProofs": workProofs})
 }
 
@@ -747,3 +1147,23 @@ func (jh *JobHandler) CleanupReservations(c *fiber.Ctx) error {
 		"cleaned": count,
 	})
 }
+
+// workProofErrorStatus maps the work proof state machine's errors to an HTTP
+// status. Illegal transitions are conflicts with the proof's current state.
+func workProofErrorStatus(err error) (int, bool) {
+	var transitionErr *services.WorkProofTransitionError
+	switch {
+	case errors.As(err, &transitionErr):
+		return 409, true
+	case err == services.ErrWorkProofNotFound:
+		return 404, true
+	case err == services.ErrWorkProofForbidden:
+		return 403, true
+	case err == services.ErrRevisionLimitReached:
+		return 400, true
+	case err == services.ErrJobNotAcceptingWork, err == services.ErrJobFull, err == services.ErrAlreadySubmittedWork,
+		err == services.ErrRefundedEscrowUnpaid:
+		return 409, true
+	}
+	return 0, false
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"microjob-backend/models"
+	"microjob-backend/money"
+	"microjob-backend/services"
+)
+
+type JobPricingHandler struct {
+	jobPricingService *services.JobPricingService
+}
+
+func NewJobPricingHandler(jobPricingService *services.JobPricingService) *JobPricingHandler {
+	return &JobPricingHandler{jobPricingService: jobPricingService}
+}
+
+// Get Job Pricing lists the screenshot tiers and boost options employers can
+// choose from when posting a job
+func (jph *JobPricingHandler) GetJobPricing(c *fiber.Ctx) error {
+	settings, err := jph.jobPricingService.GetSettings()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get job pricing"})
+	}
+
+	return c.JSON(settings)
+}
+
+// Quote Job prices a job before it is posted. Create requests must send back
+// the quoted total.
+func (jph *JobPricingHandler) QuoteJob(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var body struct {
+		BudgetMax           money.Money `json:"budgetMax"`
+		Currency            string      `json:"currency"`
+		RequiredWorkers     int         `json:"requiredWorkers"`
+		RequiredScreenshots int         `json:"requiredScreenshots"`
+		Boosts              []string    `json:"boosts"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	reward, err := amountIn(body.BudgetMax, body.Currency)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid maximum budget"})
+	}
+
+	quote, err := jph.jobPricingService.Quote(userID, reward, body.RequiredWorkers, body.RequiredScreenshots, body.Boosts)
+	if err != nil {
+		return jobPricingError(c, err, "Failed to price job")
+	}
+
+	return c.JSON(quote)
+}
+
+// Admin Update Job Pricing
+func (jph *JobPricingHandler) UpdateJobPricing(c *fiber.Ctx) error {
+	var settings models.JobPricingSettings
+	if err := c.BodyParser(&settings); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid settings data"})
+	}
+
+	err := jph.jobPricingService.UpdateSettings(&settings)
+	if err != nil {
+		return jobPricingError(c, err, "Failed to save job pricing")
+	}
+
+	return c.JSON(fiber.Map{
+		"success":  true,
+		"settings": settings,
+	})
+}
+
+func jobPricingError(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case err == services.ErrInvalidJobPrice, err == services.ErrFeeExceedsAmount,
+		errors.Is(err, services.ErrTooManyScreenshots), errors.Is(err, services.ErrUnknownJobBoost),
+		errors.Is(err, services.ErrInvalidJobPricing), errors.Is(err, services.ErrUnsupportedCurrency):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": message})
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"microjob-backend/services"
+)
+
+type ReconciliationHandler struct {
+	reconciliationService *services.ReconciliationService
+}
+
+func NewReconciliationHandler(reconciliationService *services.ReconciliationService) *ReconciliationHandler {
+	return &ReconciliationHandler{reconciliationService: reconciliationService}
+}
+
+// Run Wallet Reconciliation
+func (rh *ReconciliationHandler) RunReconciliation(c *fiber.Ctx) error {
+	adminID := c.Locals("userID").(string)
+
+	var body struct {
+		UserID string `json:"userId"` // optional, checks a single wallet
+	}
+	c.BodyParser(&body)
+
+	run, err := rh.reconciliationService.Reconcile(body.UserID, adminID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to reconcile wallets", "run": run})
+	}
+
+	return c.Status(201).JSON(run)
+}
+
+func (rh *ReconciliationHandler) GetReconciliationRuns(c *fiber.Ctx) error {
+	page, limit := pagination(c)
+	runs, err := rh.reconciliationService.GetRuns(limit, (page-1)*limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reconciliation runs"})
+	}
+
+	return c.JSON(fiber.Map{
+		"runs":  runs,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+func (rh *ReconciliationHandler) GetReconciliationRun(c *fiber.Ctx) error {
+	run, err := rh.reconciliationService.GetRun(c.Params("id"))
+	if err == services.ErrReconciliationRunNotFound {
+		return c.Status(404).JSON(fiber.Map{"error": "Reconciliation run not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reconciliation run"})
+	}
+
+	return c.JSON(run)
+}
+
+// Correct Discrepancies writes correcting entries once an admin has reviewed
+// the run and confirmed them.
+func (rh *ReconciliationHandler) CorrectDiscrepancies(c *fiber.Ctx) error {
+	adminID := c.Locals("userID").(string)
+
+	var body struct {
+		DiscrepancyIDs []string `json:"discrepancyIds"` // all open discrepancies when empty
+		Confirm        bool     `json:"confirm"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request data"})
+	}
+	if !body.Confirm {
+		return c.Status(400).JSON(fiber.Map{"error": "Set confirm to true to write correcting entries"})
+	}
+
+	corrected, err := rh.reconciliationService.CorrectDiscrepancies(c.Params("id"), body.DiscrepancyIDs, adminID)
+	switch {
+	case err == services.ErrReconciliationRunNotFound:
+		return c.Status(404).JSON(fiber.Map{"error": "Reconciliation run not found"})
+	case errors.Is(err, services.ErrInsufficientBalance):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error(), "discrepancies": corrected})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to correct discrepancies", "discrepancies": corrected})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":       true,
+		"discrepancies": corrected,
+	})
+}
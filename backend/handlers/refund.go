@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"microjob-backend/money"
+	"microjob-backend/services"
+)
+
+type RefundHandler struct {
+	refundService *services.RefundService
+}
+
+func NewRefundHandler(refundService *services.RefundService) *RefundHandler {
+	return &RefundHandler{refundService: refundService}
+}
+
+// Admin Refund Transaction
+func (rh *RefundHandler) RefundTransaction(c *fiber.Ctx) error {
+	adminID := c.Locals("userID").(string)
+
+	var body struct {
+		Amount   money.Money `json:"amount"` // omitted to refund everything not yet refunded
+		Currency string      `json:"currency"`
+		Reason   string      `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Reason == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "A reason is required"})
+	}
+
+	amount := body.Amount
+	if !amount.IsZero() {
+		var err error
+		amount, err = amountIn(body.Amount, body.Currency)
+		if err != nil || !amount.IsPositive() {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid amount"})
+		}
+	}
+
+	refund, err := rh.refundService.RefundTransaction(c.Params("id"), adminID, amount, body.Reason)
+	switch {
+	case err == services.ErrTransactionNotFound:
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	case err == services.ErrRefundNotAllowed, err == services.ErrRefundExceedsOriginal,
+		err == services.ErrRefundNoPayer, err == services.ErrTransferInvalidStatus,
+		errors.Is(err, services.ErrRefundCurrencyMismatch):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to refund transaction"})
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"success": true,
+		"refund":  refund,
+	})
+}
+
+// Admin Get Transaction Refunds
+func (rh *RefundHandler) GetTransactionRefunds(c *fiber.Ctx) error {
+	refunds, err := rh.refundService.GetRefunds(c.Params("id"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get refunds"})
+	}
+
+	return c.JSON(fiber.Map{"refunds": refunds})
+}
+
+// Admin Get Debts
+func (rh *RefundHandler) GetDebts(c *fiber.Ctx) error {
+	page, limit := pagination(c)
+	debts, err := rh.refundService.GetDebts(c.Query("userId"), c.Query("status", "outstanding"), limit, (page-1)*limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get debts"})
+	}
+
+	return c.JSON(fiber.Map{
+		"debts": debts,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// Get Wallet Debts
+func (rh *RefundHandler) GetWalletDebts(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	page, limit := pagination(c)
+	debts, err := rh.refundService.GetDebts(userID, c.Query("status"), limit, (page-1)*limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get debts"})
+	}
+
+	return c.JSON(fiber.Map{
+		"debts": debts,
+		"page":  page,
+		"limit": limit,
+	})
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"bufio"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"microjob-backend/services"
+	"microjob-backend/statements"
+)
+
+type StatementHandler struct {
+	walletService *services.WalletService
+}
+
+func NewStatementHandler(walletService *services.WalletService) *StatementHandler {
+	return &StatementHandler{walletService: walletService}
+}
+
+// Get Wallet Statement
+func (sh *StatementHandler) GetStatement(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	return sh.writeStatement(c, userID)
+}
+
+// Admin User Statement
+func (sh *StatementHandler) GetUserStatement(c *fiber.Ctx) error {
+	return sh.writeStatement(c, c.Params("userId"))
+}
+
+// writeStatement streams the statement as it is generated. Errors after the
+// first bytes can no longer change the response status, so they are logged.
+func (sh *StatementHandler) writeStatement(c *fiber.Ctx, userID string) error {
+	filter, err := statementFilter(c, userID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	format := c.Query("format", "csv")
+	newWriter := statements.NewCSVWriter
+	contentType := "text/csv"
+	switch format {
+	case "csv":
+	case "pdf":
+		newWriter = statements.NewPDFWriter
+		contentType = "application/pdf"
+	default:
+		return c.Status(400).JSON(fiber.Map{"error": "Format must be csv or pdf"})
+	}
+
+	filename := "statement-" + filter.From.Format("2006-01-02") + "-" + filter.To.AddDate(0, 0, -1).Format("2006-01-02") + "." + format
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		err := sh.walletService.WriteStatement(filter, newWriter(w))
+		if err != nil {
+			log.Printf("Failed to write statement for user %s: %v", userID, err)
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+// statementFilter reads either month=YYYY-MM or an inclusive from/to date
+// range, defaulting to the current month.
+func statementFilter(c *fiber.Ctx, userID string) (services.StatementFilter, error) {
+	filter := services.StatementFilter{
+		UserID:   userID,
+		Currency: c.Query("currency"),
+		Status:   c.Query("status"),
+	}
+	if types := c.Query("type"); types != "" {
+		filter.Types = strings.Split(types, ",")
+	}
+
+	now := time.Now().UTC()
+	filter.From = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if month := c.Query("month"); month != "" {
+		start, err := time.Parse("2006-01", month)
+		if err != nil {
+			return filter, errors.New("Month must be in YYYY-MM format")
+		}
+		filter.From = start
+	}
+	filter.To = filter.From.AddDate(0, 1, 0)
+
+	if from := c.Query("from"); from != "" {
+		start, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			return filter, errors.New("From must be in YYYY-MM-DD format")
+		}
+		filter.From = start
+	}
+	if to := c.Query("to"); to != "" {
+		end, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return filter, errors.New("To must be in YYYY-MM-DD format")
+		}
+		filter.To = end.AddDate(0, 0, 1)
+	}
+
+	if !filter.From.Before(filter.To) {
+		return filter, errors.New("From must be before to")
+	}
+	return filter, nil
+}
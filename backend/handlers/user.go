@@ -7,6 +7,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"microjob-backend/models"
+	"microjob-backend/money"
 	"microjob-backend/services"
 )
 
@@ -191,7 +192,7 @@ func (uh *UserHandler) GenerateReferralCode(c *fiber.Ctx) error {
 	})
 }
 
-// Chat Money Transfer
+// Chat Money Transfer offers money that the receiver accepts or declines
 func (uh *UserHandler) SendMoneyTransfer(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(string)
 	if userID == "" {
@@ -199,23 +200,23 @@ func (uh *UserHandler) SendMoneyTransfer(c *fiber.Ctx) error {
 	}
 
 	var body struct {
-		ChatID     string  `json:"chatId"`
-		ReceiverID string  `json:"receiverId"`
-		Amount     float64 `json:"amount"`
-		Message    string  `json:"message"`
+		ChatID     string      `json:"chatId"`
+		ReceiverID string      `json:"receiverId"`
+		Amount     money.Money `json:"amount"`
+		Message    string      `json:"message"`
 	}
 
 	if err := c.BodyParser(&body); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	if body.ChatID == "" || body.ReceiverID == "" || body.Amount <= 0 {
+	if body.ChatID == "" || body.ReceiverID == "" || !body.Amount.IsPositive() {
 		return c.Status(400).JSON(fiber.Map{"error": "Missing required fields"})
 	}
 
-	transfer, err := uh.chatService.ProcessMoneyTransfer(userID, body.ReceiverID, body.ChatID, body.Amount, body.Message)
+	transfer, err := uh.chatService.SendTransfer(userID, body.ReceiverID, body.ChatID, body.Amount, body.Message)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return transferError(c, err, "Failed to send money transfer")
 	}
 
 	return c.JSON(fiber.Map{
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"microjob-backend/models"
+	"microjob-backend/services"
+)
+
+type VelocityHandler struct {
+	velocityService *services.VelocityService
+	alertService    *services.AlertService
+}
+
+func NewVelocityHandler(velocityService *services.VelocityService, alertService *services.AlertService) *VelocityHandler {
+	return &VelocityHandler{
+		velocityService: velocityService,
+		alertService:    alertService,
+	}
+}
+
+// Admin Get Velocity Limits
+func (vh *VelocityHandler) GetVelocityLimits(c *fiber.Ctx) error {
+	settings, err := vh.velocityService.GetSettings()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get velocity limits"})
+	}
+
+	return c.JSON(settings)
+}
+
+// Admin Update Velocity Limits
+func (vh *VelocityHandler) UpdateVelocityLimits(c *fiber.Ctx) error {
+	var settings models.VelocitySettings
+	if err := c.BodyParser(&settings); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid settings data"})
+	}
+
+	err := vh.velocityService.UpdateSettings(&settings)
+	if err == services.ErrInvalidVelocityLimits {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save velocity limits"})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":  true,
+		"settings": settings,
+	})
+}
+
+// Admin Get Alerts
+func (vh *VelocityHandler) GetAlerts(c *fiber.Ctx) error {
+	page, limit := pagination(c)
+	alerts, total, err := vh.alertService.GetAlerts(c.Query("status", "open"), limit, (page-1)*limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get alerts"})
+	}
+
+	return c.JSON(fiber.Map{
+		"alerts": alerts,
+		"total":  total,
+		"page":   page,
+		"limit":  limit,
+	})
+}
+
+// Admin Acknowledge Alert
+func (vh *VelocityHandler) AcknowledgeAlert(c *fiber.Ctx) error {
+	adminID := c.Locals("userID").(string)
+
+	err := vh.alertService.Acknowledge(c.Params("id"), adminID)
+	if err == services.ErrAlertNotFound {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to acknowledge alert"})
+	}
+
+	return c.JSON(fiber.Map{"success": true})
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"microjob-backend/payments"
+	"microjob-backend/services"
+)
+
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+	fakeGateway    *payments.FakeGateway // nil unless the fake gateway is enabled
+}
+
+func NewWebhookHandler(webhookService *services.WebhookService, fakeGateway *payments.FakeGateway) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+		fakeGateway:    fakeGateway,
+	}
+}
+
+// Payment Webhook
+func (wh *WebhookHandler) HandleWebhook(c *fiber.Ctx) error {
+	payload := append([]byte(nil), c.Body()...)
+	headers := http.Header(c.GetReqHeaders())
+
+	return wh.receive(c, c.Params("provider"), payload, headers)
+}
+
+// Fake Checkout pays or fails a fake gateway checkout by sending its webhook
+// through the same path a real provider uses.
+func (wh *WebhookHandler) CompleteFakeCheckout(c *fiber.Ctx) error {
+	if wh.fakeGateway == nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Fake gateway is disabled"})
+	}
+
+	var body struct {
+		Outcome string `json:"outcome"` // "succeeded" (default) or "failed"
+	}
+	c.BodyParser(&body)
+
+	eventType := payments.EventPaymentSucceeded
+	if body.Outcome == "failed" {
+		eventType = payments.EventPaymentFailed
+	}
+
+	payload, headers, err := wh.fakeGateway.Simulate(c.Params("reference"), eventType)
+	if err == payments.ErrUnknownCheckout {
+		return c.Status(404).JSON(fiber.Map{"error": "Checkout not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to simulate payment"})
+	}
+
+	return wh.receive(c, wh.fakeGateway.Name(), payload, headers)
+}
+
+// receive stores the event and acknowledges it straight away; it is applied to
+// the deposit in the background.
+func (wh *WebhookHandler) receive(c *fiber.Ctx, provider string, payload []byte, headers http.Header) error {
+	event, err := wh.webhookService.Receive(provider, payload, headers)
+	switch {
+	case err == services.ErrWebhookDuplicate:
+		return c.JSON(fiber.Map{"received": true, "duplicate": true})
+	case err == services.ErrWebhookOutOfOrder:
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	case err == payments.ErrUnknownGateway:
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	case err == payments.ErrInvalidSignature, err == payments.ErrSignatureExpired:
+		return c.Status(401).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		// The provider redelivers anything we fail to store
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to receive webhook"})
+	}
+
+	go func() {
+		if _, err := wh.webhookService.ProcessDueEvents(); err != nil {
+			log.Printf("Failed to process webhook events: %v", err)
+		}
+	}()
+
+	return c.JSON(fiber.Map{"received": true, "eventId": event.ID})
+}
+
+// Admin Webhook Events
+func (wh *WebhookHandler) GetWebhookEvents(c *fiber.Ctx) error {
+	page, limit := pagination(c)
+	events, err := wh.webhookService.GetEvents(c.Query("status"), limit, (page-1)*limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch webhook events"})
+	}
+
+	return c.JSON(fiber.Map{
+		"events": events,
+		"page":   page,
+		"limit":  limit,
+	})
+}
+
+func (wh *WebhookHandler) RetryWebhookEvent(c *fiber.Ctx) error {
+	err := wh.webhookService.RetryEvent(c.Params("id"))
+	switch {
+	case err == services.ErrWebhookEventNotFound:
+		return c.Status(404).JSON(fiber.Map{"error": "Webhook event not found"})
+	case err == services.ErrWebhookEventNotRetried:
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to retry webhook event"})
+	}
+
+	return c.JSON(fiber.Map{"success": true})
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"microjob-backend/money"
+	"microjob-backend/services"
+)
+
+type WithdrawalHandler struct {
+	withdrawalService *services.WithdrawalService
+}
+
+func NewWithdrawalHandler(withdrawalService *services.WithdrawalService) *WithdrawalHandler {
+	return &WithdrawalHandler{withdrawalService: withdrawalService}
+}
+
+// Quote Withdrawal
+func (wh *WithdrawalHandler) QuoteWithdrawal(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	currency := strings.ToUpper(c.Query("currency", money.DefaultCurrency))
+	amount, err := money.Parse(c.Query("amount"), currency)
+	if err != nil || !amount.IsPositive() {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid amount"})
+	}
+
+	quote, err := wh.withdrawalService.QuoteWithdrawal(userID, amount)
+	if err == services.ErrWithdrawalBelowMinimum || err == services.ErrWithdrawalFeeTooHigh {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error(), "quote": quote})
+	}
+	if errors.Is(err, services.ErrUnsupportedCurrency) {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to quote withdrawal"})
+	}
+
+	return c.JSON(quote)
+}
+
+// Request Withdrawal
+func (wh *WithdrawalHandler) RequestWithdrawal(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var body struct {
+		Amount        money.Money `json:"amount"`
+		Currency      string      `json:"currency"`
+		PayoutMethod  string      `json:"payoutMethod"`
+		PayoutDetails string      `json:"payoutDetails"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request data"})
+	}
+
+	amount, err := amountIn(body.Amount, body.Currency)
+	if err != nil || !amount.IsPositive() || body.PayoutMethod == "" || body.PayoutDetails == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Amount, payout method and payout details are required"})
+	}
+
+	withdrawal, err := wh.withdrawalService.RequestWithdrawal(userID, amount, body.PayoutMethod, body.PayoutDetails)
+	switch {
+	case err == services.ErrWithdrawalBelowMinimum, err == services.ErrWithdrawalFeeTooHigh,
+		err == services.ErrOutstandingDebt, errors.Is(err, services.ErrUnsupportedCurrency):
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	case err == services.ErrInsufficientBalance:
+		return c.Status(400).JSON(fiber.Map{"error": "Insufficient balance"})
+	case errors.Is(err, services.ErrVelocityLimitExceeded):
+		return c.Status(403).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to request withdrawal"})
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"success":    true,
+		"withdrawal": withdrawal,
+	})
+}
+
+// Get User Withdrawals
+func (wh *WithdrawalHandler) GetWithdrawals(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+	if userID == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	page, limit := pagination(c)
+	withdrawals, err := wh.withdrawalService.GetUserWithdrawals(userID, limit, (page-1)*limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch withdrawals"})
+	}
+
+	return c.JSON(fiber.Map{
+		"withdrawals": withdrawals,
+		"page":        page,
+		"limit":       limit,
+	})
+}
+
+// Admin Withdrawal Queue
+func (wh *WithdrawalHandler) GetWithdrawalQueue(c *fiber.Ctx) error {
+	page, limit := pagination(c)
+	withdrawals, total, err := wh.withdrawalService.GetWithdrawals(c.Query("status", "pending"), limit, (page-1)*limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch withdrawals"})
+	}
+
+	return c.JSON(fiber.Map{
+		"withdrawals": withdrawals,
+		"total":       total,
+		"page":        page,
+		"limit":       limit,
+	})
+}
+
+func (wh *WithdrawalHandler) ApproveWithdrawal(c *fiber.Ctx) error {
+	adminID := c.Locals("userID").(string)
+
+	err := wh.withdrawalService.ApproveWithdrawal(c.Params("id"), adminID)
+	if err != nil {
+		return withdrawalError(c, err, "Failed to approve withdrawal")
+	}
+
+	return c.JSON(fiber.Map{"success": true})
+}
+
+func (wh *WithdrawalHandler) RejectWithdrawal(c *fiber.Ctx) error {
+	adminID := c.Locals("userID").(string)
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Reason == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Rejection reason is required"})
+	}
+
+	err := wh.withdrawalService.RejectWithdrawal(c.Params("id"), adminID, body.Reason)
+	if err != nil {
+		return withdrawalError(c, err, "Failed to reject withdrawal")
+	}
+
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// Payout Batches
+func (wh *WithdrawalHandler) CreatePayoutBatch(c *fiber.Ctx) error {
+	adminID := c.Locals("userID").(string)
+
+	batch, err := wh.withdrawalService.CreatePayoutBatch(adminID, c.Query("currency"))
+	if err == services.ErrNoApprovedWithdrawals {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create payout batch"})
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"success": true,
+		"batch":   batch,
+	})
+}
+
+func (wh *WithdrawalHandler) GetPayoutBatches(c *fiber.Ctx) error {
+	page, limit := pagination(c)
+	batches, err := wh.withdrawalService.GetPayoutBatches(limit, (page-1)*limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch payout batches"})
+	}
+
+	return c.JSON(fiber.Map{
+		"batches": batches,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+func (wh *WithdrawalHandler) ExportPayoutBatch(c *fiber.Ctx) error {
+	batchID := c.Params("id")
+
+	var buf bytes.Buffer
+	err := wh.withdrawalService.ExportPayoutBatchCSV(batchID, &buf)
+	if err == services.ErrPayoutBatchNotFound {
+		return c.Status(404).JSON(fiber.Map{"error": "Payout batch not found"})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to export payout batch"})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="payout-batch-`+batchID+`.csv"`)
+	return c.Send(buf.Bytes())
+}
+
+func (wh *WithdrawalHandler) RecordPayoutResults(c *fiber.Ctx) error {
+	var body struct {
+		Results []services.PayoutResult `json:"results"`
+	}
+	if err := c.BodyParser(&body); err != nil || len(body.Results) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Payout results are required"})
+	}
+
+	paid, failed, err := wh.withdrawalService.RecordPayoutResults(c.Params("id"), body.Results)
+	if err == services.ErrPayoutBatchNotFound {
+		return c.Status(404).JSON(fiber.Map{"error": "Payout batch not found"})
+	}
+	if err == services.ErrPayoutBatchCompleted || errors.Is(err, services.ErrWithdrawalNotFound) ||
+		errors.Is(err, services.ErrWithdrawalInvalidStatus) {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to record payout results"})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"paid":      paid,
+		"failed":    failed,
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
+
+func withdrawalError(c *fiber.Ctx, err error, message string) error {
+	switch err {
+	case services.ErrWithdrawalNotFound:
+		return c.Status(404).JSON(fiber.Map{"error": "Withdrawal not found"})
+	case services.ErrWithdrawalInvalidStatus:
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(500).JSON(fiber.Map{"error": message})
+}
+
+func pagination(c *fiber.Ctx) (page, limit int) {
+	page, _ = strconv.Atoi(c.Query("page", "1"))
+	limit, _ = strconv.Atoi(c.Query("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	return page, limit
+}
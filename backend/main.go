@@ -18,9 +18,11 @@ import (
 	"microjob-backend/database"
 	"microjob-backend/cache"
 	"microjob-backend/middleware"
+	"microjob-backend/payments"
 	"microjob-backend/routes"
 	"microjob-backend/cron"
 	"microjob-backend/services"
+	"microjob-backend/storage"
 )
 
 func main() {
@@ -43,13 +45,37 @@ func main() {
 		log.Fatal("Failed to connect to Redis:", err)
 	}
 
+	store, err := storage.NewFromConfig(cfg)
+	if err != nil {
+		log.Fatal("Failed to set up file storage:", err)
+	}
+
 	reservationService := services.NewReservationService(db)
-	workProofService := services.NewWorkProofService(db)
 	walletService := services.NewWalletService(db)
 	adminService := services.NewAdminService(db)
+	workProofService := services.NewWorkProofService(db.DB, adminService)
+	idempotencyService := services.NewIdempotencyService(db.DB)
+	escrowService := services.NewEscrowService(db.DB)
+	// Payment gateways available for deposits. The routes share them, so the
+	// fake gateway's checkouts are seen by the webhook and cron paths too.
+	gateways, fakeGateway, err := payments.NewRegistryFromConfig(cfg)
+	if err != nil {
+		log.Fatal("Failed to set up payment gateways:", err)
+	}
+	depositService := services.NewDepositService(db.DB, walletService, gateways)
+	webhookService := services.NewWebhookService(db.DB, gateways, depositService)
+	reconciliationService := services.NewReconciliationService(db.DB)
+	velocityService := services.NewVelocityService(db.DB, redisClient)
+	chatService := services.NewChatService(db.DB, walletService, adminService, velocityService)
+	refundService := services.NewRefundService(db.DB)
 	cacheService := services.NewCacheService(redisClient)
+	fileService := services.NewFileService(db.DB, store)
+	fingerprintService := services.NewFingerprintService(db.DB, fileService, cfg.ProofFileHostList())
+	imageService := services.NewImageService(db.DB, fileService)
 
-	cronScheduler := cron.NewCronScheduler(reservationService, workProofService, walletService, adminService)
+	cronScheduler := cron.NewCronScheduler(reservationService, workProofService, walletService, adminService, idempotencyService, escrowService, webhookService,
+		reconciliationService, chatService, refundService, fingerprintService,
+		imageService)
 	cronScheduler.Start()
 
 	// Create Fiber app
@@ -64,11 +90,11 @@ func main() {
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     cfg.AllowedOrigins,
 		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders:     "Origin,Content-Type,Accept,Authorization",
+		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,Idempotency-Key",
 		AllowCredentials: true,
 	}))
 
-	routes.Setup(app, db, cfg, redisClient, cacheService)
+	routes.Setup(app, db, cfg, redisClient, cacheService, store, gateways, fakeGateway)
 
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
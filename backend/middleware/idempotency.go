@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+
+	"microjob-backend/models"
+	"microjob-backend/services"
+)
+
+// Idempotency makes an endpoint safe to retry. When the client sends an
+// Idempotency-Key header the first request runs normally and its response is
+// stored; a retry with the same key and body gets that response back instead
+// of running the handler again. Server errors release the key.
+func Idempotency(idempotencyService *services.IdempotencyService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get("Idempotency-Key")
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > 255 {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Idempotency-Key must be at most 255 characters",
+			})
+		}
+
+		user := c.Locals("user").(*models.AuthUser)
+
+		requestHash := services.IdempotencyRequestHash(c.Method(), c.Path(), c.Body())
+
+		record, claimed, err := idempotencyService.Begin(user.ID, key, c.Method(), c.Path(), requestHash)
+		switch {
+		case errors.Is(err, services.ErrIdempotencyKeyReused):
+			return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, services.ErrIdempotencyInProgress):
+			return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+		case err != nil:
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to check idempotency key"})
+		}
+
+		if !claimed {
+			c.Set("Idempotent-Replayed", "true")
+			c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			body := ""
+			if record.ResponseBody != nil {
+				body = *record.ResponseBody
+			}
+			return c.Status(*record.ResponseStatus).SendString(body)
+		}
+
+		if err := c.Next(); err != nil {
+			idempotencyService.Release(record.ID)
+			return err
+		}
+
+		status := c.Response().StatusCode()
+		if status >= 500 {
+			idempotencyService.Release(record.ID)
+			return nil
+		}
+
+		if err := idempotencyService.Complete(record.ID, status, c.Response().Body()); err != nil {
+			log.Printf("Failed to store response for idempotency key %s: %v", key, err)
+		}
+		return nil
+	}
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"microjob-backend/money"
 )
 
 type AdminSettings struct {
@@ -13,53 +15,63 @@ type AdminSettings struct {
 }
 
 type PlatformFeeSettings struct {
-	ID         string    `json:"id" db:"id"`
-	Enabled    bool      `json:"enabled" db:"enabled"`
-	Percentage float64   `json:"percentage" db:"percentage"`
-	FixedFee   float64   `json:"fixed_fee" db:"fixed_fee"`
-	MinimumFee float64   `json:"minimum_fee" db:"minimum_fee"`
-	MaximumFee float64   `json:"maximum_fee" db:"maximum_fee"`
-	CreatedAt  time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+	ID         string      `json:"id" db:"id"`
+	Enabled    bool        `json:"enabled" db:"enabled"`
+	Percentage money.Rate  `json:"percentage" db:"percentage"`
+	FixedFee   money.Money `json:"fixed_fee" db:"fixed_fee"`
+	MinimumFee money.Money `json:"minimum_fee" db:"minimum_fee"`
+	MaximumFee money.Money `json:"maximum_fee" db:"maximum_fee"`
+	CreatedAt  time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+type AdminFeeSetting struct {
+	ID            string       `json:"id" db:"id"`
+	FeeType       string       `json:"fee_type" db:"fee_type"`
+	FeePercentage money.Rate   `json:"fee_percentage" db:"fee_percentage"`
+	FeeFixed      money.Money  `json:"fee_fixed" db:"fee_fixed"`
+	MinimumFee    money.Money  `json:"minimum_fee" db:"minimum_fee"`
+	MaximumFee    *money.Money `json:"maximum_fee" db:"maximum_fee"`
+	Tiers         []FeeTier    `json:"tiers" db:"tiers"`
+	ChargedTo     string       `json:"charged_to" db:"charged_to"` // "payer" or "payee"
+	IsActive      bool         `json:"is_active" db:"is_active"`
+	CreatedAt     time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at" db:"updated_at"`
 }
 
-type AdminFeeSettings struct {
-	ID            string    `json:"id" db:"id"`
-	FeeType       string    `json:"fee_type" db:"fee_type"`
-	FeePercentage float64   `json:"fee_percentage" db:"fee_percentage"`
-	FeeFixed      float64   `json:"fee_fixed" db:"fee_fixed"`
-	MinimumFee    float64   `json:"minimum_fee" db:"minimum_fee"`
-	MaximumFee    *float64  `json:"maximum_fee" db:"maximum_fee"`
-	IsActive      bool      `json:"is_active" db:"is_active"`
-	CreatedAt     time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+// FeeTier is one bracket of a tiered fee. Its percentage applies to the part of
+// the amount between the previous bracket's limit and UpTo; the last bracket
+// has no limit.
+type FeeTier struct {
+	UpTo       *money.Money `json:"up_to"`
+	Percentage money.Rate   `json:"percentage"`
 }
 
 type SupportPricingSettings struct {
-	ID                string    `json:"id" db:"id"`
-	SupportType       string    `json:"support_type" db:"support_type"`
-	Price             float64   `json:"price" db:"price"`
-	ResponseTimeHours int       `json:"response_time_hours" db:"response_time_hours"`
-	Description       *string   `json:"description" db:"description"`
-	IsActive          bool      `json:"is_active" db:"is_active"`
-	CreatedAt         time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
+	ID                string      `json:"id" db:"id"`
+	SupportType       string      `json:"support_type" db:"support_type"`
+	Price             money.Money `json:"price" db:"price"`
+	ResponseTimeHours int         `json:"response_time_hours" db:"response_time_hours"`
+	Description       *string     `json:"description" db:"description"`
+	IsActive          bool        `json:"is_active" db:"is_active"`
+	CreatedAt         time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time   `json:"updated_at" db:"updated_at"`
 }
 
 type SupportTicket struct {
-	ID                   string     `json:"id" db:"id"`
-	UserID               string     `json:"user_id" db:"user_id"`
-	ChatID               *string    `json:"chat_id" db:"chat_id"`
-	TicketType           string     `json:"ticket_type" db:"ticket_type"`
-	Subject              string     `json:"subject" db:"subject"`
-	Description          string     `json:"description" db:"description"`
-	Priority             string     `json:"priority" db:"priority"`
-	Status               string     `json:"status" db:"status"`
-	PaymentAmount        float64    `json:"payment_amount" db:"payment_amount"`
-	ResponseTimeHours    int        `json:"response_time_hours" db:"response_time_hours"`
-	PaymentTransactionID *string    `json:"payment_transaction_id" db:"payment_transaction_id"`
-	CreatedAt            time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt            time.Time  `json:"updated_at" db:"updated_at"`
+	ID                   string      `json:"id" db:"id"`
+	UserID               string      `json:"user_id" db:"user_id"`
+	ChatID               *string     `json:"chat_id" db:"chat_id"`
+	TicketType           string      `json:"ticket_type" db:"ticket_type"`
+	Subject              string      `json:"subject" db:"subject"`
+	Description          string      `json:"description" db:"description"`
+	Priority             string      `json:"priority" db:"priority"`
+	Status               string      `json:"status" db:"status"`
+	PaymentAmount        money.Money `json:"payment_amount" db:"payment_amount"`
+	ResponseTimeHours    int         `json:"response_time_hours" db:"response_time_hours"`
+	PaymentTransactionID *string     `json:"payment_transaction_id" db:"payment_transaction_id"`
+	CreatedAt            time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt            time.Time   `json:"updated_at" db:"updated_at"`
 }
 
 type ReservationViolation struct {
@@ -72,3 +84,33 @@ type ReservationViolation struct {
 	CreatedAt            time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt            time.Time `json:"updated_at" db:"updated_at"`
 }
+
+type AdminAlert struct {
+	ID             string                 `json:"id" db:"id"`
+	AlertType      string                 `json:"alert_type" db:"alert_type"` // "velocity_limit"
+	Severity       string                 `json:"severity" db:"severity"`     // "warning", "critical"
+	UserID         *string                `json:"user_id" db:"user_id"`
+	Message        string                 `json:"message" db:"message"`
+	Details        map[string]interface{} `json:"details" db:"details"`
+	Status         string                 `json:"status" db:"status"` // "open", "acknowledged"
+	AcknowledgedBy *string                `json:"acknowledged_by" db:"acknowledged_by"`
+	AcknowledgedAt *time.Time             `json:"acknowledged_at" db:"acknowledged_at"`
+	CreatedAt      time.Time              `json:"created_at" db:"created_at"`
+}
+
+// VelocityLimits caps money leaving a wallet. Amounts are in the default
+// currency and a zero value turns that limit off.
+type VelocityLimits struct {
+	MaxSingleAmount money.Money `json:"maxSingleAmount"`
+	MaxDailyAmount  money.Money `json:"maxDailyAmount"`
+	MaxWeeklyAmount money.Money `json:"maxWeeklyAmount"`
+	MaxPerHour      int         `json:"maxPerHour"`
+}
+
+// VelocitySettings holds the limits for established accounts and the stricter
+// ones applied while an account is younger than NewAccountDays.
+type VelocitySettings struct {
+	Default        VelocityLimits `json:"default"`
+	NewAccount     VelocityLimits `json:"newAccount"`
+	NewAccountDays int            `json:"newAccountDays"`
+}
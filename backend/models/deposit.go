@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"microjob-backend/money"
+)
+
+// Deposit is money a user pays in through a payment gateway. The wallet is
+// only credited once the gateway confirms the payment.
+type Deposit struct {
+	ID                string      `json:"id" db:"id"`
+	UserID            string      `json:"user_id" db:"user_id"`
+	Gateway           string      `json:"gateway" db:"gateway"`
+	Amount            money.Money `json:"amount" db:"amount"`
+	Currency          string      `json:"currency" db:"currency"`
+	Status            string      `json:"status" db:"status"` // "pending", "completed", "failed", "refunded"
+	ProviderReference *string     `json:"provider_reference" db:"provider_reference"`
+	PaymentURL        *string     `json:"payment_url" db:"payment_url"`
+	TransactionID     *string     `json:"transaction_id" db:"transaction_id"` // wallet transaction that credited the deposit
+	FailureReason     *string     `json:"failure_reason" db:"failure_reason"`
+	CreatedAt         time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time   `json:"updated_at" db:"updated_at"`
+	CompletedAt       *time.Time  `json:"completed_at" db:"completed_at"`
+}
+
+// PaymentWebhookEvent is a verified webhook as received from a payment
+// gateway. Events are processed in the background and retried on failure.
+type PaymentWebhookEvent struct {
+	ID                string      `json:"id" db:"id"`
+	Provider          string      `json:"provider" db:"provider"`
+	EventID           string      `json:"event_id" db:"event_id"`
+	EventType         string      `json:"event_type" db:"event_type"`
+	ProviderReference string      `json:"provider_reference" db:"provider_reference"`
+	Amount            money.Money `json:"amount" db:"amount"`
+	Payload           string      `json:"payload" db:"payload"`
+	OccurredAt        time.Time   `json:"occurred_at" db:"occurred_at"`
+	Status            string      `json:"status" db:"status"` // "pending", "processing", "processed", "failed", "rejected"
+	Attempts          int         `json:"attempts" db:"attempts"`
+	NextAttemptAt     *time.Time  `json:"next_attempt_at" db:"next_attempt_at"`
+	LastError         *string     `json:"last_error" db:"last_error"`
+	ReceivedAt        time.Time   `json:"received_at" db:"received_at"`
+	ProcessedAt       *time.Time  `json:"processed_at" db:"processed_at"`
+}
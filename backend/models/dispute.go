@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"microjob-backend/money"
+)
+
+// WorkProofDispute is a worker contesting the rejection of their work proof.
+// Admins settle it by paying in full, paying part of the amount or upholding
+// the rejection.
+type WorkProofDispute struct {
+	ID              string           `json:"id" db:"id"`
+	WorkProofID     string           `json:"work_proof_id" db:"work_proof_id"`
+	JobID           string           `json:"job_id" db:"job_id"`
+	WorkerID        string           `json:"worker_id" db:"worker_id"`
+	EmployerID      string           `json:"employer_id" db:"employer_id"`
+	Reason          string           `json:"reason" db:"reason"`
+	Description     string           `json:"description" db:"description"`
+	RequestedAction *string          `json:"requested_action" db:"requested_action"`
+	Currency        string           `json:"currency" db:"currency"`
+	Amount          money.Money      `json:"amount" db:"amount"`         // the proof's payment amount
+	Status          string           `json:"status" db:"status"`         // "pending", "under_review", "escalated", "resolved"
+	Priority        string           `json:"priority" db:"priority"`     // "low", "medium", "high", "urgent"
+	Resolution      *string          `json:"resolution" db:"resolution"` // "pay_full", "partial_pay", "uphold_rejection"
+	ResolvedAmount  *money.Money     `json:"resolved_amount" db:"resolved_amount"`
+	AdminID         *string          `json:"admin_id" db:"admin_id"`
+	AdminNotes      *string          `json:"admin_notes" db:"admin_notes"`
+	EvidenceCount   int              `json:"evidence_count" db:"-"`
+	Messages        []DisputeMessage `json:"messages,omitempty" db:"-"`
+	CreatedAt       time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at" db:"updated_at"`
+	ResolvedAt      *time.Time       `json:"resolved_at" db:"resolved_at"`
+}
+
+// DisputeMessage is a message on a dispute from either side or an admin,
+// with any evidence attached to it.
+type DisputeMessage struct {
+	ID         string    `json:"id" db:"id"`
+	DisputeID  string    `json:"dispute_id" db:"dispute_id"`
+	SenderID   string    `json:"sender_id" db:"sender_id"`
+	SenderRole string    `json:"sender_role" db:"sender_role"` // "worker", "employer", "admin"
+	Message    string    `json:"message" db:"message"`
+	Evidence   []string  `json:"evidence" db:"evidence"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
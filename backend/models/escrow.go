@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"microjob-backend/money"
+)
+
+// JobEscrow tracks the money an employer set aside when posting a job. The
+// funds sit in the job's escrow ledger account until released to workers or
+// refunded to the employer.
+type JobEscrow struct {
+	ID              string      `json:"id" db:"id"`
+	JobID           string      `json:"job_id" db:"job_id"`
+	EmployerID      string      `json:"employer_id" db:"employer_id"`
+	Currency        string      `json:"currency" db:"currency"` // the job's currency
+	AmountPerWorker money.Money `json:"amount_per_worker" db:"amount_per_worker"`
+	WorkersCount    int         `json:"workers_count" db:"workers_count"`
+	FeePerWorker    money.Money `json:"fee_per_worker" db:"fee_per_worker"` // payout fee fixed at funding
+	FeeChargedTo    *string     `json:"fee_charged_to" db:"fee_charged_to"` // nil for escrows funded before fees were stored
+	FundedAmount    money.Money `json:"funded_amount" db:"funded_amount"`
+	ReleasedAmount  money.Money `json:"released_amount" db:"released_amount"`
+	RefundedAmount  money.Money `json:"refunded_amount" db:"refunded_amount"`
+	Status          string      `json:"status" db:"status"` // "funded", "partially_released", "released", "refunded"
+	LedgerEntryID   *string     `json:"ledger_entry_id" db:"ledger_entry_id"`
+	CreatedAt       time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time   `json:"updated_at" db:"updated_at"`
+	ClosedAt        *time.Time  `json:"closed_at" db:"closed_at"`
+}
+
+// Remaining is the amount still held in escrow.
+func (e *JobEscrow) Remaining() money.Money {
+	return e.FundedAmount.Sub(e.ReleasedAmount).Sub(e.RefundedAmount)
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// File is an upload kept in storage. ContextType and ContextID say what the
+// file belongs to, which decides who may download it. URL is the stable
+// reference stored on work proofs, messages and tickets; downloads go through
+// short-lived signed URLs issued for it.
+type File struct {
+	ID           string    `json:"id" db:"id"`
+	OwnerID      string    `json:"owner_id" db:"owner_id"`
+	ContextType  string    `json:"context_type" db:"context_type"` // "chat", "job", "support_ticket", "marketplace"
+	ContextID    *string   `json:"context_id" db:"context_id"`
+	OriginalName string    `json:"original_name" db:"original_name"`
+	ContentType  string    `json:"content_type" db:"content_type"`
+	Size         int64     `json:"size" db:"size"`
+	SHA256       string    `json:"sha256" db:"sha256"`
+	Backend      string    `json:"-" db:"storage_backend"`
+	StorageKey   string    `json:"-" db:"storage_key"`
+	URL          string    `json:"url" db:"-"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// FileImage is what processing learned about an uploaded image. Dimensions
+// are as displayed, after the EXIF orientation is applied, and CapturedAt is
+// the camera's local time from the EXIF data.
+type FileImage struct {
+	FileID     string          `json:"file_id" db:"file_id"`
+	URL        string          `json:"url" db:"-"`
+	Status     string          `json:"status" db:"status"` // "pending", "processing", "processed", "failed"
+	Width      *int            `json:"width" db:"width"`
+	Height     *int            `json:"height" db:"height"`
+	CapturedAt *time.Time      `json:"captured_at" db:"captured_at"`
+	Thumbnails []FileThumbnail `json:"thumbnails" db:"-"`
+}
+
+// FileThumbnail is a scaled-down JPEG copy of an image. URL is a signed
+// download link issued with the response.
+type FileThumbnail struct {
+	Size       string `json:"size" db:"size"` // "small", "medium", "large"
+	Width      int    `json:"width" db:"width"`
+	Height     int    `json:"height" db:"height"`
+	URL        string `json:"url" db:"-"`
+	StorageKey string `json:"-" db:"storage_key"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+type IdempotencyKey struct {
+	ID             string     `json:"id" db:"id"`
+	UserID         string     `json:"user_id" db:"user_id"`
+	Key            string     `json:"key" db:"idempotency_key"`
+	RequestMethod  string     `json:"request_method" db:"request_method"`
+	RequestPath    string     `json:"request_path" db:"request_path"`
+	RequestHash    string     `json:"request_hash" db:"request_hash"` // SHA-256 of method, path and body
+	Status         string     `json:"status" db:"status"`             // "processing", "completed"
+	ResponseStatus *int       `json:"response_status" db:"response_status"`
+	ResponseBody   *string    `json:"response_body" db:"response_body"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	CompletedAt    *time.Time `json:"completed_at" db:"completed_at"`
+	ExpiresAt      time.Time  `json:"expires_at" db:"expires_at"`
+}
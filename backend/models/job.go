@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"microjob-backend/money"
 )
 
 type Category struct {
@@ -16,53 +18,234 @@ type Category struct {
 }
 
 type Microjob struct {
-	ID                 string    `json:"id" db:"id"`
-	UserID             string    `json:"user_id" db:"user_id"`
-	CategoryID         string    `json:"category_id" db:"category_id"`
-	Title              string    `json:"title" db:"title"`
-	Description        string    `json:"description" db:"description"`
-	Requirements       *string   `json:"requirements" db:"requirements"`
-	BudgetMin          *float64  `json:"budget_min" db:"budget_min"`
-	BudgetMax          *float64  `json:"budget_max" db:"budget_max"`
-	Deadline           *string   `json:"deadline" db:"deadline"` // DATE type
-	Location           *string   `json:"location" db:"location"`
-	IsRemote           bool      `json:"is_remote" db:"is_remote"`
-	Status             string    `json:"status" db:"status"` // "open", "in_progress", "completed", "cancelled"
-	Priority           string    `json:"priority" db:"priority"` // "low", "normal", "high", "urgent"
-	Attachments        JSONArray `json:"attachments" db:"attachments"`
-	SkillsRequired     JSONArray `json:"skills_required" db:"skills_required"`
-	ApplicationsCount  int       `json:"applications_count" db:"applications_count"`
-	ViewsCount         int       `json:"views_count" db:"views_count"`
-	CreatedAt          time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt          time.Time `json:"updated_at" db:"updated_at"`
+	ID                string       `json:"id" db:"id"`
+	UserID            string       `json:"user_id" db:"user_id"`
+	CategoryID        string       `json:"category_id" db:"category_id"`
+	Title             string       `json:"title" db:"title"`
+	Description       string       `json:"description" db:"description"`
+	Requirements      *string      `json:"requirements" db:"requirements"`
+	BudgetMin         *money.Money `json:"budget_min" db:"budget_min"`
+	BudgetMax         *money.Money `json:"budget_max" db:"budget_max"`
+	Currency          string       `json:"currency" db:"currency"`
+	Deadline          *string      `json:"deadline" db:"deadline"` // DATE type
+	Location          *string      `json:"location" db:"location"`
+	IsRemote          bool         `json:"is_remote" db:"is_remote"`
+	Status            string       `json:"status" db:"status"`     // "open", "in_progress", "completed", "cancelled"
+	Priority          string       `json:"priority" db:"priority"` // "low", "normal", "high", "urgent"
+	Attachments       JSONArray    `json:"attachments" db:"attachments"`
+	SkillsRequired    JSONArray    `json:"skills_required" db:"skills_required"`
+	ApplicationsCount int          `json:"applications_count" db:"applications_count"`
+	ViewsCount        int          `json:"views_count" db:"views_count"`
+	CreatedAt         time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// Job is a posted job as stored in the jobs table.
+type Job struct {
+	ID                     string         `json:"id" db:"id"`
+	UserID                 string         `json:"user_id" db:"user_id"`
+	CategoryID             string         `json:"category_id" db:"category_id"`
+	SubcategoryID          *string        `json:"subcategory_id" db:"subcategory_id"`
+	Title                  string         `json:"title" db:"title"`
+	Description            string         `json:"description" db:"description"`
+	BudgetMin              money.Money    `json:"budget_min" db:"budget_min"`
+	BudgetMax              money.Money    `json:"budget_max" db:"budget_max"` // paid per approved worker
+	Currency               string         `json:"currency" db:"currency"`     // of the budget, escrow and payouts
+	Deadline               *time.Time     `json:"deadline" db:"deadline"`
+	Location               *string        `json:"location" db:"location"`
+	IsRemote               bool           `json:"is_remote" db:"is_remote"`
+	Status                 string         `json:"status" db:"status"`               // "open", "in_progress", "completed", "cancelled", "expired"
+	ApprovalType           string         `json:"approval_type" db:"approval_type"` // "instant", "manual"
+	InstantApprovalEnabled bool           `json:"instant_approval_enabled" db:"instant_approval_enabled"`
+	ManualApprovalDays     int            `json:"manual_approval_days" db:"manual_approval_days"`
+	RequiredWorkers        int            `json:"required_workers" db:"required_workers"`
+	RequiredScreenshots    int            `json:"required_screenshots" db:"required_screenshots"`
+	PriceQuote             *JobPriceQuote `json:"price_quote,omitempty" db:"price_quote"` // what the employer paid to post it
+	Thumbnail              *string        `json:"thumbnail" db:"thumbnail"`
+	CategoryName           string         `json:"category_name,omitempty" db:"-"`
+	CategorySlug           string         `json:"category_slug,omitempty" db:"-"`
+	User                   *User          `json:"user,omitempty" db:"-"`
+	CreatedAt              time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt              time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// JobPriceQuote itemizes what an employer pays to post a job. Worker payouts
+// and the platform fee are held in the job's escrow; screenshot and boost fees
+// are charged when the job is posted.
+type JobPriceQuote struct {
+	Currency            string                `json:"currency"`
+	RewardPerWorker     money.Money           `json:"reward_per_worker"`
+	RequiredWorkers     int                   `json:"required_workers"`
+	WorkerPayouts       money.Money           `json:"worker_payouts"`
+	PlatformFee         money.Money           `json:"platform_fee"` // the payout fee the employer bears, for all workers
+	RequiredScreenshots int                   `json:"required_screenshots"`
+	Screenshots         []JobScreenshotCharge `json:"screenshots"`
+	ScreenshotFee       money.Money           `json:"screenshot_fee"`
+	Boosts              []JobBoostCharge      `json:"boosts"`
+	BoostFee            money.Money           `json:"boost_fee"`
+	Total               money.Money           `json:"total"`
+}
+
+// ListingFee is the part of the quote charged when the job is posted rather
+// than escrowed.
+func (q *JobPriceQuote) ListingFee() money.Money {
+	return q.ScreenshotFee.Add(q.BoostFee)
+}
+
+// JobScreenshotCharge is the price of one required screenshot. Percentage is
+// of the worker payouts and is zero for flat-priced screenshots.
+type JobScreenshotCharge struct {
+	ScreenshotNumber int         `json:"screenshot_number"`
+	Percentage       money.Rate  `json:"percentage"`
+	IsFree           bool        `json:"is_free"`
+	Amount           money.Money `json:"amount"`
+}
+
+type JobBoostCharge struct {
+	Key    string      `json:"key"`
+	Label  string      `json:"label"`
+	Amount money.Money `json:"amount"`
+}
+
+// JobPricingSettings decide what employers pay on top of worker rewards.
+// Flat amounts are in the default currency and converted for jobs posted in
+// other currencies.
+type JobPricingSettings struct {
+	MaxScreenshots       int                   `json:"maxScreenshots"`
+	ScreenshotTiers      []ScreenshotPriceTier `json:"screenshotTiers"`
+	DefaultScreenshotFee money.Money           `json:"defaultScreenshotFee"` // for screenshots without a tier
+	BoostOptions         []JobBoostOption      `json:"boostOptions"`
+}
+
+// ScreenshotPriceTier prices the screenshot with the given number as a
+// percentage of the job's worker payouts.
+type ScreenshotPriceTier struct {
+	ScreenshotNumber int        `json:"screenshotNumber"`
+	Percentage       money.Rate `json:"percentage"`
+	IsFree           bool       `json:"isFree"`
+}
+
+// JobBoostOption is an extra an employer can buy for a job, such as a
+// featured listing, at a flat price.
+type JobBoostOption struct {
+	Key      string      `json:"key"`
+	Label    string      `json:"label"`
+	Price    money.Money `json:"price"`
+	IsActive bool        `json:"isActive"`
+}
+
+// JobApprovalPolicy decides which work proofs for a job are approved without
+// the employer. Rules left unset do not apply; a job without a policy keeps
+// its approval type and manual approval deadline.
+type JobApprovalPolicy struct {
+	JobID               string    `json:"jobId" db:"job_id"`
+	NeverAutoApprove    bool      `json:"neverAutoApprove" db:"never_auto_approve"`       // overrides every other rule and the deadline
+	MinWorkerRating     *float64  `json:"minWorkerRating" db:"min_worker_rating"`         // workers rated at least this are trusted
+	MinScreenshots      int       `json:"minScreenshots" db:"min_screenshots"`            // with MinLinks, the evidence a proof needs
+	MinLinks            int       `json:"minLinks" db:"min_links"`
+	SampleReviewPercent *int      `json:"sampleReviewPercent" db:"sample_review_percent"` // share held for manual review
+	CreatedAt           time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt           time.Time `json:"updatedAt" db:"updated_at"`
 }
 
 type JobApplication struct {
-	ID                string     `json:"id" db:"id"`
-	JobID             string     `json:"job_id" db:"job_id"`
-	ApplicantID       string     `json:"applicant_id" db:"applicant_id"`
-	CoverLetter       *string    `json:"cover_letter" db:"cover_letter"`
-	ProposedBudget    *float64   `json:"proposed_budget" db:"proposed_budget"`
-	EstimatedDuration *string    `json:"estimated_duration" db:"estimated_duration"`
-	PortfolioLinks    JSONArray  `json:"portfolio_links" db:"portfolio_links"`
-	Status            string     `json:"status" db:"status"` // "pending", "accepted", "rejected"
-	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	ID                string       `json:"id" db:"id"`
+	JobID             string       `json:"job_id" db:"job_id"`
+	ApplicantID       string       `json:"applicant_id" db:"applicant_id"`
+	CoverLetter       *string      `json:"cover_letter" db:"cover_letter"`
+	ProposedBudget    *money.Money `json:"proposed_budget" db:"proposed_budget"`
+	EstimatedDuration *string      `json:"estimated_duration" db:"estimated_duration"`
+	PortfolioLinks    JSONArray    `json:"portfolio_links" db:"portfolio_links"`
+	Status            string       `json:"status" db:"status"` // "pending", "accepted", "rejected"
+	CreatedAt         time.Time    `json:"created_at" db:"created_at"`
 }
 
+// WorkProof is a worker's submission for a job. Its status only changes
+// through the transitions in services/workproof_state.go.
 type WorkProof struct {
-	ID            string     `json:"id" db:"id"`
-	JobID         string     `json:"job_id" db:"job_id"`
-	WorkerID      string     `json:"worker_id" db:"worker_id"`
-	ApplicationID string     `json:"application_id" db:"application_id"`
-	Title         string     `json:"title" db:"title"`
-	Description   string     `json:"description" db:"description"`
-	Status        string     `json:"status" db:"status"` // "pending", "approved", "rejected", "revision_requested"
-	PaymentAmount float64    `json:"payment_amount" db:"payment_amount"`
-	ReviewNotes   *string    `json:"review_notes" db:"review_notes"`
-	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
-	ApprovedAt    *time.Time `json:"approved_at" db:"approved_at"`
-	RejectedAt    *time.Time `json:"rejected_at" db:"rejected_at"`
+	ID                     string      `json:"id" db:"id"`
+	JobID                  string      `json:"job_id" db:"job_id"`
+	WorkerID               string      `json:"worker_id" db:"worker_id"`
+	EmployerID             string      `json:"employer_id" db:"employer_id"`
+	ApplicationID          string      `json:"application_id" db:"application_id"`
+	Title                  string      `json:"title" db:"title"`
+	Description            string      `json:"description" db:"description"`
+	SubmissionText         string      `json:"submission_text" db:"submission_text"`
+	ProofFiles             []string    `json:"proof_files" db:"proof_files"`
+	ProofLinks             []string    `json:"proof_links" db:"proof_links"`
+	Screenshots            []string    `json:"screenshots" db:"screenshots"`
+	Attachments            []string    `json:"attachments" db:"attachments"`
+	Status                 string      `json:"status" db:"status"` // "submitted", "auto_approved", "approved", "rejected", "revision_requested", "rejected_accepted", "cancelled_by_worker", "disputed", "partially_paid", "rejection_upheld"
+	SubmittedAt            time.Time   `json:"submitted_at" db:"submitted_at"`
+	ReviewedAt             *time.Time  `json:"reviewed_at" db:"reviewed_at"`
+	ReviewFeedback         *string     `json:"review_feedback" db:"review_feedback"`
+	PaymentAmount          money.Money `json:"payment_amount" db:"payment_amount"`
+	SubmissionNumber       int         `json:"submission_number" db:"submission_number"`
+	RevisionCount          int         `json:"revision_count" db:"revision_count"`
+	RevisionDeadline       *time.Time  `json:"revision_deadline" db:"revision_deadline"`
+	RejectionDeadline      *time.Time  `json:"rejection_deadline" db:"rejection_deadline"`
+	WorkerResponse         *string     `json:"worker_response" db:"worker_response"` // "accepted", "cancelled"
+	WorkerResponseAt       *time.Time  `json:"worker_response_at" db:"worker_response_at"`
+	DisputeReason          *string     `json:"dispute_reason" db:"dispute_reason"`
+	DisputeEvidence        *string     `json:"dispute_evidence" db:"dispute_evidence"`
+	DisputeRequestedAction *string     `json:"dispute_requested_action" db:"dispute_requested_action"`
+	ReviewNotes            *string     `json:"review_notes" db:"review_notes"`
+	PossibleDuplicate      bool        `json:"possible_duplicate" db:"possible_duplicate"` // files match an earlier proof by someone else or for another job
+	Images                 []FileImage `json:"images,omitempty" db:"-"`                    // uploaded screenshots with their thumbnails
+	Worker                 *User       `json:"worker,omitempty" db:"-"`
+	Employer               *User       `json:"employer,omitempty" db:"-"`
+	CreatedAt              time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt              time.Time   `json:"updated_at" db:"updated_at"`
+	ApprovedAt             *time.Time  `json:"approved_at" db:"approved_at"`
+	RejectedAt             *time.Time  `json:"rejected_at" db:"rejected_at"`
+}
+
+// WorkProofSubmission is one version of a work proof's content. The first is
+// created with the proof and every resubmission after a revision request adds
+// the next one, linked to the version it replaces.
+type WorkProofSubmission struct {
+	ID                   string    `json:"id" db:"id"`
+	WorkProofID          string    `json:"work_proof_id" db:"work_proof_id"`
+	SubmissionNumber     int       `json:"submission_number" db:"submission_number"`
+	PreviousSubmissionID *string   `json:"previous_submission_id" db:"previous_submission_id"`
+	Description          string    `json:"description" db:"description"`
+	SubmissionText       string    `json:"submission_text" db:"submission_text"`
+	ProofFiles           []string  `json:"proof_files" db:"proof_files"`
+	ProofLinks           []string  `json:"proof_links" db:"proof_links"`
+	Screenshots          []string  `json:"screenshots" db:"screenshots"`
+	Attachments          []string  `json:"attachments" db:"attachments"`
+	SubmittedAt          time.Time `json:"submitted_at" db:"submitted_at"`
+}
+
+// WorkProofDuplicate links a file of a work proof to the same or a near
+// identical file on an earlier proof. Distance is how many bits the images'
+// perceptual hashes differ in, zero for exact copies.
+type WorkProofDuplicate struct {
+	ID                 string    `json:"id" db:"id"`
+	WorkProofID        string    `json:"work_proof_id" db:"work_proof_id"`
+	SubmissionNumber   int       `json:"submission_number" db:"submission_number"`
+	FileURL            string    `json:"file_url" db:"file_url"`
+	MatchedWorkProofID string    `json:"matched_work_proof_id" db:"matched_work_proof_id"`
+	MatchedJobID       string    `json:"matched_job_id" db:"-"`
+	MatchedWorkerID    string    `json:"matched_worker_id" db:"-"`
+	MatchedFileURL     string    `json:"matched_file_url" db:"matched_file_url"`
+	MatchType          string    `json:"match_type" db:"match_type"` // "exact", "perceptual"
+	Distance           int       `json:"distance" db:"distance"`
+	CreatedAt          time.Time `json:"created_at" db:"created_at"`
+}
+
+// WorkProofEvent records one status change of a work proof. ActorID is empty
+// for changes the system made on its own, such as deadline timeouts.
+type WorkProofEvent struct {
+	ID          string    `json:"id" db:"id"`
+	WorkProofID string    `json:"work_proof_id" db:"work_proof_id"`
+	Action      string    `json:"action" db:"action"`
+	FromStatus  *string   `json:"from_status" db:"from_status"` // nil for the submission itself
+	ToStatus    string    `json:"to_status" db:"to_status"`
+	ActorID     *string   `json:"actor_id" db:"actor_id"`
+	ActorRole   string    `json:"actor_role" db:"actor_role"` // "worker", "employer", "system"
+	Reason      *string   `json:"reason" db:"reason"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 }
 
 type JobReservation struct {
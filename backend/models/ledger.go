@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"microjob-backend/money"
+)
+
+type LedgerAccount struct {
+	ID          string      `json:"id" db:"id"`
+	AccountType string      `json:"account_type" db:"account_type"` // "user_available", "user_pending", "user_locked", "platform_revenue", "platform_fees", "escrow", "external"
+	OwnerID     *string     `json:"owner_id" db:"owner_id"`         // user ID for wallet accounts, job ID for escrow, NULL for platform accounts
+	Currency    string      `json:"currency" db:"currency"`         // each currency is held in an account of its own
+	Balance     money.Money `json:"balance" db:"balance"`
+	CreatedAt   time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+// LedgerEntry is an immutable journal entry. The amounts of its lines always sum to zero.
+type LedgerEntry struct {
+	ID            string       `json:"id" db:"id"`
+	EntryType     string       `json:"entry_type" db:"entry_type"` // "deposit", "payment", "chat_transfer", "fee", "refund", ...
+	Description   *string      `json:"description" db:"description"`
+	ReferenceID   *string      `json:"reference_id" db:"reference_id"`
+	ReferenceType *string      `json:"reference_type" db:"reference_type"`
+	Lines         []LedgerLine `json:"lines,omitempty" db:"-"`
+	CreatedAt     time.Time    `json:"created_at" db:"created_at"`
+}
+
+type LedgerLine struct {
+	ID        string      `json:"id" db:"id"`
+	EntryID   string      `json:"entry_id" db:"entry_id"`
+	AccountID string      `json:"account_id" db:"account_id"`
+	Amount    money.Money `json:"amount" db:"amount"` // positive credits the account, negative debits it
+	CreatedAt time.Time   `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"microjob-backend/money"
+)
+
+// WalletReconciliationRun is one pass comparing wallets with their transaction history.
+type WalletReconciliationRun struct {
+	ID                string              `json:"id" db:"id"`
+	RequestedBy       *string             `json:"requested_by" db:"requested_by"` // nil for scheduled runs
+	UserID            *string             `json:"user_id" db:"user_id"`           // set when a single wallet was checked
+	Status            string              `json:"status" db:"status"`             // "running", "completed", "failed"
+	WalletsChecked    int                 `json:"wallets_checked" db:"wallets_checked"`
+	DiscrepancyCount  int                 `json:"discrepancy_count" db:"discrepancy_count"`
+	UnclassifiedCount int                 `json:"unclassified_count" db:"unclassified_count"` // transactions of unknown type, left out of the totals
+	Error             *string             `json:"error" db:"error"`
+	StartedAt         time.Time           `json:"started_at" db:"started_at"`
+	CompletedAt       *time.Time          `json:"completed_at" db:"completed_at"`
+	Discrepancies     []WalletDiscrepancy `json:"discrepancies,omitempty"`
+}
+
+// WalletDiscrepancy is a wallet column that disagrees with the value rebuilt
+// from the user's transactions.
+type WalletDiscrepancy struct {
+	ID             string      `json:"id" db:"id"`
+	RunID          string      `json:"run_id" db:"run_id"`
+	UserID         string      `json:"user_id" db:"user_id"`
+	Field          string      `json:"field" db:"field"` // "balance", "pending_balance", "locked_balance", "total_earned", "total_spent"
+	RecordedAmount money.Money `json:"recorded_amount" db:"recorded_amount"`
+	ExpectedAmount money.Money `json:"expected_amount" db:"expected_amount"`
+	Difference     money.Money `json:"difference" db:"difference"` // expected minus recorded
+	Status         string      `json:"status" db:"status"`         // "open", "corrected", "stale"
+	CorrectedBy    *string     `json:"corrected_by" db:"corrected_by"`
+	CorrectedAt    *time.Time  `json:"corrected_at" db:"corrected_at"`
+	LedgerEntryID  *string     `json:"ledger_entry_id" db:"ledger_entry_id"`
+	CreatedAt      time.Time   `json:"created_at" db:"created_at"`
+}
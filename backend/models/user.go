@@ -4,6 +4,8 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"time"
+
+	"microjob-backend/money"
 )
 
 type User struct {
@@ -80,11 +82,11 @@ type ReferralCode struct {
 }
 
 type Referral struct {
-	ID           string    `json:"id" db:"id"`
-	ReferrerID   string    `json:"referrer_id" db:"referrer_id"`
-	ReferredID   string    `json:"referred_id" db:"referred_id"`
-	ReferralCode string    `json:"referral_code" db:"referral_code"`
-	Status       string    `json:"status" db:"status"` // "pending", "completed"
-	RewardAmount float64   `json:"reward_amount" db:"reward_amount"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	ID           string      `json:"id" db:"id"`
+	ReferrerID   string      `json:"referrer_id" db:"referrer_id"`
+	ReferredID   string      `json:"referred_id" db:"referred_id"`
+	ReferralCode string      `json:"referral_code" db:"referral_code"`
+	Status       string      `json:"status" db:"status"` // "pending", "completed"
+	RewardAmount money.Money `json:"reward_amount" db:"reward_amount"`
+	CreatedAt    time.Time   `json:"created_at" db:"created_at"`
 }
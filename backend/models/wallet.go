@@ -2,41 +2,178 @@ package models
 
 import (
 	"time"
+
+	"microjob-backend/money"
 )
 
+// Wallet holds a user's balances in money.DefaultCurrency. Balances in other
+// currencies are kept in WalletBalance rows.
 type Wallet struct {
-	ID             string    `json:"id" db:"id"`
-	UserID         string    `json:"user_id" db:"user_id"`
-	Balance        float64   `json:"balance" db:"balance"`
-	PendingBalance float64   `json:"pending_balance" db:"pending_balance"`
-	TotalEarned    float64   `json:"total_earned" db:"total_earned"`
-	TotalSpent     float64   `json:"total_spent" db:"total_spent"`
-	CreatedAt      time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+	ID             string      `json:"id" db:"id"`
+	UserID         string      `json:"user_id" db:"user_id"`
+	Balance        money.Money `json:"balance" db:"balance"`
+	PendingBalance money.Money `json:"pending_balance" db:"pending_balance"`
+	LockedBalance  money.Money `json:"locked_balance" db:"locked_balance"` // requested withdrawals not yet paid out
+	TotalEarned    money.Money `json:"total_earned" db:"total_earned"`
+	TotalSpent     money.Money `json:"total_spent" db:"total_spent"`
+	CreatedAt      time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at" db:"updated_at"`
 }
 
 type WalletTransaction struct {
-	ID            string    `json:"id" db:"id"`
-	WalletID      string    `json:"wallet_id" db:"wallet_id"`
-	Type          string    `json:"type" db:"type"` // "deposit", "withdrawal", "payment", "earning", "refund"
-	Amount        float64   `json:"amount" db:"amount"`
-	Description   *string   `json:"description" db:"description"`
-	ReferenceID   *string   `json:"reference_id" db:"reference_id"`
-	ReferenceType *string   `json:"reference_type" db:"reference_type"`
-	Status        string    `json:"status" db:"status"` // "pending", "completed", "failed"
-	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	ID            string      `json:"id" db:"id"`
+	WalletID      string      `json:"wallet_id" db:"wallet_id"`
+	UserID        string      `json:"user_id" db:"user_id"`
+	Type          string      `json:"type" db:"type"` // "deposit", "withdrawal", "payment", "earning", "refund"
+	Amount        money.Money `json:"amount" db:"amount"`
+	Currency      string      `json:"currency" db:"currency"`
+	Description   *string     `json:"description" db:"description"`
+	ReferenceID   *string     `json:"reference_id" db:"reference_id"`
+	ReferenceType *string     `json:"reference_type" db:"reference_type"`
+	BalanceType   string      `json:"balance_type" db:"balance_type"` // "deposit" (available) or "pending"
+	Status        string      `json:"status" db:"status"`             // "pending", "completed", "failed"
+	LedgerEntryID *string     `json:"ledger_entry_id" db:"ledger_entry_id"`
+	CreatedAt     time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at" db:"updated_at"`
 }
 
+// ChatMoneyTransfer is money moving between two people in a chat. Either party
+// can start it: the sender offering money, or the receiver asking for it. It
+// waits in "requested" until the other party accepts or declines, and can be
+// disputed or refunded for a while after it completes.
 type ChatMoneyTransfer struct {
-	ID               string     `json:"id" db:"id"`
-	ChatID           string     `json:"chat_id" db:"chat_id"`
-	SenderID         string     `json:"sender_id" db:"sender_id"`
-	ReceiverID       string     `json:"receiver_id" db:"receiver_id"`
-	Amount           float64    `json:"amount" db:"amount"`
-	CommissionAmount float64    `json:"commission_amount" db:"commission_amount"`
-	NetAmount        float64    `json:"net_amount" db:"net_amount"`
-	Message          *string    `json:"message" db:"message"`
-	Status           string     `json:"status" db:"status"` // "pending", "completed", "failed"
-	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
-	CompletedAt      *time.Time `json:"completed_at" db:"completed_at"`
+	ID                  string      `json:"id" db:"id"`
+	ChatID              string      `json:"chat_id" db:"chat_id"`
+	SenderID            string      `json:"sender_id" db:"sender_id"`
+	ReceiverID          string      `json:"receiver_id" db:"receiver_id"`
+	RequestedBy         string      `json:"requested_by" db:"requested_by"` // the party who started it; the other one answers
+	Currency            string      `json:"currency" db:"currency"`
+	Amount              money.Money `json:"amount" db:"amount"`
+	CommissionAmount    money.Money `json:"commission_amount" db:"commission_amount"`
+	NetAmount           money.Money `json:"net_amount" db:"net_amount"` // what the receiver gets
+	Message             *string     `json:"message" db:"message"`
+	Status              string      `json:"status" db:"status"` // "requested", "completed", "declined", "cancelled", "expired", "disputed", "dispute_rejected", "refunded"
+	ExpiresAt           *time.Time  `json:"expires_at" db:"expires_at"`
+	RespondedAt         *time.Time  `json:"responded_at" db:"responded_at"`
+	DeclineReason       *string     `json:"decline_reason" db:"decline_reason"`
+	RefundableUntil     *time.Time  `json:"refundable_until" db:"refundable_until"`
+	DisputedAt          *time.Time  `json:"disputed_at" db:"disputed_at"`
+	DisputeReason       *string     `json:"dispute_reason" db:"dispute_reason"`
+	ResolvedBy          *string     `json:"resolved_by" db:"resolved_by"`
+	ResolvedAt          *time.Time  `json:"resolved_at" db:"resolved_at"`
+	ResolutionNote      *string     `json:"resolution_note" db:"resolution_note"`
+	RefundedBy          *string     `json:"refunded_by" db:"refunded_by"`
+	RefundedAt          *time.Time  `json:"refunded_at" db:"refunded_at"`
+	LedgerEntryID       *string     `json:"ledger_entry_id" db:"ledger_entry_id"`
+	RefundLedgerEntryID *string     `json:"refund_ledger_entry_id" db:"refund_ledger_entry_id"`
+	CreatedAt           time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time   `json:"updated_at" db:"updated_at"`
+	CompletedAt         *time.Time  `json:"completed_at" db:"completed_at"`
+}
+
+// PayerPays is what leaves the sender's wallet: the receiver's share plus the
+// commission, whichever side the commission was charged to.
+func (t *ChatMoneyTransfer) PayerPays() money.Money {
+	return t.NetAmount.Add(t.CommissionAmount)
+}
+
+// OfferedBySender reports whether the sender started the transfer, in which
+// case their money is locked until the receiver answers.
+func (t *ChatMoneyTransfer) OfferedBySender() bool {
+	return t.RequestedBy == t.SenderID
+}
+
+// EarningHold is an earning kept in the worker's pending balance until its
+// hold period ends and it is released to the available balance.
+type EarningHold struct {
+	ID            string      `json:"id" db:"id"`
+	UserID        string      `json:"user_id" db:"user_id"`
+	TransactionID *string     `json:"transaction_id" db:"transaction_id"`
+	Amount        money.Money `json:"amount" db:"amount"`
+	Currency      string      `json:"currency" db:"currency"`
+	ReferenceID   *string     `json:"reference_id" db:"reference_id"`
+	ReferenceType *string     `json:"reference_type" db:"reference_type"`
+	Status        string      `json:"status" db:"status"` // "held", "released", "reversed"
+	ReleaseAt     time.Time   `json:"release_at" db:"release_at"`
+	ReleasedAt    *time.Time  `json:"released_at" db:"released_at"`
+	ReleasedBy    *string     `json:"released_by" db:"released_by"` // admin who forced an early release
+	LedgerEntryID *string     `json:"ledger_entry_id" db:"ledger_entry_id"`
+	CreatedAt     time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+// WalletRefund is an admin reversal of part or all of a payment. The payee
+// gives back Amount from their available balance, then from held earnings,
+// and owes whatever is still missing as a WalletDebt.
+type WalletRefund struct {
+	ID                    string      `json:"id" db:"id"`
+	OriginalTransactionID string      `json:"original_transaction_id" db:"original_transaction_id"`
+	PayerID               string      `json:"payer_id" db:"payer_id"` // gets the money back
+	PayeeID               string      `json:"payee_id" db:"payee_id"` // gives it back
+	Currency              string      `json:"currency" db:"currency"`
+	Amount                money.Money `json:"amount" db:"amount"`
+	FromAvailable         money.Money `json:"from_available" db:"from_available"`
+	FromPending           money.Money `json:"from_pending" db:"from_pending"`
+	AsDebt                money.Money `json:"as_debt" db:"as_debt"`
+	Reason                string      `json:"reason" db:"reason"`
+	RefundedBy            string      `json:"refunded_by" db:"refunded_by"`
+	LedgerEntryID         *string     `json:"ledger_entry_id" db:"ledger_entry_id"`
+	CreatedAt             time.Time   `json:"created_at" db:"created_at"`
+}
+
+// WalletDebt is money a user owes the platform after a refund took more than
+// their wallet held. It is collected from their available balance as money
+// comes in.
+type WalletDebt struct {
+	ID                string      `json:"id" db:"id"`
+	UserID            string      `json:"user_id" db:"user_id"`
+	RefundID          *string     `json:"refund_id" db:"refund_id"`
+	Currency          string      `json:"currency" db:"currency"`
+	Amount            money.Money `json:"amount" db:"amount"`
+	OutstandingAmount money.Money `json:"outstanding_amount" db:"outstanding_amount"`
+	Status            string      `json:"status" db:"status"` // "outstanding", "settled"
+	CreatedAt         time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time   `json:"updated_at" db:"updated_at"`
+	SettledAt         *time.Time  `json:"settled_at" db:"settled_at"`
+}
+
+// WalletBalance is a user's balance in one currency. The default currency is
+// shown from the wallets row; other currencies have a row of their own.
+type WalletBalance struct {
+	UserID         string      `json:"user_id" db:"user_id"`
+	Currency       string      `json:"currency" db:"currency"`
+	Balance        money.Money `json:"balance" db:"balance"`
+	PendingBalance money.Money `json:"pending_balance" db:"pending_balance"`
+	LockedBalance  money.Money `json:"locked_balance" db:"locked_balance"`
+	TotalEarned    money.Money `json:"total_earned" db:"total_earned"`
+	TotalSpent     money.Money `json:"total_spent" db:"total_spent"`
+	UpdatedAt      time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+// ExchangeRate is the rate an admin set for a currency, as units of that
+// currency per unit of money.DefaultCurrency. Rates are never updated in
+// place; the newest row for a currency is the current rate.
+type ExchangeRate struct {
+	ID        string             `json:"id" db:"id"`
+	Currency  string             `json:"currency" db:"currency"`
+	Rate      money.ExchangeRate `json:"rate" db:"rate"`
+	SetBy     *string            `json:"set_by" db:"set_by"`
+	CreatedAt time.Time          `json:"created_at" db:"created_at"`
+}
+
+// CurrencyConversion moves part of a user's available balance from one
+// currency into another. The fee is taken in the source currency before the
+// rest is converted.
+type CurrencyConversion struct {
+	ID              string             `json:"id" db:"id"`
+	UserID          string             `json:"user_id" db:"user_id"`
+	FromCurrency    string             `json:"from_currency" db:"from_currency"`
+	ToCurrency      string             `json:"to_currency" db:"to_currency"`
+	Amount          money.Money        `json:"amount" db:"amount"` // debited from the source balance, fee included
+	FeeAmount       money.Money        `json:"fee_amount" db:"fee_amount"`
+	ConvertedAmount money.Money        `json:"converted_amount" db:"converted_amount"` // credited to the target balance
+	FromRate        money.ExchangeRate `json:"from_rate" db:"from_rate"`
+	ToRate          money.ExchangeRate `json:"to_rate" db:"to_rate"`
+	LedgerEntryID   *string            `json:"ledger_entry_id" db:"ledger_entry_id"`
+	CreatedAt       time.Time          `json:"created_at" db:"created_at"`
 }
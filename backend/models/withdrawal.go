@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"microjob-backend/money"
+)
+
+// WithdrawalRequest is a user's request to cash out part of their available
+// balance. The amount stays locked until the payout succeeds or is reversed.
+type WithdrawalRequest struct {
+	ID              string      `json:"id" db:"id"`
+	UserID          string      `json:"user_id" db:"user_id"`
+	Currency        string      `json:"currency" db:"currency"`
+	Amount          money.Money `json:"amount" db:"amount"`
+	FeeAmount       money.Money `json:"fee_amount" db:"fee_amount"`
+	NetAmount       money.Money `json:"net_amount" db:"net_amount"` // what the user receives
+	PayoutMethod    string      `json:"payout_method" db:"payout_method"`
+	PayoutDetails   string      `json:"payout_details" db:"payout_details"`
+	Status          string      `json:"status" db:"status"` // "pending", "approved", "processing", "paid", "rejected", "failed"
+	BatchID         *string     `json:"batch_id" db:"batch_id"`
+	ReviewedBy      *string     `json:"reviewed_by" db:"reviewed_by"`
+	ReviewedAt      *time.Time  `json:"reviewed_at" db:"reviewed_at"`
+	RejectionReason *string     `json:"rejection_reason" db:"rejection_reason"`
+	PayoutReference *string     `json:"payout_reference" db:"payout_reference"`
+	FailureReason   *string     `json:"failure_reason" db:"failure_reason"`
+	CreatedAt       time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time   `json:"updated_at" db:"updated_at"`
+	CompletedAt     *time.Time  `json:"completed_at" db:"completed_at"`
+}
+
+// PayoutBatch groups approved withdrawals that are exported together for the
+// finance team to pay out.
+type PayoutBatch struct {
+	ID              string      `json:"id" db:"id"`
+	Status          string      `json:"status" db:"status"`     // "exported", "completed"
+	Currency        string      `json:"currency" db:"currency"` // a batch only pays out one currency
+	WithdrawalCount int         `json:"withdrawal_count" db:"withdrawal_count"`
+	TotalAmount     money.Money `json:"total_amount" db:"total_amount"` // sum of net amounts to pay
+	CreatedBy       string      `json:"created_by" db:"created_by"`
+	CreatedAt       time.Time   `json:"created_at" db:"created_at"`
+	CompletedAt     *time.Time  `json:"completed_at" db:"completed_at"`
+}
@@ -0,0 +1,115 @@
+package money
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Exchange rates have eight decimal places, matching DECIMAL(20,8) columns.
+const exchangeRateScale = 8
+
+// ExchangeRate is how many units of a currency one unit of DefaultCurrency
+// buys, so 0.92 EUR per USD is ExchangeRate(92000000).
+type ExchangeRate int64
+
+// BaseExchangeRate is the rate of DefaultCurrency against itself.
+const BaseExchangeRate ExchangeRate = 100000000
+
+// ParseExchangeRate reads a decimal such as "0.92" or "109.5".
+func ParseExchangeRate(s string) (ExchangeRate, error) {
+	value, err := parseDecimal(s, exchangeRateScale, nil)
+	if err != nil {
+		return 0, err
+	}
+	return ExchangeRate(value), nil
+}
+
+func (r ExchangeRate) String() string {
+	return formatDecimal(int64(r), exchangeRateScale)
+}
+
+func (r *ExchangeRate) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return r.parse(string(v))
+	case string:
+		return r.parse(v)
+	default:
+		return fmt.Errorf("money: cannot scan %T into an exchange rate", src)
+	}
+}
+
+func (r *ExchangeRate) parse(s string) error {
+	parsed, err := ParseExchangeRate(s)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+func (r ExchangeRate) Value() (driver.Value, error) {
+	return r.String(), nil
+}
+
+func (r ExchangeRate) MarshalJSON() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a quoted decimal string.
+func (r *ExchangeRate) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return ErrInvalidAmount
+		}
+		s = unquoted
+	}
+	return r.parse(s)
+}
+
+// Convert prices m in another currency. fromRate is the rate of m's currency
+// and toRate that of currency, both against DefaultCurrency.
+func (m Money) Convert(fromRate, toRate ExchangeRate, currency string, mode RoundingMode) Money {
+	num := int64(toRate) * pow10(MinorUnits(currency))
+	den := int64(fromRate) * pow10(MinorUnits(m.currencyOrDefault()))
+	return Money{Minor: mulDiv(m.Minor, num, den, mode), Currency: currency}
+}
+
+// WithCurrency reads an amount scanned from a DECIMAL column, and so taken to
+// be in DefaultCurrency, as an amount in the currency stored beside it.
+func (m Money) WithCurrency(currency string) Money {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	shift := MinorUnits(currency) - MinorUnits(m.currencyOrDefault())
+	minor := m.Minor
+	switch {
+	case shift > 0:
+		minor *= pow10(shift)
+	case shift < 0:
+		minor /= pow10(-shift)
+	}
+	return Money{Minor: minor, Currency: strings.ToUpper(currency)}
+}
+
+func (m Money) currencyOrDefault() string {
+	if m.Currency == "" {
+		return DefaultCurrency
+	}
+	return m.Currency
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
@@ -0,0 +1,232 @@
+// Package money holds monetary amounts as integer minor units so that balances,
+// fees and transfers are exact. Amounts and rates are stored in DECIMAL columns
+// and sent over JSON as plain decimal numbers (12.50), exactly as before.
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is assumed for amounts read from columns that carry no currency of their own.
+const DefaultCurrency = "USD"
+
+var (
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrTooPrecise       = errors.New("amount has more decimal places than the currency allows")
+)
+
+// Minor unit exponents that differ from the usual two decimal places
+var minorUnits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// MinorUnits returns the number of decimal places used by currency.
+func MinorUnits(currency string) int {
+	if n, ok := minorUnits[strings.ToUpper(currency)]; ok {
+		return n
+	}
+	return 2
+}
+
+// Money is an amount in the minor units of its currency (cents for USD).
+// An empty Currency means "not yet known" and takes the currency of whatever
+// it is combined with; amounts scanned from the database default to USD.
+type Money struct {
+	Minor    int64
+	Currency string
+}
+
+func New(minor int64, currency string) Money {
+	return Money{Minor: minor, Currency: currency}
+}
+
+// Parse reads a decimal string such as "12.5" or "-0.07". Digits beyond the
+// currency's minor units are rejected unless they are zeros.
+func Parse(s, currency string) (Money, error) {
+	minor, err := parseDecimal(s, MinorUnits(currency), nil)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Minor: minor, Currency: currency}, nil
+}
+
+// FromFloat converts a value that has already been through a float64, such as
+// a number taken from a generic JSON map. It rounds half up to minor units.
+func FromFloat(f float64, currency string) Money {
+	mode := RoundHalfUp
+	minor, err := parseDecimal(strconv.FormatFloat(f, 'f', -1, 64), MinorUnits(currency), &mode)
+	if err != nil {
+		minor = int64(math.Round(f * math.Pow10(MinorUnits(currency))))
+	}
+	return Money{Minor: minor, Currency: currency}
+}
+
+func (m Money) currencyWith(o Money) string {
+	switch {
+	case m.Currency == "":
+		return o.Currency
+	case o.Currency == "" || strings.EqualFold(m.Currency, o.Currency):
+		return m.Currency
+	}
+	panic(fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency))
+}
+
+// Add returns m + o. Combining two different currencies is a programming error and panics.
+func (m Money) Add(o Money) Money {
+	return Money{Minor: m.Minor + o.Minor, Currency: m.currencyWith(o)}
+}
+
+// Sub returns m - o. Combining two different currencies is a programming error and panics.
+func (m Money) Sub(o Money) Money {
+	return Money{Minor: m.Minor - o.Minor, Currency: m.currencyWith(o)}
+}
+
+func (m Money) Neg() Money {
+	return Money{Minor: -m.Minor, Currency: m.Currency}
+}
+
+func (m Money) Abs() Money {
+	if m.Minor < 0 {
+		return m.Neg()
+	}
+	return m
+}
+
+// Mul multiplies m by a whole number, e.g. a per-worker price by the number of workers.
+func (m Money) Mul(n int64) Money {
+	return Money{Minor: m.Minor * n, Currency: m.Currency}
+}
+
+// ApplyRate returns r percent of m rounded to minor units with mode.
+func (m Money) ApplyRate(r Rate, mode RoundingMode) Money {
+	return Money{Minor: mulDiv(m.Minor, int64(r), 100*rateScale, mode), Currency: m.Currency}
+}
+
+// Prorate returns the part of m that part is of whole, rounded to minor units
+// with mode, e.g. the fee on a partial payment of a priced amount. whole must
+// be positive.
+func (m Money) Prorate(part, whole Money, mode RoundingMode) Money {
+	return Money{Minor: mulDiv(m.Minor, part.Minor, whole.Minor, mode), Currency: m.Currency}
+}
+
+// Cmp returns -1, 0 or +1 depending on whether m is less than, equal to or greater than o.
+func (m Money) Cmp(o Money) int {
+	m.currencyWith(o)
+	switch {
+	case m.Minor < o.Minor:
+		return -1
+	case m.Minor > o.Minor:
+		return 1
+	}
+	return 0
+}
+
+func (m Money) Equal(o Money) bool       { return m.Cmp(o) == 0 }
+func (m Money) LessThan(o Money) bool    { return m.Cmp(o) < 0 }
+func (m Money) GreaterThan(o Money) bool { return m.Cmp(o) > 0 }
+func (m Money) IsZero() bool             { return m.Minor == 0 }
+func (m Money) IsPositive() bool         { return m.Minor > 0 }
+func (m Money) IsNegative() bool         { return m.Minor < 0 }
+
+func Min(a, b Money) Money {
+	if a.LessThan(b) {
+		return a
+	}
+	return b
+}
+
+func Max(a, b Money) Money {
+	if a.GreaterThan(b) {
+		return a
+	}
+	return b
+}
+
+// String formats the amount as a plain decimal with the currency's minor units, e.g. "12.50".
+func (m Money) String() string {
+	return formatDecimal(m.Minor, MinorUnits(m.Currency))
+}
+
+// Scan implements sql.Scanner for DECIMAL columns.
+func (m *Money) Scan(src interface{}) error {
+	if m.Currency == "" {
+		m.Currency = DefaultCurrency
+	}
+	scale := MinorUnits(m.Currency)
+
+	switch v := src.(type) {
+	case nil:
+		m.Minor = 0
+	case []byte:
+		minor, err := parseDecimal(string(v), scale, nil)
+		if err != nil {
+			return err
+		}
+		m.Minor = minor
+	case string:
+		minor, err := parseDecimal(v, scale, nil)
+		if err != nil {
+			return err
+		}
+		m.Minor = minor
+	case int64:
+		m.Minor = v * int64(math.Pow10(scale))
+	case float64:
+		m.Minor = FromFloat(v, m.Currency).Minor
+	default:
+		return fmt.Errorf("money: cannot scan %T", src)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer; the amount is sent as a decimal string so
+// Postgres stores it without passing through a float.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a quoted decimal string.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return ErrInvalidAmount
+		}
+		s = unquoted
+	}
+	if m.Currency == "" {
+		m.Currency = DefaultCurrency
+	}
+
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return ErrInvalidAmount
+		}
+		m.Minor = FromFloat(f, m.Currency).Minor
+		return nil
+	}
+
+	minor, err := parseDecimal(s, MinorUnits(m.Currency), nil)
+	if err != nil {
+		return err
+	}
+	m.Minor = minor
+	return nil
+}
@@ -0,0 +1,245 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestRoundingModes(t *testing.T) {
+	tests := []struct {
+		name  string
+		minor int64
+		rate  Rate
+		mode  RoundingMode
+		want  int64
+	}{
+		{"half up rounds a half away from zero", 25, 5000, RoundHalfUp, 13},
+		{"half up rounds a negative half away from zero", -25, 5000, RoundHalfUp, -13},
+		{"half up keeps below a half", 174, 1000, RoundHalfUp, 17},
+		{"half even rounds a half down to even", 25, 5000, RoundHalfEven, 12},
+		{"half even rounds a half up to even", 35, 5000, RoundHalfEven, 18},
+		{"half even rounds a negative half to even", -25, 5000, RoundHalfEven, -12},
+		{"half even rounds above a half up", 176, 1000, RoundHalfEven, 18},
+		{"down truncates", 179, 1000, RoundDown, 17},
+		{"down truncates toward zero", -179, 1000, RoundDown, -17},
+		{"up rounds any remainder away", 171, 1000, RoundUp, 18},
+		{"up rounds a negative remainder away", -171, 1000, RoundUp, -18},
+		{"exact results are not rounded", 200, 1000, RoundUp, 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := New(tt.minor, "USD").ApplyRate(tt.rate, tt.mode)
+			if got.Minor != tt.want || got.Currency != "USD" {
+				t.Errorf("%d × %s%% = %+v, want %d USD", tt.minor, tt.rate, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromFloat(t *testing.T) {
+	tests := []struct {
+		f        float64
+		currency string
+		want     int64
+	}{
+		{12.5, "USD", 1250},
+		{12.345, "USD", 1235},
+		{1.005, "USD", 101},
+		{0.1 + 0.2, "USD", 30},
+		{-2.675, "USD", -268},
+		{1234.5, "JPY", 1235},
+		{1.2345, "KWD", 1235},
+		{0, "USD", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v %s", tt.f, tt.currency), func(t *testing.T) {
+			got := FromFloat(tt.f, tt.currency)
+			if got.Minor != tt.want || got.Currency != tt.currency {
+				t.Errorf("FromFloat(%v, %s) = %+v, want %d", tt.f, tt.currency, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   Money
+		fromRate string
+		toRate   string
+		currency string
+		mode     RoundingMode
+		want     int64
+	}{
+		{"USD to EUR", New(10000, "USD"), "1", "0.92", "EUR", RoundHalfUp, 9200},
+		{"USD to JPY drops the minor units", New(1000, "USD"), "1", "149.5", "JPY", RoundHalfUp, 1495},
+		{"JPY to USD adds minor units", New(1000, "JPY"), "150", "1", "USD", RoundHalfUp, 667},
+		{"JPY to USD rounded down", New(1000, "JPY"), "150", "1", "USD", RoundDown, 666},
+		{"USD to KWD has three decimals", New(100, "USD"), "1", "0.30712345", "KWD", RoundHalfUp, 307},
+		{"EUR to GBP goes through both rates", New(10000, "EUR"), "0.92", "0.79", "GBP", RoundHalfUp, 8587},
+		{"no currency is read as the default", New(10000, ""), "1", "0.92", "EUR", RoundHalfUp, 9200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fromRate, err := ParseExchangeRate(tt.fromRate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			toRate, err := ParseExchangeRate(tt.toRate)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := tt.amount.Convert(fromRate, toRate, tt.currency, tt.mode)
+			if got.Minor != tt.want || got.Currency != tt.currency {
+				t.Errorf("Convert = %+v, want %d %s", got, tt.want, tt.currency)
+			}
+		})
+	}
+}
+
+func TestWithCurrency(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   Money
+		currency string
+		want     Money
+	}{
+		{"same exponent keeps minor units", New(1250, "USD"), "EUR", New(1250, "EUR")},
+		{"to no minor units", New(125000, "USD"), "JPY", New(1250, "JPY")},
+		{"to no minor units truncates", New(1299, "USD"), "JPY", New(12, "JPY")},
+		{"to three decimals", New(123, "USD"), "KWD", New(1230, "KWD")},
+		{"from three decimals to none", New(5000, "KWD"), "JPY", New(5, "JPY")},
+		{"from none to two decimals", New(1250, "JPY"), "USD", New(125000, "USD")},
+		{"no currency is read as the default", New(1250, ""), "JPY", New(12, "JPY")},
+		{"an empty target is the default", New(1250, "USD"), "", New(1250, "USD")},
+		{"the target is upper-cased", New(1250, "USD"), "eur", New(1250, "EUR")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.amount.WithCurrency(tt.currency)
+			if got != tt.want {
+				t.Errorf("%+v.WithCurrency(%q) = %+v, want %+v", tt.amount, tt.currency, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRates(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount Money
+		rate   string
+		want   int64
+	}{
+		{"whole percent", New(10000, "USD"), "3", 300},
+		{"fractional percent", New(10000, "USD"), "2.5", 250},
+		{"basis point", New(10000, "USD"), "0.01", 1},
+		{"rounds half up", New(50, "USD"), "3", 2},
+		{"rounds below a half down", New(49, "USD"), "3", 1},
+		{"hundred percent", New(1234, "USD"), "100", 1234},
+		{"no minor units", New(1000, "JPY"), "2.5", 25},
+		{"zero rate", New(1000, "USD"), "0", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, err := ParseRate(tt.rate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := tt.amount.ApplyRate(rate, FeeRounding)
+			if got.Minor != tt.want || got.Currency != tt.amount.Currency {
+				t.Errorf("%s%% of %+v = %+v, want %d", tt.rate, tt.amount, got, tt.want)
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		percent float64
+		want    Rate
+	}{
+		{3, 300},
+		{2.5, 250},
+		{2.555, 256},
+		{0.004, 0},
+	} {
+		if got := RateFromFloat(tt.percent); got != tt.want {
+			t.Errorf("RateFromFloat(%v) = %d, want %d", tt.percent, got, tt.want)
+		}
+	}
+}
+
+func TestProrate(t *testing.T) {
+	tests := []struct {
+		name  string
+		fee   Money
+		part  Money
+		whole Money
+		want  int64
+	}{
+		{"full share", New(50, "USD"), New(1000, "USD"), New(1000, "USD"), 50},
+		{"half share", New(50, "USD"), New(500, "USD"), New(1000, "USD"), 25},
+		{"rounds half up", New(25, "USD"), New(500, "USD"), New(1000, "USD"), 13},
+		{"third share", New(100, "USD"), New(1000, "USD"), New(3000, "USD"), 33},
+		{"nothing paid", New(100, "USD"), New(0, "USD"), New(3000, "USD"), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.fee.Prorate(tt.part, tt.whole, FeeRounding)
+			if got.Minor != tt.want || got.Currency != tt.fee.Currency {
+				t.Errorf("Prorate = %+v, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCurrencyMismatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		op        func(a, b Money) Money
+		a, b      Money
+		want      Money
+		wantPanic bool
+	}{
+		{"add same currency", Money.Add, New(100, "USD"), New(50, "USD"), New(150, "USD"), false},
+		{"add ignores case", Money.Add, New(100, "USD"), New(50, "usd"), New(150, "USD"), false},
+		{"add to no currency", Money.Add, New(100, ""), New(50, "EUR"), New(150, "EUR"), false},
+		{"add no currency", Money.Add, New(100, "EUR"), New(50, ""), New(150, "EUR"), false},
+		{"sub same currency", Money.Sub, New(100, "USD"), New(150, "USD"), New(-50, "USD"), false},
+		{"add different currencies", Money.Add, New(100, "USD"), New(50, "EUR"), Money{}, true},
+		{"sub different currencies", Money.Sub, New(100, "JPY"), New(50, "USD"), Money{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				if !tt.wantPanic {
+					if r != nil {
+						t.Errorf("unexpected panic: %v", r)
+					}
+					return
+				}
+				err, ok := r.(error)
+				if !ok || !errors.Is(err, ErrCurrencyMismatch) {
+					t.Errorf("panic = %v, want %v", r, ErrCurrencyMismatch)
+				}
+			}()
+
+			got := tt.op(tt.a, tt.b)
+			if tt.wantPanic {
+				t.Fatalf("got %+v, want a panic", got)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package money
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Rate decimal places: percentages are stored as DECIMAL(5,2), so a Rate is a
+// whole number of basis points and 2.50% is Rate(250).
+const rateScale = 100
+
+type Rate int64
+
+// ParseRate reads a percentage such as "2.5" into basis points.
+func ParseRate(s string) (Rate, error) {
+	bps, err := parseDecimal(s, 2, nil)
+	if err != nil {
+		return 0, err
+	}
+	return Rate(bps), nil
+}
+
+// RateFromFloat converts a percentage taken from a float, rounding to the nearest basis point.
+func RateFromFloat(percent float64) Rate {
+	mode := RoundHalfUp
+	bps, err := parseDecimal(strconv.FormatFloat(percent, 'f', -1, 64), 2, &mode)
+	if err != nil {
+		bps = int64(math.Round(percent * rateScale))
+	}
+	return Rate(bps)
+}
+
+// String formats the rate as a percentage, e.g. "2.50".
+func (r Rate) String() string {
+	return formatDecimal(int64(r), 2)
+}
+
+func (r *Rate) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*r = 0
+	case []byte:
+		return r.parse(string(v))
+	case string:
+		return r.parse(v)
+	case int64:
+		*r = Rate(v * rateScale)
+	case float64:
+		*r = RateFromFloat(v)
+	default:
+		return fmt.Errorf("money: cannot scan %T into a rate", src)
+	}
+	return nil
+}
+
+func (r *Rate) parse(s string) error {
+	parsed, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+func (r Rate) Value() (driver.Value, error) {
+	return r.String(), nil
+}
+
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return ErrInvalidAmount
+		}
+		s = unquoted
+	}
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return ErrInvalidAmount
+		}
+		*r = RateFromFloat(f)
+		return nil
+	}
+	return r.parse(s)
+}
@@ -0,0 +1,142 @@
+package money
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+type RoundingMode int
+
+const (
+	// RoundHalfUp rounds halves away from zero: 0.125 becomes 0.13, -0.125 becomes -0.13.
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven rounds halves to the even neighbour: 0.125 becomes 0.12, 0.135 becomes 0.14.
+	RoundHalfEven
+	// RoundDown truncates toward zero.
+	RoundDown
+	// RoundUp rounds any remainder away from zero.
+	RoundUp
+)
+
+// FeeRounding is the rule for every fee and commission the platform charges.
+// The fee is rounded half up to the minor unit and the payee receives the gross
+// amount minus that rounded fee, so gross = fee + net holds to the cent.
+const FeeRounding = RoundHalfUp
+
+// mulDiv returns a*b/den rounded with mode. den must be positive.
+func mulDiv(a, b, den int64, mode RoundingMode) int64 {
+	n := new(big.Int).Mul(big.NewInt(a), big.NewInt(b))
+	q, r := new(big.Int).QuoRem(n, big.NewInt(den), new(big.Int))
+	if r.Sign() == 0 {
+		return q.Int64()
+	}
+
+	twice := new(big.Int).Abs(r)
+	twice.Lsh(twice, 1)
+	half := twice.Cmp(big.NewInt(den))
+
+	if roundsAway(mode, half, q.Bit(0) == 1) {
+		if n.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q.Int64()
+}
+
+// roundsAway decides whether a truncated value moves away from zero. half compares
+// the discarded remainder with one half (-1 below, 0 exactly, +1 above).
+func roundsAway(mode RoundingMode, half int, odd bool) bool {
+	switch mode {
+	case RoundUp:
+		return true
+	case RoundDown:
+		return false
+	case RoundHalfEven:
+		return half > 0 || (half == 0 && odd)
+	default:
+		return half >= 0
+	}
+}
+
+// parseDecimal turns a decimal string into an integer scaled by 10^scale. Extra
+// non-zero digits are an error when mode is nil and are rounded otherwise.
+func parseDecimal(s string, scale int, mode *RoundingMode) (int64, error) {
+	s = strings.TrimSpace(s)
+	neg := false
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+
+	intPart, frac, _ := strings.Cut(s, ".")
+	if intPart == "" && frac == "" || !isDigits(intPart) || !isDigits(frac) {
+		return 0, ErrInvalidAmount
+	}
+
+	var extra string
+	if len(frac) > scale {
+		frac, extra = frac[:scale], frac[scale:]
+	}
+	frac += strings.Repeat("0", scale-len(frac))
+
+	digits := strings.TrimLeft(intPart+frac, "0")
+	var value int64
+	if digits != "" {
+		parsed, err := strconv.ParseInt(digits, 10, 64)
+		if err != nil {
+			return 0, ErrInvalidAmount
+		}
+		value = parsed
+	}
+
+	if strings.Trim(extra, "0") != "" {
+		if mode == nil {
+			return 0, ErrTooPrecise
+		}
+		half := 0
+		switch {
+		case extra[0] < '5':
+			half = -1
+		case extra[0] > '5' || strings.Trim(extra[1:], "0") != "":
+			half = 1
+		}
+		if roundsAway(*mode, half, value%2 == 1) {
+			value++
+		}
+	}
+
+	if neg {
+		value = -value
+	}
+	return value, nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func formatDecimal(value int64, scale int) string {
+	sign := ""
+	abs := uint64(value)
+	if value < 0 {
+		sign = "-"
+		abs = uint64(-value)
+	}
+
+	digits := strconv.FormatUint(abs, 10)
+	if scale == 0 {
+		return sign + digits
+	}
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
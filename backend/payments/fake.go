@@ -0,0 +1,132 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"microjob-backend/money"
+)
+
+// FakeSignatureHeader carries the webhook signature in the format built by Sign.
+const FakeSignatureHeader = "X-Fake-Signature"
+
+var ErrUnknownCheckout = errors.New("unknown checkout")
+
+// FakeGateway is an in-process provider for development and tests. Checkouts
+// are kept in memory and "paid" by calling Simulate, which produces a signed
+// webhook exactly as a real provider would send one.
+type FakeGateway struct {
+	secret      []byte
+	checkoutURL string
+
+	mu        sync.Mutex
+	checkouts map[string]money.Money
+}
+
+func NewFakeGateway(secret, checkoutURL string) *FakeGateway {
+	return &FakeGateway{
+		secret:      []byte(secret),
+		checkoutURL: checkoutURL,
+		checkouts:   make(map[string]money.Money),
+	}
+}
+
+type fakeEvent struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Reference string `json:"reference"`
+	Amount    string `json:"amount"`
+	Currency  string `json:"currency"`
+	Created   int64  `json:"created"`
+}
+
+func (g *FakeGateway) Name() string {
+	return "fake"
+}
+
+func (g *FakeGateway) CreateCheckout(ctx context.Context, req CheckoutRequest) (*Checkout, error) {
+	reference := "fake_" + uuid.New().String()
+
+	g.mu.Lock()
+	g.checkouts[reference] = req.Amount
+	g.mu.Unlock()
+
+	expiresAt := time.Now().Add(time.Hour)
+	return &Checkout{
+		ProviderReference: reference,
+		PaymentURL:        g.checkoutURL + "/" + reference,
+		ExpiresAt:         &expiresAt,
+	}, nil
+}
+
+func (g *FakeGateway) VerifyWebhook(payload []byte, headers http.Header) (*WebhookEvent, error) {
+	_, err := VerifySignature(g.secret, payload, headers.Get(FakeSignatureHeader), time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var event fakeEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	amount, err := money.Parse(event.Amount, event.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WebhookEvent{
+		EventID:           event.ID,
+		Type:              event.Type,
+		ProviderReference: event.Reference,
+		Amount:            amount,
+		OccurredAt:        time.Unix(event.Created, 0),
+	}, nil
+}
+
+func (g *FakeGateway) Refund(ctx context.Context, req RefundRequest) (*RefundResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	paid, ok := g.checkouts[req.ProviderReference]
+	if !ok {
+		return nil, ErrUnknownCheckout
+	}
+	if req.Amount.GreaterThan(paid) {
+		return nil, errors.New("refund exceeds the paid amount")
+	}
+	g.checkouts[req.ProviderReference] = paid.Sub(req.Amount)
+
+	return &RefundResult{ProviderReference: req.ProviderReference, Status: "completed"}, nil
+}
+
+// Simulate settles a checkout with the given event type and returns the
+// signed webhook the provider would deliver for it.
+func (g *FakeGateway) Simulate(reference, eventType string) ([]byte, http.Header, error) {
+	g.mu.Lock()
+	amount, ok := g.checkouts[reference]
+	g.mu.Unlock()
+	if !ok {
+		return nil, nil, ErrUnknownCheckout
+	}
+
+	payload, err := json.Marshal(fakeEvent{
+		ID:        "evt_" + uuid.New().String(),
+		Type:      eventType,
+		Reference: reference,
+		Amount:    amount.String(),
+		Currency:  amount.Currency,
+		Created:   time.Now().Unix(),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	headers := http.Header{}
+	headers.Set(FakeSignatureHeader, Sign(g.secret, payload, time.Now()))
+	return payload, headers, nil
+}
@@ -0,0 +1,58 @@
+package payments
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"microjob-backend/money"
+)
+
+func TestFakeGatewayWebhook(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount money.Money
+	}{
+		{"two decimals", money.New(2500, "USD")},
+		{"no minor units", money.New(1000, "JPY")},
+		{"three decimals", money.New(12345, "KWD")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gateway := NewFakeGateway("whsec_test", "http://localhost/checkout")
+			checkout, err := gateway.CreateCheckout(context.Background(), CheckoutRequest{Amount: tt.amount})
+			if err != nil {
+				t.Fatal(err)
+			}
+			payload, headers, err := gateway.Simulate(checkout.ProviderReference, EventPaymentSucceeded)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			event, err := gateway.VerifyWebhook(payload, headers)
+			if err != nil {
+				t.Fatalf("VerifyWebhook: %v", err)
+			}
+			if event.Type != EventPaymentSucceeded || event.ProviderReference != checkout.ProviderReference {
+				t.Errorf("event = %+v", event)
+			}
+			if event.Amount != tt.amount {
+				t.Errorf("Amount = %+v, want %+v", event.Amount, tt.amount)
+			}
+
+			other := NewFakeGateway("whsec_other", "http://localhost/checkout")
+			if _, err := other.VerifyWebhook(payload, headers); err != ErrInvalidSignature {
+				t.Errorf("another secret: err = %v, want %v", err, ErrInvalidSignature)
+			}
+			if _, err := gateway.VerifyWebhook(payload, http.Header{}); err != ErrInvalidSignature {
+				t.Errorf("unsigned: err = %v, want %v", err, ErrInvalidSignature)
+			}
+		})
+	}
+
+	gateway := NewFakeGateway("whsec_test", "http://localhost/checkout")
+	if _, _, err := gateway.Simulate("fake_missing", EventPaymentSucceeded); err != ErrUnknownCheckout {
+		t.Errorf("unknown checkout: err = %v, want %v", err, ErrUnknownCheckout)
+	}
+}
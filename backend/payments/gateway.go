@@ -0,0 +1,126 @@
+// Package payments defines the interface deposit providers implement and a
+// registry the services use to look them up by name.
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"microjob-backend/config"
+	"microjob-backend/money"
+)
+
+var (
+	ErrUnknownGateway   = errors.New("unknown payment gateway")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrMissingSecret    = errors.New("FAKE_PAYMENT_SECRET must be set to enable the fake payment gateway")
+)
+
+// Webhook event types every gateway maps its own events onto
+const (
+	EventPaymentSucceeded = "payment.succeeded"
+	EventPaymentFailed    = "payment.failed"
+	EventPaymentRefunded  = "payment.refunded"
+)
+
+// PaymentGateway is a provider that takes payments from users on our behalf.
+type PaymentGateway interface {
+	Name() string
+	// CreateCheckout starts a payment and returns where to send the user to pay.
+	CreateCheckout(ctx context.Context, req CheckoutRequest) (*Checkout, error)
+	// VerifyWebhook authenticates a raw webhook body and parses it into an event.
+	// Bodies whose signed timestamp is too old fail with ErrSignatureExpired.
+	VerifyWebhook(payload []byte, headers http.Header) (*WebhookEvent, error)
+	// Refund returns a completed payment, fully or in part, to the payer.
+	Refund(ctx context.Context, req RefundRequest) (*RefundResult, error)
+}
+
+type CheckoutRequest struct {
+	Reference   string // our deposit ID, echoed back by the provider
+	UserID      string
+	Amount      money.Money
+	Description string
+	ReturnURL   string
+	CancelURL   string
+}
+
+type Checkout struct {
+	ProviderReference string
+	PaymentURL        string
+	ExpiresAt         *time.Time
+}
+
+type WebhookEvent struct {
+	EventID           string
+	Type              string
+	ProviderReference string
+	Amount            money.Money
+	OccurredAt        time.Time
+}
+
+type RefundRequest struct {
+	ProviderReference string
+	Amount            money.Money
+	Reason            string
+}
+
+type RefundResult struct {
+	ProviderReference string
+	Status            string // "pending", "completed"
+}
+
+// Registry holds the gateways enabled in this deployment.
+type Registry struct {
+	gateways map[string]PaymentGateway
+}
+
+func NewRegistry(gateways ...PaymentGateway) *Registry {
+	r := &Registry{gateways: make(map[string]PaymentGateway)}
+	for _, gateway := range gateways {
+		r.Register(gateway)
+	}
+	return r
+}
+
+func (r *Registry) Register(gateway PaymentGateway) {
+	r.gateways[gateway.Name()] = gateway
+}
+
+func (r *Registry) Get(name string) (PaymentGateway, error) {
+	gateway, ok := r.gateways[name]
+	if !ok {
+		return nil, ErrUnknownGateway
+	}
+	return gateway, nil
+}
+
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.gateways))
+	for name := range r.gateways {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewRegistryFromConfig registers the gateways enabled in cfg. The fake gateway
+// is returned as well, or nil when disabled, for its development checkout route.
+// Enabling it without a secret of its own is an error rather than a gateway
+// anyone can sign webhooks for.
+func NewRegistryFromConfig(cfg *config.Config) (*Registry, *FakeGateway, error) {
+	registry := NewRegistry()
+
+	var fake *FakeGateway
+	if cfg.FakePaymentsEnabled && !cfg.IsProduction() {
+		if cfg.FakePaymentSecret == "" {
+			return nil, nil, ErrMissingSecret
+		}
+		fake = NewFakeGateway(cfg.FakePaymentSecret, "/api/payments/fake/checkout")
+		registry.Register(fake)
+	}
+
+	return registry, fake, nil
+}
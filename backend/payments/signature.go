@@ -0,0 +1,75 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureTolerance is how far the signed timestamp may be from now before a
+// webhook is treated as a replay.
+const SignatureTolerance = 5 * time.Minute
+
+var ErrSignatureExpired = errors.New("webhook signature timestamp is outside the tolerance")
+
+// Sign builds a "t=<unix>,v1=<hex>" signature header, where v1 is the
+// HMAC-SHA256 of "<unix>.<payload>". Signing the timestamp stops an attacker
+// from replaying an old body with a fresh time.
+func Sign(secret, payload []byte, at time.Time) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(computeHMAC(secret, timestamp, payload))
+}
+
+// VerifySignature checks a header produced by Sign and returns the signed time.
+func VerifySignature(secret, payload []byte, header string, now time.Time) (time.Time, error) {
+	var timestamp string
+	var signatures [][]byte
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			if signature, err := hex.DecodeString(value); err == nil {
+				signatures = append(signatures, signature)
+			}
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return time.Time{}, ErrInvalidSignature
+	}
+
+	expected := computeHMAC(secret, timestamp, payload)
+	valid := false
+	for _, signature := range signatures {
+		if hmac.Equal(signature, expected) {
+			valid = true
+		}
+	}
+	if !valid {
+		return time.Time{}, ErrInvalidSignature
+	}
+
+	signedAt := time.Unix(unix, 0)
+	if age := now.Sub(signedAt); age > SignatureTolerance || age < -SignatureTolerance {
+		return time.Time{}, ErrSignatureExpired
+	}
+
+	return signedAt, nil
+}
+
+func computeHMAC(secret []byte, timestamp string, payload []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,55 @@
+package payments
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	secret := []byte("whsec_test")
+	payload := []byte(`{"id":"evt_1","type":"payment.succeeded"}`)
+	signedAt := time.Unix(1700000000, 0)
+	valid := Sign(secret, payload, signedAt)
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+
+	tests := []struct {
+		name    string
+		secret  []byte
+		payload []byte
+		header  string
+		now     time.Time
+		wantErr error
+	}{
+		{"valid", secret, payload, valid, signedAt, nil},
+		{"valid within the tolerance", secret, payload, valid, signedAt.Add(SignatureTolerance), nil},
+		{"valid from a clock slightly ahead", secret, payload, valid, signedAt.Add(-time.Minute), nil},
+		{"spaces around the parts", secret, payload, "t=" + timestamp + ", v1=" + valid[len("t="+timestamp+",v1="):],
+			signedAt, nil},
+		{"one of several signatures matches", secret, payload, valid + ",v1=00ff", signedAt, nil},
+		{"tampered payload", secret, []byte(`{"id":"evt_1","type":"payment.refunded"}`), valid, signedAt,
+			ErrInvalidSignature},
+		{"wrong secret", []byte("whsec_other"), payload, valid, signedAt, ErrInvalidSignature},
+		{"replayed with a fresh timestamp", secret, payload,
+			"t=" + strconv.FormatInt(signedAt.Unix()+60, 10) + valid[len("t="+timestamp):], signedAt, ErrInvalidSignature},
+		{"too old", secret, payload, valid, signedAt.Add(SignatureTolerance + time.Second), ErrSignatureExpired},
+		{"too far in the future", secret, payload, valid, signedAt.Add(-SignatureTolerance - time.Second),
+			ErrSignatureExpired},
+		{"no timestamp", secret, payload, valid[len("t="+timestamp+","):], signedAt, ErrInvalidSignature},
+		{"no signature", secret, payload, "t=" + timestamp, signedAt, ErrInvalidSignature},
+		{"signature not hex", secret, payload, "t=" + timestamp + ",v1=zz", signedAt, ErrInvalidSignature},
+		{"empty header", secret, payload, "", signedAt, ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifySignature(tt.secret, tt.payload, tt.header, tt.now)
+			if err != tt.wantErr {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !got.Equal(signedAt) {
+				t.Errorf("signed at %v, want %v", got, signedAt)
+			}
+		})
+	}
+}
@@ -8,19 +8,51 @@ import (
 	"microjob-backend/cache"
 	"microjob-backend/handlers"
 	"microjob-backend/middleware"
+	"microjob-backend/payments"
 	"microjob-backend/services"
+	"microjob-backend/storage"
 )
 
-func Setup(app *fiber.App, db *database.DB, cfg *config.Config, redisClient *cache.RedisClient, cacheService *services.CacheService) {
+func Setup(app *fiber.App, db *database.DB, cfg *config.Config, redisClient *cache.RedisClient, cacheService *services.CacheService, store storage.Storage,
+	gateways *payments.Registry, fakeGateway *payments.FakeGateway) {
 	reservationService := services.NewReservationService(db)
-	workProofService := services.NewWorkProofService(db)
 	walletService := services.NewWalletService(db)
 	adminService := services.NewAdminService(db)
+	workProofService := services.NewWorkProofService(db.DB, adminService)
+	idempotencyService := services.NewIdempotencyService(db.DB)
+	velocityService := services.NewVelocityService(db.DB, redisClient)
+	alertService := services.NewAlertService(db.DB)
+	withdrawalService := services.NewWithdrawalService(db.DB, adminService, velocityService)
+	depositService := services.NewDepositService(db.DB, walletService, gateways)
+	webhookService := services.NewWebhookService(db.DB, gateways, depositService)
+	reconciliationService := services.NewReconciliationService(db.DB)
+	feeEngine := services.NewFeeEngine(db.DB)
+	exchangeService := services.NewExchangeService(db.DB)
+	chatService := services.NewChatService(db.DB, walletService, adminService, velocityService)
+	refundService := services.NewRefundService(db.DB)
+	disputeService := services.NewDisputeService(db.DB, workProofService, walletService)
+	fileService := services.NewFileService(db.DB, store)
+	fingerprintService := services.NewFingerprintService(db.DB, fileService, cfg.ProofFileHostList())
+	imageService := services.NewImageService(db.DB, fileService)
+	jobPricingService := services.NewJobPricingService(db.DB)
 
 	authHandler := handlers.NewAuthHandler(db, cfg, cacheService)
 	adminHandler := handlers.NewAdminHandler(db, cfg, cacheService)
-	jobHandler := handlers.NewJobHandler(db, cfg, cacheService)
-	walletHandler := handlers.NewWalletHandler(db, cfg)
+	jobHandler := handlers.NewJobHandler(db, cfg, cacheService, imageService)
+	withdrawalHandler := handlers.NewWithdrawalHandler(withdrawalService)
+	depositHandler := handlers.NewDepositHandler(depositService)
+	webhookHandler := handlers.NewWebhookHandler(webhookService, fakeGateway)
+	reconciliationHandler := handlers.NewReconciliationHandler(reconciliationService)
+	statementHandler := handlers.NewStatementHandler(walletService)
+	feeHandler := handlers.NewFeeHandler(feeEngine)
+	exchangeHandler := handlers.NewExchangeHandler(exchangeService)
+	chatHandler := handlers.NewChatHandler(chatService)
+	velocityHandler := handlers.NewVelocityHandler(velocityService, alertService)
+	refundHandler := handlers.NewRefundHandler(refundService)
+	disputeHandler := handlers.NewDisputeHandler(disputeService)
+	fingerprintHandler := handlers.NewFingerprintHandler(fingerprintService, workProofService)
+	fileHandler := handlers.NewFileHandler(fileService, store)
+	jobPricingHandler := handlers.NewJobPricingHandler(jobPricingService)
 	cronHandler := handlers.NewCronHandler(reservationService, workProofService, walletService, adminService)
 
 	// Health check
@@ -36,19 +68,79 @@ func Setup(app *fiber.App, db *database.DB, cfg *config.Config, redisClient *cac
 	auth.Post("/login", authHandler.Login)
 	auth.Post("/register", authHandler.Register)
 
+	// Payment provider webhooks (authenticated by signature)
+	webhooks := api.Group("/webhooks")
+	webhooks.Post("/payments/:provider", webhookHandler.HandleWebhook)
+
+	// Downloads through signed URLs of the local storage backend
+	api.Get("/files/content/*", fileHandler.ServeContent)
+
+	// Fake gateway checkout page stand-in for development
+	if fakeGateway != nil {
+		api.Post("/payments/fake/checkout/:reference", webhookHandler.CompleteFakeCheckout)
+	}
+
 	// Protected routes
 	protected := api.Group("", middleware.AuthMiddleware(cfg.JWTSecret))
 
+	// Money-moving endpoints accept an Idempotency-Key header so retries cannot pay twice
+	idempotent := middleware.Idempotency(idempotencyService)
+
 	// Admin routes
 	admin := protected.Group("/admin", middleware.AdminMiddleware())
 	admin.Get("/approval-settings", adminHandler.GetApprovalSettings)
 	admin.Put("/approval-settings", adminHandler.UpdateApprovalSettings)
 	admin.Get("/commission", adminHandler.GetCommissionSettings)
 	admin.Put("/commission", adminHandler.UpdateCommissionSettings)
+	admin.Get("/earnings-hold-settings", adminHandler.GetEarningsHoldSettings)
+	admin.Put("/earnings-hold-settings", adminHandler.UpdateEarningsHoldSettings)
+	admin.Get("/users/:userId/earning-holds", adminHandler.GetEarningHolds)
+	admin.Post("/users/:userId/earning-holds/release", idempotent, adminHandler.ReleaseEarningHolds)
+	admin.Get("/users/:userId/statement", statementHandler.GetUserStatement)
+	admin.Get("/wallet-reconciliation/runs", reconciliationHandler.GetReconciliationRuns)
+	admin.Post("/wallet-reconciliation/runs", reconciliationHandler.RunReconciliation)
+	admin.Get("/wallet-reconciliation/runs/:id", reconciliationHandler.GetReconciliationRun)
+	admin.Post("/wallet-reconciliation/runs/:id/correct", idempotent, reconciliationHandler.CorrectDiscrepancies)
+	admin.Get("/withdrawal-settings", adminHandler.GetWithdrawalSettings)
+	admin.Put("/withdrawal-settings", adminHandler.UpdateWithdrawalSettings)
+	admin.Post("/deposits/:id/refund", idempotent, depositHandler.RefundDeposit)
+	admin.Get("/transactions/:id/refunds", refundHandler.GetTransactionRefunds)
+	admin.Post("/transactions/:id/refund", idempotent, refundHandler.RefundTransaction)
+	admin.Get("/debts", refundHandler.GetDebts)
+	admin.Get("/payment-webhooks", webhookHandler.GetWebhookEvents)
+	admin.Post("/payment-webhooks/:id/retry", webhookHandler.RetryWebhookEvent)
+	admin.Get("/withdrawals", withdrawalHandler.GetWithdrawalQueue)
+	admin.Post("/withdrawals/:id/approve", idempotent, withdrawalHandler.ApproveWithdrawal)
+	admin.Post("/withdrawals/:id/reject", idempotent, withdrawalHandler.RejectWithdrawal)
+	admin.Get("/payout-batches", withdrawalHandler.GetPayoutBatches)
+	admin.Post("/payout-batches", idempotent, withdrawalHandler.CreatePayoutBatch)
+	admin.Get("/payout-batches/:id/export", withdrawalHandler.ExportPayoutBatch)
+	admin.Post("/payout-batches/:id/results", idempotent, withdrawalHandler.RecordPayoutResults)
 	admin.Get("/feature-settings", adminHandler.GetFeatureSettings)
 	admin.Post("/feature-settings", adminHandler.UpdateFeatureSettings)
 	admin.Get("/platform-fee", adminHandler.GetPlatformFeeSettings)
 	admin.Put("/platform-fee", adminHandler.UpdatePlatformFeeSettings)
+	admin.Post("/fee-simulations", feeHandler.SimulateFees)
+	admin.Get("/exchange-rates", exchangeHandler.GetExchangeRates)
+	admin.Post("/exchange-rates", exchangeHandler.SetExchangeRate)
+	admin.Get("/exchange-rates/:currency/history", exchangeHandler.GetRateHistory)
+	admin.Get("/chat-money-transfers", chatHandler.GetMoneyTransferQueue)
+	admin.Post("/chat-money-transfers/:id/resolve", idempotent, chatHandler.ResolveMoneyTransferDispute)
+	admin.Get("/job-pricing", jobPricingHandler.GetJobPricing)
+	admin.Put("/job-pricing", jobPricingHandler.UpdateJobPricing)
+	admin.Get("/velocity-limits", velocityHandler.GetVelocityLimits)
+	admin.Put("/velocity-limits", velocityHandler.UpdateVelocityLimits)
+	admin.Get("/alerts", velocityHandler.GetAlerts)
+	admin.Post("/alerts/:id/acknowledge", velocityHandler.AcknowledgeAlert)
+	admin.Get("/disputes", disputeHandler.GetDisputeQueue)
+	admin.Get("/disputes/:id", disputeHandler.AdminGetDispute)
+	admin.Post("/disputes/:id/take", disputeHandler.TakeDispute)
+	admin.Post("/disputes/:id/escalate", disputeHandler.EscalateDispute)
+	admin.Post("/disputes/:id/messages", disputeHandler.AdminAddMessage)
+	admin.Post("/disputes/:id/resolve", idempotent, disputeHandler.ResolveDispute)
+	admin.Get("/work-proofs/duplicates", fingerprintHandler.GetFlaggedWorkProofs)
+	admin.Get("/work-proofs/:id/duplicates", fingerprintHandler.AdminGetWorkProofDuplicates)
+	admin.Get("/files/:id/url", fileHandler.AdminGetDownloadURL)
 	admin.Get("/reservation-settings", adminHandler.GetReservationSettings)
 	admin.Post("/reservation-settings", adminHandler.UpdateReservationSettings)
 	admin.Get("/reservation-violations", adminHandler.GetReservationViolations)
@@ -61,8 +153,17 @@ func Setup(app *fiber.App, db *database.DB, cfg *config.Config, redisClient *cac
 	// Job routes
 	jobs := protected.Group("/jobs")
 	jobs.Get("/", jobHandler.GetJobs)
+	jobs.Post("/", idempotent, jobHandler.CreateJob)
+	jobs.Get("/pricing", jobPricingHandler.GetJobPricing)
+	jobs.Post("/quote", jobPricingHandler.QuoteJob)
 	jobs.Put("/", jobHandler.UpdateJobWorkers)
 	jobs.Post("/reserve", jobHandler.ReserveJob)
+	jobs.Post("/work-proofs", idempotent, jobHandler.SubmitWorkProof)
+	jobs.Post("/:id/cancel", idempotent, jobHandler.CancelJob)
+	jobs.Get("/:id/escrow", jobHandler.GetJobEscrow)
+	jobs.Get("/:id/work-proofs", jobHandler.GetWorkProofs)
+	jobs.Get("/:id/approval-policy", jobHandler.GetApprovalPolicy)
+	jobs.Put("/:id/approval-policy", jobHandler.UpdateApprovalPolicy)
 
 	// Reservation routes
 	reservations := protected.Group("/reservations")
@@ -74,13 +175,68 @@ func Setup(app *fiber.App, db *database.DB, cfg *config.Config, redisClient *cac
 
 	// Work proof routes
 	workProofs := protected.Group("/work-proofs")
-	workProofs.Post("/approve", jobHandler.ApproveWorkProof)
+	workProofs.Post("/approve", idempotent, jobHandler.ApproveWorkProof)
 	workProofs.Post("/reject", jobHandler.RejectWorkProof)
 	workProofs.Post("/request-revision", jobHandler.RequestRevision)
+	workProofs.Post("/bulk-review", idempotent, jobHandler.BulkReviewWorkProofs)
+	workProofs.Post("/:id/resubmit", idempotent, jobHandler.ResubmitWorkProof)
+	workProofs.Get("/:id/submissions", jobHandler.GetWorkProofSubmissions)
+	workProofs.Post("/:id/accept-rejection", jobHandler.AcceptWorkProofRejection)
+	workProofs.Post("/:id/cancel-revision", jobHandler.CancelWorkProofRevision)
+	workProofs.Get("/:id/events", jobHandler.GetWorkProofEvents)
+	workProofs.Post("/:id/dispute", disputeHandler.OpenDispute)
+	workProofs.Get("/:id/duplicates", fingerprintHandler.GetWorkProofDuplicates)
+
+	// Work proof dispute routes
+	disputes := protected.Group("/disputes")
+	disputes.Get("/:id", disputeHandler.GetDispute)
+	disputes.Post("/:id/messages", disputeHandler.AddMessage)
+
+	// File routes
+	files := protected.Group("/files")
+	files.Post("/", fileHandler.UploadFile)
+	files.Get("/:id", fileHandler.GetFile)
+	files.Get("/:id/url", fileHandler.GetDownloadURL)
 
-	// Wallet/Chat routes
+	// Fee quotes
+	protected.Get("/fees/quote", feeHandler.QuoteFee)
+
+	// Exchange rates
+	protected.Get("/exchange-rates", exchangeHandler.GetExchangeRates)
+
+	// Wallet routes
+	wallet := protected.Group("/wallet")
+	wallet.Get("/statement", statementHandler.GetStatement)
+	wallet.Get("/balances", exchangeHandler.GetBalances)
+	wallet.Get("/conversions", exchangeHandler.GetConversions)
+	wallet.Get("/convert/quote", exchangeHandler.QuoteConversion)
+	wallet.Post("/convert", idempotent, exchangeHandler.Convert)
+	wallet.Get("/debts", refundHandler.GetWalletDebts)
+
+	// Chat money transfer routes
 	chat := protected.Group("/chat")
-	chat.Post("/money-transfer", walletHandler.ProcessMoneyTransfer)
+	chat.Post("/money-transfer", idempotent, chatHandler.SendMoneyTransfer)
+	chat.Post("/money-request", idempotent, chatHandler.RequestMoneyTransfer)
+	chat.Get("/:chatId/money-transfers", chatHandler.GetMoneyTransfers)
+	chat.Get("/money-transfers/:id", chatHandler.GetMoneyTransfer)
+	chat.Post("/money-transfers/:id/accept", idempotent, chatHandler.AcceptMoneyTransfer)
+	chat.Post("/money-transfers/:id/decline", chatHandler.DeclineMoneyTransfer)
+	chat.Post("/money-transfers/:id/cancel", chatHandler.CancelMoneyTransfer)
+	chat.Post("/money-transfers/:id/dispute", chatHandler.DisputeMoneyTransfer)
+	chat.Post("/money-transfers/:id/refund", idempotent, chatHandler.RefundMoneyTransfer)
+
+	// Deposit routes
+	deposits := protected.Group("/deposits")
+	deposits.Get("/", depositHandler.GetDeposits)
+	deposits.Post("/", idempotent, depositHandler.CreateDeposit)
+	deposits.Get("/gateways", depositHandler.GetGateways)
+	deposits.Get("/:id", depositHandler.GetDeposit)
+
+	// Withdrawal routes
+	withdrawals := protected.Group("/withdrawals")
+	withdrawals.Get("/", withdrawalHandler.GetWithdrawals)
+	withdrawals.Post("/", idempotent, withdrawalHandler.RequestWithdrawal)
+	withdrawals.Get("/quote", withdrawalHandler.QuoteWithdrawal)
 
 	// Favorites routes
 	favorites := protected.Group("/favorites")
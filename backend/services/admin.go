@@ -18,64 +18,59 @@ func NewAdminService(db *sql.DB) *AdminService {
 	return &AdminService{db: db}
 }
 
-// Platform Fee Settings
+// Platform Fee Settings are the job payout fee, shown in the older
+// platform fee shape for the settings page that still edits it.
 func (as *AdminService) GetPlatformFeeSettings() (*models.PlatformFeeSettings, error) {
-	query := `
-		SELECT id, enabled, percentage, fixed_fee, minimum_fee, maximum_fee, created_at, updated_at
-		FROM platform_fee_settings 
-		WHERE id = 'default'`
-	
-	var settings models.PlatformFeeSettings
-	err := as.db.QueryRow(query).Scan(
-		&settings.ID, &settings.Enabled, &settings.Percentage, &settings.FixedFee,
-		&settings.MinimumFee, &settings.MaximumFee, &settings.CreatedAt, &settings.UpdatedAt,
-	)
-	
-	if err == sql.ErrNoRows {
-		// Create default settings
-		return as.createDefaultPlatformFeeSettings()
+	setting, err := NewFeeEngine(as.db).GetSetting(FeeJobPayout)
+	if err != nil {
+		return nil, err
+	}
+	if setting == nil {
+		now := time.Now()
+		return &models.PlatformFeeSettings{ID: FeeJobPayout, CreatedAt: now, UpdatedAt: now}, nil
 	}
-	
-	return &settings, err
-}
 
-func (as *AdminService) createDefaultPlatformFeeSettings() (*models.PlatformFeeSettings, error) {
 	settings := &models.PlatformFeeSettings{
-		ID:         "default",
-		Enabled:    true,
-		Percentage: 5.0,
-		FixedFee:   0.0,
-		MinimumFee: 0.0,
-		MaximumFee: 0,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+		ID:         setting.ID,
+		Enabled:    setting.IsActive,
+		Percentage: setting.FeePercentage,
+		FixedFee:   setting.FeeFixed,
+		MinimumFee: setting.MinimumFee,
+		CreatedAt:  setting.CreatedAt,
+		UpdatedAt:  setting.UpdatedAt,
 	}
-	
-	query := `
-		INSERT INTO platform_fee_settings (id, enabled, percentage, fixed_fee, minimum_fee, maximum_fee, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
-	
-	_, err := as.db.Exec(query, settings.ID, settings.Enabled, settings.Percentage,
-		settings.FixedFee, settings.MinimumFee, settings.MaximumFee, settings.CreatedAt, settings.UpdatedAt)
-	
-	return settings, err
+	if setting.MaximumFee != nil {
+		settings.MaximumFee = *setting.MaximumFee
+	}
+	return settings, nil
 }
 
+// UpdatePlatformFeeSettings saves the job payout fee. A zero maximum means no
+// maximum, and brackets and the charged side set elsewhere are kept.
 func (as *AdminService) UpdatePlatformFeeSettings(updateData *models.PlatformFeeSettings) (*models.PlatformFeeSettings, error) {
-	updateData.UpdatedAt = time.Now()
-	
-	query := `
-		UPDATE platform_fee_settings 
-		SET enabled = $1, percentage = $2, fixed_fee = $3, minimum_fee = $4, maximum_fee = $5, updated_at = $6
-		WHERE id = 'default'`
-	
-	_, err := as.db.Exec(query, updateData.Enabled, updateData.Percentage, updateData.FixedFee,
-		updateData.MinimumFee, updateData.MaximumFee, updateData.UpdatedAt)
-	
+	setting, err := NewFeeEngine(as.db).GetSetting(FeeJobPayout)
 	if err != nil {
 		return nil, err
 	}
-	
+	if setting == nil {
+		setting = &models.AdminFeeSetting{FeeType: FeeJobPayout, ChargedTo: FeeChargedToPayee}
+	}
+
+	setting.IsActive = updateData.Enabled
+	setting.FeePercentage = updateData.Percentage
+	setting.FeeFixed = updateData.FixedFee
+	setting.MinimumFee = updateData.MinimumFee
+	setting.MaximumFee = nil
+	if updateData.MaximumFee.IsPositive() {
+		maximum := updateData.MaximumFee
+		setting.MaximumFee = &maximum
+	}
+
+	_, err = as.UpsertFeeSetting(setting)
+	if err != nil {
+		return nil, err
+	}
+
 	return as.GetPlatformFeeSettings()
 }
 
@@ -132,7 +127,7 @@ func (as *AdminService) UpdateRevisionSettings(settings map[string]interface{})
 // Fee Settings
 func (as *AdminService) GetAllFeeSettings() ([]models.AdminFeeSetting, error) {
 	query := `
-		SELECT fee_type, fee_percentage, fee_fixed, minimum_fee, maximum_fee, is_active, created_at, updated_at
+		SELECT ` + feeSettingColumns + `
 		FROM admin_fee_settings 
 		ORDER BY fee_type`
 	
@@ -144,38 +139,41 @@ func (as *AdminService) GetAllFeeSettings() ([]models.AdminFeeSetting, error) {
 
 	var feeSettings []models.AdminFeeSetting
 	for rows.Next() {
-		var setting models.AdminFeeSetting
-		err := rows.Scan(&setting.FeeType, &setting.FeePercentage, &setting.FeeFixed,
-			&setting.MinimumFee, &setting.MaximumFee, &setting.IsActive,
-			&setting.CreatedAt, &setting.UpdatedAt)
+		setting, err := scanFeeSetting(rows)
 		if err != nil {
 			return nil, err
 		}
-		feeSettings = append(feeSettings, setting)
+		feeSettings = append(feeSettings, *setting)
 	}
 
 	return feeSettings, nil
 }
 
 func (as *AdminService) UpsertFeeSetting(feeSetting *models.AdminFeeSetting) (*models.AdminFeeSetting, error) {
+	err := prepareFeeSetting(feeSetting)
+	if err != nil {
+		return nil, err
+	}
+
+	tiersJSON, err := json.Marshal(feeSetting.Tiers)
+	if err != nil {
+		return nil, err
+	}
 	feeSetting.UpdatedAt = time.Now()
 	
 	query := `
-		INSERT INTO admin_fee_settings (fee_type, fee_percentage, fee_fixed, minimum_fee, maximum_fee, is_active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO admin_fee_settings (fee_type, fee_percentage, fee_fixed, minimum_fee, maximum_fee, tiers, charged_to,
+			is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		ON CONFLICT (fee_type)
 		DO UPDATE SET 
 			fee_percentage = $2, fee_fixed = $3, minimum_fee = $4, maximum_fee = $5, 
-			is_active = $6, updated_at = $8
-		RETURNING fee_type, fee_percentage, fee_fixed, minimum_fee, maximum_fee, is_active, created_at, updated_at`
-	
-	var result models.AdminFeeSetting
-	err := as.db.QueryRow(query, feeSetting.FeeType, feeSetting.FeePercentage, feeSetting.FeeFixed,
-		feeSetting.MinimumFee, feeSetting.MaximumFee, feeSetting.IsActive, time.Now(), feeSetting.UpdatedAt).Scan(
-		&result.FeeType, &result.FeePercentage, &result.FeeFixed, &result.MinimumFee,
-		&result.MaximumFee, &result.IsActive, &result.CreatedAt, &result.UpdatedAt)
+			tiers = $6, charged_to = $7, is_active = $8, updated_at = $10
+		RETURNING ` + feeSettingColumns
 	
-	return &result, err
+	return scanFeeSetting(as.db.QueryRow(query, feeSetting.FeeType, feeSetting.FeePercentage, feeSetting.FeeFixed,
+		feeSetting.MinimumFee, feeSetting.MaximumFee, string(tiersJSON), feeSetting.ChargedTo, feeSetting.IsActive,
+		time.Now(), feeSetting.UpdatedAt))
 }
 
 // Generic settings methods for other admin settings
@@ -203,6 +201,36 @@ func (as *AdminService) UpdateReservationSettings(settings map[string]interface{
 	return as.updateSettingsByKey("reservation_settings", settings)
 }
 
+func (as *AdminService) GetEarningsHoldSettings() (map[string]interface{}, error) {
+	settings, err := as.getSettingsByKey("earnings_hold_settings")
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := settings["holdPeriodHours"]; !ok {
+		settings["holdPeriodHours"] = DefaultEarningsHoldHours
+	}
+	return settings, nil
+}
+
+func (as *AdminService) UpdateEarningsHoldSettings(settings map[string]interface{}) error {
+	return as.updateSettingsByKey("earnings_hold_settings", settings)
+}
+
+func (as *AdminService) GetWithdrawalSettings() (map[string]interface{}, error) {
+	settings, err := as.getSettingsByKey("withdrawal_settings")
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := settings["minimumAmount"]; !ok {
+		settings["minimumAmount"] = DefaultMinimumWithdrawal
+	}
+	return settings, nil
+}
+
+func (as *AdminService) UpdateWithdrawalSettings(settings map[string]interface{}) error {
+	return as.updateSettingsByKey("withdrawal_settings", settings)
+}
+
 // Helper methods
 func (as *AdminService) getSettingsByKey(key string) (map[string]interface{}, error) {
 	query := `SELECT setting_value FROM admin_settings WHERE setting_key = $1`
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"microjob-backend/models"
+)
+
+var ErrAlertNotFound = errors.New("alert not found or already acknowledged")
+
+type AlertService struct {
+	db *sql.DB
+}
+
+func NewAlertService(db *sql.DB) *AlertService {
+	return &AlertService{db: db}
+}
+
+// Raise records an alert for admins. An alert of the same type for the same
+// user that is still open from the last hour absorbs repeats, so a user
+// retrying a blocked action does not flood the queue.
+func (as *AlertService) Raise(alert *models.AdminAlert) error {
+	if alert.Severity == "" {
+		alert.Severity = "warning"
+	}
+	details, err := json.Marshal(alert.Details)
+	if err != nil {
+		return err
+	}
+
+	alert.Status = "open"
+	alert.CreatedAt = time.Now()
+	err = as.db.QueryRow(`
+		INSERT INTO admin_alerts (alert_type, severity, user_id, message, details, status, created_at)
+		SELECT $1, $2, $3, $4, $5, $6, $7
+		WHERE NOT EXISTS (
+			SELECT 1 FROM admin_alerts
+			WHERE alert_type = $1 AND user_id IS NOT DISTINCT FROM $3::uuid
+			  AND status = 'open' AND created_at > $7::timestamptz - INTERVAL '1 hour'
+		)
+		RETURNING id`,
+		alert.AlertType, alert.Severity, alert.UserID, alert.Message, string(details), alert.Status,
+		alert.CreatedAt).Scan(&alert.ID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	return err
+}
+
+func (as *AlertService) GetAlerts(status string, limit, offset int) ([]models.AdminAlert, int, error) {
+	var total int
+	err := as.db.QueryRow(`SELECT COUNT(*) FROM admin_alerts WHERE ($1 = '' OR status = $1)`, status).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := as.db.Query(`
+		SELECT id, alert_type, severity, user_id, message, details, status, acknowledged_by, acknowledged_at,
+			created_at
+		FROM admin_alerts
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3`, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var alerts []models.AdminAlert
+	for rows.Next() {
+		var alert models.AdminAlert
+		var details string
+		err := rows.Scan(&alert.ID, &alert.AlertType, &alert.Severity, &alert.UserID, &alert.Message, &details,
+			&alert.Status, &alert.AcknowledgedBy, &alert.AcknowledgedAt, &alert.CreatedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := json.Unmarshal([]byte(details), &alert.Details); err != nil {
+			return nil, 0, err
+		}
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, total, rows.Err()
+}
+
+func (as *AlertService) Acknowledge(alertID, adminID string) error {
+	result, err := as.db.Exec(`
+		UPDATE admin_alerts
+		SET status = 'acknowledged', acknowledged_by = $1, acknowledged_at = $2
+		WHERE id = $3 AND status = 'open'`, adminID, time.Now(), alertID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrAlertNotFound
+	}
+	return nil
+}
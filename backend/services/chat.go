@@ -2,190 +2,765 @@ package services
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/google/uuid"
 	"microjob-backend/models"
+	"microjob-backend/money"
+)
+
+const (
+	// ChatTransferRequestExpiry is how long the other party has to answer a
+	// money transfer before it expires.
+	ChatTransferRequestExpiry = 72 * time.Hour
+
+	// ChatTransferDisputeWindow is how long after completion the sender can
+	// dispute a transfer and the receiver can refund it.
+	ChatTransferDisputeWindow = 7 * 24 * time.Hour
+)
+
+var (
+	ErrTransferNotFound       = errors.New("money transfer not found")
+	ErrTransferNotParticipant = errors.New("you are not a party to this money transfer")
+	ErrTransferInvalidStatus  = errors.New("money transfer cannot be changed in its current status")
+	ErrTransferWindowClosed   = errors.New("the dispute and refund window for this transfer has closed")
+	ErrTransferToSelf         = errors.New("cannot transfer money to yourself")
+	ErrTransferOwnRequest     = errors.New("the other party has to answer this money transfer")
+	ErrTransferNotInChat      = errors.New("both parties must be active participants in the chat")
 )
 
 type ChatService struct {
 	db            *sql.DB
 	walletService *WalletService
 	adminService  *AdminService
+	velocity      *VelocityService
+	fees          *FeeEngine
 }
 
-func NewChatService(db *sql.DB, walletService *WalletService, adminService *AdminService) *ChatService {
+func NewChatService(db *sql.DB, walletService *WalletService, adminService *AdminService, velocity *VelocityService) *ChatService {
 	return &ChatService{
 		db:            db,
 		walletService: walletService,
 		adminService:  adminService,
+		velocity:      velocity,
+		fees:          NewFeeEngine(db),
 	}
 }
 
-func (cs *ChatService) ProcessMoneyTransfer(senderID, receiverID, chatID string, amount float64, message string) (*models.ChatMoneyTransfer, error) {
-	// Get commission settings
-	feeSettings, err := cs.adminService.GetAllFeeSettings()
+// SendTransfer offers money to the receiver. The amount the sender pays is
+// locked in their wallet until the receiver accepts or declines.
+func (cs *ChatService) SendTransfer(senderID, receiverID, chatID string, amount money.Money, message string) (*models.ChatMoneyTransfer, error) {
+	return cs.createTransfer(senderID, senderID, receiverID, chatID, amount, message)
+}
+
+// RequestTransfer asks the sender for money. Nothing moves until the sender
+// accepts.
+func (cs *ChatService) RequestTransfer(receiverID, senderID, chatID string, amount money.Money, message string) (*models.ChatMoneyTransfer, error) {
+	return cs.createTransfer(receiverID, senderID, receiverID, chatID, amount, message)
+}
+
+func (cs *ChatService) createTransfer(requestedBy, senderID, receiverID, chatID string, amount money.Money, message string) (*models.ChatMoneyTransfer, error) {
+	if senderID == receiverID {
+		return nil, ErrTransferToSelf
+	}
+
+	tx, err := cs.db.Begin()
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	var commissionAmount float64 = 0
-	for _, setting := range feeSettings {
-		if setting.FeeType == "chat_transfer" && setting.IsActive {
-			commissionAmount = (amount * setting.FeePercentage) / 100
-			commissionAmount += setting.FeeFixed
-
-			if commissionAmount < setting.MinimumFee {
-				commissionAmount = setting.MinimumFee
-			}
-
-			if setting.MaximumFee != nil && commissionAmount > *setting.MaximumFee {
-				commissionAmount = *setting.MaximumFee
-			}
-			break
-		}
+	// Money only moves between people talking in the chat it is sent from
+	var participants int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM chat_participants
+		WHERE chat_id = $1 AND user_id IN ($2, $3) AND is_active = true`,
+		chatID, senderID, receiverID).Scan(&participants)
+	if err != nil {
+		return nil, err
+	}
+	if participants != 2 {
+		return nil, ErrTransferNotInChat
 	}
 
-	netAmount := amount - commissionAmount
+	currency, err := supportedCurrency(tx, amount.Currency)
+	if err != nil {
+		return nil, err
+	}
+	amount = amount.WithCurrency(currency)
 
-	// Start database transaction
-	tx, err := cs.db.Begin()
+	fee, err := cs.fees.Calculate(FeeChatTransfer, amount, senderID, receiverID)
+	if err == ErrFeeExceedsAmount {
+		return nil, fmt.Errorf("%w: transfer amount does not cover the %s commission", ErrFeeExceedsAmount, fee.TotalFee)
+	}
 	if err != nil {
 		return nil, err
 	}
-	defer tx.Rollback()
 
-	// Create transfer record
+	// An offer takes money out of the sender's available balance now
+	offered := requestedBy == senderID
+	if offered {
+		err = cs.velocity.Check(tx, senderID, fee.PayerPays)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ChatTransferRequestExpiry)
 	transfer := &models.ChatMoneyTransfer{
 		ID:               uuid.New().String(),
 		ChatID:           chatID,
 		SenderID:         senderID,
 		ReceiverID:       receiverID,
+		RequestedBy:      requestedBy,
+		Currency:         currency,
 		Amount:           amount,
-		CommissionAmount: commissionAmount,
-		NetAmount:        netAmount,
-		Message:          message,
-		Status:           "pending",
-		CreatedAt:        time.Now(),
-		UpdatedAt:        time.Now(),
+		CommissionAmount: fee.TotalFee,
+		NetAmount:        fee.PayeeReceives,
+		Message:          &message,
+		Status:           "requested",
+		ExpiresAt:        &expiresAt,
+		CreatedAt:        now,
+		UpdatedAt:        now,
 	}
 
-	insertQuery := `
-		INSERT INTO chat_money_transfers (id, chat_id, sender_id, receiver_id, amount, 
-			commission_amount, net_amount, message, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
-
-	_, err = tx.Exec(insertQuery, transfer.ID, transfer.ChatID, transfer.SenderID, transfer.ReceiverID,
-		transfer.Amount, transfer.CommissionAmount, transfer.NetAmount, transfer.Message,
-		transfer.Status, transfer.CreatedAt, transfer.UpdatedAt)
+	_, err = tx.Exec(`
+		INSERT INTO chat_money_transfers (id, chat_id, sender_id, receiver_id, requested_by, currency, amount,
+			commission_amount, net_amount, message, status, expires_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`,
+		transfer.ID, transfer.ChatID, transfer.SenderID, transfer.ReceiverID, transfer.RequestedBy,
+		transfer.Currency, transfer.Amount, transfer.CommissionAmount, transfer.NetAmount, transfer.Message,
+		transfer.Status, transfer.ExpiresAt, transfer.CreatedAt, transfer.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
 
-	// Check sender balance
-	var senderBalance float64
-	err = tx.QueryRow("SELECT balance FROM wallets WHERE user_id = $1", senderID).Scan(&senderBalance)
+	if offered {
+		err = cs.hold(tx, transfer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if offered {
+		cs.velocity.Record(senderID, transfer.PayerPays())
+	}
+	err = tx.Commit()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get sender balance: %w", err)
+		if offered {
+			cs.velocity.Forget(senderID)
+		}
+		return nil, err
 	}
 
-	if senderBalance < amount {
-		return nil, fmt.Errorf("insufficient balance")
+	return transfer, nil
+}
+
+// hold locks an offered amount in the sender's wallet. The sender's history
+// shows one transfer that completes or fails with the receiver's answer.
+func (cs *ChatService) hold(tx *sql.Tx, transfer *models.ChatMoneyTransfer) error {
+	description := fmt.Sprintf("Money transfer offered: %s", messageOf(transfer))
+	entry := &models.LedgerEntry{
+		EntryType:     "chat_transfer_hold",
+		Description:   &description,
+		ReferenceID:   &transfer.ID,
+		ReferenceType: stringPtr("chat_transfer"),
+	}
+	err := cs.walletService.ledger.Post(tx, entry, []LedgerLeg{
+		{AccountType: AccountUserAvailable, OwnerID: transfer.SenderID, Amount: transfer.PayerPays().Neg()},
+		{AccountType: AccountUserLocked, OwnerID: transfer.SenderID, Amount: transfer.PayerPays()},
+	})
+	if err != nil {
+		return err
 	}
 
-	// Process wallet transactions
-	// Deduct from sender
-	_, err = tx.Exec(`
-		UPDATE wallets 
-		SET balance = balance - $1, total_spent = total_spent + $1, updated_at = $2 
-		WHERE user_id = $3`, amount, time.Now(), senderID)
+	return insertWalletTransaction(tx, &models.WalletTransaction{
+		UserID:        transfer.SenderID,
+		Type:          "chat_transfer_sent",
+		Amount:        transfer.PayerPays(),
+		Description:   stringPtr(fmt.Sprintf("Money transfer to user: %s", messageOf(transfer))),
+		ReferenceID:   &transfer.ID,
+		ReferenceType: stringPtr("chat_transfer"),
+		BalanceType:   "deposit",
+		Status:        "pending",
+		LedgerEntryID: &entry.ID,
+	})
+}
+
+// AcceptTransfer is the answering party agreeing to a transfer. Money offered
+// by the sender comes out of their locked balance; money requested by the
+// receiver comes out of the sender's available balance now.
+func (cs *ChatService) AcceptTransfer(transferID, userID string) (*models.ChatMoneyTransfer, error) {
+	tx, err := cs.db.Begin()
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	// Add to receiver
-	_, err = tx.Exec(`
-		UPDATE wallets 
-		SET balance = balance + $1, total_earned = total_earned + $1, updated_at = $2 
-		WHERE user_id = $3`, netAmount, time.Now(), receiverID)
+	transfer, err := lockTransfer(tx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	err = checkAnswerable(transfer, userID)
 	if err != nil {
 		return nil, err
 	}
+	// A requested transfer leaves the sender's wallet only now
+	if !transfer.OfferedBySender() {
+		err = cs.velocity.Check(tx, transfer.SenderID, transfer.PayerPays())
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	// Create wallet transaction records
-	senderTransaction := &models.WalletTransaction{
-		ID:            uuid.New().String(),
-		UserID:        senderID,
-		Type:          "chat_transfer_sent",
-		Amount:        amount,
-		Description:   fmt.Sprintf("Money transfer to user: %s", message),
+	source := AccountUserAvailable
+	if transfer.OfferedBySender() {
+		source = AccountUserLocked
+	}
+	legs := []LedgerLeg{
+		{AccountType: source, OwnerID: transfer.SenderID, Amount: transfer.PayerPays().Neg()},
+		{AccountType: AccountUserAvailable, OwnerID: transfer.ReceiverID, Amount: transfer.NetAmount},
+	}
+	if transfer.CommissionAmount.IsPositive() {
+		legs = append(legs, LedgerLeg{AccountType: AccountPlatformFees, Amount: transfer.CommissionAmount})
+	}
+
+	entry := &models.LedgerEntry{
+		EntryType:     "chat_transfer",
+		Description:   transfer.Message,
 		ReferenceID:   &transfer.ID,
 		ReferenceType: stringPtr("chat_transfer"),
-		BalanceType:   "deposit",
-		Status:        "completed",
-		CreatedAt:     time.Now(),
-		UpdatedAt:     time.Now(),
+	}
+	err = cs.walletService.ledger.Post(tx, entry, legs)
+	if err != nil {
+		return nil, err
 	}
 
-	receiverTransaction := &models.WalletTransaction{
-		ID:            uuid.New().String(),
-		UserID:        receiverID,
+	if transfer.OfferedBySender() {
+		err = setTransferTransactionStatus(tx, transfer.ID, "completed")
+	} else {
+		err = insertWalletTransaction(tx, &models.WalletTransaction{
+			UserID:        transfer.SenderID,
+			Type:          "chat_transfer_sent",
+			Amount:        transfer.PayerPays(),
+			Description:   stringPtr(fmt.Sprintf("Money transfer to user: %s", messageOf(transfer))),
+			ReferenceID:   &transfer.ID,
+			ReferenceType: stringPtr("chat_transfer"),
+			BalanceType:   "deposit",
+			Status:        "completed",
+			LedgerEntryID: &entry.ID,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertWalletTransaction(tx, &models.WalletTransaction{
+		UserID:        transfer.ReceiverID,
 		Type:          "chat_transfer_received",
-		Amount:        netAmount,
-		Description:   fmt.Sprintf("Money received from user: %s", message),
+		Amount:        transfer.NetAmount,
+		Description:   stringPtr(fmt.Sprintf("Money received from user: %s", messageOf(transfer))),
 		ReferenceID:   &transfer.ID,
 		ReferenceType: stringPtr("chat_transfer"),
 		BalanceType:   "deposit",
 		Status:        "completed",
-		CreatedAt:     time.Now(),
-		UpdatedAt:     time.Now(),
+		LedgerEntryID: &entry.ID,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Insert transaction records
-	transactionQuery := `
-		INSERT INTO wallet_transactions (id, user_id, type, amount, description, reference_id, 
-			reference_type, balance_type, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	err = updateWalletTotals(tx, transfer.SenderID, money.Money{}, transfer.PayerPays())
+	if err != nil {
+		return nil, err
+	}
+	err = updateWalletTotals(tx, transfer.ReceiverID, transfer.NetAmount, money.Money{})
+	if err != nil {
+		return nil, err
+	}
 
-	_, err = tx.Exec(transactionQuery, senderTransaction.ID, senderTransaction.UserID, senderTransaction.Type,
-		senderTransaction.Amount, senderTransaction.Description, senderTransaction.ReferenceID,
-		senderTransaction.ReferenceType, senderTransaction.BalanceType, senderTransaction.Status,
-		senderTransaction.CreatedAt, senderTransaction.UpdatedAt)
+	now := time.Now()
+	refundableUntil := now.Add(ChatTransferDisputeWindow)
+	transfer.Status = "completed"
+	transfer.RespondedAt = &now
+	transfer.CompletedAt = &now
+	transfer.RefundableUntil = &refundableUntil
+	transfer.LedgerEntryID = &entry.ID
+	transfer.UpdatedAt = now
+	_, err = tx.Exec(`
+		UPDATE chat_money_transfers
+		SET status = $1, responded_at = $2, completed_at = $2, refundable_until = $3, ledger_entry_id = $4,
+			updated_at = $2
+		WHERE id = $5`, transfer.Status, now, transfer.RefundableUntil, transfer.LedgerEntryID, transfer.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = tx.Exec(transactionQuery, receiverTransaction.ID, receiverTransaction.UserID, receiverTransaction.Type,
-		receiverTransaction.Amount, receiverTransaction.Description, receiverTransaction.ReferenceID,
-		receiverTransaction.ReferenceType, receiverTransaction.BalanceType, receiverTransaction.Status,
-		receiverTransaction.CreatedAt, receiverTransaction.UpdatedAt)
+	if !transfer.OfferedBySender() {
+		cs.velocity.Record(transfer.SenderID, transfer.PayerPays())
+	}
+	err = tx.Commit()
 	if err != nil {
+		if !transfer.OfferedBySender() {
+			cs.velocity.Forget(transfer.SenderID)
+		}
 		return nil, err
 	}
 
-	// Update transfer status to completed
+	return transfer, nil
+}
+
+// DeclineTransfer is the answering party turning a transfer down.
+func (cs *ChatService) DeclineTransfer(transferID, userID, reason string) (*models.ChatMoneyTransfer, error) {
+	return cs.closeRequest(transferID, func(transfer *models.ChatMoneyTransfer) error {
+		err := checkAnswerable(transfer, userID)
+		if err != nil {
+			return err
+		}
+		transfer.Status = "declined"
+		if reason != "" {
+			transfer.DeclineReason = &reason
+		}
+		return nil
+	})
+}
+
+// CancelTransfer withdraws a transfer before the other party has answered.
+// Only the party who started it can cancel.
+func (cs *ChatService) CancelTransfer(transferID, userID string) (*models.ChatMoneyTransfer, error) {
+	return cs.closeRequest(transferID, func(transfer *models.ChatMoneyTransfer) error {
+		if transfer.RequestedBy != userID {
+			return ErrTransferNotParticipant
+		}
+		if transfer.Status != "requested" {
+			return ErrTransferInvalidStatus
+		}
+		transfer.Status = "cancelled"
+		return nil
+	})
+}
+
+// ExpireTransfers closes every request nobody answered in time and returns
+// offered money to the senders.
+func (cs *ChatService) ExpireTransfers() (int, error) {
+	rows, err := cs.db.Query(`
+		SELECT id FROM chat_money_transfers
+		WHERE status = 'requested' AND expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+
+	var transferIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		transferIDs = append(transferIDs, id)
+	}
+	rows.Close()
+
+	expired := 0
+	for _, transferID := range transferIDs {
+		_, err := cs.closeRequest(transferID, func(transfer *models.ChatMoneyTransfer) error {
+			if transfer.Status != "requested" || transfer.ExpiresAt == nil || transfer.ExpiresAt.After(time.Now()) {
+				return ErrTransferInvalidStatus
+			}
+			transfer.Status = "expired"
+			return nil
+		})
+		if err == ErrTransferInvalidStatus {
+			continue // answered while the batch was running
+		}
+		if err != nil {
+			log.Printf("Failed to expire money transfer %s: %v", transferID, err)
+			continue
+		}
+		expired++
+	}
+
+	return expired, nil
+}
+
+// closeRequest ends an unanswered transfer after decide has checked it and set
+// the final status, releasing any money the sender had locked.
+func (cs *ChatService) closeRequest(transferID string, decide func(*models.ChatMoneyTransfer) error) (*models.ChatMoneyTransfer, error) {
+	tx, err := cs.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	transfer, err := lockTransfer(tx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	err = decide(transfer)
+	if err != nil {
+		return nil, err
+	}
+
+	if transfer.OfferedBySender() {
+		description := fmt.Sprintf("Money transfer %s", transfer.Status)
+		entry := &models.LedgerEntry{
+			EntryType:     "chat_transfer_release",
+			Description:   &description,
+			ReferenceID:   &transfer.ID,
+			ReferenceType: stringPtr("chat_transfer"),
+		}
+		err = cs.walletService.ledger.Post(tx, entry, []LedgerLeg{
+			{AccountType: AccountUserLocked, OwnerID: transfer.SenderID, Amount: transfer.PayerPays().Neg()},
+			{AccountType: AccountUserAvailable, OwnerID: transfer.SenderID, Amount: transfer.PayerPays()},
+		})
+		if err != nil {
+			return nil, err
+		}
+		err = setTransferTransactionStatus(tx, transfer.ID, "failed")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	transfer.RespondedAt = &now
+	transfer.UpdatedAt = now
 	_, err = tx.Exec(`
-		UPDATE chat_money_transfers 
-		SET status = 'completed', completed_at = $1, updated_at = $1 
-		WHERE id = $2`, time.Now(), transfer.ID)
+		UPDATE chat_money_transfers
+		SET status = $1, decline_reason = $2, responded_at = $3, updated_at = $3
+		WHERE id = $4`, transfer.Status, transfer.DeclineReason, now, transfer.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Commit transaction
-	err = tx.Commit()
+	return transfer, tx.Commit()
+}
+
+// DisputeTransfer lets the sender contest a completed transfer within the
+// dispute window. An admin then decides whether it is refunded.
+func (cs *ChatService) DisputeTransfer(transferID, userID, reason string) (*models.ChatMoneyTransfer, error) {
+	tx, err := cs.db.Begin()
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	transfer.Status = "completed"
-	completedAt := time.Now()
-	transfer.CompletedAt = &completedAt
+	transfer, err := lockTransfer(tx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.SenderID != userID {
+		return nil, ErrTransferNotParticipant
+	}
+	if transfer.Status != "completed" {
+		return nil, ErrTransferInvalidStatus
+	}
+	err = checkRefundable(transfer)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	transfer.Status = "disputed"
+	transfer.DisputedAt = &now
+	transfer.DisputeReason = &reason
+	transfer.UpdatedAt = now
+	_, err = tx.Exec(`
+		UPDATE chat_money_transfers
+		SET status = $1, disputed_at = $2, dispute_reason = $3, updated_at = $2
+		WHERE id = $4`, transfer.Status, now, transfer.DisputeReason, transfer.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return transfer, tx.Commit()
+}
+
+// RefundTransfer lets the receiver give a completed or disputed transfer back
+// within the dispute window.
+func (cs *ChatService) RefundTransfer(transferID, userID, reason string) (*models.ChatMoneyTransfer, error) {
+	tx, err := cs.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	transfer, err := lockTransfer(tx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.ReceiverID != userID {
+		return nil, ErrTransferNotParticipant
+	}
+	err = checkRefundable(transfer)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cs.refund(tx, transfer, userID, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	return transfer, tx.Commit()
+}
+
+// ResolveDispute settles a disputed transfer. Refunding reverses it in full,
+// commission included; otherwise the transfer stands as dispute_rejected,
+// which cannot be disputed again.
+func (cs *ChatService) ResolveDispute(transferID, adminID string, refund bool, note string) (*models.ChatMoneyTransfer, error) {
+	tx, err := cs.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	transfer, err := lockTransfer(tx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.Status != "disputed" {
+		return nil, ErrTransferInvalidStatus
+	}
+
+	now := time.Now()
+	transfer.ResolvedBy = &adminID
+	transfer.ResolvedAt = &now
+	transfer.ResolutionNote = &note
+	_, err = tx.Exec(`
+		UPDATE chat_money_transfers
+		SET resolved_by = $1, resolved_at = $2, resolution_note = $3, updated_at = $2
+		WHERE id = $4`, adminID, now, note, transfer.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if refund {
+		err = cs.refund(tx, transfer, adminID, "dispute upheld")
+	} else {
+		transfer.Status = "dispute_rejected"
+		transfer.UpdatedAt = now
+		_, err = tx.Exec(`UPDATE chat_money_transfers SET status = $1 WHERE id = $2`, transfer.Status, transfer.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return transfer, tx.Commit()
+}
+
+// refund moves a completed transfer back: the receiver returns what they got
+// and the commission comes back out of platform fees.
+func (cs *ChatService) refund(tx *sql.Tx, transfer *models.ChatMoneyTransfer, refundedBy, reason string) error {
+	if transfer.Status != "completed" && transfer.Status != "disputed" {
+		return ErrTransferInvalidStatus
+	}
+
+	// Part of it may already have been given back by an admin refund
+	var refunded bool
+	err := tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM wallet_refunds r
+			JOIN wallet_transactions t ON t.id = r.original_transaction_id
+			WHERE t.reference_id = $1 AND t.type = 'chat_transfer_received'
+		)`, transfer.ID).Scan(&refunded)
+	if err != nil {
+		return err
+	}
+	if refunded {
+		return ErrTransferInvalidStatus
+	}
 
+	description := fmt.Sprintf("Money transfer refund: %s", reason)
+	legs := []LedgerLeg{
+		{AccountType: AccountUserAvailable, OwnerID: transfer.ReceiverID, Amount: transfer.NetAmount.Neg()},
+		{AccountType: AccountUserAvailable, OwnerID: transfer.SenderID, Amount: transfer.PayerPays()},
+	}
+	if transfer.CommissionAmount.IsPositive() {
+		legs = append(legs, LedgerLeg{AccountType: AccountPlatformFees, Amount: transfer.CommissionAmount.Neg()})
+	}
+	entry := &models.LedgerEntry{
+		EntryType:     "chat_transfer_refund",
+		Description:   &description,
+		ReferenceID:   &transfer.ID,
+		ReferenceType: stringPtr("chat_transfer"),
+	}
+	err = cs.walletService.ledger.Post(tx, entry, legs)
+	if err != nil {
+		return err
+	}
+
+	for _, transaction := range []*models.WalletTransaction{
+		{UserID: transfer.ReceiverID, Type: "chat_transfer_reversal", Amount: transfer.NetAmount},
+		{UserID: transfer.SenderID, Type: "chat_transfer_refund", Amount: transfer.PayerPays()},
+	} {
+		transaction.Description = &description
+		transaction.ReferenceID = &transfer.ID
+		transaction.ReferenceType = stringPtr("chat_transfer")
+		transaction.BalanceType = "deposit"
+		transaction.Status = "completed"
+		transaction.LedgerEntryID = &entry.ID
+		err = insertWalletTransaction(tx, transaction)
+		if err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	transfer.Status = "refunded"
+	transfer.RefundedBy = &refundedBy
+	transfer.RefundedAt = &now
+	transfer.RefundLedgerEntryID = &entry.ID
+	transfer.UpdatedAt = now
+	_, err = tx.Exec(`
+		UPDATE chat_money_transfers
+		SET status = $1, refunded_by = $2, refunded_at = $3, refund_ledger_entry_id = $4, updated_at = $3
+		WHERE id = $5`, transfer.Status, refundedBy, now, transfer.RefundLedgerEntryID, transfer.ID)
+	return err
+}
+
+func (cs *ChatService) GetTransfer(transferID, userID string) (*models.ChatMoneyTransfer, error) {
+	transfer, err := scanTransfer(cs.db.QueryRow(chatTransferSelect+` WHERE id = $1`, transferID))
+	if err == sql.ErrNoRows {
+		return nil, ErrTransferNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if transfer.SenderID != userID && transfer.ReceiverID != userID {
+		return nil, ErrTransferNotParticipant
+	}
 	return transfer, nil
 }
 
+// GetChatTransfers lists the transfers in a chat that the user is a party to,
+// newest first.
+func (cs *ChatService) GetChatTransfers(chatID, userID string, limit, offset int) ([]models.ChatMoneyTransfer, error) {
+	rows, err := cs.db.Query(chatTransferSelect+`
+		WHERE chat_id = $1 AND (sender_id = $2 OR receiver_id = $2)
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4`, chatID, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTransfers(rows)
+}
+
+// GetTransfers lists transfers for admins, oldest first so disputes are worked
+// in the order they were raised.
+func (cs *ChatService) GetTransfers(status string, limit, offset int) ([]models.ChatMoneyTransfer, int, error) {
+	var total int
+	err := cs.db.QueryRow(`SELECT COUNT(*) FROM chat_money_transfers WHERE ($1 = '' OR status = $1)`, status).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := cs.db.Query(chatTransferSelect+`
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY created_at
+		LIMIT $2 OFFSET $3`, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	transfers, err := scanTransfers(rows)
+	return transfers, total, err
+}
+
+// checkAnswerable reports whether userID can accept or decline the transfer:
+// it has to be the party who did not start it, before the request expires.
+func checkAnswerable(transfer *models.ChatMoneyTransfer, userID string) error {
+	if transfer.SenderID != userID && transfer.ReceiverID != userID {
+		return ErrTransferNotParticipant
+	}
+	if transfer.RequestedBy == userID {
+		return ErrTransferOwnRequest
+	}
+	if transfer.Status != "requested" || (transfer.ExpiresAt != nil && transfer.ExpiresAt.Before(time.Now())) {
+		return ErrTransferInvalidStatus
+	}
+	return nil
+}
+
+// checkRefundable reports whether a transfer can still be refunded. Transfers
+// completed before the window was recorded are past it.
+func checkRefundable(transfer *models.ChatMoneyTransfer) error {
+	if transfer.Status != "completed" && transfer.Status != "disputed" {
+		return ErrTransferInvalidStatus
+	}
+	if transfer.RefundableUntil == nil || transfer.RefundableUntil.Before(time.Now()) {
+		return ErrTransferWindowClosed
+	}
+	return nil
+}
+
+func setTransferTransactionStatus(tx *sql.Tx, transferID, status string) error {
+	_, err := tx.Exec(`
+		UPDATE wallet_transactions SET status = $1, updated_at = $2
+		WHERE reference_type = 'chat_transfer' AND reference_id = $3 AND type = 'chat_transfer_sent'`,
+		status, time.Now(), transferID)
+	return err
+}
+
+func messageOf(transfer *models.ChatMoneyTransfer) string {
+	if transfer.Message == nil {
+		return ""
+	}
+	return *transfer.Message
+}
+
+const chatTransferSelect = `
+	SELECT id, chat_id, sender_id, receiver_id, COALESCE(requested_by, sender_id), currency, amount,
+		commission_amount, net_amount, message, status, expires_at, responded_at, decline_reason,
+		refundable_until, disputed_at, dispute_reason, resolved_by, resolved_at, resolution_note,
+		refunded_by, refunded_at, ledger_entry_id, refund_ledger_entry_id, created_at, updated_at, completed_at
+	FROM chat_money_transfers`
+
+func lockTransfer(tx *sql.Tx, transferID string) (*models.ChatMoneyTransfer, error) {
+	transfer, err := scanTransfer(tx.QueryRow(chatTransferSelect+` WHERE id = $1 FOR UPDATE`, transferID))
+	if err == sql.ErrNoRows {
+		return nil, ErrTransferNotFound
+	}
+	return transfer, err
+}
+
+func scanTransfer(row interface{ Scan(...interface{}) error }) (*models.ChatMoneyTransfer, error) {
+	var transfer models.ChatMoneyTransfer
+	err := row.Scan(&transfer.ID, &transfer.ChatID, &transfer.SenderID, &transfer.ReceiverID,
+		&transfer.RequestedBy, &transfer.Currency, &transfer.Amount, &transfer.CommissionAmount,
+		&transfer.NetAmount, &transfer.Message, &transfer.Status, &transfer.ExpiresAt, &transfer.RespondedAt,
+		&transfer.DeclineReason, &transfer.RefundableUntil, &transfer.DisputedAt, &transfer.DisputeReason,
+		&transfer.ResolvedBy, &transfer.ResolvedAt, &transfer.ResolutionNote, &transfer.RefundedBy,
+		&transfer.RefundedAt, &transfer.LedgerEntryID, &transfer.RefundLedgerEntryID, &transfer.CreatedAt,
+		&transfer.UpdatedAt, &transfer.CompletedAt)
+	if err != nil {
+		return nil, err
+	}
+	transfer.Amount = transfer.Amount.WithCurrency(transfer.Currency)
+	transfer.CommissionAmount = transfer.CommissionAmount.WithCurrency(transfer.Currency)
+	transfer.NetAmount = transfer.NetAmount.WithCurrency(transfer.Currency)
+	return &transfer, nil
+}
+
+func scanTransfers(rows *sql.Rows) ([]models.ChatMoneyTransfer, error) {
+	transfers := []models.ChatMoneyTransfer{}
+	for rows.Next() {
+		transfer, err := scanTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, *transfer)
+	}
+	return transfers, rows.Err()
+}
+
 func stringPtr(s string) *string {
 	return &s
 }
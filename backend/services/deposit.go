@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"microjob-backend/models"
+	"microjob-backend/money"
+	"microjob-backend/payments"
+)
+
+var (
+	ErrDepositNotFound       = errors.New("deposit not found")
+	ErrDepositNotRefundable  = errors.New("only completed deposits can be refunded")
+	ErrDepositAmountMismatch = errors.New("paid amount does not match the deposit")
+)
+
+type DepositService struct {
+	db            *sql.DB
+	walletService *WalletService
+	gateways      *payments.Registry
+}
+
+func NewDepositService(db *sql.DB, walletService *WalletService, gateways *payments.Registry) *DepositService {
+	return &DepositService{
+		db:            db,
+		walletService: walletService,
+		gateways:      gateways,
+	}
+}
+
+func (ds *DepositService) GetGateways() []string {
+	return ds.gateways.Names()
+}
+
+// CreateDeposit records a pending deposit and starts a checkout with the
+// gateway. The wallet is credited when the gateway confirms the payment, in
+// the currency of the deposit.
+func (ds *DepositService) CreateDeposit(ctx context.Context, userID, gatewayName string, amount money.Money, returnURL, cancelURL string) (*models.Deposit, error) {
+	gateway, err := ds.gateways.Get(gatewayName)
+	if err != nil {
+		return nil, err
+	}
+	currency, err := supportedCurrency(ds.db, amount.Currency)
+	if err != nil {
+		return nil, err
+	}
+	amount = amount.WithCurrency(currency)
+
+	now := time.Now()
+	deposit := &models.Deposit{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Gateway:   gatewayName,
+		Amount:    amount,
+		Currency:  currency,
+		Status:    "pending",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	_, err = ds.db.Exec(`
+		INSERT INTO deposits (id, user_id, gateway, amount, currency, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		deposit.ID, deposit.UserID, deposit.Gateway, deposit.Amount, deposit.Currency, deposit.Status,
+		deposit.CreatedAt, deposit.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	checkout, err := gateway.CreateCheckout(ctx, payments.CheckoutRequest{
+		Reference:   deposit.ID,
+		UserID:      userID,
+		Amount:      amount,
+		Description: "Wallet deposit",
+		ReturnURL:   returnURL,
+		CancelURL:   cancelURL,
+	})
+	if err != nil {
+		reason := err.Error()
+		deposit.Status = "failed"
+		deposit.FailureReason = &reason
+		ds.db.Exec(`UPDATE deposits SET status = $1, failure_reason = $2, updated_at = $3 WHERE id = $4`,
+			deposit.Status, reason, time.Now(), deposit.ID)
+		return nil, fmt.Errorf("failed to create checkout: %w", err)
+	}
+
+	deposit.ProviderReference = &checkout.ProviderReference
+	deposit.PaymentURL = &checkout.PaymentURL
+	deposit.UpdatedAt = time.Now()
+	_, err = ds.db.Exec(`
+		UPDATE deposits SET provider_reference = $1, payment_url = $2, updated_at = $3
+		WHERE id = $4`, deposit.ProviderReference, deposit.PaymentURL, deposit.UpdatedAt, deposit.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return deposit, nil
+}
+
+// ApplyEvent moves a deposit to the state reported by its gateway. Events that
+// no longer match the deposit's state are ignored, so a redelivered success
+// event cannot credit the wallet twice.
+func (ds *DepositService) ApplyEvent(gatewayName string, event *payments.WebhookEvent) error {
+	tx, err := ds.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	deposit, err := lockDeposit(tx, `gateway = $1 AND provider_reference = $2`, gatewayName, event.ProviderReference)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	switch event.Type {
+	case payments.EventPaymentSucceeded:
+		if deposit.Status != "pending" {
+			return nil
+		}
+		if !strings.EqualFold(event.Amount.Currency, deposit.Currency) || event.Amount.Minor != deposit.Amount.Minor {
+			return ErrDepositAmountMismatch
+		}
+
+		description := fmt.Sprintf("Deposit via %s", deposit.Gateway)
+		transaction := &models.WalletTransaction{
+			UserID:        deposit.UserID,
+			Type:          "deposit",
+			Amount:        deposit.Amount,
+			Description:   &description,
+			ReferenceID:   &deposit.ID,
+			ReferenceType: stringPtr("deposit"),
+			BalanceType:   "deposit",
+			Status:        "completed",
+		}
+		err = ds.walletService.addTransactionTx(tx, transaction)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			UPDATE deposits SET status = 'completed', transaction_id = $1, updated_at = $2, completed_at = $2
+			WHERE id = $3`, transaction.ID, now, deposit.ID)
+	case payments.EventPaymentFailed:
+		if deposit.Status != "pending" {
+			return nil
+		}
+		_, err = tx.Exec(`
+			UPDATE deposits SET status = 'failed', failure_reason = $1, updated_at = $2
+			WHERE id = $3`, "payment failed at gateway", now, deposit.ID)
+	case payments.EventPaymentRefunded:
+		// Refunds issued from the gateway's dashboard rather than through RefundDeposit
+		if deposit.Status != "completed" {
+			return nil
+		}
+		err = ds.reverseDeposit(tx, deposit, "refunded at gateway")
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RefundDeposit debits the wallet and refunds a completed deposit through its gateway.
+func (ds *DepositService) RefundDeposit(ctx context.Context, depositID, reason string) (*models.Deposit, error) {
+	tx, err := ds.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	deposit, err := lockDeposit(tx, `id = $1`, depositID)
+	if err != nil {
+		return nil, err
+	}
+	if deposit.Status != "completed" || deposit.ProviderReference == nil {
+		return nil, ErrDepositNotRefundable
+	}
+
+	gateway, err := ds.gateways.Get(deposit.Gateway)
+	if err != nil {
+		return nil, err
+	}
+
+	// Debit first so a user who already spent the deposit cannot be refunded
+	err = ds.reverseDeposit(tx, deposit, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = gateway.Refund(ctx, payments.RefundRequest{
+		ProviderReference: *deposit.ProviderReference,
+		Amount:            deposit.Amount,
+		Reason:            reason,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("gateway refund failed: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	deposit.Status = "refunded"
+	return deposit, nil
+}
+
+func (ds *DepositService) reverseDeposit(tx *sql.Tx, deposit *models.Deposit, reason string) error {
+	description := fmt.Sprintf("Deposit refund: %s", reason)
+	err := ds.walletService.addTransactionTx(tx, &models.WalletTransaction{
+		UserID:        deposit.UserID,
+		Type:          "deposit_refund",
+		Amount:        deposit.Amount,
+		Description:   &description,
+		ReferenceID:   &deposit.ID,
+		ReferenceType: stringPtr("deposit"),
+		BalanceType:   "deposit",
+		Status:        "completed",
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE deposits SET status = 'refunded', updated_at = $1 WHERE id = $2`, time.Now(), deposit.ID)
+	return err
+}
+
+func (ds *DepositService) GetDeposit(depositID string) (*models.Deposit, error) {
+	deposit, err := scanDeposit(ds.db.QueryRow(depositSelect+` WHERE id = $1`, depositID))
+	if err == sql.ErrNoRows {
+		return nil, ErrDepositNotFound
+	}
+	return deposit, err
+}
+
+func (ds *DepositService) GetUserDeposits(userID string, limit, offset int) ([]models.Deposit, error) {
+	rows, err := ds.db.Query(depositSelect+`
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3`, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deposits []models.Deposit
+	for rows.Next() {
+		deposit, err := scanDeposit(rows)
+		if err != nil {
+			return nil, err
+		}
+		deposits = append(deposits, *deposit)
+	}
+
+	return deposits, rows.Err()
+}
+
+const depositSelect = `
+	SELECT id, user_id, gateway, amount, currency, status, provider_reference, payment_url, transaction_id,
+		failure_reason, created_at, updated_at, completed_at
+	FROM deposits`
+
+func lockDeposit(tx *sql.Tx, where string, args ...interface{}) (*models.Deposit, error) {
+	deposit, err := scanDeposit(tx.QueryRow(depositSelect+` WHERE `+where+` FOR UPDATE`, args...))
+	if err == sql.ErrNoRows {
+		return nil, ErrDepositNotFound
+	}
+	return deposit, err
+}
+
+func scanDeposit(row interface{ Scan(...interface{}) error }) (*models.Deposit, error) {
+	var deposit models.Deposit
+	err := row.Scan(&deposit.ID, &deposit.UserID, &deposit.Gateway, &deposit.Amount, &deposit.Currency,
+		&deposit.Status, &deposit.ProviderReference, &deposit.PaymentURL, &deposit.TransactionID,
+		&deposit.FailureReason, &deposit.CreatedAt, &deposit.UpdatedAt, &deposit.CompletedAt)
+	if err != nil {
+		return nil, err
+	}
+	deposit.Amount = deposit.Amount.WithCurrency(deposit.Currency)
+	return &deposit, nil
+}
@@ -0,0 +1,435 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"microjob-backend/models"
+	"microjob-backend/money"
+)
+
+// Dispute resolutions
+const (
+	DisputePayFull         = "pay_full"
+	DisputePartialPay      = "partial_pay"
+	DisputeUpholdRejection = "uphold_rejection"
+)
+
+var (
+	ErrDisputeNotFound        = errors.New("dispute not found")
+	ErrDisputeClosed          = errors.New("dispute has already been resolved")
+	ErrDisputeWindowClosed    = errors.New("the rejection can no longer be disputed")
+	ErrInvalidDisputeDecision = errors.New("decision must be pay_full, partial_pay or uphold_rejection")
+	ErrInvalidPartialAmount   = errors.New("a partial payment must be more than zero and less than the full amount")
+)
+
+var disputeActions = map[string]string{
+	DisputePayFull:         WorkProofActionDisputePayFull,
+	DisputePartialPay:      WorkProofActionDisputePartial,
+	DisputeUpholdRejection: WorkProofActionDisputeUphold,
+}
+
+type DisputeService struct {
+	db            *sql.DB
+	workProofs    *WorkProofService
+	walletService *WalletService
+}
+
+func NewDisputeService(db *sql.DB, workProofs *WorkProofService, walletService *WalletService) *DisputeService {
+	return &DisputeService{
+		db:            db,
+		workProofs:    workProofs,
+		walletService: walletService,
+	}
+}
+
+// OpenDispute lets the worker contest a rejected proof before its rejection
+// deadline. The deadline no longer applies once the dispute is open; the
+// proof waits for an admin instead.
+func (ds *DisputeService) OpenDispute(proofID, workerID, reason, description, requestedAction string, evidence []string) (*models.WorkProofDispute, error) {
+	tx, err := ds.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if evidence == nil {
+		evidence = []string{}
+	}
+	dispute := &models.WorkProofDispute{
+		Reason:      reason,
+		Description: description,
+		Status:      "pending",
+		Priority:    "medium",
+	}
+	if requestedAction != "" {
+		dispute.RequestedAction = &requestedAction
+	}
+
+	err = ds.workProofs.transitionTx(tx, proofID, WorkProofActionOpenDispute, workerID, reason,
+		func(tx *sql.Tx, proof *models.WorkProof, now time.Time) error {
+			if proof.RejectionDeadline != nil && now.After(*proof.RejectionDeadline) {
+				return ErrDisputeWindowClosed
+			}
+
+			evidenceJSON, _ := json.Marshal(evidence)
+			_, err := tx.Exec(`
+				UPDATE work_proofs
+				SET dispute_reason = $1, dispute_evidence = $2, dispute_requested_action = $3
+				WHERE id = $4`, reason, string(evidenceJSON), dispute.RequestedAction, proof.ID)
+			if err != nil {
+				return err
+			}
+
+			err = tx.QueryRow(`SELECT currency FROM jobs WHERE id = $1`, proof.JobID).Scan(&dispute.Currency)
+			if err != nil {
+				return err
+			}
+
+			dispute.WorkProofID = proof.ID
+			dispute.JobID = proof.JobID
+			dispute.WorkerID = proof.WorkerID
+			dispute.EmployerID = proof.EmployerID
+			dispute.Amount = proof.PaymentAmount.WithCurrency(dispute.Currency)
+			dispute.CreatedAt = now
+			dispute.UpdatedAt = now
+			err = tx.QueryRow(`
+				INSERT INTO work_proof_disputes (work_proof_id, job_id, worker_id, employer_id, reason,
+					description, requested_action, currency, amount, status, priority, created_at, updated_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $12)
+				RETURNING id`,
+				dispute.WorkProofID, dispute.JobID, dispute.WorkerID, dispute.EmployerID, dispute.Reason,
+				dispute.Description, dispute.RequestedAction, dispute.Currency, dispute.Amount, dispute.Status,
+				dispute.Priority, now).Scan(&dispute.ID)
+			if err != nil {
+				return err
+			}
+
+			message := description
+			if message == "" {
+				message = reason
+			}
+			_, err = insertDisputeMessage(tx, dispute.ID, workerID, WorkProofActorWorker, message, evidence, now)
+			dispute.EvidenceCount = len(evidence)
+			return err
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	return dispute, tx.Commit()
+}
+
+// AddMessage adds a message, with any evidence, to an open dispute. Workers
+// and employers may only write on their own disputes; admins pass the admin
+// role.
+func (ds *DisputeService) AddMessage(disputeID, senderID, role, message string, evidence []string) (*models.DisputeMessage, error) {
+	tx, err := ds.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	dispute, err := lockDispute(tx, disputeID)
+	if err != nil {
+		return nil, err
+	}
+	if role != WorkProofActorAdmin {
+		role, err = disputeRole(dispute, senderID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if dispute.Status == "resolved" {
+		return nil, ErrDisputeClosed
+	}
+
+	now := time.Now()
+	msg, err := insertDisputeMessage(tx, dispute.ID, senderID, role, message, evidence, now)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`UPDATE work_proof_disputes SET updated_at = $1 WHERE id = $2`, now, dispute.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, tx.Commit()
+}
+
+// GetDispute returns a dispute with its messages. Only its worker, its
+// employer and admins may see it.
+func (ds *DisputeService) GetDispute(disputeID, userID string, isAdmin bool) (*models.WorkProofDispute, error) {
+	dispute, err := scanDispute(ds.db.QueryRow(disputeSelect+` WHERE d.id = $1`, disputeID))
+	if err == sql.ErrNoRows {
+		return nil, ErrDisputeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !isAdmin {
+		if _, err := disputeRole(dispute, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := ds.db.Query(`
+		SELECT id, dispute_id, sender_id, sender_role, message, evidence, created_at
+		FROM work_proof_dispute_messages
+		WHERE dispute_id = $1
+		ORDER BY created_at, id`, dispute.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var msg models.DisputeMessage
+		var evidenceJSON []byte
+		err := rows.Scan(&msg.ID, &msg.DisputeID, &msg.SenderID, &msg.SenderRole, &msg.Message, &evidenceJSON,
+			&msg.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		json.Unmarshal(evidenceJSON, &msg.Evidence)
+		dispute.Messages = append(dispute.Messages, msg)
+	}
+
+	return dispute, rows.Err()
+}
+
+// GetDisputes is the admin moderation queue: most urgent first, then oldest
+// first within a priority so nothing waits forever.
+func (ds *DisputeService) GetDisputes(status, priority string, limit, offset int) ([]models.WorkProofDispute, int, error) {
+	// "open" covers every status short of resolved
+	where := `
+		WHERE (CASE WHEN $1 = 'open' THEN d.status <> 'resolved' ELSE $1 = '' OR d.status = $1 END)
+		  AND ($2 = '' OR d.priority = $2)`
+
+	var total int
+	err := ds.db.QueryRow(`SELECT COUNT(*) FROM work_proof_disputes d`+where, status, priority).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := ds.db.Query(disputeSelect+where+`
+		ORDER BY CASE d.priority WHEN 'urgent' THEN 4 WHEN 'high' THEN 3 WHEN 'medium' THEN 2 ELSE 1 END DESC,
+			d.created_at
+		LIMIT $3 OFFSET $4`, status, priority, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var disputes []models.WorkProofDispute
+	for rows.Next() {
+		dispute, err := scanDispute(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		disputes = append(disputes, *dispute)
+	}
+
+	return disputes, total, rows.Err()
+}
+
+// TakeDispute assigns an open dispute to an admin. Pending disputes move to
+// under review; escalated ones stay escalated.
+func (ds *DisputeService) TakeDispute(disputeID, adminID string) (*models.WorkProofDispute, error) {
+	return ds.update(disputeID, func(tx *sql.Tx, dispute *models.WorkProofDispute, now time.Time) error {
+		if dispute.Status == "pending" {
+			dispute.Status = "under_review"
+		}
+		dispute.AdminID = &adminID
+		_, err := tx.Exec(`
+			UPDATE work_proof_disputes SET status = $1, admin_id = $2, updated_at = $3 WHERE id = $4`,
+			dispute.Status, adminID, now, dispute.ID)
+		return err
+	})
+}
+
+// EscalateDispute marks an open dispute urgent and records why on it.
+func (ds *DisputeService) EscalateDispute(disputeID, adminID, reason string) (*models.WorkProofDispute, error) {
+	return ds.update(disputeID, func(tx *sql.Tx, dispute *models.WorkProofDispute, now time.Time) error {
+		dispute.Status = "escalated"
+		dispute.Priority = "urgent"
+		_, err := tx.Exec(`
+			UPDATE work_proof_disputes SET status = $1, priority = $2, updated_at = $3 WHERE id = $4`,
+			dispute.Status, dispute.Priority, now, dispute.ID)
+		if err != nil {
+			return err
+		}
+		_, err = insertDisputeMessage(tx, dispute.ID, adminID, WorkProofActorAdmin, "Escalated: "+reason, nil, now)
+		return err
+	})
+}
+
+// ResolveDispute settles a dispute and moves the proof to its final status.
+// Paying in full or in part pays the worker out of the job's escrow, or the
+// employer's wallet when the job has none or it has been refunded, as an
+// approval would. Whatever is not paid stays in escrow and goes back to the
+// employer when the escrow is closed. A zero amount for a partial payment pays
+// half.
+func (ds *DisputeService) ResolveDispute(disputeID, adminID, decision string, amount money.Money, notes string) (*models.WorkProofDispute, error) {
+	action, ok := disputeActions[decision]
+	if !ok {
+		return nil, ErrInvalidDisputeDecision
+	}
+
+	return ds.update(disputeID, func(tx *sql.Tx, dispute *models.WorkProofDispute, now time.Time) error {
+		paid, err := disputePayout(dispute, decision, amount)
+		if err != nil {
+			return err
+		}
+
+		err = ds.workProofs.applyTransition(tx, dispute.WorkProofID, action, adminID, WorkProofActorAdmin, notes,
+			func(tx *sql.Tx, proof *models.WorkProof, now time.Time) error {
+				if paid.IsZero() {
+					return nil
+				}
+				return ds.walletService.payForWorkTx(tx,
+					proof.JobID,
+					proof.EmployerID,
+					proof.WorkerID,
+					paid,
+					fmt.Sprintf("Dispute resolution: payment for %s", proof.Title),
+					dispute.ID,
+					"dispute_resolution",
+				)
+			})
+		if err != nil {
+			return err
+		}
+
+		dispute.Status = "resolved"
+		dispute.Resolution = &decision
+		dispute.ResolvedAmount = &paid
+		dispute.AdminID = &adminID
+		dispute.AdminNotes = &notes
+		dispute.ResolvedAt = &now
+		_, err = tx.Exec(`
+			UPDATE work_proof_disputes
+			SET status = $1, resolution = $2, resolved_amount = $3, admin_id = $4, admin_notes = $5,
+				resolved_at = $6, updated_at = $6
+			WHERE id = $7`, dispute.Status, decision, paid, adminID, notes, now, dispute.ID)
+		return err
+	})
+}
+
+// disputePayout is what the worker is paid when an admin settles a dispute
+// with decision. A partial payment defaults to half the disputed amount and
+// must be less than all of it; an upheld rejection pays nothing.
+func disputePayout(dispute *models.WorkProofDispute, decision string, amount money.Money) (money.Money, error) {
+	switch decision {
+	case DisputePayFull:
+		return dispute.Amount, nil
+	case DisputePartialPay:
+		paid := amount.WithCurrency(dispute.Currency)
+		if paid.IsZero() {
+			paid = money.New(dispute.Amount.Minor/2, dispute.Currency)
+		}
+		if !paid.IsPositive() || !paid.LessThan(dispute.Amount) {
+			return money.Money{}, ErrInvalidPartialAmount
+		}
+		return paid, nil
+	}
+	return money.Money{}, nil
+}
+
+// update runs change against a locked open dispute in one transaction.
+func (ds *DisputeService) update(disputeID string, change func(tx *sql.Tx, dispute *models.WorkProofDispute, now time.Time) error) (*models.WorkProofDispute, error) {
+	tx, err := ds.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	dispute, err := lockDispute(tx, disputeID)
+	if err != nil {
+		return nil, err
+	}
+	if dispute.Status == "resolved" {
+		return nil, ErrDisputeClosed
+	}
+
+	now := time.Now()
+	dispute.UpdatedAt = now
+	if err := change(tx, dispute, now); err != nil {
+		return nil, err
+	}
+
+	return dispute, tx.Commit()
+}
+
+func disputeRole(dispute *models.WorkProofDispute, userID string) (string, error) {
+	switch userID {
+	case dispute.WorkerID:
+		return WorkProofActorWorker, nil
+	case dispute.EmployerID:
+		return WorkProofActorEmployer, nil
+	}
+	return "", ErrWorkProofForbidden
+}
+
+func insertDisputeMessage(tx *sql.Tx, disputeID, senderID, role, message string, evidence []string, at time.Time) (*models.DisputeMessage, error) {
+	if evidence == nil {
+		evidence = []string{}
+	}
+
+	evidenceJSON, _ := json.Marshal(evidence)
+
+	msg := &models.DisputeMessage{
+		DisputeID:  disputeID,
+		SenderID:   senderID,
+		SenderRole: role,
+		Message:    message,
+		Evidence:   evidence,
+		CreatedAt:  at,
+	}
+	err := tx.QueryRow(`
+		INSERT INTO work_proof_dispute_messages (dispute_id, sender_id, sender_role, message, evidence, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`, disputeID, senderID, role, message, evidenceJSON, at).Scan(&msg.ID)
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+const disputeSelect = `
+	SELECT d.id, d.work_proof_id, d.job_id, d.worker_id, d.employer_id, d.reason, d.description,
+		d.requested_action, d.currency, d.amount, d.status, d.priority, d.resolution, d.resolved_amount,
+		d.admin_id, d.admin_notes, d.created_at, d.updated_at, d.resolved_at,
+		(SELECT COALESCE(SUM(jsonb_array_length(m.evidence)), 0)
+		 FROM work_proof_dispute_messages m WHERE m.dispute_id = d.id)
+	FROM work_proof_disputes d`
+
+func lockDispute(tx *sql.Tx, disputeID string) (*models.WorkProofDispute, error) {
+	dispute, err := scanDispute(tx.QueryRow(disputeSelect+` WHERE d.id = $1 FOR UPDATE OF d`, disputeID))
+	if err == sql.ErrNoRows {
+		return nil, ErrDisputeNotFound
+	}
+	return dispute, err
+}
+
+func scanDispute(row interface{ Scan(...interface{}) error }) (*models.WorkProofDispute, error) {
+	var dispute models.WorkProofDispute
+	var resolvedAmount *money.Money
+	err := row.Scan(&dispute.ID, &dispute.WorkProofID, &dispute.JobID, &dispute.WorkerID, &dispute.EmployerID,
+		&dispute.Reason, &dispute.Description, &dispute.RequestedAction, &dispute.Currency, &dispute.Amount,
+		&dispute.Status, &dispute.Priority, &dispute.Resolution, &resolvedAmount, &dispute.AdminID,
+		&dispute.AdminNotes, &dispute.CreatedAt, &dispute.UpdatedAt, &dispute.ResolvedAt, &dispute.EvidenceCount)
+	if err != nil {
+		return nil, err
+	}
+	dispute.Amount = dispute.Amount.WithCurrency(dispute.Currency)
+	if resolvedAmount != nil {
+		resolved := resolvedAmount.WithCurrency(dispute.Currency)
+		dispute.ResolvedAmount = &resolved
+	}
+	return &dispute, nil
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"testing"
+
+	"microjob-backend/models"
+	"microjob-backend/money"
+)
+
+func TestDisputePayout(t *testing.T) {
+	dispute := &models.WorkProofDispute{Currency: "EUR", Amount: money.New(1001, "EUR")}
+	jpyDispute := &models.WorkProofDispute{Currency: "JPY", Amount: money.New(1000, "JPY")}
+
+	tests := []struct {
+		name     string
+		dispute  *models.WorkProofDispute
+		decision string
+		amount   money.Money
+		want     money.Money
+		wantErr  error
+	}{
+		{"pay in full", dispute, DisputePayFull, money.Money{}, money.New(1001, "EUR"), nil},
+		{"full ignores the amount", dispute, DisputePayFull, money.New(300, "EUR"), money.New(1001, "EUR"), nil},
+		{"partial amount", dispute, DisputePartialPay, money.New(300, "EUR"), money.New(300, "EUR"), nil},
+		{"partial defaults to half", dispute, DisputePartialPay, money.Money{}, money.New(500, "EUR"), nil},
+		{"partial in the dispute's currency", jpyDispute, DisputePartialPay, money.New(25000, ""), money.New(250, "JPY"), nil},
+		{"partial of everything", dispute, DisputePartialPay, money.New(1001, "EUR"), money.Money{}, ErrInvalidPartialAmount},
+		{"partial of more than everything", dispute, DisputePartialPay, money.New(2000, "EUR"), money.Money{},
+			ErrInvalidPartialAmount},
+		{"negative partial", dispute, DisputePartialPay, money.New(-100, "EUR"), money.Money{}, ErrInvalidPartialAmount},
+		{"upheld rejection pays nothing", dispute, DisputeUpholdRejection, money.New(300, "EUR"), money.Money{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := disputePayout(tt.dispute, tt.decision, tt.amount)
+			if err != tt.wantErr {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("paid %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
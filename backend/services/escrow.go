@@ -0,0 +1,425 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"microjob-backend/models"
+	"microjob-backend/money"
+)
+
+var (
+	ErrNoEscrow        = errors.New("job has no escrow")
+	ErrEscrowExhausted = errors.New("job escrow does not cover this payment")
+	ErrEscrowRefunded  = errors.New("job escrow has been refunded to the employer")
+
+	// ErrRefundedEscrowUnpaid is returned when work on a job whose escrow was
+	// refunded is paid and the employer's balance does not cover it.
+	ErrRefundedEscrowUnpaid = errors.New("the job's escrow was refunded and the employer's balance does not cover this payment")
+)
+
+type EscrowService struct {
+	db     *sql.DB
+	ledger *LedgerService
+	fees   *FeeEngine
+}
+
+func NewEscrowService(db *sql.DB) *EscrowService {
+	return &EscrowService{
+		db:     db,
+		ledger: NewLedgerService(db),
+		fees:   NewFeeEngine(db),
+	}
+}
+
+// FundJob moves budget × required workers from the employer's available
+// balance in the job's currency into the job's escrow account, plus the payout
+// fee per worker when that fee is charged to the employer. The fee is stored
+// on the escrow so workers are paid on the terms the job was funded with.
+// Jobs without a budget are not escrowed.
+func (es *EscrowService) FundJob(tx *sql.Tx, job *models.Job) (*models.JobEscrow, error) {
+	workers := job.RequiredWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	if !job.BudgetMax.IsPositive() {
+		return nil, nil
+	}
+
+	fee, err := es.fees.Calculate(FeeJobPayout, job.BudgetMax, job.UserID, "")
+	if err != nil {
+		return nil, err
+	}
+	total := fee.PayerPays.Mul(int64(workers))
+
+	now := time.Now()
+	escrow := &models.JobEscrow{
+		ID:              uuid.New().String(),
+		JobID:           job.ID,
+		EmployerID:      job.UserID,
+		Currency:        normalizeCurrency(job.BudgetMax.Currency),
+		AmountPerWorker: job.BudgetMax,
+		WorkersCount:    workers,
+		FeePerWorker:    fee.TotalFee,
+		FeeChargedTo:    &fee.ChargedTo,
+		FundedAmount:    total,
+		Status:          "funded",
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	description := fmt.Sprintf("Escrow for job: %s", job.Title)
+	entry := &models.LedgerEntry{
+		EntryType:     "escrow_fund",
+		Description:   &description,
+		ReferenceID:   &job.ID,
+		ReferenceType: stringPtr("job"),
+	}
+	err = es.ledger.Post(tx, entry, []LedgerLeg{
+		{AccountType: AccountUserAvailable, OwnerID: job.UserID, Amount: total.Neg()},
+		{AccountType: AccountEscrow, OwnerID: job.ID, Amount: total},
+	})
+	if err != nil {
+		return nil, err
+	}
+	escrow.LedgerEntryID = &entry.ID
+
+	_, err = tx.Exec(`
+		INSERT INTO job_escrows (id, job_id, employer_id, currency, amount_per_worker, workers_count, fee_per_worker,
+			fee_charged_to, funded_amount, released_amount, refunded_amount, status, ledger_entry_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 0, 0, $10, $11, $12, $13)`,
+		escrow.ID, escrow.JobID, escrow.EmployerID, escrow.Currency, escrow.AmountPerWorker, escrow.WorkersCount,
+		escrow.FeePerWorker, escrow.FeeChargedTo, escrow.FundedAmount, escrow.Status, escrow.LedgerEntryID,
+		escrow.CreatedAt, escrow.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertWalletTransaction(tx, &models.WalletTransaction{
+		UserID:        job.UserID,
+		Type:          "escrow_hold",
+		Amount:        total,
+		Description:   &description,
+		ReferenceID:   &job.ID,
+		ReferenceType: stringPtr("job"),
+		BalanceType:   "deposit",
+		Status:        "completed",
+		LedgerEntryID: &entry.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return escrow, nil
+}
+
+// Release pays a worker amount out of the job's escrow into their pending
+// balance, moving the payout fee fixed at funding to platform fees. It returns
+// ErrNoEscrow when the job was never escrowed and ErrEscrowRefunded when the
+// escrow has already gone back to the employer.
+func (es *EscrowService) Release(tx *sql.Tx, jobID, workerID string, amount money.Money, description, referenceID, referenceType string) error {
+	escrow, err := es.lockByJobID(tx, jobID)
+	if err != nil {
+		return err
+	}
+	fee, err := es.payoutFee(escrow, workerID, amount.WithCurrency(escrow.Currency))
+	if err != nil {
+		return err
+	}
+	if escrow.Status == "refunded" {
+		return ErrEscrowRefunded
+	}
+	if escrow.Remaining().LessThan(fee.PayerPays) {
+		return ErrEscrowExhausted
+	}
+
+	legs := []LedgerLeg{
+		{AccountType: AccountEscrow, OwnerID: jobID, Amount: fee.PayerPays.Neg()},
+		{AccountType: AccountUserPending, OwnerID: workerID, Amount: fee.PayeeReceives},
+	}
+	if fee.TotalFee.IsPositive() {
+		legs = append(legs, LedgerLeg{AccountType: AccountPlatformFees, Amount: fee.TotalFee})
+	}
+
+	entry := &models.LedgerEntry{
+		EntryType:     "escrow_release",
+		Description:   &description,
+		ReferenceID:   &referenceID,
+		ReferenceType: &referenceType,
+	}
+	err = es.ledger.Post(tx, entry, legs)
+	if err != nil {
+		return err
+	}
+
+	earning := &models.WalletTransaction{
+		UserID:        workerID,
+		Type:          "earning",
+		Amount:        fee.PayeeReceives,
+		Description:   &description,
+		ReferenceID:   &referenceID,
+		ReferenceType: &referenceType,
+		BalanceType:   "pending", // Earnings go to pending first
+		Status:        "completed",
+		LedgerEntryID: &entry.ID,
+	}
+	err = insertWalletTransaction(tx, earning)
+	if err != nil {
+		return err
+	}
+	err = holdEarning(tx, earning)
+	if err != nil {
+		return err
+	}
+
+	// The employer's balance went down when the escrow was funded; the money
+	// counts as spent once it is paid out, and this row records that
+	err = insertWalletTransaction(tx, &models.WalletTransaction{
+		UserID:        escrow.EmployerID,
+		Type:          "escrow_release",
+		Amount:        fee.PayerPays,
+		Description:   &description,
+		ReferenceID:   &referenceID,
+		ReferenceType: &referenceType,
+		BalanceType:   "deposit",
+		Status:        "completed",
+		LedgerEntryID: &entry.ID,
+	})
+	if err != nil {
+		return err
+	}
+	err = updateWalletTotals(tx, escrow.EmployerID, money.Money{}, fee.PayerPays)
+	if err != nil {
+		return err
+	}
+	err = updateWalletTotals(tx, workerID, fee.PayeeReceives, money.Money{})
+	if err != nil {
+		return err
+	}
+
+	escrow.ReleasedAmount = escrow.ReleasedAmount.Add(fee.PayerPays)
+	escrow.Status = "partially_released"
+	if escrow.Remaining().IsZero() {
+		escrow.Status = "released"
+	}
+	return es.update(tx, escrow)
+}
+
+// PayoutFee prices paying amount of work on a job as Release would, with the
+// current fee when the job was never escrowed.
+func (es *EscrowService) PayoutFee(tx *sql.Tx, jobID, employerID, workerID string, amount money.Money) (*FeeBreakdown, error) {
+	escrow, err := es.lockByJobID(tx, jobID)
+	if err == ErrNoEscrow {
+		return es.fees.Calculate(FeeJobPayout, amount, employerID, workerID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return es.payoutFee(escrow, workerID, amount.WithCurrency(escrow.Currency))
+}
+
+// payoutFee prices paying amount to a worker with the fee stored at funding,
+// prorated when the amount is less than a full share. Escrows funded before
+// the fee was stored are priced at the current fee.
+func (es *EscrowService) payoutFee(escrow *models.JobEscrow, workerID string, amount money.Money) (*FeeBreakdown, error) {
+	if escrow.FeeChargedTo == nil {
+		return es.fees.Calculate(FeeJobPayout, amount, escrow.EmployerID, workerID)
+	}
+
+	total := escrow.FeePerWorker
+	if !amount.Equal(escrow.AmountPerWorker) {
+		total = total.Prorate(amount, escrow.AmountPerWorker, money.FeeRounding)
+	}
+	fee := &FeeBreakdown{
+		TransactionType: FeeJobPayout,
+		PayerID:         escrow.EmployerID,
+		PayeeID:         workerID,
+		Amount:          amount,
+		ChargedTo:       *escrow.FeeChargedTo,
+		Items:           []FeeItem{},
+		TotalFee:        total,
+		PayerPays:       amount,
+		PayeeReceives:   amount,
+	}
+	if total.IsPositive() {
+		fee.Items = append(fee.Items, FeeItem{Label: "Job payout fee", Amount: total})
+	}
+	if fee.ChargedTo == FeeChargedToPayer {
+		fee.PayerPays = amount.Add(total)
+	} else {
+		fee.PayeeReceives = amount.Sub(total)
+	}
+	if !fee.PayeeReceives.IsPositive() {
+		return fee, ErrFeeExceedsAmount
+	}
+	return fee, nil
+}
+
+// RefundRemaining returns whatever is still in escrow to the employer and
+// closes the escrow. Jobs without escrow refund nothing.
+func (es *EscrowService) RefundRemaining(tx *sql.Tx, jobID, reason string) (money.Money, error) {
+	escrow, err := es.lockByJobID(tx, jobID)
+	if err == ErrNoEscrow {
+		return money.Money{}, nil
+	}
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	remaining := escrow.Remaining()
+	if remaining.IsPositive() {
+		description := fmt.Sprintf("Escrow refund: %s", reason)
+		entry := &models.LedgerEntry{
+			EntryType:     "escrow_refund",
+			Description:   &description,
+			ReferenceID:   &jobID,
+			ReferenceType: stringPtr("job"),
+		}
+		err = es.ledger.Post(tx, entry, []LedgerLeg{
+			{AccountType: AccountEscrow, OwnerID: jobID, Amount: remaining.Neg()},
+			{AccountType: AccountUserAvailable, OwnerID: escrow.EmployerID, Amount: remaining},
+		})
+		if err != nil {
+			return money.Money{}, err
+		}
+
+		err = insertWalletTransaction(tx, &models.WalletTransaction{
+			UserID:        escrow.EmployerID,
+			Type:          "escrow_refund",
+			Amount:        remaining,
+			Description:   &description,
+			ReferenceID:   &jobID,
+			ReferenceType: stringPtr("job"),
+			BalanceType:   "deposit",
+			Status:        "completed",
+			LedgerEntryID: &entry.ID,
+		})
+		if err != nil {
+			return money.Money{}, err
+		}
+
+		escrow.RefundedAmount = escrow.RefundedAmount.Add(remaining)
+		escrow.Status = "refunded"
+	}
+
+	now := time.Now()
+	escrow.ClosedAt = &now
+	return remaining, es.update(tx, escrow)
+}
+
+// RefundExpiredJobs refunds the unused escrow of jobs that were cancelled or
+// whose deadline has passed, once no submitted work is waiting for review and
+// no rejected work can still be disputed.
+func (es *EscrowService) RefundExpiredJobs() (int, error) {
+	rows, err := es.db.Query(`
+		SELECT e.job_id
+		FROM job_escrows e
+		JOIN jobs j ON e.job_id = j.id
+		WHERE e.closed_at IS NULL
+		  AND (j.status IN ('cancelled', 'expired') OR (j.deadline IS NOT NULL AND j.deadline < NOW()))
+		  AND NOT EXISTS (
+			  SELECT 1 FROM work_proofs wp
+			  WHERE wp.job_id = e.job_id
+			    AND (wp.status IN ('submitted', 'pending', 'revision_requested', 'disputed')
+			      OR (wp.status = 'rejected' AND wp.rejection_deadline > NOW()))
+		  )`)
+	if err != nil {
+		return 0, err
+	}
+
+	var jobIDs []string
+	for rows.Next() {
+		var jobID string
+		if err := rows.Scan(&jobID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		jobIDs = append(jobIDs, jobID)
+	}
+	rows.Close()
+
+	refunded := 0
+	for _, jobID := range jobIDs {
+		err := es.refundExpiredJob(jobID)
+		if err != nil {
+			log.Printf("Failed to refund escrow for job %s: %v", jobID, err)
+			continue
+		}
+		refunded++
+	}
+
+	return refunded, nil
+}
+
+func (es *EscrowService) refundExpiredJob(jobID string) error {
+	tx, err := es.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE jobs SET status = 'expired', updated_at = $1
+		WHERE id = $2 AND status NOT IN ('cancelled', 'expired', 'completed')`, time.Now(), jobID)
+	if err != nil {
+		return err
+	}
+
+	_, err = es.RefundRemaining(tx, jobID, "job expired")
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (es *EscrowService) GetByJobID(jobID string) (*models.JobEscrow, error) {
+	escrow, err := scanEscrow(es.db.QueryRow(escrowSelect+` WHERE job_id = $1`, jobID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNoEscrow
+	}
+	return escrow, err
+}
+
+const escrowSelect = `
+	SELECT id, job_id, employer_id, currency, amount_per_worker, workers_count, fee_per_worker, fee_charged_to,
+		funded_amount, released_amount, refunded_amount, status, ledger_entry_id, created_at, updated_at, closed_at
+	FROM job_escrows`
+
+func (es *EscrowService) lockByJobID(tx *sql.Tx, jobID string) (*models.JobEscrow, error) {
+	escrow, err := scanEscrow(tx.QueryRow(escrowSelect+` WHERE job_id = $1 FOR UPDATE`, jobID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNoEscrow
+	}
+	return escrow, err
+}
+
+func scanEscrow(row *sql.Row) (*models.JobEscrow, error) {
+	var escrow models.JobEscrow
+	err := row.Scan(&escrow.ID, &escrow.JobID, &escrow.EmployerID, &escrow.Currency, &escrow.AmountPerWorker,
+		&escrow.WorkersCount, &escrow.FeePerWorker, &escrow.FeeChargedTo, &escrow.FundedAmount, &escrow.ReleasedAmount, &escrow.RefundedAmount,
+		&escrow.Status, &escrow.LedgerEntryID, &escrow.CreatedAt, &escrow.UpdatedAt, &escrow.ClosedAt)
+	if err != nil {
+		return nil, err
+	}
+	escrow.AmountPerWorker = escrow.AmountPerWorker.WithCurrency(escrow.Currency)
+	escrow.FeePerWorker = escrow.FeePerWorker.WithCurrency(escrow.Currency)
+	escrow.FundedAmount = escrow.FundedAmount.WithCurrency(escrow.Currency)
+	escrow.ReleasedAmount = escrow.ReleasedAmount.WithCurrency(escrow.Currency)
+	escrow.RefundedAmount = escrow.RefundedAmount.WithCurrency(escrow.Currency)
+	return &escrow, nil
+}
+
+func (es *EscrowService) update(tx *sql.Tx, escrow *models.JobEscrow) error {
+	escrow.UpdatedAt = time.Now()
+	_, err := tx.Exec(`
+		UPDATE job_escrows
+		SET released_amount = $1, refunded_amount = $2, status = $3, updated_at = $4, closed_at = $5
+		WHERE id = $6`,
+		escrow.ReleasedAmount, escrow.RefundedAmount, escrow.Status, escrow.UpdatedAt, escrow.ClosedAt, escrow.ID)
+	return err
+}
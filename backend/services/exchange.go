@@ -0,0 +1,359 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"microjob-backend/models"
+	"microjob-backend/money"
+)
+
+var (
+	ErrUnsupportedCurrency = errors.New("currency is not supported")
+	ErrInvalidExchangeRate = errors.New("invalid exchange rate")
+	ErrSameCurrency        = errors.New("cannot convert a currency into itself")
+	ErrConversionTooSmall  = errors.New("amount is too small to convert")
+)
+
+// ExchangeService keeps the exchange rates admins set and converts wallet
+// balances between currencies at those rates.
+type ExchangeService struct {
+	db     *sql.DB
+	ledger *LedgerService
+	fees   *FeeEngine
+}
+
+func NewExchangeService(db *sql.DB) *ExchangeService {
+	return &ExchangeService{
+		db:     db,
+		ledger: NewLedgerService(db),
+		fees:   NewFeeEngine(db),
+	}
+}
+
+// ConversionQuote prices a conversion before it is made.
+type ConversionQuote struct {
+	FromCurrency    string             `json:"from_currency"`
+	ToCurrency      string             `json:"to_currency"`
+	Amount          money.Money        `json:"amount"` // debited from the source balance, fee included
+	FeeAmount       money.Money        `json:"fee_amount"`
+	FeeItems        []FeeItem          `json:"fee_items"`
+	ConvertedAmount money.Money        `json:"converted_amount"`
+	FromRate        money.ExchangeRate `json:"from_rate"`
+	ToRate          money.ExchangeRate `json:"to_rate"`
+}
+
+// normalizeCurrency upper-cases a currency code. Amounts without a currency
+// are in money.DefaultCurrency.
+func normalizeCurrency(currency string) string {
+	if currency == "" {
+		return money.DefaultCurrency
+	}
+	return strings.ToUpper(currency)
+}
+
+type rowQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// exchangeRate returns the current rate of currency. A currency is supported
+// once an admin has set a rate for it.
+func exchangeRate(q rowQueryer, currency string) (money.ExchangeRate, error) {
+	currency = normalizeCurrency(currency)
+	if currency == money.DefaultCurrency {
+		return money.BaseExchangeRate, nil
+	}
+
+	var rate money.ExchangeRate
+	err := q.QueryRow(`
+		SELECT rate FROM exchange_rates
+		WHERE currency = $1
+		ORDER BY created_at DESC
+		LIMIT 1`, currency).Scan(&rate)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, currency)
+	}
+	return rate, err
+}
+
+// supportedCurrency normalizes currency and checks that wallets can hold it.
+func supportedCurrency(q rowQueryer, currency string) (string, error) {
+	currency = normalizeCurrency(currency)
+	_, err := exchangeRate(q, currency)
+	return currency, err
+}
+
+// SetRate records a new rate for currency. Earlier rates are kept as history.
+// Balances are stored with two decimal places, so currencies with more minor
+// units cannot be held.
+func (es *ExchangeService) SetRate(currency string, rate money.ExchangeRate, adminID string) (*models.ExchangeRate, error) {
+	currency = normalizeCurrency(currency)
+	if len(currency) != 3 || strings.Trim(currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return nil, fmt.Errorf("%w: %q is not a currency code", ErrInvalidExchangeRate, currency)
+	}
+	if currency == money.DefaultCurrency {
+		return nil, fmt.Errorf("%w: %s is the base currency", ErrInvalidExchangeRate, currency)
+	}
+	if money.MinorUnits(currency) > 2 {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, currency)
+	}
+	if rate <= 0 {
+		return nil, fmt.Errorf("%w: rate must be positive", ErrInvalidExchangeRate)
+	}
+
+	record := &models.ExchangeRate{
+		ID:        uuid.New().String(),
+		Currency:  currency,
+		Rate:      rate,
+		SetBy:     &adminID,
+		CreatedAt: time.Now(),
+	}
+	_, err := es.db.Exec(`
+		INSERT INTO exchange_rates (id, currency, rate, set_by, created_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		record.ID, record.Currency, record.Rate, record.SetBy, record.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// GetRates returns the current rate of every supported currency.
+func (es *ExchangeService) GetRates() ([]models.ExchangeRate, error) {
+	return es.queryRates(`
+		SELECT DISTINCT ON (currency) id, currency, rate, set_by, created_at
+		FROM exchange_rates
+		ORDER BY currency, created_at DESC`)
+}
+
+// GetRateHistory returns the rates set for a currency, newest first.
+func (es *ExchangeService) GetRateHistory(currency string, limit, offset int) ([]models.ExchangeRate, error) {
+	return es.queryRates(`
+		SELECT id, currency, rate, set_by, created_at
+		FROM exchange_rates
+		WHERE currency = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3`, normalizeCurrency(currency), limit, offset)
+}
+
+func (es *ExchangeService) queryRates(query string, args ...interface{}) ([]models.ExchangeRate, error) {
+	rows, err := es.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []models.ExchangeRate{}
+	for rows.Next() {
+		var rate models.ExchangeRate
+		err := rows.Scan(&rate.ID, &rate.Currency, &rate.Rate, &rate.SetBy, &rate.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+
+	return rates, rows.Err()
+}
+
+// GetBalances returns a user's balance in every currency they hold, the
+// default currency first.
+func (es *ExchangeService) GetBalances(userID string) ([]models.WalletBalance, error) {
+	base := models.WalletBalance{UserID: userID, Currency: money.DefaultCurrency}
+	err := es.db.QueryRow(`
+		SELECT balance, pending_balance, locked_balance, total_earned, total_spent, updated_at
+		FROM wallets
+		WHERE user_id = $1`, userID).Scan(&base.Balance, &base.PendingBalance, &base.LockedBalance,
+		&base.TotalEarned, &base.TotalSpent, &base.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	balances := []models.WalletBalance{base}
+
+	rows, err := es.db.Query(`
+		SELECT currency, balance, pending_balance, locked_balance, total_earned, total_spent, updated_at
+		FROM wallet_balances
+		WHERE user_id = $1
+		ORDER BY currency`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		balance := models.WalletBalance{UserID: userID}
+		err := rows.Scan(&balance.Currency, &balance.Balance, &balance.PendingBalance, &balance.LockedBalance,
+			&balance.TotalEarned, &balance.TotalSpent, &balance.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		balance.Balance = balance.Balance.WithCurrency(balance.Currency)
+		balance.PendingBalance = balance.PendingBalance.WithCurrency(balance.Currency)
+		balance.LockedBalance = balance.LockedBalance.WithCurrency(balance.Currency)
+		balance.TotalEarned = balance.TotalEarned.WithCurrency(balance.Currency)
+		balance.TotalSpent = balance.TotalSpent.WithCurrency(balance.Currency)
+		balances = append(balances, balance)
+	}
+
+	return balances, rows.Err()
+}
+
+// QuoteConversion prices converting amount into another currency. The
+// currency conversion fee is taken in the source currency and what is left
+// is converted at the current rates, rounded down.
+func (es *ExchangeService) QuoteConversion(userID string, amount money.Money, to string) (*ConversionQuote, error) {
+	return es.quote(es.db, userID, amount, to)
+}
+
+func (es *ExchangeService) quote(q rowQueryer, userID string, amount money.Money, to string) (*ConversionQuote, error) {
+	quote := &ConversionQuote{
+		FromCurrency: normalizeCurrency(amount.Currency),
+		ToCurrency:   normalizeCurrency(to),
+	}
+	if quote.FromCurrency == quote.ToCurrency {
+		return nil, ErrSameCurrency
+	}
+
+	var err error
+	quote.FromRate, err = exchangeRate(q, quote.FromCurrency)
+	if err != nil {
+		return nil, err
+	}
+	quote.ToRate, err = exchangeRate(q, quote.ToCurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	fee, err := es.fees.Calculate(FeeCurrencyConversion, amount, userID, "")
+	if err != nil && err != ErrFeeExceedsAmount {
+		return nil, err
+	}
+	quote.Amount = fee.PayerPays
+	quote.FeeAmount = fee.TotalFee
+	quote.FeeItems = fee.Items
+	quote.ConvertedAmount = fee.PayeeReceives.Convert(quote.FromRate, quote.ToRate, quote.ToCurrency, money.RoundDown)
+	if err == ErrFeeExceedsAmount || !quote.ConvertedAmount.IsPositive() {
+		return quote, ErrConversionTooSmall
+	}
+
+	return quote, nil
+}
+
+// Convert moves amount out of the user's available balance in its currency
+// and credits the converted amount to their available balance in to. Both
+// sides pass through the FX clearing account so each currency balances.
+func (es *ExchangeService) Convert(userID string, amount money.Money, to string) (*models.CurrencyConversion, error) {
+	tx, err := es.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	quote, err := es.quote(tx, userID, amount, to)
+	if err != nil {
+		return nil, err
+	}
+
+	conversion := &models.CurrencyConversion{
+		ID:              uuid.New().String(),
+		UserID:          userID,
+		FromCurrency:    quote.FromCurrency,
+		ToCurrency:      quote.ToCurrency,
+		Amount:          quote.Amount,
+		FeeAmount:       quote.FeeAmount,
+		ConvertedAmount: quote.ConvertedAmount,
+		FromRate:        quote.FromRate,
+		ToRate:          quote.ToRate,
+		CreatedAt:       time.Now(),
+	}
+
+	description := fmt.Sprintf("Converted %s %s to %s %s",
+		quote.Amount, quote.FromCurrency, quote.ConvertedAmount, quote.ToCurrency)
+	entry := &models.LedgerEntry{
+		EntryType:     "currency_conversion",
+		Description:   &description,
+		ReferenceID:   &conversion.ID,
+		ReferenceType: stringPtr("currency_conversion"),
+	}
+	legs := []LedgerLeg{
+		{AccountType: AccountUserAvailable, OwnerID: userID, Amount: quote.Amount.Neg()},
+		{AccountType: AccountFXClearing, Amount: quote.Amount.Sub(quote.FeeAmount)},
+		{AccountType: AccountFXClearing, Amount: quote.ConvertedAmount.Neg()},
+		{AccountType: AccountUserAvailable, OwnerID: userID, Amount: quote.ConvertedAmount},
+	}
+	if quote.FeeAmount.IsPositive() {
+		legs = append(legs, LedgerLeg{AccountType: AccountPlatformFees, Amount: quote.FeeAmount})
+	}
+	err = es.ledger.Post(tx, entry, legs)
+	if err != nil {
+		return nil, err
+	}
+	conversion.LedgerEntryID = &entry.ID
+
+	_, err = tx.Exec(`
+		INSERT INTO currency_conversions (id, user_id, from_currency, to_currency, amount, fee_amount,
+			converted_amount, from_rate, to_rate, ledger_entry_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		conversion.ID, conversion.UserID, conversion.FromCurrency, conversion.ToCurrency, conversion.Amount,
+		conversion.FeeAmount, conversion.ConvertedAmount, conversion.FromRate, conversion.ToRate,
+		conversion.LedgerEntryID, conversion.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, transaction := range []*models.WalletTransaction{
+		{Type: "conversion_out", Amount: conversion.Amount},
+		{Type: "conversion_in", Amount: conversion.ConvertedAmount},
+	} {
+		transaction.UserID = userID
+		transaction.Description = &description
+		transaction.ReferenceID = &conversion.ID
+		transaction.ReferenceType = stringPtr("currency_conversion")
+		transaction.BalanceType = "deposit"
+		transaction.Status = "completed"
+		transaction.LedgerEntryID = &entry.ID
+		err = insertWalletTransaction(tx, transaction)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return conversion, tx.Commit()
+}
+
+// GetConversions lists a user's conversions, newest first.
+func (es *ExchangeService) GetConversions(userID string, limit, offset int) ([]models.CurrencyConversion, error) {
+	rows, err := es.db.Query(`
+		SELECT id, user_id, from_currency, to_currency, amount, fee_amount, converted_amount,
+			from_rate, to_rate, ledger_entry_id, created_at
+		FROM currency_conversions
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3`, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversions := []models.CurrencyConversion{}
+	for rows.Next() {
+		var conversion models.CurrencyConversion
+		err := rows.Scan(&conversion.ID, &conversion.UserID, &conversion.FromCurrency, &conversion.ToCurrency,
+			&conversion.Amount, &conversion.FeeAmount, &conversion.ConvertedAmount, &conversion.FromRate,
+			&conversion.ToRate, &conversion.LedgerEntryID, &conversion.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		conversion.Amount = conversion.Amount.WithCurrency(conversion.FromCurrency)
+		conversion.FeeAmount = conversion.FeeAmount.WithCurrency(conversion.FromCurrency)
+		conversion.ConvertedAmount = conversion.ConvertedAmount.WithCurrency(conversion.ToCurrency)
+		conversions = append(conversions, conversion)
+	}
+
+	return conversions, rows.Err()
+}
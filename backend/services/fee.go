@@ -0,0 +1,302 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"microjob-backend/models"
+	"microjob-backend/money"
+)
+
+// Transaction types priced by the fee engine. Each one is configured by the
+// admin_fee_settings row with the same fee_type.
+const (
+	FeeJobPayout          = "job_payout"
+	FeeMarketplaceOrder   = "marketplace_order"
+	FeeChatTransfer       = "chat_transfer"
+	FeeWithdrawal         = "withdrawal"
+	FeeSupportTicket      = "support_ticket"
+	FeeCurrencyConversion = "currency_conversion"
+)
+
+// FeeTransactionTypes lists every transaction type the engine prices.
+var FeeTransactionTypes = []string{FeeJobPayout, FeeMarketplaceOrder, FeeChatTransfer, FeeWithdrawal, FeeSupportTicket,
+	FeeCurrencyConversion}
+
+func IsFeeTransactionType(transactionType string) bool {
+	for _, feeType := range FeeTransactionTypes {
+		if feeType == transactionType {
+			return true
+		}
+	}
+	return false
+}
+
+// The side of a transaction that bears its fee.
+const (
+	FeeChargedToPayer = "payer"
+	FeeChargedToPayee = "payee"
+)
+
+var (
+	ErrFeeExceedsAmount  = errors.New("amount does not cover the fee")
+	ErrInvalidFeeSetting = errors.New("invalid fee setting")
+)
+
+// FeeItem is one line of a fee breakdown.
+type FeeItem struct {
+	Label  string      `json:"label"`
+	Amount money.Money `json:"amount"`
+}
+
+// FeeBreakdown itemizes the fee on a transaction. A fee charged to the payee
+// comes out of what they receive; a fee charged to the payer is added to what
+// they pay. Either way TotalFee goes to platform fees.
+type FeeBreakdown struct {
+	TransactionType string      `json:"transaction_type"`
+	PayerID         string      `json:"payer_id,omitempty"`
+	PayeeID         string      `json:"payee_id,omitempty"`
+	Amount          money.Money `json:"amount"`
+	ChargedTo       string      `json:"charged_to"`
+	Items           []FeeItem   `json:"items"`
+	TotalFee        money.Money `json:"total_fee"`
+	PayerPays       money.Money `json:"payer_pays"`
+	PayeeReceives   money.Money `json:"payee_receives"`
+}
+
+// FeeEngine prices every kind of transaction from admin_fee_settings.
+type FeeEngine struct {
+	db *sql.DB
+}
+
+func NewFeeEngine(db *sql.DB) *FeeEngine {
+	return &FeeEngine{db: db}
+}
+
+// Calculate prices a transaction with the fee setting for its type. Types
+// without an active setting are free. Fixed amounts in the setting are in
+// money.DefaultCurrency and are converted at the current rate for amounts in
+// other currencies. ErrFeeExceedsAmount is returned, along with the
+// breakdown, when the payee would receive nothing.
+func (fe *FeeEngine) Calculate(transactionType string, amount money.Money, payerID, payeeID string) (*FeeBreakdown, error) {
+	setting, err := fe.GetSetting(transactionType)
+	if err != nil {
+		return nil, err
+	}
+	if currency := normalizeCurrency(amount.Currency); setting != nil && currency != money.DefaultCurrency {
+		rate, err := exchangeRate(fe.db, currency)
+		if err != nil {
+			return nil, err
+		}
+		setting = settingInCurrency(setting, currency, rate)
+	}
+
+	return calculateWithSetting(setting, transactionType, amount, payerID, payeeID)
+}
+
+// calculateWithSetting is Calculate with the setting already in the amount's
+// currency.
+func calculateWithSetting(setting *models.AdminFeeSetting, transactionType string, amount money.Money, payerID, payeeID string) (*FeeBreakdown, error) {
+	breakdown := CalculateFee(setting, amount)
+	breakdown.TransactionType = transactionType
+	breakdown.PayerID = payerID
+	breakdown.PayeeID = payeeID
+	if !breakdown.PayeeReceives.IsPositive() {
+		return breakdown, ErrFeeExceedsAmount
+	}
+	return breakdown, nil
+}
+
+// GetSetting returns the fee setting for a transaction type, or nil if none
+// has been saved.
+func (fe *FeeEngine) GetSetting(transactionType string) (*models.AdminFeeSetting, error) {
+	setting, err := scanFeeSetting(fe.db.QueryRow(`
+		SELECT `+feeSettingColumns+`
+		FROM admin_fee_settings
+		WHERE fee_type = $1`, transactionType))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return setting, err
+}
+
+// CalculateFee prices amount with a fee setting that need not be saved, so
+// drafts can be previewed. A nil or inactive setting charges nothing.
+func CalculateFee(setting *models.AdminFeeSetting, amount money.Money) *FeeBreakdown {
+	breakdown := &FeeBreakdown{
+		Amount:    amount,
+		ChargedTo: FeeChargedToPayee,
+		Items:     []FeeItem{},
+		TotalFee:  money.New(0, amount.Currency),
+	}
+	if setting != nil && setting.IsActive {
+		if setting.ChargedTo == FeeChargedToPayer {
+			breakdown.ChargedTo = FeeChargedToPayer
+		}
+		breakdown.Items = feeItems(setting, amount)
+		for _, item := range breakdown.Items {
+			breakdown.TotalFee = breakdown.TotalFee.Add(item.Amount)
+		}
+	}
+
+	breakdown.PayerPays = amount
+	breakdown.PayeeReceives = amount
+	if breakdown.ChargedTo == FeeChargedToPayer {
+		breakdown.PayerPays = amount.Add(breakdown.TotalFee)
+	} else {
+		breakdown.PayeeReceives = amount.Sub(breakdown.TotalFee)
+	}
+	return breakdown
+}
+
+// NoFee is the breakdown of a transaction that carries no fee.
+func NoFee(amount money.Money) *FeeBreakdown {
+	return CalculateFee(nil, amount)
+}
+
+// feeItems lists the parts of a fee in the order they are applied: the
+// percentage, or one item per bracket when the setting is tiered, then the
+// fixed fee, then whatever brings the total up to the minimum or down to the
+// maximum. Percentages are rounded with money.FeeRounding.
+func feeItems(setting *models.AdminFeeSetting, amount money.Money) []FeeItem {
+	items := []FeeItem{}
+	subtotal := money.New(0, amount.Currency)
+	add := func(label string, fee money.Money) {
+		if fee.IsZero() {
+			return
+		}
+		items = append(items, FeeItem{Label: label, Amount: fee})
+		subtotal = subtotal.Add(fee)
+	}
+
+	if len(setting.Tiers) > 0 {
+		lower := money.New(0, amount.Currency)
+		for _, tier := range setting.Tiers {
+			if !amount.GreaterThan(lower) {
+				break
+			}
+			upper := amount
+			if tier.UpTo != nil && tier.UpTo.LessThan(amount) {
+				upper = *tier.UpTo
+			}
+			add(fmt.Sprintf("%s%% of %s to %s", tier.Percentage, lower, upper),
+				upper.Sub(lower).ApplyRate(tier.Percentage, money.FeeRounding))
+			if tier.UpTo == nil {
+				break
+			}
+			lower = *tier.UpTo
+		}
+	} else {
+		add(fmt.Sprintf("%s%% fee", setting.FeePercentage), amount.ApplyRate(setting.FeePercentage, money.FeeRounding))
+	}
+
+	add("Fixed fee", setting.FeeFixed)
+
+	if subtotal.LessThan(setting.MinimumFee) {
+		add("Minimum fee adjustment", setting.MinimumFee.Sub(subtotal))
+	}
+	if setting.MaximumFee != nil && subtotal.GreaterThan(*setting.MaximumFee) {
+		add("Maximum fee cap", setting.MaximumFee.Sub(subtotal))
+	}
+
+	return items
+}
+
+// settingInCurrency copies a fee setting with its fixed fee, limits and
+// bracket bounds converted from money.DefaultCurrency at rate.
+func settingInCurrency(setting *models.AdminFeeSetting, currency string, rate money.ExchangeRate) *models.AdminFeeSetting {
+	convert := func(amount money.Money) money.Money {
+		return amount.Convert(money.BaseExchangeRate, rate, currency, money.FeeRounding)
+	}
+
+	converted := *setting
+	converted.FeeFixed = convert(setting.FeeFixed)
+	converted.MinimumFee = convert(setting.MinimumFee)
+	if setting.MaximumFee != nil {
+		maximum := convert(*setting.MaximumFee)
+		converted.MaximumFee = &maximum
+	}
+	converted.Tiers = make([]models.FeeTier, len(setting.Tiers))
+	for i, tier := range setting.Tiers {
+		if tier.UpTo != nil {
+			upTo := convert(*tier.UpTo)
+			tier.UpTo = &upTo
+		}
+		converted.Tiers[i] = tier
+	}
+	return &converted
+}
+
+// prepareFeeSetting fills in defaults the admin may leave out and validates
+// the result.
+func prepareFeeSetting(setting *models.AdminFeeSetting) error {
+	if setting.ChargedTo == "" {
+		setting.ChargedTo = FeeChargedToPayee
+	}
+	if setting.Tiers == nil {
+		setting.Tiers = []models.FeeTier{}
+	}
+	return ValidateFeeSetting(setting)
+}
+
+// ValidateFeeSetting checks a fee setting before it is saved. Brackets must
+// rise strictly and only the last may be open-ended.
+func ValidateFeeSetting(setting *models.AdminFeeSetting) error {
+	if setting.ChargedTo != FeeChargedToPayer && setting.ChargedTo != FeeChargedToPayee {
+		return fmt.Errorf("%w: charged to must be %q or %q", ErrInvalidFeeSetting, FeeChargedToPayer, FeeChargedToPayee)
+	}
+	if setting.FeePercentage < 0 || setting.FeeFixed.IsNegative() || setting.MinimumFee.IsNegative() {
+		return fmt.Errorf("%w: fees cannot be negative", ErrInvalidFeeSetting)
+	}
+	if setting.MaximumFee != nil && setting.MaximumFee.LessThan(setting.MinimumFee) {
+		return fmt.Errorf("%w: maximum fee is below the minimum fee", ErrInvalidFeeSetting)
+	}
+
+	lower := money.Money{}
+	for i, tier := range setting.Tiers {
+		if tier.Percentage < 0 {
+			return fmt.Errorf("%w: bracket %d has a negative percentage", ErrInvalidFeeSetting, i+1)
+		}
+		last := i == len(setting.Tiers)-1
+		if tier.UpTo == nil {
+			if !last {
+				return fmt.Errorf("%w: only the last bracket can be open-ended", ErrInvalidFeeSetting)
+			}
+			continue
+		}
+		if last {
+			return fmt.Errorf("%w: the last bracket must be open-ended", ErrInvalidFeeSetting)
+		}
+		if !tier.UpTo.GreaterThan(lower) {
+			return fmt.Errorf("%w: bracket limits must rise", ErrInvalidFeeSetting)
+		}
+		lower = *tier.UpTo
+	}
+
+	return nil
+}
+
+const feeSettingColumns = `id, fee_type, fee_percentage, fee_fixed, minimum_fee, maximum_fee, tiers, charged_to,
+	is_active, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanFeeSetting(row rowScanner) (*models.AdminFeeSetting, error) {
+	var setting models.AdminFeeSetting
+	var tiersJSON []byte
+	err := row.Scan(&setting.ID, &setting.FeeType, &setting.FeePercentage, &setting.FeeFixed,
+		&setting.MinimumFee, &setting.MaximumFee, &tiersJSON, &setting.ChargedTo,
+		&setting.IsActive, &setting.CreatedAt, &setting.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	setting.Tiers = []models.FeeTier{}
+	if err := json.Unmarshal(tiersJSON, &setting.Tiers); err != nil {
+		return nil, err
+	}
+	return &setting, nil
+}
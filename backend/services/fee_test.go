@@ -0,0 +1,259 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"microjob-backend/models"
+	"microjob-backend/money"
+)
+
+func usd(minor int64) money.Money {
+	return money.New(minor, "USD")
+}
+
+func usdPtr(minor int64) *money.Money {
+	amount := usd(minor)
+	return &amount
+}
+
+func TestCalculateFee(t *testing.T) {
+	tiered := []models.FeeTier{
+		{UpTo: usdPtr(10000), Percentage: 500},
+		{UpTo: usdPtr(100000), Percentage: 300},
+		{Percentage: 100},
+	}
+
+	tests := []struct {
+		name          string
+		setting       *models.AdminFeeSetting
+		amount        money.Money
+		wantFee       int64
+		wantPayerPays int64
+		wantReceives  int64
+		wantChargedTo string
+		wantItems     int
+	}{
+		{
+			name:          "no setting is free",
+			amount:        usd(10000),
+			wantPayerPays: 10000, wantReceives: 10000, wantChargedTo: FeeChargedToPayee,
+		},
+		{
+			name:          "inactive setting is free",
+			setting:       &models.AdminFeeSetting{FeePercentage: 300, ChargedTo: FeeChargedToPayer},
+			amount:        usd(10000),
+			wantPayerPays: 10000, wantReceives: 10000, wantChargedTo: FeeChargedToPayee,
+		},
+		{
+			name:    "percentage charged to payee",
+			setting: &models.AdminFeeSetting{FeePercentage: 300, ChargedTo: FeeChargedToPayee, IsActive: true},
+			amount:  usd(10000),
+			wantFee: 300, wantPayerPays: 10000, wantReceives: 9700, wantChargedTo: FeeChargedToPayee, wantItems: 1,
+		},
+		{
+			name:    "percentage charged to payer",
+			setting: &models.AdminFeeSetting{FeePercentage: 300, ChargedTo: FeeChargedToPayer, IsActive: true},
+			amount:  usd(10000),
+			wantFee: 300, wantPayerPays: 10300, wantReceives: 10000, wantChargedTo: FeeChargedToPayer, wantItems: 1,
+		},
+		{
+			name:    "percentage rounds half up",
+			setting: &models.AdminFeeSetting{FeePercentage: 300, ChargedTo: FeeChargedToPayee, IsActive: true},
+			amount:  usd(50),
+			wantFee: 2, wantPayerPays: 50, wantReceives: 48, wantChargedTo: FeeChargedToPayee, wantItems: 1,
+		},
+		{
+			name: "percentage and fixed fee",
+			setting: &models.AdminFeeSetting{FeePercentage: 290, FeeFixed: usd(30), ChargedTo: FeeChargedToPayee,
+				IsActive: true},
+			amount:  usd(1000),
+			wantFee: 59, wantPayerPays: 1000, wantReceives: 941, wantChargedTo: FeeChargedToPayee, wantItems: 2,
+		},
+		{
+			name: "raised to the minimum",
+			setting: &models.AdminFeeSetting{FeePercentage: 300, MinimumFee: usd(100), ChargedTo: FeeChargedToPayee,
+				IsActive: true},
+			amount:  usd(1000),
+			wantFee: 100, wantPayerPays: 1000, wantReceives: 900, wantChargedTo: FeeChargedToPayee, wantItems: 2,
+		},
+		{
+			name: "capped at the maximum",
+			setting: &models.AdminFeeSetting{FeePercentage: 300, MaximumFee: usdPtr(500), ChargedTo: FeeChargedToPayer,
+				IsActive: true},
+			amount:  usd(100000),
+			wantFee: 500, wantPayerPays: 100500, wantReceives: 100000, wantChargedTo: FeeChargedToPayer, wantItems: 2,
+		},
+		{
+			name: "between minimum and maximum is untouched",
+			setting: &models.AdminFeeSetting{FeePercentage: 300, MinimumFee: usd(100), MaximumFee: usdPtr(500),
+				ChargedTo: FeeChargedToPayee, IsActive: true},
+			amount:  usd(10000),
+			wantFee: 300, wantPayerPays: 10000, wantReceives: 9700, wantChargedTo: FeeChargedToPayee, wantItems: 1,
+		},
+		{
+			name:    "within the first bracket",
+			setting: &models.AdminFeeSetting{Tiers: tiered, ChargedTo: FeeChargedToPayee, IsActive: true},
+			amount:  usd(5000),
+			wantFee: 250, wantPayerPays: 5000, wantReceives: 4750, wantChargedTo: FeeChargedToPayee, wantItems: 1,
+		},
+		{
+			name:    "at a bracket limit",
+			setting: &models.AdminFeeSetting{Tiers: tiered, ChargedTo: FeeChargedToPayee, IsActive: true},
+			amount:  usd(10000),
+			wantFee: 500, wantPayerPays: 10000, wantReceives: 9500, wantChargedTo: FeeChargedToPayee, wantItems: 1,
+		},
+		{
+			name:    "across every bracket",
+			setting: &models.AdminFeeSetting{Tiers: tiered, ChargedTo: FeeChargedToPayee, IsActive: true},
+			amount:  usd(150000),
+			wantFee: 3700, wantPayerPays: 150000, wantReceives: 146300, wantChargedTo: FeeChargedToPayee, wantItems: 3,
+		},
+		{
+			name: "brackets ignore the flat percentage",
+			setting: &models.AdminFeeSetting{FeePercentage: 1000, Tiers: tiered, ChargedTo: FeeChargedToPayer,
+				IsActive: true},
+			amount:  usd(20000),
+			wantFee: 800, wantPayerPays: 20800, wantReceives: 20000, wantChargedTo: FeeChargedToPayer, wantItems: 2,
+		},
+		{
+			name: "brackets with a fixed fee and maximum",
+			setting: &models.AdminFeeSetting{Tiers: tiered, FeeFixed: usd(100), MaximumFee: usdPtr(1000),
+				ChargedTo: FeeChargedToPayee, IsActive: true},
+			amount:  usd(150000),
+			wantFee: 1000, wantPayerPays: 150000, wantReceives: 149000, wantChargedTo: FeeChargedToPayee, wantItems: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CalculateFee(tt.setting, tt.amount)
+			if got.TotalFee != usd(tt.wantFee) {
+				t.Errorf("TotalFee = %s, want %s", got.TotalFee, usd(tt.wantFee))
+			}
+			if got.PayerPays != usd(tt.wantPayerPays) {
+				t.Errorf("PayerPays = %s, want %s", got.PayerPays, usd(tt.wantPayerPays))
+			}
+			if got.PayeeReceives != usd(tt.wantReceives) {
+				t.Errorf("PayeeReceives = %s, want %s", got.PayeeReceives, usd(tt.wantReceives))
+			}
+			if got.ChargedTo != tt.wantChargedTo {
+				t.Errorf("ChargedTo = %q, want %q", got.ChargedTo, tt.wantChargedTo)
+			}
+			if len(got.Items) != tt.wantItems {
+				t.Errorf("got %d items %+v, want %d", len(got.Items), got.Items, tt.wantItems)
+			}
+
+			sum := usd(0)
+			for _, item := range got.Items {
+				sum = sum.Add(item.Amount)
+			}
+			if sum != got.TotalFee {
+				t.Errorf("items add up to %s, total fee is %s", sum, got.TotalFee)
+			}
+		})
+	}
+}
+
+func TestCalculateWithSettingFeeExceedsAmount(t *testing.T) {
+	fixed := func(chargedTo string) *models.AdminFeeSetting {
+		return &models.AdminFeeSetting{FeeFixed: usd(100), ChargedTo: chargedTo, IsActive: true}
+	}
+
+	tests := []struct {
+		name    string
+		setting *models.AdminFeeSetting
+		amount  money.Money
+		wantErr error
+	}{
+		{"payee receives something", fixed(FeeChargedToPayee), usd(101), nil},
+		{"fee takes the whole amount", fixed(FeeChargedToPayee), usd(100), ErrFeeExceedsAmount},
+		{"fee exceeds the amount", fixed(FeeChargedToPayee), usd(50), ErrFeeExceedsAmount},
+		{"payer covers a fee above the amount", fixed(FeeChargedToPayer), usd(50), nil},
+		{"nothing to pay", nil, usd(0), ErrFeeExceedsAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := calculateWithSetting(tt.setting, FeeJobPayout, tt.amount, "payer", "payee")
+			if err != tt.wantErr {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got == nil {
+				t.Fatal("no breakdown returned")
+			}
+			if got.TransactionType != FeeJobPayout || got.PayerID != "payer" || got.PayeeID != "payee" {
+				t.Errorf("breakdown not labelled: %+v", got)
+			}
+		})
+	}
+}
+
+func TestValidateFeeSetting(t *testing.T) {
+	valid := func(change func(*models.AdminFeeSetting)) *models.AdminFeeSetting {
+		setting := &models.AdminFeeSetting{
+			FeePercentage: 300,
+			FeeFixed:      usd(30),
+			MinimumFee:    usd(50),
+			MaximumFee:    usdPtr(1000),
+			Tiers:         []models.FeeTier{},
+			ChargedTo:     FeeChargedToPayee,
+		}
+		if change != nil {
+			change(setting)
+		}
+		return setting
+	}
+
+	tests := []struct {
+		name    string
+		setting *models.AdminFeeSetting
+		wantErr bool
+	}{
+		{"valid flat fee", valid(nil), false},
+		{"charged to payer", valid(func(s *models.AdminFeeSetting) { s.ChargedTo = FeeChargedToPayer }), false},
+		{"no maximum", valid(func(s *models.AdminFeeSetting) { s.MaximumFee = nil }), false},
+		{"maximum equal to minimum", valid(func(s *models.AdminFeeSetting) { s.MaximumFee = usdPtr(50) }), false},
+		{"valid brackets", valid(func(s *models.AdminFeeSetting) {
+			s.Tiers = []models.FeeTier{{UpTo: usdPtr(10000), Percentage: 500}, {Percentage: 300}}
+		}), false},
+		{"single open bracket", valid(func(s *models.AdminFeeSetting) {
+			s.Tiers = []models.FeeTier{{Percentage: 300}}
+		}), false},
+		{"unknown payer side", valid(func(s *models.AdminFeeSetting) { s.ChargedTo = "both" }), true},
+		{"missing payer side", valid(func(s *models.AdminFeeSetting) { s.ChargedTo = "" }), true},
+		{"negative percentage", valid(func(s *models.AdminFeeSetting) { s.FeePercentage = -1 }), true},
+		{"negative fixed fee", valid(func(s *models.AdminFeeSetting) { s.FeeFixed = usd(-1) }), true},
+		{"negative minimum", valid(func(s *models.AdminFeeSetting) { s.MinimumFee = usd(-1) }), true},
+		{"maximum below minimum", valid(func(s *models.AdminFeeSetting) { s.MaximumFee = usdPtr(49) }), true},
+		{"negative bracket percentage", valid(func(s *models.AdminFeeSetting) {
+			s.Tiers = []models.FeeTier{{UpTo: usdPtr(10000), Percentage: 500}, {Percentage: -1}}
+		}), true},
+		{"open bracket before the last", valid(func(s *models.AdminFeeSetting) {
+			s.Tiers = []models.FeeTier{{Percentage: 500}, {UpTo: usdPtr(10000), Percentage: 300}}
+		}), true},
+		{"last bracket has a limit", valid(func(s *models.AdminFeeSetting) {
+			s.Tiers = []models.FeeTier{{UpTo: usdPtr(10000), Percentage: 500}}
+		}), true},
+		{"limits do not rise", valid(func(s *models.AdminFeeSetting) {
+			s.Tiers = []models.FeeTier{
+				{UpTo: usdPtr(10000), Percentage: 500}, {UpTo: usdPtr(10000), Percentage: 300}, {Percentage: 100},
+			}
+		}), true},
+		{"first limit is zero", valid(func(s *models.AdminFeeSetting) {
+			s.Tiers = []models.FeeTier{{UpTo: usdPtr(0), Percentage: 500}, {Percentage: 300}}
+		}), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateFeeSetting(tt.setting)
+			if tt.wantErr && !errors.Is(err, ErrInvalidFeeSetting) {
+				t.Errorf("err = %v, want %v", err, ErrInvalidFeeSetting)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
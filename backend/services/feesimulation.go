@@ -0,0 +1,229 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"microjob-backend/models"
+	"microjob-backend/money"
+)
+
+// DefaultFeeSimulationDays is how much history a simulation replays when the
+// admin does not say.
+const DefaultFeeSimulationDays = 30
+
+// FeeSimulation compares the fees charged under the current settings with
+// the fees a proposed configuration would have charged on the same
+// transactions.
+type FeeSimulation struct {
+	Days             int             `json:"days"`
+	From             time.Time       `json:"from"`
+	To               time.Time       `json:"to"`
+	TransactionCount int             `json:"transaction_count"`
+	CurrentRevenue   money.Money     `json:"current_revenue"`
+	ProjectedRevenue money.Money     `json:"projected_revenue"`
+	RevenueDelta     money.Money     `json:"revenue_delta"`
+	UncoveredCount   int             `json:"uncovered_count"` // transactions the proposed fee would swallow whole
+	Types            []FeeTypeImpact `json:"types"`
+	MostAffected     []UserFeeImpact `json:"most_affected_users"`
+}
+
+type FeeTypeImpact struct {
+	TransactionType string      `json:"transaction_type"`
+	Count           int         `json:"count"`
+	Volume          money.Money `json:"volume"`
+	CurrentFees     money.Money `json:"current_fees"`
+	ProjectedFees   money.Money `json:"projected_fees"`
+	Delta           money.Money `json:"delta"`
+}
+
+// UserFeeImpact totals the change for the user who bears each fee.
+type UserFeeImpact struct {
+	UserID        string      `json:"user_id"`
+	Transactions  int         `json:"transactions"`
+	CurrentFees   money.Money `json:"current_fees"`
+	ProjectedFees money.Money `json:"projected_fees"`
+	Delta         money.Money `json:"delta"`
+}
+
+// Completed transactions of every fee type, at the amount the fee is worked
+// out on and in its currency. Withdrawals and conversions are replayed at the
+// amount that left the wallet.
+const feeHistoryQuery = `
+	SELECT 'job_payout', wp.payment_amount, j.currency, j.user_id::text, wp.worker_id::text
+	FROM work_proofs wp
+	JOIN jobs j ON j.id = wp.job_id
+	WHERE wp.status IN ('approved', 'auto_approved') AND wp.reviewed_at >= $1
+	UNION ALL
+	SELECT 'marketplace_order', o.amount, mi.currency, o.buyer_id::text, o.seller_id::text
+	FROM orders o
+	JOIN marketplace_items mi ON mi.id = o.marketplace_item_id
+	WHERE o.status = 'completed' AND o.completed_at >= $1
+	UNION ALL
+	SELECT 'chat_transfer', t.amount, t.currency, t.sender_id::text, t.receiver_id::text
+	FROM chat_money_transfers t
+	WHERE t.status IN ('completed', 'disputed', 'dispute_rejected') AND t.completed_at >= $1
+	UNION ALL
+	SELECT 'withdrawal', w.amount, w.currency, w.user_id::text, ''
+	FROM withdrawal_requests w
+	WHERE w.status NOT IN ('rejected', 'failed') AND w.created_at >= $1
+	UNION ALL
+	SELECT 'support_ticket', s.payment_amount, 'USD', s.user_id::text, ''
+	FROM support_tickets s
+	WHERE s.payment_amount > 0 AND s.created_at >= $1
+	UNION ALL
+	SELECT 'currency_conversion', c.amount, c.from_currency, c.user_id::text, ''
+	FROM currency_conversions c
+	WHERE c.created_at >= $1`
+
+// Simulate replays the last days of transactions through the current fee
+// settings and through proposed ones. Types missing from proposed keep their
+// current setting. Transactions in other currencies are valued in
+// money.DefaultCurrency at today's rates so the totals add up. Nothing is
+// saved.
+func (fe *FeeEngine) Simulate(proposed []models.AdminFeeSetting, days, topUsers int) (*FeeSimulation, error) {
+	current, err := fe.allSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	projected := make(map[string]*models.AdminFeeSetting, len(current))
+	for feeType, setting := range current {
+		projected[feeType] = setting
+	}
+	for i := range proposed {
+		setting := &proposed[i]
+		if !IsFeeTransactionType(setting.FeeType) {
+			return nil, fmt.Errorf("%w: unknown fee type %q", ErrInvalidFeeSetting, setting.FeeType)
+		}
+		if err := prepareFeeSetting(setting); err != nil {
+			return nil, err
+		}
+		projected[setting.FeeType] = setting
+	}
+
+	if days < 1 {
+		days = DefaultFeeSimulationDays
+	}
+	now := time.Now()
+	simulation := &FeeSimulation{
+		Days: days,
+		From: now.AddDate(0, 0, -days),
+		To:   now,
+	}
+
+	rows, err := fe.db.Query(feeHistoryQuery, simulation.From)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types := make(map[string]*FeeTypeImpact)
+	users := make(map[string]*UserFeeImpact)
+	rates := map[string]money.ExchangeRate{money.DefaultCurrency: money.BaseExchangeRate}
+	for rows.Next() {
+		var transactionType, currency, payerID, payeeID string
+		var amount money.Money
+		if err := rows.Scan(&transactionType, &amount, &currency, &payerID, &payeeID); err != nil {
+			return nil, err
+		}
+
+		currency = normalizeCurrency(currency)
+		rate, ok := rates[currency]
+		if !ok {
+			rate, err = exchangeRate(fe.db, currency)
+			if err != nil {
+				return nil, err
+			}
+			rates[currency] = rate
+		}
+		amount = amount.WithCurrency(currency).Convert(rate, money.BaseExchangeRate, money.DefaultCurrency, money.FeeRounding)
+
+		before := CalculateFee(current[transactionType], amount)
+		after := CalculateFee(projected[transactionType], amount)
+		if !after.PayeeReceives.IsPositive() {
+			simulation.UncoveredCount++
+		}
+		simulation.TransactionCount++
+
+		impact := types[transactionType]
+		if impact == nil {
+			impact = &FeeTypeImpact{TransactionType: transactionType}
+			types[transactionType] = impact
+		}
+		impact.Count++
+		impact.Volume = impact.Volume.Add(amount)
+		impact.CurrentFees = impact.CurrentFees.Add(before.TotalFee)
+		impact.ProjectedFees = impact.ProjectedFees.Add(after.TotalFee)
+
+		// The payee bears a payee-side fee unless there is no payee, as with
+		// withdrawals and support tickets
+		bearer := payerID
+		if after.ChargedTo == FeeChargedToPayee && payeeID != "" {
+			bearer = payeeID
+		}
+		user := users[bearer]
+		if user == nil {
+			user = &UserFeeImpact{UserID: bearer}
+			users[bearer] = user
+		}
+		user.Transactions++
+		user.CurrentFees = user.CurrentFees.Add(before.TotalFee)
+		user.ProjectedFees = user.ProjectedFees.Add(after.TotalFee)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	simulation.Types = []FeeTypeImpact{}
+	for _, feeType := range FeeTransactionTypes {
+		impact := types[feeType]
+		if impact == nil {
+			continue
+		}
+		impact.Delta = impact.ProjectedFees.Sub(impact.CurrentFees)
+		simulation.CurrentRevenue = simulation.CurrentRevenue.Add(impact.CurrentFees)
+		simulation.ProjectedRevenue = simulation.ProjectedRevenue.Add(impact.ProjectedFees)
+		simulation.Types = append(simulation.Types, *impact)
+	}
+	simulation.RevenueDelta = simulation.ProjectedRevenue.Sub(simulation.CurrentRevenue)
+
+	simulation.MostAffected = []UserFeeImpact{}
+	for _, user := range users {
+		user.Delta = user.ProjectedFees.Sub(user.CurrentFees)
+		if !user.Delta.IsZero() {
+			simulation.MostAffected = append(simulation.MostAffected, *user)
+		}
+	}
+	sort.Slice(simulation.MostAffected, func(i, j int) bool {
+		a, b := simulation.MostAffected[i].Delta.Abs(), simulation.MostAffected[j].Delta.Abs()
+		if !a.Equal(b) {
+			return a.GreaterThan(b)
+		}
+		return simulation.MostAffected[i].UserID < simulation.MostAffected[j].UserID
+	})
+	if len(simulation.MostAffected) > topUsers {
+		simulation.MostAffected = simulation.MostAffected[:topUsers]
+	}
+
+	return simulation, nil
+}
+
+func (fe *FeeEngine) allSettings() (map[string]*models.AdminFeeSetting, error) {
+	rows, err := fe.db.Query(`SELECT ` + feeSettingColumns + ` FROM admin_fee_settings`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settings := make(map[string]*models.AdminFeeSetting)
+	for rows.Next() {
+		setting, err := scanFeeSetting(rows)
+		if err != nil {
+			return nil, err
+		}
+		settings[setting.FeeType] = setting
+	}
+	return settings, rows.Err()
+}
@@ -0,0 +1,308 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"microjob-backend/models"
+	"microjob-backend/storage"
+)
+
+const (
+	// MaxUploadSize matches the request body limit set on the Fiber app
+	MaxUploadSize = 10 << 20
+	// SignedURLExpiry is how long a download link stays valid
+	SignedURLExpiry = 15 * time.Minute
+
+	fileURLPrefix = "/api/files/"
+)
+
+// Upload contexts, saying what a file belongs to
+const (
+	FileContextChat          = "chat"
+	FileContextJob           = "job"
+	FileContextSupportTicket = "support_ticket"
+	FileContextMarketplace   = "marketplace"
+)
+
+var (
+	ErrFileNotFound        = errors.New("file not found")
+	ErrFileForbidden       = errors.New("you are not allowed to access this file")
+	ErrFileTooLarge        = fmt.Errorf("files may be at most %d MB", MaxUploadSize>>20)
+	ErrFileEmpty           = errors.New("file is empty")
+	ErrUnsupportedFileType = errors.New("unsupported file type")
+	ErrInvalidFileContext  = errors.New("context must be chat, job, support_ticket or marketplace with the ID of something you take part in")
+)
+
+// uploadTypes maps the content types accepted for upload, as sniffed from the
+// file itself, to the extension stored files get.
+var uploadTypes = map[string]string{
+	"image/jpeg":                ".jpg",
+	"image/png":                 ".png",
+	"image/gif":                 ".gif",
+	"image/webp":                ".webp",
+	"application/pdf":           ".pdf",
+	"application/zip":           ".zip",
+	"text/plain; charset=utf-8": ".txt",
+}
+
+// FileService stores uploads and decides who may download them. Chat files
+// are for the chat's participants, job files for the employer and the worker
+// who uploaded them, ticket files for the ticket's owner and marketplace
+// images for everyone signed in. Admins reach all files through their routes.
+type FileService struct {
+	db    *sql.DB
+	store storage.Storage
+}
+
+func NewFileService(db *sql.DB, store storage.Storage) *FileService {
+	return &FileService{db: db, store: store}
+}
+
+// FileURL is the stable reference to a stored file.
+func FileURL(fileID string) string {
+	return fileURLPrefix + fileID
+}
+
+// FileIDFromURL returns the file a reference made by FileURL points at.
+func FileIDFromURL(fileURL string) (string, bool) {
+	u, err := url.Parse(fileURL)
+	if err != nil || u.Host != "" || !strings.HasPrefix(u.Path, fileURLPrefix) {
+		return "", false
+	}
+	fileID := strings.TrimPrefix(u.Path, fileURLPrefix)
+	if _, err := uuid.Parse(fileID); err != nil {
+		return "", false
+	}
+	return fileID, true
+}
+
+// Upload checks and stores size bytes read from r. The content type is
+// sniffed from the bytes rather than taken from the client.
+func (fs *FileService) Upload(ctx context.Context, ownerID, contextType, contextID, name string, r io.Reader, size int64) (*models.File, error) {
+	if size <= 0 {
+		return nil, ErrFileEmpty
+	}
+	if size > MaxUploadSize {
+		return nil, ErrFileTooLarge
+	}
+	if err := fs.checkUploadContext(ownerID, contextType, contextID); err != nil {
+		return nil, err
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	head = head[:n]
+	contentType := http.DetectContentType(head)
+	ext, ok := uploadTypes[contentType]
+	if !ok || (contextType == FileContextMarketplace && !strings.HasPrefix(contentType, "image/")) {
+		return nil, ErrUnsupportedFileType
+	}
+
+	now := time.Now()
+	file := &models.File{
+		ID:           uuid.New().String(),
+		OwnerID:      ownerID,
+		ContextType:  contextType,
+		OriginalName: cleanFileName(name),
+		ContentType:  contentType,
+		Size:         size,
+		Backend:      fs.store.Name(),
+		CreatedAt:    now,
+	}
+	if contextID != "" {
+		file.ContextID = &contextID
+	}
+	file.StorageKey = fmt.Sprintf("%s/%s/%s%s", contextType, now.Format("2006/01"), file.ID, ext)
+
+	hash := sha256.New()
+	content := io.TeeReader(io.MultiReader(bytes.NewReader(head), r), hash)
+	if err := fs.store.Put(ctx, file.StorageKey, content, size, contentType); err != nil {
+		return nil, err
+	}
+	file.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	_, err = fs.db.Exec(`
+		INSERT INTO files (id, owner_id, context_type, context_id, original_name, content_type, size, sha256,
+			storage_backend, storage_key, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		file.ID, file.OwnerID, file.ContextType, file.ContextID, file.OriginalName, file.ContentType, file.Size,
+		file.SHA256, file.Backend, file.StorageKey, file.CreatedAt)
+	if err != nil {
+		fs.store.Delete(ctx, file.StorageKey)
+		return nil, err
+	}
+
+	file.URL = FileURL(file.ID)
+	return file, nil
+}
+
+// checkUploadContext makes sure the uploader takes part in what the file is
+// attached to. Marketplace images may be uploaded before their listing exists.
+func (fs *FileService) checkUploadContext(ownerID, contextType, contextID string) error {
+	if contextID == "" {
+		if contextType == FileContextMarketplace {
+			return nil
+		}
+		return ErrInvalidFileContext
+	}
+	if _, err := uuid.Parse(contextID); err != nil {
+		return ErrInvalidFileContext
+	}
+
+	var allowed bool
+	var err error
+	switch contextType {
+	case FileContextChat:
+		allowed, err = fs.isChatParticipant(contextID, ownerID)
+	case FileContextJob:
+		// Workers upload proof files before the proof exists, so any
+		// existing job will do
+		err = fs.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM jobs WHERE id = $1)`, contextID).Scan(&allowed)
+	case FileContextSupportTicket:
+		err = fs.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM support_tickets WHERE id = $1 AND user_id = $2)`,
+			contextID, ownerID).Scan(&allowed)
+	case FileContextMarketplace:
+		err = fs.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM marketplace_items WHERE id = $1 AND seller_id = $2)`,
+			contextID, ownerID).Scan(&allowed)
+	}
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrInvalidFileContext
+	}
+	return nil
+}
+
+// GetFile returns a file userID may access. Admins may access every file.
+func (fs *FileService) GetFile(fileID, userID string, isAdmin bool) (*models.File, error) {
+	file, err := fs.getFile(`id::text = $1`, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if !isAdmin {
+		allowed, err := fs.canAccess(file, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, ErrFileForbidden
+		}
+	}
+	return file, nil
+}
+
+// GetFileByKey returns the file stored under key, for serving signed local
+// downloads whose URLs carry the key.
+func (fs *FileService) GetFileByKey(key string) (*models.File, error) {
+	return fs.getFile(`storage_key = $1`, key)
+}
+
+// SignedURL issues a download link for a file userID may access.
+func (fs *FileService) SignedURL(ctx context.Context, fileID, userID string, isAdmin bool) (string, time.Time, error) {
+	file, err := fs.GetFile(fileID, userID, isAdmin)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(SignedURLExpiry)
+	signedURL, err := fs.store.SignedURL(ctx, file.StorageKey, SignedURLExpiry)
+	return signedURL, expiresAt, err
+}
+
+// Open reads a stored file without access checks, for background work on
+// files already attached to something.
+func (fs *FileService) Open(ctx context.Context, fileID string) (io.ReadCloser, *models.File, error) {
+	file, err := fs.getFile(`id::text = $1`, fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := fs.store.Open(ctx, file.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return content, file, nil
+}
+
+func (fs *FileService) canAccess(file *models.File, userID string) (bool, error) {
+	if file.OwnerID == userID {
+		return true, nil
+	}
+	if file.ContextType == FileContextMarketplace {
+		return true, nil
+	}
+	if file.ContextID == nil {
+		return false, nil
+	}
+
+	var allowed bool
+	var err error
+	switch file.ContextType {
+	case FileContextChat:
+		allowed, err = fs.isChatParticipant(*file.ContextID, userID)
+	case FileContextJob:
+		err = fs.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM jobs WHERE id = $1 AND user_id = $2)`,
+			*file.ContextID, userID).Scan(&allowed)
+	case FileContextSupportTicket:
+		err = fs.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM support_tickets WHERE id = $1 AND user_id = $2)`,
+			*file.ContextID, userID).Scan(&allowed)
+	}
+	return allowed, err
+}
+
+func (fs *FileService) isChatParticipant(chatID, userID string) (bool, error) {
+	var participant bool
+	err := fs.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM chat_participants WHERE chat_id = $1 AND user_id = $2 AND is_active = true
+		)`, chatID, userID).Scan(&participant)
+	return participant, err
+}
+
+func (fs *FileService) getFile(where string, arg string) (*models.File, error) {
+	var file models.File
+	err := fs.db.QueryRow(`
+		SELECT id, owner_id, context_type, context_id, original_name, content_type, size, sha256,
+			storage_backend, storage_key, created_at
+		FROM files
+		WHERE `+where, arg).Scan(&file.ID, &file.OwnerID, &file.ContextType, &file.ContextID,
+		&file.OriginalName, &file.ContentType, &file.Size, &file.SHA256, &file.Backend, &file.StorageKey,
+		&file.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	file.URL = FileURL(file.ID)
+	return &file, nil
+}
+
+// cleanFileName keeps the base name of an uploaded file, without path parts
+// some browsers send, at a length the files table takes.
+func cleanFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" {
+		name = "file"
+	}
+	if len(name) > 255 {
+		name = strings.ToValidUTF8(name[:255], "")
+	}
+	return name
+}
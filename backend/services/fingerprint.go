@@ -0,0 +1,358 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"microjob-backend/models"
+)
+
+const (
+	// fingerprintBatchSize is how many proofs one sweep fingerprints
+	fingerprintBatchSize = 50
+	// maxFingerprintFileSize caps how much of a proof file is read
+	maxFingerprintFileSize = 20 << 20
+	// maxPerceptualDistance is how many of the 64 hash bits two images may
+	// differ in and still count as the same picture
+	maxPerceptualDistance = 6
+)
+
+// FingerprintService hashes the files and screenshots attached to work proofs
+// and flags proofs that reuse files from other workers or other jobs. Uploaded
+// files are read from storage; other URLs are only downloaded from
+// allowedHosts, since they come from workers.
+type FingerprintService struct {
+	db           *sql.DB
+	files        *FileService
+	client       *http.Client
+	allowedHosts map[string]bool
+}
+
+func NewFingerprintService(db *sql.DB, files *FileService, allowedHosts []string) *FingerprintService {
+	hosts := make(map[string]bool, len(allowedHosts))
+	for _, host := range allowedHosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts[host] = true
+		}
+	}
+	return &FingerprintService{
+		db:           db,
+		files:        files,
+		client:       &http.Client{Timeout: 15 * time.Second},
+		allowedHosts: hosts,
+	}
+}
+
+type fileFingerprint struct {
+	url    string
+	sha256 string
+	phash  *int64
+}
+
+// FingerprintPending fingerprints proofs submitted or resubmitted since the
+// last sweep, oldest first so earlier proofs are on file before the proofs
+// that might copy them.
+func (fs *FingerprintService) FingerprintPending() (int, error) {
+	rows, err := fs.db.Query(`
+		SELECT id FROM work_proofs
+		WHERE fingerprinted_at IS NULL
+		ORDER BY submitted_at, id
+		LIMIT $1`, fingerprintBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var proofIDs []string
+	for rows.Next() {
+		var proofID string
+		if err := rows.Scan(&proofID); err != nil {
+			return 0, err
+		}
+		proofIDs = append(proofIDs, proofID)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	processed := 0
+	for _, proofID := range proofIDs {
+		if err := fs.fingerprintProof(proofID); err != nil {
+			log.Printf("[FINGERPRINT] Failed to fingerprint work proof %s: %v", proofID, err)
+			continue
+		}
+		processed++
+	}
+	return processed, nil
+}
+
+func (fs *FingerprintService) fingerprintProof(proofID string) error {
+	var jobID, workerID string
+	var submissionNumber int
+	var submittedAt time.Time
+	var proofFilesJSON, screenshotsJSON []byte
+	err := fs.db.QueryRow(`
+		SELECT job_id, worker_id, submission_number, submitted_at, proof_files, screenshots
+		FROM work_proofs
+		WHERE id = $1`, proofID).Scan(&jobID, &workerID, &submissionNumber, &submittedAt, &proofFilesJSON,
+		&screenshotsJSON)
+	if err != nil {
+		return err
+	}
+
+	var files, screenshots []string
+	json.Unmarshal(proofFilesJSON, &files)
+	json.Unmarshal(screenshotsJSON, &screenshots)
+
+	// Download before opening the transaction so slow hosts hold no locks.
+	// Files that cannot be fetched are skipped rather than failing the proof.
+	seen := make(map[string]bool)
+	var fingerprints []fileFingerprint
+	for _, fileURL := range append(files, screenshots...) {
+		if seen[fileURL] {
+			continue
+		}
+		seen[fileURL] = true
+
+		fp, err := fs.fingerprintFile(fileURL)
+		if err != nil {
+			log.Printf("[FINGERPRINT] Skipping %s on work proof %s: %v", fileURL, proofID, err)
+			continue
+		}
+		fingerprints = append(fingerprints, *fp)
+	}
+
+	tx, err := fs.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	duplicate := false
+	for _, fp := range fingerprints {
+		_, err := tx.Exec(`
+			INSERT INTO work_proof_fingerprints (work_proof_id, submission_number, file_url, sha256, phash)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (work_proof_id, submission_number, file_url) DO NOTHING`,
+			proofID, submissionNumber, fp.url, fp.sha256, fp.phash)
+		if err != nil {
+			return err
+		}
+
+		// Earlier proofs holding the same bytes or, for images, a picture
+		// close enough to pass for the same one. Reuse within the worker's
+		// own proofs for the same job is a resubmission, not a duplicate.
+		result, err := tx.Exec(`
+			INSERT INTO work_proof_duplicates (work_proof_id, submission_number, file_url, matched_work_proof_id,
+				matched_file_url, match_type, distance)
+			SELECT $1, $2, $3, f.work_proof_id, f.file_url,
+				CASE WHEN f.sha256 = $4 THEN 'exact' ELSE 'perceptual' END,
+				CASE WHEN f.sha256 = $4 THEN 0
+					ELSE length(replace((f.phash # $5::bigint)::bit(64)::text, '0', '')) END
+			FROM work_proof_fingerprints f
+			JOIN work_proofs wp ON wp.id = f.work_proof_id
+			WHERE f.work_proof_id <> $1
+			  AND (wp.worker_id <> $6 OR wp.job_id <> $7)
+			  AND wp.submitted_at < $8
+			  AND (f.sha256 = $4 OR ($5::bigint IS NOT NULL AND f.phash IS NOT NULL
+				AND length(replace((f.phash # $5::bigint)::bit(64)::text, '0', '')) <= $9))
+			ON CONFLICT (work_proof_id, submission_number, file_url, matched_work_proof_id, matched_file_url)
+				DO NOTHING`,
+			proofID, submissionNumber, fp.url, fp.sha256, fp.phash, workerID, jobID, submittedAt,
+			maxPerceptualDistance)
+		if err != nil {
+			return err
+		}
+		if affected, _ := result.RowsAffected(); affected > 0 {
+			duplicate = true
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE work_proofs SET possible_duplicate = possible_duplicate OR $1, fingerprinted_at = $2
+		WHERE id = $3`, duplicate, time.Now(), proofID)
+	if err != nil {
+		return err
+	}
+
+	if duplicate {
+		log.Printf("[FINGERPRINT] Work proof %s reuses files from earlier proofs", proofID)
+	}
+	return tx.Commit()
+}
+
+func (fs *FingerprintService) fingerprintFile(fileURL string) (*fileFingerprint, error) {
+	data, err := fs.readFile(fileURL)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	fp := &fileFingerprint{url: fileURL, sha256: hex.EncodeToString(sum[:])}
+
+	// Files that are not images only get the content hash
+	if img, _, err := image.Decode(bytes.NewReader(data)); err == nil {
+		hash := int64(differenceHash(img))
+		fp.phash = &hash
+	}
+	return fp, nil
+}
+
+func (fs *FingerprintService) readFile(fileURL string) ([]byte, error) {
+	var content io.ReadCloser
+	if fileID, ok := FileIDFromURL(fileURL); ok {
+		file, _, err := fs.files.Open(context.Background(), fileID)
+		if err != nil {
+			return nil, err
+		}
+		content = file
+	} else {
+		u, err := url.Parse(fileURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("not an http(s) URL")
+		}
+		if !fs.allowedHosts[strings.ToLower(u.Hostname())] {
+			return nil, fmt.Errorf("host %q is not an allowed proof file host", u.Hostname())
+		}
+
+		resp, err := fs.client.Get(fileURL)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("download failed with status %d", resp.StatusCode)
+		}
+		content = resp.Body
+	}
+	defer content.Close()
+
+	data, err := io.ReadAll(io.LimitReader(content, maxFingerprintFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFingerprintFileSize {
+		return nil, fmt.Errorf("file is larger than %d bytes", maxFingerprintFileSize)
+	}
+	return data, nil
+}
+
+// differenceHash is a 64-bit dHash: the image is shrunk to 9×8 grey cells and
+// each bit says whether a cell is brighter than its right-hand neighbour.
+// Re-encoding, resizing and small edits leave most bits unchanged.
+func differenceHash(img image.Image) uint64 {
+	const cols, rows = 9, 8
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	var cells [rows][cols]uint64
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			x0, x1 := bounds.Min.X+x*width/cols, bounds.Min.X+(x+1)*width/cols
+			y0, y1 := bounds.Min.Y+y*height/rows, bounds.Min.Y+(y+1)*height/rows
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			if y1 <= y0 {
+				y1 = y0 + 1
+			}
+
+			// Sample at most 8×8 pixels per cell so large images stay cheap
+			stepX, stepY := (x1-x0+7)/8, (y1-y0+7)/8
+			var sum, n uint64
+			for py := y0; py < y1; py += stepY {
+				for px := x0; px < x1; px += stepX {
+					r, g, b, _ := img.At(px, py).RGBA()
+					sum += (299*uint64(r) + 587*uint64(g) + 114*uint64(b)) / 1000
+					n++
+				}
+			}
+			cells[y][x] = sum / n
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols-1; x++ {
+			hash <<= 1
+			if cells[y][x] > cells[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// GetDuplicates lists the earlier proofs a work proof's files match.
+func (fs *FingerprintService) GetDuplicates(proofID string) ([]models.WorkProofDuplicate, error) {
+	rows, err := fs.db.Query(duplicateSelect+`
+		WHERE d.work_proof_id = $1
+		ORDER BY d.submission_number DESC, d.created_at`, proofID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanDuplicates(rows)
+}
+
+// GetFlaggedProofs lists, newest first, the proofs marked as possible
+// duplicates with what each of them matched.
+func (fs *FingerprintService) GetFlaggedProofs(limit, offset int) ([]models.WorkProofDuplicate, int, error) {
+	var total int
+	err := fs.db.QueryRow(`SELECT COUNT(*) FROM work_proofs WHERE possible_duplicate`).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := fs.db.Query(duplicateSelect+`
+		WHERE d.work_proof_id IN (
+			SELECT id FROM work_proofs WHERE possible_duplicate
+			ORDER BY submitted_at DESC, id
+			LIMIT $1 OFFSET $2
+		)
+		ORDER BY d.created_at DESC`, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	duplicates, err := scanDuplicates(rows)
+	return duplicates, total, err
+}
+
+const duplicateSelect = `
+	SELECT d.id, d.work_proof_id, d.submission_number, d.file_url, d.matched_work_proof_id, m.job_id,
+		m.worker_id, d.matched_file_url, d.match_type, d.distance, d.created_at
+	FROM work_proof_duplicates d
+	JOIN work_proofs m ON m.id = d.matched_work_proof_id`
+
+func scanDuplicates(rows *sql.Rows) ([]models.WorkProofDuplicate, error) {
+	var duplicates []models.WorkProofDuplicate
+	for rows.Next() {
+		var d models.WorkProofDuplicate
+		err := rows.Scan(&d.ID, &d.WorkProofID, &d.SubmissionNumber, &d.FileURL, &d.MatchedWorkProofID,
+			&d.MatchedJobID, &d.MatchedWorkerID, &d.MatchedFileURL, &d.MatchType, &d.Distance, &d.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		duplicates = append(duplicates, d)
+	}
+	return duplicates, rows.Err()
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"microjob-backend/models"
+)
+
+// IdempotencyKeyTTL is how long a stored response can be replayed.
+const IdempotencyKeyTTL = 24 * time.Hour
+
+var (
+	ErrIdempotencyKeyReused  = errors.New("idempotency key was already used for a different request")
+	ErrIdempotencyInProgress = errors.New("a request with this idempotency key is still being processed")
+)
+
+type IdempotencyService struct {
+	db *sql.DB
+}
+
+func NewIdempotencyService(db *sql.DB) *IdempotencyService {
+	return &IdempotencyService{db: db}
+}
+
+// IdempotencyRequestHash fingerprints a request, so a key reused for another
+// endpoint or body is caught.
+func IdempotencyRequestHash(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + "\n" + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Begin claims key for the user. claimed is true when the caller should run the
+// request; otherwise the returned record holds the stored response to replay.
+func (is *IdempotencyService) Begin(userID, key, method, path, requestHash string) (record *models.IdempotencyKey, claimed bool, err error) {
+	// Expired keys may be reused for a new request
+	_, err = is.db.Exec(`DELETE FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2 AND expires_at < NOW()`,
+		userID, key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now()
+	record = &models.IdempotencyKey{
+		ID:            uuid.New().String(),
+		UserID:        userID,
+		Key:           key,
+		RequestMethod: method,
+		RequestPath:   path,
+		RequestHash:   requestHash,
+		Status:        "processing",
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(IdempotencyKeyTTL),
+	}
+
+	result, err := is.db.Exec(`
+		INSERT INTO idempotency_keys (id, user_id, idempotency_key, request_method, request_path,
+			request_hash, status, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (user_id, idempotency_key) DO NOTHING`,
+		record.ID, record.UserID, record.Key, record.RequestMethod, record.RequestPath,
+		record.RequestHash, record.Status, record.CreatedAt, record.ExpiresAt)
+	if err != nil {
+		return nil, false, err
+	}
+	if inserted, _ := result.RowsAffected(); inserted == 1 {
+		return record, true, nil
+	}
+
+	existing, err := is.getByKey(userID, key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := checkReplay(existing, method, path, requestHash); err != nil {
+		return nil, false, err
+	}
+	return existing, false, nil
+}
+
+// checkReplay reports whether the stored response for an existing key can be
+// replayed to a request with the given method, path and hash.
+func checkReplay(existing *models.IdempotencyKey, method, path, requestHash string) error {
+	if existing.RequestMethod != method || existing.RequestPath != path || existing.RequestHash != requestHash {
+		return ErrIdempotencyKeyReused
+	}
+	if existing.Status != "completed" {
+		return ErrIdempotencyInProgress
+	}
+	return nil
+}
+
+// Complete stores the response that later retries will receive.
+func (is *IdempotencyService) Complete(id string, status int, body []byte) error {
+	_, err := is.db.Exec(`
+		UPDATE idempotency_keys
+		SET status = 'completed', response_status = $1, response_body = $2, completed_at = $3
+		WHERE id = $4`, status, string(body), time.Now(), id)
+	return err
+}
+
+// Release forgets a claimed key so the client can retry after a server error.
+func (is *IdempotencyService) Release(id string) error {
+	_, err := is.db.Exec(`DELETE FROM idempotency_keys WHERE id = $1 AND status = 'processing'`, id)
+	return err
+}
+
+func (is *IdempotencyService) CleanupExpiredKeys() (int64, error) {
+	result, err := is.db.Exec(`DELETE FROM idempotency_keys WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (is *IdempotencyService) getByKey(userID, key string) (*models.IdempotencyKey, error) {
+	var record models.IdempotencyKey
+	err := is.db.QueryRow(`
+		SELECT id, user_id, idempotency_key, request_method, request_path, request_hash, status,
+			response_status, response_body, created_at, completed_at, expires_at
+		FROM idempotency_keys
+		WHERE user_id = $1 AND idempotency_key = $2`, userID, key).Scan(
+		&record.ID, &record.UserID, &record.Key, &record.RequestMethod, &record.RequestPath,
+		&record.RequestHash, &record.Status, &record.ResponseStatus, &record.ResponseBody,
+		&record.CreatedAt, &record.CompletedAt, &record.ExpiresAt,
+	)
+	if err == sql.ErrNoRows {
+		// The key expired and was removed between our insert and this read
+		return nil, ErrIdempotencyInProgress
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"testing"
+
+	"microjob-backend/models"
+)
+
+func TestIdempotencyRequestHash(t *testing.T) {
+	base := IdempotencyRequestHash("POST", "/api/wallet/withdraw", []byte(`{"amount":"10.00"}`))
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantSame bool
+	}{
+		{"same request", "POST", "/api/wallet/withdraw", `{"amount":"10.00"}`, true},
+		{"another body", "POST", "/api/wallet/withdraw", `{"amount":"100.00"}`, false},
+		{"another path", "POST", "/api/chat/money-transfer", `{"amount":"10.00"}`, false},
+		{"another method", "PUT", "/api/wallet/withdraw", `{"amount":"10.00"}`, false},
+		{"path and body split differently", "POST", "/api/wallet/withdraw\n{", `"amount":"10.00"}`, false},
+		{"no body", "POST", "/api/wallet/withdraw", ``, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := IdempotencyRequestHash(tt.method, tt.path, []byte(tt.body))
+			if (got == base) != tt.wantSame {
+				t.Errorf("hash %s, same as the original = %v, want %v", got, got == base, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestCheckReplay(t *testing.T) {
+	hash := IdempotencyRequestHash("POST", "/api/wallet/withdraw", []byte(`{"amount":"10.00"}`))
+	stored := func(status string) *models.IdempotencyKey {
+		return &models.IdempotencyKey{
+			RequestMethod: "POST",
+			RequestPath:   "/api/wallet/withdraw",
+			RequestHash:   hash,
+			Status:        status,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		existing *models.IdempotencyKey
+		method   string
+		path     string
+		hash     string
+		wantErr  error
+	}{
+		{"same request once completed", stored("completed"), "POST", "/api/wallet/withdraw", hash, nil},
+		{"same request still running", stored("processing"), "POST", "/api/wallet/withdraw", hash, ErrIdempotencyInProgress},
+		{"another body", stored("completed"), "POST", "/api/wallet/withdraw",
+			IdempotencyRequestHash("POST", "/api/wallet/withdraw", []byte(`{"amount":"99.00"}`)), ErrIdempotencyKeyReused},
+		{"another path", stored("completed"), "POST", "/api/chat/money-transfer", hash, ErrIdempotencyKeyReused},
+		{"another method", stored("completed"), "PUT", "/api/wallet/withdraw", hash, ErrIdempotencyKeyReused},
+		{"reuse is reported before progress", stored("processing"), "POST", "/api/wallet/withdraw", "other",
+			ErrIdempotencyKeyReused},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkReplay(tt.existing, tt.method, tt.path, tt.hash); err != tt.wantErr {
+				t.Errorf("checkReplay = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,423 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+	"microjob-backend/models"
+)
+
+const (
+	// imageBatchSize is how many images one run of the worker processes
+	imageBatchSize = 20
+	// maxImagePixels guards against images that decode to huge bitmaps
+	maxImagePixels = 40_000_000
+	// imageMaxAttempts is how often an image is tried before it is failed
+	imageMaxAttempts = 3
+	// imageStaleAfter is when an image still processing is assumed abandoned
+	imageStaleAfter = 10 * time.Minute
+)
+
+// thumbnailSizes are the longest sides thumbnails are scaled to, smallest
+// first. Images are never scaled up.
+var thumbnailSizes = []struct {
+	name    string
+	maxSide int
+}{
+	{"small", 160},
+	{"medium", 480},
+	{"large", 1024},
+}
+
+// ImageService processes uploaded images in the background: it removes their
+// location data, records when they were taken and their size, and renders
+// thumbnails so reviewers need not download every full image.
+type ImageService struct {
+	db    *sql.DB
+	files *FileService
+}
+
+func NewImageService(db *sql.DB, files *FileService) *ImageService {
+	return &ImageService{db: db, files: files}
+}
+
+// ProcessPending processes images uploaded since the last run, along with
+// images whose processing was abandoned, and returns how many succeeded.
+func (is *ImageService) ProcessPending() (int, error) {
+	fileIDs, err := is.claimImages()
+	if err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for _, fileID := range fileIDs {
+		if err := is.processImage(context.Background(), fileID); err != nil {
+			log.Printf("[IMAGES] Failed to process image %s: %v", fileID, err)
+			is.failImage(fileID, err)
+			continue
+		}
+		processed++
+	}
+	return processed, nil
+}
+
+// claimImages marks the next images as processing. The claim is the insert
+// or update itself, so overlapping runs never take the same image.
+func (is *ImageService) claimImages() ([]string, error) {
+	rows, err := is.db.Query(`
+		WITH retried AS (
+			UPDATE file_images
+			SET attempts = attempts + 1, updated_at = NOW()
+			WHERE status = 'processing' AND updated_at < $1 AND attempts < $2
+			RETURNING file_id
+		), claimed AS (
+			INSERT INTO file_images (file_id, status)
+			SELECT f.id, 'processing'
+			FROM files f
+			WHERE f.content_type LIKE 'image/%'
+			  AND NOT EXISTS (SELECT 1 FROM file_images i WHERE i.file_id = f.id)
+			ORDER BY f.created_at
+			LIMIT $3
+			ON CONFLICT (file_id) DO NOTHING
+			RETURNING file_id
+		)
+		SELECT file_id FROM retried
+		UNION ALL
+		SELECT file_id FROM claimed`, time.Now().Add(-imageStaleAfter), imageMaxAttempts, imageBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fileIDs []string
+	for rows.Next() {
+		var fileID string
+		if err := rows.Scan(&fileID); err != nil {
+			return nil, err
+		}
+		fileIDs = append(fileIDs, fileID)
+	}
+	return fileIDs, rows.Err()
+}
+
+func (is *ImageService) processImage(ctx context.Context, fileID string) error {
+	content, file, err := is.files.Open(ctx, fileID)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(io.LimitReader(content, MaxUploadSize+1))
+	content.Close()
+	if err != nil {
+		return err
+	}
+
+	// The original is replaced before thumbnails are made, so no copy with
+	// the location outlives processing
+	data, meta := stripJPEGLocation(data)
+	if meta.locationRemoved {
+		if err := is.replaceOriginal(ctx, file, data); err != nil {
+			return err
+		}
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return fmt.Errorf("image of %dx%d pixels is too large to process", config.Width, config.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	// Thumbnails are rendered from the next larger one, largest first from
+	// the original, which is much cheaper than scaling the original each time
+	var thumbnails []models.FileThumbnail
+	source := img
+	for i := len(thumbnailSizes) - 1; i >= 0; i-- {
+		size := thumbnailSizes[i]
+		width, height := fitWithin(source.Bounds().Dx(), source.Bounds().Dy(), size.maxSide)
+		scaled := scaleImage(source, width, height)
+		source = scaled
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, orientImage(scaled, meta.orientation), &jpeg.Options{Quality: 80}); err != nil {
+			return err
+		}
+		if meta.orientation >= 5 {
+			width, height = height, width
+		}
+		thumbnail := models.FileThumbnail{
+			Size:       size.name,
+			Width:      width,
+			Height:     height,
+			StorageKey: fmt.Sprintf("thumbnails/%s/%s.jpg", file.ID, size.name),
+		}
+		if err := is.files.store.Put(ctx, thumbnail.StorageKey, &buf, int64(buf.Len()), "image/jpeg"); err != nil {
+			return err
+		}
+		thumbnails = append(thumbnails, thumbnail)
+	}
+
+	width, height := config.Width, config.Height
+	if meta.orientation >= 5 {
+		width, height = height, width
+	}
+
+	tx, err := is.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, thumbnail := range thumbnails {
+		_, err := tx.Exec(`
+			INSERT INTO file_thumbnails (file_id, size, width, height, storage_key)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (file_id, size) DO UPDATE SET
+				width = EXCLUDED.width, height = EXCLUDED.height, storage_key = EXCLUDED.storage_key`,
+			file.ID, thumbnail.Size, thumbnail.Width, thumbnail.Height, thumbnail.StorageKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE file_images
+		SET status = 'processed', width = $1, height = $2, captured_at = $3, error = NULL, updated_at = NOW()
+		WHERE file_id = $4`, width, height, meta.capturedAt, file.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// replaceOriginal stores the cleaned image over the uploaded one. That the
+// location was removed is recorded at once, as a retry finds nothing to remove.
+func (is *ImageService) replaceOriginal(ctx context.Context, file *models.File, data []byte) error {
+	err := is.files.store.Put(ctx, file.StorageKey, bytes.NewReader(data), int64(len(data)), file.ContentType)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(data)
+	_, err = is.db.Exec(`
+		UPDATE files SET size = $1, sha256 = $2 WHERE id = $3`, len(data), hex.EncodeToString(sum[:]), file.ID)
+	if err != nil {
+		return err
+	}
+	_, err = is.db.Exec(`UPDATE file_images SET location_removed = TRUE WHERE file_id = $1`, file.ID)
+	return err
+}
+
+// failImage gives up on an image once it has used its attempts; until then it
+// stays processing and is picked up again when stale.
+func (is *ImageService) failImage(fileID string, cause error) {
+	_, err := is.db.Exec(`
+		UPDATE file_images
+		SET status = CASE WHEN attempts >= $1 OR $2 THEN 'failed' ELSE status END,
+			error = $3, updated_at = NOW()
+		WHERE file_id = $4`, imageMaxAttempts, errors.Is(cause, image.ErrFormat), cause.Error(), fileID)
+	if err != nil {
+		log.Printf("[IMAGES] Failed to record failure of image %s: %v", fileID, err)
+	}
+}
+
+// AttachWorkProofImages fills in the images of a work proof's screenshots,
+// with signed thumbnail URLs. Only files the worker uploaded for the proof's
+// job count, so a proof cannot expose someone else's upload.
+func (is *ImageService) AttachWorkProofImages(ctx context.Context, proof *models.WorkProof) error {
+	var fileIDs []string
+	for _, screenshot := range proof.Screenshots {
+		if fileID, ok := FileIDFromURL(screenshot); ok {
+			fileIDs = append(fileIDs, fileID)
+		}
+	}
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	rows, err := is.db.Query(`
+		SELECT f.id, COALESCE(i.status, 'pending'), i.width, i.height, i.captured_at
+		FROM files f
+		LEFT JOIN file_images i ON i.file_id = f.id
+		WHERE f.id::text = ANY($1) AND f.owner_id = $2 AND f.context_type = 'job' AND f.context_id = $3
+		  AND f.content_type LIKE 'image/%'`, pq.Array(fileIDs), proof.WorkerID, proof.JobID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	images := make(map[string]*models.FileImage)
+	for rows.Next() {
+		var img models.FileImage
+		if err := rows.Scan(&img.FileID, &img.Status, &img.Width, &img.Height, &img.CapturedAt); err != nil {
+			return err
+		}
+		img.URL = FileURL(img.FileID)
+		images[img.FileID] = &img
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	thumbRows, err := is.db.Query(`
+		SELECT file_id, size, width, height, storage_key
+		FROM file_thumbnails
+		WHERE file_id::text = ANY($1)
+		ORDER BY width`, pq.Array(fileIDs))
+	if err != nil {
+		return err
+	}
+	defer thumbRows.Close()
+
+	for thumbRows.Next() {
+		var fileID string
+		var thumbnail models.FileThumbnail
+		err := thumbRows.Scan(&fileID, &thumbnail.Size, &thumbnail.Width, &thumbnail.Height, &thumbnail.StorageKey)
+		if err != nil {
+			return err
+		}
+		img, ok := images[fileID]
+		if !ok {
+			continue
+		}
+		thumbnail.URL, err = is.files.store.SignedURL(ctx, thumbnail.StorageKey, SignedURLExpiry)
+		if err != nil {
+			return err
+		}
+		img.Thumbnails = append(img.Thumbnails, thumbnail)
+	}
+	if err := thumbRows.Err(); err != nil {
+		return err
+	}
+
+	proof.Images = nil
+	for _, fileID := range fileIDs {
+		if img, ok := images[fileID]; ok {
+			proof.Images = append(proof.Images, *img)
+			delete(images, fileID)
+		}
+	}
+	return nil
+}
+
+// fitWithin scales width and height down so the longer side is at most
+// maxSide, keeping the aspect ratio.
+func fitWithin(width, height, maxSide int) (int, int) {
+	longest := width
+	if height > longest {
+		longest = height
+	}
+	if longest <= maxSide {
+		return width, height
+	}
+	width = (width*maxSide + longest/2) / longest
+	height = (height*maxSide + longest/2) / longest
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	return width, height
+}
+
+// scaleImage shrinks src to width×height by averaging the source pixels each
+// target pixel covers, and flattens transparency onto white since thumbnails
+// are JPEGs.
+func scaleImage(src image.Image, width, height int) *image.RGBA {
+	bounds := src.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*srcHeight/height
+		y1 := bounds.Min.Y + (y+1)*srcHeight/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*srcWidth/width
+			x1 := bounds.Min.X + (x+1)*srcWidth/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			// Colours are premultiplied, so adding the missing alpha as white
+			// composites the pixel over a white background
+			white := 0xffff - a/n
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8((r/n + white) >> 8),
+				G: uint8((g/n + white) >> 8),
+				B: uint8((b/n + white) >> 8),
+				A: 0xff,
+			})
+		}
+	}
+	return dst
+}
+
+// orientImage turns img upright according to its EXIF orientation, 1 to 8.
+func orientImage(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	outWidth, outHeight := width, height
+	if orientation >= 5 {
+		outWidth, outHeight = height, width
+	}
+	out := image.NewRGBA(image.Rect(0, 0, outWidth, outHeight))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = width-1-x, y
+			case 3: // upside down
+				dx, dy = width-1-x, height-1-y
+			case 4: // mirrored upside down
+				dx, dy = x, height-1-y
+			case 5: // mirrored, turned left
+				dx, dy = y, x
+			case 6: // turned left
+				dx, dy = height-1-y, x
+			case 7: // mirrored, turned right
+				dx, dy = height-1-y, width-1-x
+			case 8: // turned right
+				dx, dy = y, width-1-x
+			}
+			out.SetRGBA(dx, dy, img.RGBAAt(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return out
+}
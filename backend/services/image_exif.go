@@ -0,0 +1,222 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"time"
+)
+
+// EXIF tags read or cleared by image processing
+const (
+	exifTagOrientation      = 0x0112
+	exifTagDateTime         = 0x0132
+	exifTagExifIFD          = 0x8769
+	exifTagGPSIFD           = 0x8825
+	exifTagDateTimeOriginal = 0x9003
+)
+
+const exifDateFormat = "2006:01:02 15:04:05"
+
+// jpegMetadata is what image processing keeps from a JPEG's metadata.
+type jpegMetadata struct {
+	capturedAt      *time.Time
+	orientation     int
+	locationRemoved bool
+}
+
+// stripJPEGLocation reads the capture time and orientation of a JPEG and
+// removes its location. The GPS directory of the EXIF data is emptied in
+// place, keeping the other tags, and XMP packets, which can repeat the
+// location, are dropped. Data that is not a well-formed JPEG is returned
+// unchanged.
+func stripJPEGLocation(data []byte) ([]byte, jpegMetadata) {
+	var meta jpegMetadata
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return data, meta
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return data, jpegMetadata{}
+		}
+		marker := data[i+1]
+		// Image data starts at the first scan; everything after is copied
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+		if marker == 0xFF {
+			i++
+			continue
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return data, jpegMetadata{}
+		}
+		segment := data[i : i+2+length]
+		payload := segment[4:]
+
+		if marker == 0xE1 {
+			if bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+				readExif(payload[6:], &meta)
+			} else if bytes.HasPrefix(payload, []byte("http://ns.adobe.com/xap/1.0/\x00")) {
+				meta.locationRemoved = true
+				i += len(segment)
+				continue
+			}
+		}
+		out = append(out, segment...)
+		i += len(segment)
+	}
+	out = append(out, data[i:]...)
+
+	if !meta.locationRemoved {
+		return data, meta
+	}
+	return out, meta
+}
+
+type tiffData struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+type ifdEntry struct {
+	tag      uint16
+	typ      uint16
+	count    uint32
+	valueOff int // where the value starts, within the entry or elsewhere
+	size     int
+}
+
+var tiffTypeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+// readExif reads the TIFF structure of an EXIF segment, editing it in place
+// to empty the GPS directory.
+func readExif(data []byte, meta *jpegMetadata) {
+	if len(data) < 8 {
+		return
+	}
+	t := tiffData{data: data}
+	switch string(data[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return
+	}
+	if t.order.Uint16(data[2:]) != 42 {
+		return
+	}
+
+	var dateTime, dateTimeOriginal string
+	for _, e := range t.ifd(int(t.order.Uint32(data[4:]))) {
+		switch e.tag {
+		case exifTagOrientation:
+			if e.typ == 3 {
+				meta.orientation = int(t.order.Uint16(data[e.valueOff:]))
+			}
+		case exifTagDateTime:
+			dateTime = t.ascii(e)
+		case exifTagExifIFD:
+			for _, sub := range t.ifd(t.offset(e)) {
+				if sub.tag == exifTagDateTimeOriginal {
+					dateTimeOriginal = t.ascii(sub)
+				}
+			}
+		case exifTagGPSIFD:
+			if t.clearIFD(t.offset(e)) {
+				meta.locationRemoved = true
+			}
+		}
+	}
+
+	// Cameras write the time the photo was taken to DateTimeOriginal; editors
+	// rewrite DateTime when saving
+	for _, value := range []string{dateTimeOriginal, dateTime} {
+		if capturedAt, err := time.Parse(exifDateFormat, value); err == nil {
+			meta.capturedAt = &capturedAt
+			break
+		}
+	}
+}
+
+// ifd returns the entries of the directory at off, skipping entries whose
+// values fall outside the data.
+func (t tiffData) ifd(off int) []ifdEntry {
+	if off <= 0 || off+2 > len(t.data) {
+		return nil
+	}
+	count := int(t.order.Uint16(t.data[off:]))
+	if off+2+count*12 > len(t.data) {
+		return nil
+	}
+
+	entries := make([]ifdEntry, 0, count)
+	for n := 0; n < count; n++ {
+		pos := off + 2 + n*12
+		e := ifdEntry{
+			tag:   t.order.Uint16(t.data[pos:]),
+			typ:   t.order.Uint16(t.data[pos+2:]),
+			count: t.order.Uint32(t.data[pos+4:]),
+		}
+		typeSize, ok := tiffTypeSizes[e.typ]
+		if !ok || uint64(e.count)*uint64(typeSize) > uint64(len(t.data)) {
+			continue
+		}
+		e.size = int(e.count) * typeSize
+		e.valueOff = pos + 8
+		if e.size > 4 {
+			e.valueOff = int(t.order.Uint32(t.data[pos+8:]))
+		}
+		if e.valueOff+e.size > len(t.data) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// offset reads an entry holding the offset of another directory.
+func (t tiffData) offset(e ifdEntry) int {
+	if e.size != 4 {
+		return 0
+	}
+	return int(t.order.Uint32(t.data[e.valueOff:]))
+}
+
+func (t tiffData) ascii(e ifdEntry) string {
+	if e.typ != 2 {
+		return ""
+	}
+	return strings.TrimRight(string(t.data[e.valueOff:e.valueOff+e.size]), "\x00 ")
+}
+
+// clearIFD zeroes the values and entries of the directory at off, leaving a
+// directory with no entries, and reports whether there was anything to clear.
+func (t tiffData) clearIFD(off int) bool {
+	if off <= 0 || off+2 > len(t.data) {
+		return false
+	}
+	count := int(t.order.Uint16(t.data[off:]))
+	if count == 0 {
+		return false
+	}
+
+	for _, e := range t.ifd(off) {
+		if e.size > 4 {
+			clear(t.data[e.valueOff : e.valueOff+e.size])
+		}
+	}
+	end := off + 2 + count*12 + 4
+	if end > len(t.data) {
+		end = len(t.data)
+	}
+	clear(t.data[off:end])
+	return true
+}
@@ -2,41 +2,185 @@ package services
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 	"microjob-backend/models"
+	"microjob-backend/money"
+)
+
+var (
+	ErrNotJobOwner          = errors.New("only the job owner can do this")
+	ErrJobNotCancellable    = errors.New("job can no longer be cancelled")
+	ErrJobHasPendingReviews = errors.New("review the submitted work proofs before cancelling the job")
 )
 
 type JobService struct {
-	db *sql.DB
+	db     *sql.DB
+	escrow *EscrowService
+	ledger *LedgerService
 }
 
 func NewJobService(db *sql.DB) *JobService {
-	return &JobService{db: db}
+	return &JobService{
+		db:     db,
+		escrow: NewEscrowService(db),
+		ledger: NewLedgerService(db),
+	}
 }
 
+// CreateJob stores the job and funds its escrow from the employer's balance in
+// one transaction, so a job is never posted without the money to pay for it.
+// The budget must be in a currency wallets can hold. When the job carries a
+// price quote, its screenshot and boost fees are charged in the same
+// transaction; they pay for the listing and are not refunded on cancellation.
 func (js *JobService) CreateJob(job *models.Job) error {
+	tx, err := js.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	job.Currency, err = supportedCurrency(tx, job.Currency)
+	if err != nil {
+		return err
+	}
+
+	var quoteJSON []byte
+	if job.PriceQuote != nil {
+		quoteJSON, err = json.Marshal(job.PriceQuote)
+		if err != nil {
+			return err
+		}
+	}
+
 	query := `
 		INSERT INTO jobs (id, user_id, title, description, category_id, subcategory_id, 
-			budget_min, budget_max, deadline, status, approval_type, instant_approval_enabled,
-			manual_approval_days, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`
-	
-	_, err := js.db.Exec(query, job.ID, job.UserID, job.Title, job.Description, job.CategoryID,
-		job.SubcategoryID, job.BudgetMin, job.BudgetMax, job.Deadline, job.Status,
-		job.ApprovalType, job.InstantApprovalEnabled, job.ManualApprovalDays,
-		job.CreatedAt, job.UpdatedAt)
+			budget_min, budget_max, currency, deadline, status, approval_type, instant_approval_enabled,
+			manual_approval_days, required_workers, required_screenshots, price_quote, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)`
 	
-	return err
+	_, err = tx.Exec(query, job.ID, job.UserID, job.Title, job.Description, job.CategoryID,
+		job.SubcategoryID, job.BudgetMin, job.BudgetMax, job.Currency, job.Deadline, job.Status,
+		job.ApprovalType, job.InstantApprovalEnabled, job.ManualApprovalDays, job.RequiredWorkers,
+		job.RequiredScreenshots, quoteJSON, job.CreatedAt, job.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = js.escrow.FundJob(tx, job)
+	if err != nil {
+		return err
+	}
+
+	if job.PriceQuote != nil {
+		err = js.chargeListingFee(tx, job)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// chargeListingFee moves the screenshot and boost fees of a job's quote from
+// the employer's available balance to platform fees.
+func (js *JobService) chargeListingFee(tx *sql.Tx, job *models.Job) error {
+	fee := job.PriceQuote.ListingFee()
+	if !fee.IsPositive() {
+		return nil
+	}
+
+	description := fmt.Sprintf("Listing fees for job: %s", job.Title)
+	entry := &models.LedgerEntry{
+		EntryType:     "job_listing_fee",
+		Description:   &description,
+		ReferenceID:   &job.ID,
+		ReferenceType: stringPtr("job"),
+	}
+	err := js.ledger.Post(tx, entry, []LedgerLeg{
+		{AccountType: AccountUserAvailable, OwnerID: job.UserID, Amount: fee.Neg()},
+		{AccountType: AccountPlatformFees, Amount: fee},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = insertWalletTransaction(tx, &models.WalletTransaction{
+		UserID:        job.UserID,
+		Type:          "job_listing_fee",
+		Amount:        fee,
+		Description:   &description,
+		ReferenceID:   &job.ID,
+		ReferenceType: stringPtr("job"),
+		BalanceType:   "deposit",
+		Status:        "completed",
+		LedgerEntryID: &entry.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return updateWalletTotals(tx, job.UserID, money.Money{}, fee)
+}
+
+// CancelJob cancels an open job and refunds the unused escrow to the employer.
+func (js *JobService) CancelJob(jobID, userID string) (money.Money, error) {
+	tx, err := js.db.Begin()
+	if err != nil {
+		return money.Money{}, err
+	}
+	defer tx.Rollback()
+
+	var ownerID, status string
+	err = tx.QueryRow("SELECT user_id, status FROM jobs WHERE id = $1 FOR UPDATE", jobID).Scan(&ownerID, &status)
+	if err != nil {
+		return money.Money{}, err
+	}
+	if ownerID != userID {
+		return money.Money{}, ErrNotJobOwner
+	}
+	if status == "completed" || status == "cancelled" || status == "expired" {
+		return money.Money{}, ErrJobNotCancellable
+	}
+
+	var pending int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM work_proofs
+		WHERE job_id = $1 AND status IN ('submitted', 'pending', 'revision_requested', 'disputed')`, jobID).Scan(&pending)
+	if err != nil {
+		return money.Money{}, err
+	}
+	if pending > 0 {
+		return money.Money{}, ErrJobHasPendingReviews
+	}
+
+	_, err = tx.Exec("UPDATE jobs SET status = 'cancelled', updated_at = $1 WHERE id = $2", time.Now(), jobID)
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	refunded, err := js.escrow.RefundRemaining(tx, jobID, "job cancelled")
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	return refunded, tx.Commit()
+}
+
+func (js *JobService) GetJobEscrow(jobID string) (*models.JobEscrow, error) {
+	return js.escrow.GetByJobID(jobID)
 }
 
 func (js *JobService) GetJobByID(jobID string) (*models.Job, error) {
 	query := `
 		SELECT j.id, j.user_id, j.title, j.description, j.category_id, j.subcategory_id,
-			   j.budget_min, j.budget_max, j.deadline, j.status, j.approval_type,
-			   j.instant_approval_enabled, j.manual_approval_days, j.created_at, j.updated_at,
+			   j.budget_min, j.budget_max, j.currency, j.deadline, j.status, j.approval_type,
+			   j.instant_approval_enabled, j.manual_approval_days, j.required_workers, j.required_screenshots,
+			   j.price_quote, j.created_at, j.updated_at,
 			   u.first_name, u.last_name, u.username, u.avatar
 		FROM jobs j
 		LEFT JOIN users u ON j.user_id = u.id
@@ -44,13 +188,21 @@ func (js *JobService) GetJobByID(jobID string) (*models.Job, error) {
 	
 	var job models.Job
 	var user models.User
+	var quoteJSON []byte
 	err := js.db.QueryRow(query, jobID).Scan(
 		&job.ID, &job.UserID, &job.Title, &job.Description, &job.CategoryID, &job.SubcategoryID,
-		&job.BudgetMin, &job.BudgetMax, &job.Deadline, &job.Status, &job.ApprovalType,
-		&job.InstantApprovalEnabled, &job.ManualApprovalDays, &job.CreatedAt, &job.UpdatedAt,
+		&job.BudgetMin, &job.BudgetMax, &job.Currency, &job.Deadline, &job.Status, &job.ApprovalType,
+		&job.InstantApprovalEnabled, &job.ManualApprovalDays, &job.RequiredWorkers, &job.RequiredScreenshots,
+		&quoteJSON, &job.CreatedAt, &job.UpdatedAt,
 		&user.FirstName, &user.LastName, &user.Username, &user.Avatar,
 	)
 	
+	if err != nil {
+		return nil, err
+	}
+	job.BudgetMin = job.BudgetMin.WithCurrency(job.Currency)
+	job.BudgetMax = job.BudgetMax.WithCurrency(job.Currency)
+	job.PriceQuote, err = readJobPriceQuote(quoteJSON)
 	if err != nil {
 		return nil, err
 	}
@@ -62,7 +214,7 @@ func (js *JobService) GetJobByID(jobID string) (*models.Job, error) {
 func (js *JobService) GetJobsByUserID(userID string, status string, limit, offset int) ([]models.Job, error) {
 	query := `
 		SELECT id, user_id, title, description, category_id, subcategory_id,
-			   budget_min, budget_max, deadline, status, approval_type,
+			   budget_min, budget_max, currency, deadline, status, approval_type,
 			   instant_approval_enabled, manual_approval_days, created_at, updated_at
 		FROM jobs 
 		WHERE user_id = $1`
@@ -100,12 +252,14 @@ func (js *JobService) GetJobsByUserID(userID string, status string, limit, offse
 	for rows.Next() {
 		var job models.Job
 		err := rows.Scan(&job.ID, &job.UserID, &job.Title, &job.Description, &job.CategoryID,
-			&job.SubcategoryID, &job.BudgetMin, &job.BudgetMax, &job.Deadline, &job.Status,
+			&job.SubcategoryID, &job.BudgetMin, &job.BudgetMax, &job.Currency, &job.Deadline, &job.Status,
 			&job.ApprovalType, &job.InstantApprovalEnabled, &job.ManualApprovalDays,
 			&job.CreatedAt, &job.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
+		job.BudgetMin = job.BudgetMin.WithCurrency(job.Currency)
+		job.BudgetMax = job.BudgetMax.WithCurrency(job.Currency)
 		jobs = append(jobs, job)
 	}
 
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"microjob-backend/models"
+	"microjob-backend/money"
+)
+
+var (
+	ErrInvalidJobPrice    = errors.New("reward, workers and screenshots cannot be negative")
+	ErrTooManyScreenshots = errors.New("too many required screenshots")
+	ErrUnknownJobBoost    = errors.New("unknown boost option")
+	ErrJobPriceMismatch   = errors.New("job total does not match the current price")
+	ErrInvalidJobPricing  = errors.New("invalid job pricing settings")
+)
+
+// DefaultJobPricingSettings apply until an admin saves their own. The first
+// screenshot is free and later ones cost a rising share of the payouts.
+var DefaultJobPricingSettings = models.JobPricingSettings{
+	MaxScreenshots: 5,
+	ScreenshotTiers: []models.ScreenshotPriceTier{
+		{ScreenshotNumber: 1, IsFree: true},
+		{ScreenshotNumber: 2, Percentage: 300},
+		{ScreenshotNumber: 3, Percentage: 300},
+		{ScreenshotNumber: 4, Percentage: 500},
+		{ScreenshotNumber: 5, Percentage: 500},
+	},
+	DefaultScreenshotFee: money.New(5, money.DefaultCurrency),
+	BoostOptions: []models.JobBoostOption{
+		{Key: "featured", Label: "Featured listing", Price: money.New(500, money.DefaultCurrency), IsActive: true},
+		{Key: "urgent", Label: "Urgent badge", Price: money.New(200, money.DefaultCurrency), IsActive: true},
+	},
+}
+
+// JobPricingService prices jobs before they are posted. The quote is the
+// only source of what an employer pays; totals worked out by the client are
+// checked against it rather than trusted.
+type JobPricingService struct {
+	db   *sql.DB
+	fees *FeeEngine
+}
+
+func NewJobPricingService(db *sql.DB) *JobPricingService {
+	return &JobPricingService{
+		db:   db,
+		fees: NewFeeEngine(db),
+	}
+}
+
+func (jp *JobPricingService) GetSettings() (*models.JobPricingSettings, error) {
+	settings := DefaultJobPricingSettings
+
+	var settingsJSON string
+	err := jp.db.QueryRow(`SELECT setting_value FROM admin_settings WHERE setting_key = 'job_pricing'`).Scan(&settingsJSON)
+	if err == sql.ErrNoRows {
+		return &settings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Fields missing from the stored settings keep their defaults. The lists
+	// are decoded afresh rather than over the default ones, which are shared.
+	settings.ScreenshotTiers = nil
+	settings.BoostOptions = nil
+	if err := json.Unmarshal([]byte(settingsJSON), &settings); err != nil {
+		return nil, err
+	}
+	if settings.ScreenshotTiers == nil {
+		settings.ScreenshotTiers = DefaultJobPricingSettings.ScreenshotTiers
+	}
+	if settings.BoostOptions == nil {
+		settings.BoostOptions = DefaultJobPricingSettings.BoostOptions
+	}
+	return &settings, nil
+}
+
+func (jp *JobPricingService) UpdateSettings(settings *models.JobPricingSettings) error {
+	if err := validateJobPricingSettings(settings); err != nil {
+		return err
+	}
+
+	settingsJSON, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+
+	_, err = jp.db.Exec(`
+		INSERT INTO admin_settings (setting_key, setting_value, updated_at)
+		VALUES ('job_pricing', $1, $2)
+		ON CONFLICT (setting_key)
+		DO UPDATE SET setting_value = $1, updated_at = $2`, string(settingsJSON), time.Now())
+	return err
+}
+
+// Quote prices a job paying reward to each of workers, with the given number
+// of required screenshots and boosts, at the current settings, fee and
+// exchange rate.
+func (jp *JobPricingService) Quote(employerID string, reward money.Money, workers, screenshots int, boosts []string) (*models.JobPriceQuote, error) {
+	currency := normalizeCurrency(reward.Currency)
+	rate, err := exchangeRate(jp.db, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := jp.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	feeSetting, err := jp.fees.GetSetting(FeeJobPayout)
+	if err != nil {
+		return nil, err
+	}
+
+	return priceJob(settings, feeSetting, rate, employerID, reward.WithCurrency(currency), workers, screenshots, boosts)
+}
+
+// priceJob works out a quote in reward's currency, whose rate is rate. The
+// platform fee is the job payout fee the employer bears, as escrow funding
+// charges it; a payout fee borne by workers comes out of their rewards and
+// adds nothing. Screenshot percentages apply to the worker payouts. Fixed
+// prices in settings and feeSetting are in money.DefaultCurrency.
+func priceJob(settings *models.JobPricingSettings, feeSetting *models.AdminFeeSetting, rate money.ExchangeRate,
+	employerID string, reward money.Money, workers, screenshots int, boosts []string) (*models.JobPriceQuote, error) {
+	if reward.IsNegative() || workers < 0 || screenshots < 0 {
+		return nil, ErrInvalidJobPrice
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	if screenshots > settings.MaxScreenshots {
+		return nil, fmt.Errorf("%w: jobs may require at most %d", ErrTooManyScreenshots, settings.MaxScreenshots)
+	}
+
+	currency := reward.Currency
+	convert := func(amount money.Money) money.Money {
+		return amount.Convert(money.BaseExchangeRate, rate, currency, money.FeeRounding)
+	}
+	if feeSetting != nil && currency != money.DefaultCurrency {
+		feeSetting = settingInCurrency(feeSetting, currency, rate)
+	}
+
+	zero := money.New(0, currency)
+	quote := &models.JobPriceQuote{
+		Currency:            currency,
+		RewardPerWorker:     reward,
+		RequiredWorkers:     workers,
+		WorkerPayouts:       reward.Mul(int64(workers)),
+		PlatformFee:         zero,
+		RequiredScreenshots: screenshots,
+		Screenshots:         []models.JobScreenshotCharge{},
+		ScreenshotFee:       zero,
+		Boosts:              []models.JobBoostCharge{},
+		BoostFee:            zero,
+	}
+
+	if reward.IsPositive() {
+		fee, err := calculateWithSetting(feeSetting, FeeJobPayout, reward, employerID, "")
+		if err != nil {
+			return nil, err
+		}
+		quote.PlatformFee = fee.PayerPays.Sub(reward).Mul(int64(workers))
+	}
+
+	for n := 1; n <= screenshots; n++ {
+		charge := models.JobScreenshotCharge{ScreenshotNumber: n, Amount: convert(settings.DefaultScreenshotFee)}
+		for _, tier := range settings.ScreenshotTiers {
+			if tier.ScreenshotNumber != n {
+				continue
+			}
+			if tier.IsFree {
+				charge.IsFree = true
+				charge.Amount = zero
+			} else {
+				charge.Percentage = tier.Percentage
+				charge.Amount = quote.WorkerPayouts.ApplyRate(tier.Percentage, money.FeeRounding)
+			}
+			break
+		}
+		quote.Screenshots = append(quote.Screenshots, charge)
+		quote.ScreenshotFee = quote.ScreenshotFee.Add(charge.Amount)
+	}
+
+	chosen := make(map[string]bool)
+	for _, key := range boosts {
+		if chosen[key] {
+			continue
+		}
+		chosen[key] = true
+
+		option := findBoostOption(settings.BoostOptions, key)
+		if option == nil {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownJobBoost, key)
+		}
+		charge := models.JobBoostCharge{Key: option.Key, Label: option.Label, Amount: convert(option.Price)}
+		quote.Boosts = append(quote.Boosts, charge)
+		quote.BoostFee = quote.BoostFee.Add(charge.Amount)
+	}
+
+	quote.Total = quote.WorkerPayouts.Add(quote.PlatformFee).Add(quote.ListingFee())
+	return quote, nil
+}
+
+// CheckJobTotal compares the total an employer was shown with the quote,
+// returning ErrJobPriceMismatch when they differ.
+func CheckJobTotal(quote *models.JobPriceQuote, total money.Money) error {
+	if normalizeCurrency(total.Currency) != quote.Currency || !total.Equal(quote.Total) {
+		return ErrJobPriceMismatch
+	}
+	return nil
+}
+
+// findBoostOption returns the active boost option with key.
+func findBoostOption(options []models.JobBoostOption, key string) *models.JobBoostOption {
+	for i := range options {
+		if options[i].Key == key && options[i].IsActive {
+			return &options[i]
+		}
+	}
+	return nil
+}
+
+// readJobPriceQuote reads a quote stored as JSON. Amounts are read in the
+// default currency and then moved to the quote's own.
+func readJobPriceQuote(quoteJSON []byte) (*models.JobPriceQuote, error) {
+	if quoteJSON == nil {
+		return nil, nil
+	}
+
+	var quote models.JobPriceQuote
+	if err := json.Unmarshal(quoteJSON, &quote); err != nil {
+		return nil, err
+	}
+	for _, amount := range []*money.Money{&quote.RewardPerWorker, &quote.WorkerPayouts, &quote.PlatformFee,
+		&quote.ScreenshotFee, &quote.BoostFee, &quote.Total} {
+		*amount = amount.WithCurrency(quote.Currency)
+	}
+	for i := range quote.Screenshots {
+		quote.Screenshots[i].Amount = quote.Screenshots[i].Amount.WithCurrency(quote.Currency)
+	}
+	for i := range quote.Boosts {
+		quote.Boosts[i].Amount = quote.Boosts[i].Amount.WithCurrency(quote.Currency)
+	}
+	return &quote, nil
+}
+
+func validateJobPricingSettings(settings *models.JobPricingSettings) error {
+	if settings.MaxScreenshots < 0 {
+		return fmt.Errorf("%w: maximum screenshots cannot be negative", ErrInvalidJobPricing)
+	}
+	if settings.DefaultScreenshotFee.IsNegative() {
+		return fmt.Errorf("%w: the default screenshot fee cannot be negative", ErrInvalidJobPricing)
+	}
+
+	numbers := make(map[int]bool)
+	for _, tier := range settings.ScreenshotTiers {
+		if tier.ScreenshotNumber < 1 || tier.ScreenshotNumber > settings.MaxScreenshots {
+			return fmt.Errorf("%w: tier for screenshot %d is outside 1 to %d", ErrInvalidJobPricing,
+				tier.ScreenshotNumber, settings.MaxScreenshots)
+		}
+		if numbers[tier.ScreenshotNumber] {
+			return fmt.Errorf("%w: screenshot %d has more than one tier", ErrInvalidJobPricing, tier.ScreenshotNumber)
+		}
+		numbers[tier.ScreenshotNumber] = true
+		if tier.Percentage < 0 || tier.Percentage > money.RateFromFloat(100) {
+			return fmt.Errorf("%w: tier percentages must be between 0 and 100", ErrInvalidJobPricing)
+		}
+	}
+
+	keys := make(map[string]bool)
+	for _, option := range settings.BoostOptions {
+		if option.Key == "" || option.Label == "" {
+			return fmt.Errorf("%w: boost options need a key and a label", ErrInvalidJobPricing)
+		}
+		if keys[option.Key] {
+			return fmt.Errorf("%w: boost option %q appears more than once", ErrInvalidJobPricing, option.Key)
+		}
+		keys[option.Key] = true
+		if option.Price.IsNegative() {
+			return fmt.Errorf("%w: boost option %q has a negative price", ErrInvalidJobPricing, option.Key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"microjob-backend/models"
+	"microjob-backend/money"
+)
+
+func TestPriceJob(t *testing.T) {
+	payerFee := &models.AdminFeeSetting{FeePercentage: 500, ChargedTo: FeeChargedToPayer, IsActive: true}
+	payeeFee := &models.AdminFeeSetting{FeePercentage: 500, ChargedTo: FeeChargedToPayee, IsActive: true}
+	payerFixedFee := &models.AdminFeeSetting{FeePercentage: 500, FeeFixed: usd(30), ChargedTo: FeeChargedToPayer,
+		IsActive: true}
+	fixedOnly := &models.AdminFeeSetting{FeeFixed: usd(30), ChargedTo: FeeChargedToPayer, IsActive: true}
+
+	oneFreeScreenshot := DefaultJobPricingSettings
+	oneFreeScreenshot.MaxScreenshots = 2
+	oneFreeScreenshot.ScreenshotTiers = []models.ScreenshotPriceTier{{ScreenshotNumber: 1, IsFree: true}}
+
+	tests := []struct {
+		name          string
+		settings      models.JobPricingSettings
+		fee           *models.AdminFeeSetting
+		rate          string
+		reward        money.Money
+		workers       int
+		screenshots   int
+		boosts        []string
+		wantWorkers   int
+		wantPayouts   int64
+		wantFee       int64
+		wantShots     int64
+		wantBoosts    int64
+		wantTotal     int64
+		wantShotItems int
+	}{
+		{
+			name: "reward times workers", settings: DefaultJobPricingSettings, rate: "1",
+			reward: usd(1000), workers: 3,
+			wantWorkers: 3, wantPayouts: 3000, wantTotal: 3000,
+		},
+		{
+			name: "no workers is one worker", settings: DefaultJobPricingSettings, rate: "1",
+			reward: usd(1000), workers: 0,
+			wantWorkers: 1, wantPayouts: 1000, wantTotal: 1000,
+		},
+		{
+			name: "payout fee borne by the employer", settings: DefaultJobPricingSettings, fee: payerFee, rate: "1",
+			reward: usd(1000), workers: 3,
+			wantWorkers: 3, wantPayouts: 3000, wantFee: 150, wantTotal: 3150,
+		},
+		{
+			name: "payout fee borne by workers adds nothing", settings: DefaultJobPricingSettings, fee: payeeFee,
+			rate: "1", reward: usd(1000), workers: 3,
+			wantWorkers: 3, wantPayouts: 3000, wantTotal: 3000,
+		},
+		{
+			name: "screenshot tiers", settings: DefaultJobPricingSettings, rate: "1",
+			reward: usd(1000), workers: 3, screenshots: 3,
+			wantWorkers: 3, wantPayouts: 3000, wantShots: 180, wantTotal: 3180, wantShotItems: 3,
+		},
+		{
+			name: "boosts are charged once each", settings: DefaultJobPricingSettings, rate: "1",
+			reward: usd(1000), workers: 3, boosts: []string{"featured", "urgent", "featured"},
+			wantWorkers: 3, wantPayouts: 3000, wantBoosts: 700, wantTotal: 3700,
+		},
+		{
+			name: "everything", settings: DefaultJobPricingSettings, fee: payerFixedFee, rate: "1",
+			reward: usd(1000), workers: 3, screenshots: 5, boosts: []string{"featured"},
+			wantWorkers: 3, wantPayouts: 3000, wantFee: 240, wantShots: 480, wantBoosts: 500, wantTotal: 4220,
+			wantShotItems: 5,
+		},
+		{
+			name: "no reward is not charged a payout fee", settings: DefaultJobPricingSettings, fee: payerFixedFee,
+			rate: "1", reward: usd(0), workers: 2, boosts: []string{"featured"},
+			wantWorkers: 2, wantBoosts: 500, wantTotal: 500,
+		},
+		{
+			name: "fixed prices are converted to the job currency", settings: oneFreeScreenshot, fee: payerFixedFee,
+			rate: "0.92", reward: money.New(1000, "EUR"), workers: 2, screenshots: 2, boosts: []string{"featured"},
+			wantWorkers: 2, wantPayouts: 2000, wantFee: 156, wantShots: 5, wantBoosts: 460, wantTotal: 2621,
+			wantShotItems: 2,
+		},
+		{
+			name: "currency without minor units", settings: DefaultJobPricingSettings, fee: fixedOnly,
+			rate: "150", reward: money.New(1000, "JPY"), workers: 2, screenshots: 2, boosts: []string{"featured"},
+			wantWorkers: 2, wantPayouts: 2000, wantFee: 90, wantShots: 60, wantBoosts: 750, wantTotal: 2900,
+			wantShotItems: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, err := money.ParseExchangeRate(tt.rate)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			quote, err := priceJob(&tt.settings, tt.fee, rate, "employer", tt.reward, tt.workers, tt.screenshots, tt.boosts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			currency := tt.reward.Currency
+			if quote.Currency != currency {
+				t.Errorf("Currency = %q, want %q", quote.Currency, currency)
+			}
+			if quote.RequiredWorkers != tt.wantWorkers {
+				t.Errorf("RequiredWorkers = %d, want %d", quote.RequiredWorkers, tt.wantWorkers)
+			}
+			for _, amount := range []struct {
+				name string
+				got  money.Money
+				want int64
+			}{
+				{"WorkerPayouts", quote.WorkerPayouts, tt.wantPayouts},
+				{"PlatformFee", quote.PlatformFee, tt.wantFee},
+				{"ScreenshotFee", quote.ScreenshotFee, tt.wantShots},
+				{"BoostFee", quote.BoostFee, tt.wantBoosts},
+				{"Total", quote.Total, tt.wantTotal},
+			} {
+				if amount.got != money.New(amount.want, currency) {
+					t.Errorf("%s = %+v, want %d %s", amount.name, amount.got, amount.want, currency)
+				}
+			}
+			if len(quote.Screenshots) != tt.wantShotItems {
+				t.Errorf("got %d screenshot charges, want %d", len(quote.Screenshots), tt.wantShotItems)
+			}
+		})
+	}
+}
+
+func TestPriceJobErrors(t *testing.T) {
+	inactive := DefaultJobPricingSettings
+	inactive.BoostOptions = []models.JobBoostOption{{Key: "featured", Label: "Featured listing", Price: usd(500)}}
+	bigPayeeFee := &models.AdminFeeSetting{FeeFixed: usd(1000), ChargedTo: FeeChargedToPayee, IsActive: true}
+
+	tests := []struct {
+		name        string
+		settings    models.JobPricingSettings
+		fee         *models.AdminFeeSetting
+		reward      money.Money
+		workers     int
+		screenshots int
+		boosts      []string
+		wantErr     error
+	}{
+		{"negative reward", DefaultJobPricingSettings, nil, usd(-1), 1, 0, nil, ErrInvalidJobPrice},
+		{"negative workers", DefaultJobPricingSettings, nil, usd(1000), -1, 0, nil, ErrInvalidJobPrice},
+		{"negative screenshots", DefaultJobPricingSettings, nil, usd(1000), 1, -1, nil, ErrInvalidJobPrice},
+		{"too many screenshots", DefaultJobPricingSettings, nil, usd(1000), 1, 6, nil, ErrTooManyScreenshots},
+		{"unknown boost", DefaultJobPricingSettings, nil, usd(1000), 1, 0, []string{"sparkles"}, ErrUnknownJobBoost},
+		{"inactive boost", inactive, nil, usd(1000), 1, 0, []string{"featured"}, ErrUnknownJobBoost},
+		{"reward does not cover the fee", DefaultJobPricingSettings, bigPayeeFee, usd(1000), 1, 0, nil,
+			ErrFeeExceedsAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quote, err := priceJob(&tt.settings, tt.fee, money.BaseExchangeRate, "employer", tt.reward, tt.workers,
+				tt.screenshots, tt.boosts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if quote != nil {
+				t.Errorf("got a quote %+v with an error", quote)
+			}
+		})
+	}
+}
+
+func TestCheckJobTotal(t *testing.T) {
+	quote := &models.JobPriceQuote{Currency: "EUR", Total: money.New(3150, "EUR")}
+
+	tests := []struct {
+		name    string
+		total   money.Money
+		wantErr error
+	}{
+		{"matches the quote", money.New(3150, "EUR"), nil},
+		{"currency case does not matter", money.New(3150, "eur"), nil},
+		{"a cent short", money.New(3149, "EUR"), ErrJobPriceMismatch},
+		{"a cent over", money.New(3151, "EUR"), ErrJobPriceMismatch},
+		{"missing total", money.New(0, "EUR"), ErrJobPriceMismatch},
+		{"another currency", money.New(3150, "USD"), ErrJobPriceMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckJobTotal(quote, tt.total); err != tt.wantErr {
+				t.Errorf("CheckJobTotal(%+v) = %v, want %v", tt.total, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"microjob-backend/models"
+	"microjob-backend/money"
+)
+
+// Ledger account types
+const (
+	AccountUserAvailable   = "user_available"
+	AccountUserPending     = "user_pending"
+	AccountUserLocked      = "user_locked" // withdrawals awaiting payout
+	AccountPlatformRevenue = "platform_revenue"
+	AccountPlatformFees    = "platform_fees"
+	AccountEscrow          = "escrow"
+	AccountExternal        = "external"
+	AccountFXClearing      = "fx_clearing" // the other side of currency conversions
+	AccountUserDebt        = "user_debt"   // negative while a user owes the platform
+)
+
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
+// Accounts that hold real money on behalf of someone and must never go negative.
+var nonNegativeAccounts = map[string]bool{
+	AccountUserAvailable: true,
+	AccountUserPending:   true,
+	AccountUserLocked:    true,
+	AccountEscrow:        true,
+}
+
+// LedgerLeg is one side of a journal entry. OwnerID is empty for platform-wide
+// accounts. The leg posts to the account in the currency of Amount, or in
+// money.DefaultCurrency when Amount has none.
+type LedgerLeg struct {
+	AccountType string
+	OwnerID     string
+	Amount      money.Money
+}
+
+type LedgerService struct {
+	db *sql.DB
+}
+
+func NewLedgerService(db *sql.DB) *LedgerService {
+	return &LedgerService{db: db}
+}
+
+// Post writes a journal entry that balances in each currency inside tx,
+// updates the cached account balances and refreshes the wallet balances of any
+// user accounts touched.
+func (ls *LedgerService) Post(tx *sql.Tx, entry *models.LedgerEntry, legs []LedgerLeg) error {
+	if err := validateLegs(legs); err != nil {
+		return err
+	}
+
+	if entry.ID == "" {
+		entry.ID = uuid.New().String()
+	}
+	entry.CreatedAt = time.Now()
+
+	_, err := tx.Exec(`
+		INSERT INTO ledger_entries (id, entry_type, description, reference_id, reference_type, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		entry.ID, entry.EntryType, entry.Description, entry.ReferenceID, entry.ReferenceType, entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	// Lock accounts in a stable order so concurrent entries cannot deadlock
+	ordered := make([]LedgerLeg, len(legs))
+	copy(ordered, legs)
+	sort.Slice(ordered, func(i, j int) bool {
+		if ordered[i].AccountType != ordered[j].AccountType {
+			return ordered[i].AccountType < ordered[j].AccountType
+		}
+		if ordered[i].OwnerID != ordered[j].OwnerID {
+			return ordered[i].OwnerID < ordered[j].OwnerID
+		}
+		return ordered[i].currency() < ordered[j].currency()
+	})
+
+	entry.Lines = nil
+	for _, leg := range ordered {
+		account, err := ls.lockAccount(tx, leg.AccountType, leg.OwnerID, leg.currency())
+		if err != nil {
+			return err
+		}
+
+		newBalance, err := postedBalance(leg, account.Balance)
+		if err != nil {
+			return err
+		}
+
+		line := models.LedgerLine{
+			ID:        uuid.New().String(),
+			EntryID:   entry.ID,
+			AccountID: account.ID,
+			Amount:    leg.Amount,
+			CreatedAt: entry.CreatedAt,
+		}
+		_, err = tx.Exec(`
+			INSERT INTO ledger_lines (id, entry_id, account_id, amount, created_at)
+			VALUES ($1, $2, $3, $4, $5)`,
+			line.ID, line.EntryID, line.AccountID, line.Amount, line.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`UPDATE ledger_accounts SET balance = $1, updated_at = $2 WHERE id = $3`,
+			newBalance, entry.CreatedAt, account.ID)
+		if err != nil {
+			return err
+		}
+
+		if err := ls.syncWallet(tx, leg.AccountType, leg.OwnerID, account.Currency, newBalance); err != nil {
+			return err
+		}
+
+		entry.Lines = append(entry.Lines, line)
+	}
+
+	return nil
+}
+
+// validateLegs checks that an entry has two or more non-zero legs and that
+// they sum to zero in each currency.
+func validateLegs(legs []LedgerLeg) error {
+	if len(legs) < 2 {
+		return fmt.Errorf("ledger entry needs at least two legs")
+	}
+
+	sums := make(map[string]money.Money)
+	for _, leg := range legs {
+		if leg.Amount.IsZero() {
+			return fmt.Errorf("ledger leg for %s has zero amount", leg.AccountType)
+		}
+		currency := leg.currency()
+		sums[currency] = sums[currency].Add(leg.Amount)
+	}
+	for currency, sum := range sums {
+		if !sum.IsZero() {
+			return fmt.Errorf("ledger entry is not balanced: %s legs sum to %s", currency, sum)
+		}
+	}
+	return nil
+}
+
+// postedBalance is an account's balance once leg is posted to it, or
+// ErrInsufficientBalance if that takes an account holding real money negative.
+func postedBalance(leg LedgerLeg, balance money.Money) (money.Money, error) {
+	newBalance := balance.Add(leg.Amount)
+	if nonNegativeAccounts[leg.AccountType] && newBalance.IsNegative() {
+		return money.Money{}, ErrInsufficientBalance
+	}
+	return newBalance, nil
+}
+
+// Balance locks an account inside tx and returns its balance, so a caller
+// sizing a posting to it knows the balance cannot change before it posts.
+func (ls *LedgerService) Balance(tx *sql.Tx, accountType, ownerID, currency string) (money.Money, error) {
+	account, err := ls.lockAccount(tx, accountType, ownerID, currency)
+	if err != nil {
+		return money.Money{}, err
+	}
+	return account.Balance, nil
+}
+
+func (leg LedgerLeg) currency() string {
+	return normalizeCurrency(leg.Amount.Currency)
+}
+
+func (ls *LedgerService) lockAccount(tx *sql.Tx, accountType, ownerID, currency string) (*models.LedgerAccount, error) {
+	var owner *string
+	if ownerID != "" {
+		owner = &ownerID
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO ledger_accounts (id, account_type, owner_id, currency, balance, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, 0, $5, $5)
+		ON CONFLICT DO NOTHING`, uuid.New().String(), accountType, owner, currency, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var account models.LedgerAccount
+	err = tx.QueryRow(`
+		SELECT id, account_type, owner_id, currency, balance, created_at, updated_at
+		FROM ledger_accounts
+		WHERE account_type = $1 AND owner_id IS NOT DISTINCT FROM $2 AND currency = $3
+		FOR UPDATE`, accountType, owner, currency).Scan(
+		&account.ID, &account.AccountType, &account.OwnerID, &account.Currency, &account.Balance,
+		&account.CreatedAt, &account.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock ledger account %s: %w", accountType, err)
+	}
+	account.Balance = account.Balance.WithCurrency(account.Currency)
+
+	return &account, nil
+}
+
+// syncWallet keeps the balance columns of the wallets row, or of the
+// wallet_balances row for other currencies, equal to the ledger.
+func (ls *LedgerService) syncWallet(tx *sql.Tx, accountType, userID, currency string, balance money.Money) error {
+	var column string
+	switch accountType {
+	case AccountUserAvailable:
+		column = "balance"
+	case AccountUserPending:
+		column = "pending_balance"
+	case AccountUserLocked:
+		column = "locked_balance"
+	default:
+		return nil
+	}
+
+	now := time.Now()
+	_, err := tx.Exec(`
+		INSERT INTO wallets (id, user_id, balance, pending_balance, total_earned, total_spent, created_at, updated_at)
+		VALUES ($1, $2, 0, 0, 0, 0, $3, $3)
+		ON CONFLICT (user_id) DO NOTHING`, uuid.New().String(), userID, now)
+	if err != nil {
+		return err
+	}
+
+	if currency == money.DefaultCurrency {
+		_, err = tx.Exec(fmt.Sprintf(`UPDATE wallets SET %s = $1, updated_at = $2 WHERE user_id = $3`, column),
+			balance, now, userID)
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO wallet_balances (id, user_id, currency, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (user_id, currency) DO NOTHING`, uuid.New().String(), userID, currency, now)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(`
+		UPDATE wallet_balances SET %s = $1, updated_at = $2
+		WHERE user_id = $3 AND currency = $4`, column), balance, now, userID, currency)
+	return err
+}
+
+// GetUserAccounts returns the available, pending and locked ledger accounts of
+// a user in every currency they hold.
+func (ls *LedgerService) GetUserAccounts(userID string) ([]models.LedgerAccount, error) {
+	query := `
+		SELECT id, account_type, owner_id, currency, balance, created_at, updated_at
+		FROM ledger_accounts
+		WHERE owner_id = $1 AND account_type IN ('user_available', 'user_locked', 'user_pending')
+		ORDER BY currency, account_type`
+
+	rows, err := ls.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []models.LedgerAccount
+	for rows.Next() {
+		var account models.LedgerAccount
+		err := rows.Scan(&account.ID, &account.AccountType, &account.OwnerID, &account.Currency,
+			&account.Balance, &account.CreatedAt, &account.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		account.Balance = account.Balance.WithCurrency(account.Currency)
+		accounts = append(accounts, account)
+	}
+
+	return accounts, nil
+}
+
+type WalletLedgerCheck struct {
+	UserID               string      `json:"user_id"`
+	WalletBalance        money.Money `json:"wallet_balance"`
+	LedgerBalance        money.Money `json:"ledger_balance"`
+	WalletPendingBalance money.Money `json:"wallet_pending_balance"`
+	LedgerPendingBalance money.Money `json:"ledger_pending_balance"`
+	WalletLockedBalance  money.Money `json:"wallet_locked_balance"`
+	LedgerLockedBalance  money.Money `json:"ledger_locked_balance"`
+	Consistent           bool        `json:"consistent"`
+}
+
+// CheckWallet compares a wallet against the sum of its ledger lines in
+// money.DefaultCurrency.
+func (ls *LedgerService) CheckWallet(userID string) (*WalletLedgerCheck, error) {
+	check := &WalletLedgerCheck{UserID: userID}
+
+	err := ls.db.QueryRow(`SELECT balance, pending_balance, locked_balance FROM wallets WHERE user_id = $1`, userID).Scan(
+		&check.WalletBalance, &check.WalletPendingBalance, &check.WalletLockedBalance)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	err = ls.db.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN a.account_type = 'user_available' THEN l.amount END), 0),
+			COALESCE(SUM(CASE WHEN a.account_type = 'user_pending' THEN l.amount END), 0),
+			COALESCE(SUM(CASE WHEN a.account_type = 'user_locked' THEN l.amount END), 0)
+		FROM ledger_lines l
+		JOIN ledger_accounts a ON l.account_id = a.id
+		WHERE a.owner_id = $1 AND a.currency = $2`, userID, money.DefaultCurrency).Scan(&check.LedgerBalance, &check.LedgerPendingBalance, &check.LedgerLockedBalance)
+	if err != nil {
+		return nil, err
+	}
+
+	check.Consistent = check.WalletBalance.Equal(check.LedgerBalance) &&
+		check.WalletPendingBalance.Equal(check.LedgerPendingBalance) &&
+		check.WalletLockedBalance.Equal(check.LedgerLockedBalance)
+
+	return check, nil
+}
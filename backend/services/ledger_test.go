@@ -0,0 +1,89 @@
+package services
+
+import (
+	"testing"
+
+	"microjob-backend/money"
+)
+
+func TestValidateLegs(t *testing.T) {
+	leg := func(accountType string, minor int64, currency string) LedgerLeg {
+		return LedgerLeg{AccountType: accountType, OwnerID: "user", Amount: money.New(minor, currency)}
+	}
+
+	tests := []struct {
+		name    string
+		legs    []LedgerLeg
+		wantErr bool
+	}{
+		{"two legs balance", []LedgerLeg{
+			leg(AccountUserAvailable, -1000, "USD"), leg(AccountUserLocked, 1000, "USD"),
+		}, false},
+		{"three legs balance", []LedgerLeg{
+			leg(AccountEscrow, -1000, "USD"), leg(AccountUserAvailable, 950, "USD"), leg(AccountPlatformFees, 50, "USD"),
+		}, false},
+		{"each currency balances on its own", []LedgerLeg{
+			leg(AccountUserAvailable, -1000, "USD"), leg(AccountFXClearing, 1000, "USD"),
+			leg(AccountFXClearing, -920, "EUR"), leg(AccountUserAvailable, 920, "EUR"),
+		}, false},
+		{"no currency is the default", []LedgerLeg{
+			leg(AccountUserAvailable, -1000, ""), leg(AccountUserLocked, 1000, "USD"),
+		}, false},
+		{"currency case does not matter", []LedgerLeg{
+			leg(AccountUserAvailable, -1000, "eur"), leg(AccountUserLocked, 1000, "EUR"),
+		}, false},
+		{"no legs", nil, true},
+		{"one leg", []LedgerLeg{leg(AccountUserAvailable, 1000, "USD")}, true},
+		{"zero leg", []LedgerLeg{
+			leg(AccountUserAvailable, -1000, "USD"), leg(AccountUserLocked, 1000, "USD"), leg(AccountPlatformFees, 0, "USD"),
+		}, true},
+		{"a cent out", []LedgerLeg{
+			leg(AccountUserAvailable, -1000, "USD"), leg(AccountUserLocked, 999, "USD"),
+		}, true},
+		{"balances only across currencies", []LedgerLeg{
+			leg(AccountUserAvailable, -1000, "USD"), leg(AccountUserAvailable, 1000, "EUR"),
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateLegs(tt.legs)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateLegs = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPostedBalance(t *testing.T) {
+	tests := []struct {
+		name        string
+		accountType string
+		balance     int64
+		amount      int64
+		want        int64
+		wantErr     error
+	}{
+		{"credit", AccountUserAvailable, 1000, 500, 1500, nil},
+		{"debit", AccountUserAvailable, 1000, -400, 600, nil},
+		{"debit to zero", AccountUserLocked, 1000, -1000, 0, nil},
+		{"overdrawn wallet", AccountUserAvailable, 1000, -1001, 0, ErrInsufficientBalance},
+		{"overdrawn pending balance", AccountUserPending, 0, -1, 0, ErrInsufficientBalance},
+		{"overdrawn escrow", AccountEscrow, 500, -600, 0, ErrInsufficientBalance},
+		{"external account goes negative", AccountExternal, 0, -1000, -1000, nil},
+		{"debt goes negative", AccountUserDebt, 0, -250, -250, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leg := LedgerLeg{AccountType: tt.accountType, Amount: money.New(tt.amount, "USD")}
+			got, err := postedBalance(leg, money.New(tt.balance, "USD"))
+			if err != tt.wantErr {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got != money.New(tt.want, "USD") {
+				t.Errorf("balance = %+v, want %d USD", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,516 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"microjob-backend/models"
+	"microjob-backend/money"
+)
+
+// AccountReconciliation is the platform account that funds or absorbs
+// corrections written after a reconciliation.
+const AccountReconciliation = "reconciliation"
+
+// Wallet columns checked by a reconciliation run
+const (
+	FieldBalance        = "balance"
+	FieldPendingBalance = "pending_balance"
+	FieldLockedBalance  = "locked_balance"
+	FieldTotalEarned    = "total_earned"
+	FieldTotalSpent     = "total_spent"
+)
+
+// reconciliationFields is the order discrepancies are reported in.
+var reconciliationFields = []string{FieldBalance, FieldPendingBalance, FieldLockedBalance, FieldTotalEarned, FieldTotalSpent}
+
+var (
+	ErrReconciliationRunNotFound = errors.New("reconciliation run not found")
+	ErrDiscrepancyNotOpen        = errors.New("discrepancy is not open")
+	ErrDiscrepancyStale          = errors.New("wallet changed since the run; reconcile again before correcting")
+)
+
+// walletFigures holds the five reconciled wallet columns, either as stored or
+// as rebuilt from the transaction history.
+type walletFigures map[string]money.Money
+
+type ReconciliationService struct {
+	db     *sql.DB
+	ledger *LedgerService
+}
+
+func NewReconciliationService(db *sql.DB) *ReconciliationService {
+	return &ReconciliationService{
+		db:     db,
+		ledger: NewLedgerService(db),
+	}
+}
+
+// Reconcile rebuilds wallet balances and totals from wallet_transactions and
+// records every column that disagrees. It never changes a wallet; corrections
+// are written separately by CorrectDiscrepancies. userID limits the run to one
+// wallet and requestedBy is empty for scheduled runs.
+func (rs *ReconciliationService) Reconcile(userID, requestedBy string) (*models.WalletReconciliationRun, error) {
+	run := &models.WalletReconciliationRun{
+		ID:        uuid.New().String(),
+		Status:    "running",
+		StartedAt: time.Now(),
+	}
+	if userID != "" {
+		run.UserID = &userID
+	}
+	if requestedBy != "" {
+		run.RequestedBy = &requestedBy
+	}
+
+	_, err := rs.db.Exec(`
+		INSERT INTO wallet_reconciliation_runs (id, requested_by, user_id, status, started_at)
+		VALUES ($1, $2, $3, $4, $5)`, run.ID, run.RequestedBy, run.UserID, run.Status, run.StartedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = rs.reconcile(run, userID)
+	now := time.Now()
+	run.CompletedAt = &now
+	run.Status = "completed"
+	if err != nil {
+		run.Status = "failed"
+		run.Error = stringPtr(err.Error())
+	}
+
+	_, updateErr := rs.db.Exec(`
+		UPDATE wallet_reconciliation_runs
+		SET status = $1, wallets_checked = $2, discrepancy_count = $3, unclassified_count = $4,
+			error = $5, completed_at = $6
+		WHERE id = $7`, run.Status, run.WalletsChecked, run.DiscrepancyCount, run.UnclassifiedCount,
+		run.Error, run.CompletedAt, run.ID)
+	if err != nil {
+		return run, err
+	}
+	return run, updateErr
+}
+
+func (rs *ReconciliationService) reconcile(run *models.WalletReconciliationRun, userID string) error {
+	// Wallets and transactions are read from one snapshot so payments made
+	// during the run cannot show up as drift
+	snapshot, err := rs.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer snapshot.Rollback()
+
+	recorded, err := recordedFigures(snapshot, userID)
+	if err != nil {
+		return err
+	}
+	expected, unclassified, err := expectedFigures(snapshot, userID)
+	if err != nil {
+		return err
+	}
+	snapshot.Rollback()
+
+	run.WalletsChecked = len(recorded)
+	run.UnclassifiedCount = unclassified
+
+	for walletUserID, figures := range recorded {
+		for _, field := range reconciliationFields {
+			want := expected[walletUserID][field]
+			if figures[field].Equal(want) {
+				continue
+			}
+
+			discrepancy := models.WalletDiscrepancy{
+				ID:             uuid.New().String(),
+				RunID:          run.ID,
+				UserID:         walletUserID,
+				Field:          field,
+				RecordedAmount: figures[field],
+				ExpectedAmount: want,
+				Difference:     want.Sub(figures[field]),
+				Status:         "open",
+				CreatedAt:      time.Now(),
+			}
+			_, err = rs.db.Exec(`
+				INSERT INTO wallet_discrepancies (id, run_id, user_id, field, recorded_amount, expected_amount,
+					difference, status, created_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+				discrepancy.ID, discrepancy.RunID, discrepancy.UserID, discrepancy.Field, discrepancy.RecordedAmount,
+				discrepancy.ExpectedAmount, discrepancy.Difference, discrepancy.Status, discrepancy.CreatedAt)
+			if err != nil {
+				return err
+			}
+			run.Discrepancies = append(run.Discrepancies, discrepancy)
+		}
+	}
+	run.DiscrepancyCount = len(run.Discrepancies)
+
+	return nil
+}
+
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func recordedFigures(q queryer, userID string) (map[string]walletFigures, error) {
+	rows, err := q.Query(`
+		SELECT user_id, balance, pending_balance, locked_balance, total_earned, total_spent
+		FROM wallets
+		WHERE ($1 = '' OR user_id::text = $1)`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	figures := make(map[string]walletFigures)
+	for rows.Next() {
+		var id string
+		var balance, pending, locked, earned, spent money.Money
+		err := rows.Scan(&id, &balance, &pending, &locked, &earned, &spent)
+		if err != nil {
+			return nil, err
+		}
+		figures[id] = walletFigures{
+			FieldBalance:        balance,
+			FieldPendingBalance: pending,
+			FieldLockedBalance:  locked,
+			FieldTotalEarned:    earned,
+			FieldTotalSpent:     spent,
+		}
+	}
+
+	return figures, rows.Err()
+}
+
+// expectedFigures replays the transaction history of each wallet. Corrections
+// written by the reconciliation itself are left out, since they exist to bring
+// the wallet back in line with that history. Only the USD balances kept on the
+// wallets row are reconciled.
+func expectedFigures(q queryer, userID string) (map[string]walletFigures, int, error) {
+	return replayTransactions(q, `($1 = '' OR w.user_id::text = $1) AND t.type <> 'reconciliation'
+		AND t.currency = 'USD'`, userID)
+}
+
+// replayTransactions folds the transactions matching where into figures per
+// user and counts the transactions of unknown type.
+func replayTransactions(q queryer, where string, args ...interface{}) (map[string]walletFigures, int, error) {
+	rows, err := q.Query(`
+		SELECT w.user_id, t.type, COALESCE(t.balance_type, 'deposit'), COALESCE(t.status, 'completed'),
+			SUM(`+transactionAmount+`), COUNT(*)
+		FROM wallet_transactions t
+		JOIN wallets w ON w.id = t.wallet_id
+		WHERE `+where+`
+		GROUP BY w.user_id, t.type, COALESCE(t.balance_type, 'deposit'), COALESCE(t.status, 'completed')`, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	figures := make(map[string]walletFigures)
+	unclassified := 0
+	for rows.Next() {
+		var id, transactionType, balanceType, status string
+		var amount money.Money
+		var count int
+		err := rows.Scan(&id, &transactionType, &balanceType, &status, &amount, &count)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if figures[id] == nil {
+			figures[id] = walletFigures{}
+		}
+		if !applyTransaction(figures[id], transactionType, balanceType, status, amount) {
+			unclassified += count
+		}
+	}
+
+	return figures, unclassified, rows.Err()
+}
+
+// transactionAmount selects the amount applyTransaction expects. Older rows
+// may store debits as negative amounts, so everything except corrections is
+// made unsigned.
+const transactionAmount = `CASE WHEN t.type = 'reconciliation' THEN t.amount ELSE ABS(t.amount) END`
+
+// applyTransaction adds the effect of transactions of one kind to figures,
+// mirroring how the wallet service posts them. Amounts are unsigned and the
+// type gives the direction, except for signed corrections. It reports false for
+// types it does not know.
+func applyTransaction(figures walletFigures, transactionType, balanceType, status string, amount money.Money) bool {
+	account := FieldBalance
+	if balanceType == "pending" {
+		account = FieldPendingBalance
+	}
+	add := func(field string, delta money.Money) {
+		figures[field] = figures[field].Add(delta)
+	}
+
+	if transactionType == "chat_transfer_sent" && status == "pending" {
+		// Money offered in a chat is locked until the receiver answers; the
+		// transaction then completes like any other transfer or fails
+		add(account, amount.Neg())
+		add(FieldLockedBalance, amount)
+		return true
+	}
+
+	if transactionType == "withdrawal" {
+		// Requested withdrawals sit in the locked balance until paid out or returned
+		switch status {
+		case "pending":
+			add(account, amount.Neg())
+			add(FieldLockedBalance, amount)
+		case "completed":
+			add(account, amount.Neg())
+		}
+		return true
+	}
+
+	if status != "completed" {
+		return true
+	}
+
+	switch transactionType {
+	case "deposit", "earning", "refund", "chat_transfer_received":
+		add(account, amount)
+		add(FieldTotalEarned, amount)
+	case "escrow_refund", "conversion_in", "chat_transfer_refund", "reversal_credit":
+		add(account, amount)
+	case "payment", "fee", "chat_transfer_sent", "job_listing_fee":
+		add(account, amount.Neg())
+		if account == FieldBalance {
+			add(FieldTotalSpent, amount)
+		}
+	case "escrow_hold", "deposit_refund", "conversion_out", "chat_transfer_reversal", "reversal", "debt_repayment":
+		add(account, amount.Neg())
+	case "escrow_release":
+		// Paid out of escrow funded earlier, so only the spend is new
+		add(FieldTotalSpent, amount)
+	case "transfer_pending_to_available":
+		add(FieldPendingBalance, amount.Neg())
+		add(FieldBalance, amount)
+	case "reconciliation":
+		if balanceType == "locked" {
+			account = FieldLockedBalance
+		}
+		add(account, amount)
+	default:
+		return false
+	}
+	return true
+}
+
+// CorrectDiscrepancies writes correcting entries for the chosen open
+// discrepancies of a run, or all of them when discrepancyIDs is empty. Balance
+// columns are corrected through the ledger against the reconciliation account;
+// the lifetime totals are counters and are set directly. A discrepancy whose
+// wallet has changed since the run is marked stale and left alone.
+func (rs *ReconciliationService) CorrectDiscrepancies(runID string, discrepancyIDs []string, adminID string) ([]models.WalletDiscrepancy, error) {
+	run, err := rs.GetRun(runID)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool)
+	for _, id := range discrepancyIDs {
+		wanted[id] = true
+	}
+
+	var results []models.WalletDiscrepancy
+	for _, discrepancy := range run.Discrepancies {
+		if discrepancy.Status != "open" || (len(wanted) > 0 && !wanted[discrepancy.ID]) {
+			continue
+		}
+
+		err := rs.correct(&discrepancy, adminID)
+		if err == ErrDiscrepancyNotOpen {
+			// Corrected by a concurrent request
+			continue
+		}
+		if err == ErrDiscrepancyStale {
+			_, err = rs.db.Exec(`UPDATE wallet_discrepancies SET status = 'stale' WHERE id = $1`, discrepancy.ID)
+			discrepancy.Status = "stale"
+		}
+		if err != nil {
+			return results, fmt.Errorf("correcting %s of user %s: %w", discrepancy.Field, discrepancy.UserID, err)
+		}
+		results = append(results, discrepancy)
+	}
+
+	return results, nil
+}
+
+func (rs *ReconciliationService) correct(discrepancy *models.WalletDiscrepancy, adminID string) error {
+	tx, err := rs.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Locking the wallet holds off payments until the correction is written
+	var status string
+	err = tx.QueryRow(`SELECT status FROM wallet_discrepancies WHERE id = $1 FOR UPDATE`, discrepancy.ID).Scan(&status)
+	if err != nil {
+		return err
+	}
+	if status != "open" {
+		return ErrDiscrepancyNotOpen
+	}
+	_, err = tx.Exec(`SELECT 1 FROM wallets WHERE user_id = $1 FOR UPDATE`, discrepancy.UserID)
+	if err != nil {
+		return err
+	}
+
+	recorded, err := recordedFigures(tx, discrepancy.UserID)
+	if err != nil {
+		return err
+	}
+	expected, _, err := expectedFigures(tx, discrepancy.UserID)
+	if err != nil {
+		return err
+	}
+	current := recorded[discrepancy.UserID][discrepancy.Field]
+	if !expected[discrepancy.UserID][discrepancy.Field].Sub(current).Equal(discrepancy.Difference) {
+		return ErrDiscrepancyStale
+	}
+
+	switch discrepancy.Field {
+	case FieldTotalEarned, FieldTotalSpent:
+		_, err = tx.Exec(fmt.Sprintf(`UPDATE wallets SET %s = $1, updated_at = $2 WHERE user_id = $3`, discrepancy.Field),
+			discrepancy.ExpectedAmount, time.Now(), discrepancy.UserID)
+	default:
+		err = rs.postCorrection(tx, discrepancy)
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	discrepancy.Status = "corrected"
+	discrepancy.CorrectedBy = &adminID
+	discrepancy.CorrectedAt = &now
+	_, err = tx.Exec(`
+		UPDATE wallet_discrepancies SET status = $1, corrected_by = $2, corrected_at = $3, ledger_entry_id = $4
+		WHERE id = $5`, discrepancy.Status, adminID, now, discrepancy.LedgerEntryID, discrepancy.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// postCorrection moves the difference between the user's balance account and
+// the reconciliation account, and shows it in the user's history.
+func (rs *ReconciliationService) postCorrection(tx *sql.Tx, discrepancy *models.WalletDiscrepancy) error {
+	accountType, balanceType := AccountUserAvailable, "deposit"
+	switch discrepancy.Field {
+	case FieldPendingBalance:
+		accountType, balanceType = AccountUserPending, "pending"
+	case FieldLockedBalance:
+		accountType, balanceType = AccountUserLocked, "locked"
+	}
+
+	description := fmt.Sprintf("Balance correction after reconciliation run %s", discrepancy.RunID)
+	entry := &models.LedgerEntry{
+		EntryType:     "reconciliation",
+		Description:   &description,
+		ReferenceID:   &discrepancy.ID,
+		ReferenceType: stringPtr("wallet_discrepancy"),
+	}
+	err := rs.ledger.Post(tx, entry, []LedgerLeg{
+		{AccountType: accountType, OwnerID: discrepancy.UserID, Amount: discrepancy.Difference},
+		{AccountType: AccountReconciliation, Amount: discrepancy.Difference.Neg()},
+	})
+	if err != nil {
+		return err
+	}
+	discrepancy.LedgerEntryID = &entry.ID
+
+	// Unlike other transactions the amount is signed, as a correction can go either way
+	return insertWalletTransaction(tx, &models.WalletTransaction{
+		UserID:        discrepancy.UserID,
+		Type:          "reconciliation",
+		Amount:        discrepancy.Difference,
+		Description:   &description,
+		ReferenceID:   &discrepancy.ID,
+		ReferenceType: stringPtr("wallet_discrepancy"),
+		BalanceType:   balanceType,
+		Status:        "completed",
+		LedgerEntryID: &entry.ID,
+	})
+}
+
+// GetRun returns a run with its discrepancies.
+func (rs *ReconciliationService) GetRun(runID string) (*models.WalletReconciliationRun, error) {
+	run, err := scanReconciliationRun(rs.db.QueryRow(reconciliationRunSelect+` WHERE id = $1`, runID))
+	if err == sql.ErrNoRows {
+		return nil, ErrReconciliationRunNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := rs.db.Query(`
+		SELECT id, run_id, user_id, field, recorded_amount, expected_amount, difference, status,
+			corrected_by, corrected_at, ledger_entry_id, created_at
+		FROM wallet_discrepancies
+		WHERE run_id = $1
+		ORDER BY user_id, created_at`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var discrepancy models.WalletDiscrepancy
+		err := rows.Scan(&discrepancy.ID, &discrepancy.RunID, &discrepancy.UserID, &discrepancy.Field,
+			&discrepancy.RecordedAmount, &discrepancy.ExpectedAmount, &discrepancy.Difference, &discrepancy.Status,
+			&discrepancy.CorrectedBy, &discrepancy.CorrectedAt, &discrepancy.LedgerEntryID, &discrepancy.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		run.Discrepancies = append(run.Discrepancies, discrepancy)
+	}
+
+	return run, rows.Err()
+}
+
+func (rs *ReconciliationService) GetRuns(limit, offset int) ([]models.WalletReconciliationRun, error) {
+	rows, err := rs.db.Query(reconciliationRunSelect+`
+		ORDER BY started_at DESC
+		LIMIT $1 OFFSET $2`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []models.WalletReconciliationRun
+	for rows.Next() {
+		run, err := scanReconciliationRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+
+	return runs, rows.Err()
+}
+
+const reconciliationRunSelect = `
+	SELECT id, requested_by, user_id, status, wallets_checked, discrepancy_count, unclassified_count,
+		error, started_at, completed_at
+	FROM wallet_reconciliation_runs`
+
+func scanReconciliationRun(row interface{ Scan(...interface{}) error }) (*models.WalletReconciliationRun, error) {
+	var run models.WalletReconciliationRun
+	err := row.Scan(&run.ID, &run.RequestedBy, &run.UserID, &run.Status, &run.WalletsChecked,
+		&run.DiscrepancyCount, &run.UnclassifiedCount, &run.Error, &run.StartedAt, &run.CompletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
@@ -0,0 +1,490 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"microjob-backend/models"
+	"microjob-backend/money"
+)
+
+var (
+	ErrTransactionNotFound    = errors.New("transaction not found")
+	ErrRefundNotAllowed       = errors.New("only completed earnings and received chat transfers can be refunded")
+	ErrRefundExceedsOriginal  = errors.New("refund exceeds what is left of the original transaction")
+	ErrRefundCurrencyMismatch = errors.New("refund must be in the currency of the original transaction")
+	ErrRefundNoPayer          = errors.New("original transaction has no payer to refund")
+	ErrOutstandingDebt        = errors.New("wallet has an outstanding debt")
+)
+
+// Transaction types an admin can refund. Each is the payee's side of a payment.
+var refundableTransactionTypes = map[string]bool{
+	"earning":                true,
+	"chat_transfer_received": true,
+}
+
+type RefundService struct {
+	db     *sql.DB
+	ledger *LedgerService
+}
+
+func NewRefundService(db *sql.DB) *RefundService {
+	return &RefundService{
+		db:     db,
+		ledger: NewLedgerService(db),
+	}
+}
+
+// RefundTransaction gives amount of the payment behind transactionID back to
+// whoever paid it; a zero amount refunds whatever has not been refunded yet.
+// The payee returns it from the earning's own hold, their available balance
+// and their other held earnings, in that order, and owes the rest as a debt.
+// Fees the platform took on the original payment are kept.
+func (rs *RefundService) RefundTransaction(transactionID, adminID string, amount money.Money, reason string) (*models.WalletRefund, error) {
+	tx, err := rs.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	original, err := lockWalletTransaction(tx, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	if !refundableTransactionTypes[original.Type] || original.Status != "completed" {
+		return nil, ErrRefundNotAllowed
+	}
+	currency := normalizeCurrency(original.Currency)
+	if !amount.IsZero() && normalizeCurrency(amount.Currency) != currency {
+		return nil, fmt.Errorf("%w: %s", ErrRefundCurrencyMismatch, currency)
+	}
+
+	var transfer *models.ChatMoneyTransfer
+	if original.Type == "chat_transfer_received" && original.ReferenceID != nil {
+		transfer, err = lockTransfer(tx, *original.ReferenceID)
+		if err != nil {
+			return nil, err
+		}
+		if transfer.Status != "completed" && transfer.Status != "disputed" && transfer.Status != "dispute_rejected" {
+			return nil, ErrTransferInvalidStatus
+		}
+	}
+
+	var refunded money.Money
+	err = tx.QueryRow(`
+		SELECT COALESCE(SUM(amount), 0) FROM wallet_refunds
+		WHERE original_transaction_id = $1`, original.ID).Scan(&refunded)
+	if err != nil {
+		return nil, err
+	}
+	remaining := original.Amount.Sub(refunded.WithCurrency(currency))
+	if amount.IsZero() {
+		amount = remaining
+	}
+	if !amount.IsPositive() || amount.GreaterThan(remaining) {
+		return nil, ErrRefundExceedsOriginal
+	}
+
+	payerID, err := paymentPayer(tx, original)
+	if err != nil {
+		return nil, err
+	}
+
+	refund := &models.WalletRefund{
+		ID:                    uuid.New().String(),
+		OriginalTransactionID: original.ID,
+		PayerID:               payerID,
+		PayeeID:               original.UserID,
+		Currency:              currency,
+		Amount:                amount,
+		Reason:                reason,
+		RefundedBy:            adminID,
+		CreatedAt:             time.Now(),
+	}
+
+	fromHold, err := rs.pullFromHolds(tx, refund.PayeeID, currency, &original.ID, amount)
+	if err != nil {
+		return nil, err
+	}
+	available, err := rs.ledger.Balance(tx, AccountUserAvailable, refund.PayeeID, currency)
+	if err != nil {
+		return nil, err
+	}
+	refund.FromAvailable = money.Min(available, amount.Sub(fromHold))
+	fromOtherHolds, err := rs.pullFromHolds(tx, refund.PayeeID, currency, nil, amount.Sub(fromHold).Sub(refund.FromAvailable))
+	if err != nil {
+		return nil, err
+	}
+	refund.FromPending = fromHold.Add(fromOtherHolds)
+	refund.AsDebt = amount.Sub(refund.FromAvailable).Sub(refund.FromPending)
+
+	legs := []LedgerLeg{{AccountType: AccountUserAvailable, OwnerID: refund.PayerID, Amount: amount}}
+	for _, leg := range []LedgerLeg{
+		{AccountType: AccountUserAvailable, OwnerID: refund.PayeeID, Amount: refund.FromAvailable.Neg()},
+		{AccountType: AccountUserPending, OwnerID: refund.PayeeID, Amount: refund.FromPending.Neg()},
+		{AccountType: AccountUserDebt, OwnerID: refund.PayeeID, Amount: refund.AsDebt.Neg()},
+	} {
+		if !leg.Amount.IsZero() {
+			legs = append(legs, leg)
+		}
+	}
+
+	description := fmt.Sprintf("Refund: %s", reason)
+	entry := &models.LedgerEntry{
+		EntryType:     "wallet_refund",
+		Description:   &description,
+		ReferenceID:   &refund.ID,
+		ReferenceType: stringPtr("wallet_refund"),
+	}
+	err = rs.ledger.Post(tx, entry, legs)
+	if err != nil {
+		return nil, err
+	}
+	refund.LedgerEntryID = &entry.ID
+
+	_, err = tx.Exec(`
+		INSERT INTO wallet_refunds (id, original_transaction_id, payer_id, payee_id, currency, amount, from_available,
+			from_pending, as_debt, reason, refunded_by, ledger_entry_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		refund.ID, refund.OriginalTransactionID, refund.PayerID, refund.PayeeID, refund.Currency, refund.Amount,
+		refund.FromAvailable, refund.FromPending, refund.AsDebt, refund.Reason, refund.RefundedBy,
+		refund.LedgerEntryID, refund.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Both sides reference the original so either user's history links back to it
+	transactions := []*models.WalletTransaction{
+		{UserID: refund.PayerID, Type: "reversal_credit", Amount: amount, BalanceType: "deposit"},
+		{UserID: refund.PayeeID, Type: "reversal", Amount: refund.FromAvailable, BalanceType: "deposit"},
+		{UserID: refund.PayeeID, Type: "reversal", Amount: refund.FromPending, BalanceType: "pending"},
+	}
+	for _, transaction := range transactions {
+		if transaction.Amount.IsZero() {
+			continue
+		}
+		transaction.Description = &description
+		transaction.ReferenceID = &original.ID
+		transaction.ReferenceType = stringPtr("wallet_transaction")
+		transaction.Status = "completed"
+		transaction.LedgerEntryID = &entry.ID
+		err = insertWalletTransaction(tx, transaction)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if refund.AsDebt.IsPositive() {
+		_, err = tx.Exec(`
+			INSERT INTO wallet_debts (id, user_id, refund_id, currency, amount, outstanding_amount, status,
+				created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $5, 'outstanding', $6, $6)`,
+			uuid.New().String(), refund.PayeeID, refund.ID, currency, refund.AsDebt, refund.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if transfer != nil && amount.Equal(remaining) {
+		_, err = tx.Exec(`
+			UPDATE chat_money_transfers
+			SET status = 'refunded', refunded_by = $1, refunded_at = $2, refund_ledger_entry_id = $3, updated_at = $2
+			WHERE id = $4`, adminID, refund.CreatedAt, entry.ID, transfer.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return refund, tx.Commit()
+}
+
+// paymentPayer finds who paid for original from the other side of its ledger
+// entry. Money released from a job's escrow goes back to the employer.
+func paymentPayer(tx *sql.Tx, original *models.WalletTransaction) (string, error) {
+	if original.LedgerEntryID == nil {
+		return "", ErrRefundNoPayer
+	}
+
+	var payerID string
+	err := tx.QueryRow(`
+		SELECT COALESCE(e.employer_id, a.owner_id)
+		FROM ledger_lines l
+		JOIN ledger_accounts a ON a.id = l.account_id
+		LEFT JOIN job_escrows e ON a.account_type = 'escrow' AND e.job_id = a.owner_id
+		WHERE l.entry_id = $1 AND l.amount < 0
+		  AND a.account_type IN ('user_available', 'user_locked', 'escrow')
+		LIMIT 1`, *original.LedgerEntryID).Scan(&payerID)
+	if err == sql.ErrNoRows {
+		return "", ErrRefundNoPayer
+	}
+	return payerID, err
+}
+
+// pullFromHolds takes up to want out of userID's held earnings, shrinking the
+// holds so their later release only pays out what is left. A transactionID
+// limits it to the hold of that earning; otherwise the holds released last
+// are taken first.
+func (rs *RefundService) pullFromHolds(tx *sql.Tx, userID, currency string, transactionID *string, want money.Money) (money.Money, error) {
+	pulled := money.New(0, currency)
+	if !want.IsPositive() {
+		return pulled, nil
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, amount FROM earning_holds
+		WHERE user_id = $1 AND currency = $2 AND status = 'held' AND ($3::uuid IS NULL OR transaction_id = $3)
+		ORDER BY release_at DESC
+		FOR UPDATE`, userID, currency, transactionID)
+	if err != nil {
+		return pulled, err
+	}
+	var holds []models.EarningHold
+	for rows.Next() {
+		var hold models.EarningHold
+		if err := rows.Scan(&hold.ID, &hold.Amount); err != nil {
+			rows.Close()
+			return pulled, err
+		}
+		hold.Amount = hold.Amount.WithCurrency(currency)
+		holds = append(holds, hold)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return pulled, err
+	}
+
+	now := time.Now()
+	for _, hold := range holds {
+		take := money.Min(hold.Amount, want.Sub(pulled))
+		if take.Equal(hold.Amount) {
+			_, err = tx.Exec(`UPDATE earning_holds SET status = 'reversed', updated_at = $1 WHERE id = $2`, now, hold.ID)
+		} else {
+			_, err = tx.Exec(`UPDATE earning_holds SET amount = $1, updated_at = $2 WHERE id = $3`,
+				hold.Amount.Sub(take), now, hold.ID)
+		}
+		if err != nil {
+			return pulled, err
+		}
+
+		pulled = pulled.Add(take)
+		if !pulled.LessThan(want) {
+			break
+		}
+	}
+
+	return pulled, nil
+}
+
+func (rs *RefundService) GetRefunds(transactionID string) ([]models.WalletRefund, error) {
+	rows, err := rs.db.Query(`
+		SELECT id, original_transaction_id, payer_id, payee_id, currency, amount, from_available, from_pending,
+			as_debt, reason, refunded_by, ledger_entry_id, created_at
+		FROM wallet_refunds
+		WHERE original_transaction_id = $1
+		ORDER BY created_at`, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refunds []models.WalletRefund
+	for rows.Next() {
+		var refund models.WalletRefund
+		err := rows.Scan(&refund.ID, &refund.OriginalTransactionID, &refund.PayerID, &refund.PayeeID,
+			&refund.Currency, &refund.Amount, &refund.FromAvailable, &refund.FromPending, &refund.AsDebt,
+			&refund.Reason, &refund.RefundedBy, &refund.LedgerEntryID, &refund.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		refund.Amount = refund.Amount.WithCurrency(refund.Currency)
+		refund.FromAvailable = refund.FromAvailable.WithCurrency(refund.Currency)
+		refund.FromPending = refund.FromPending.WithCurrency(refund.Currency)
+		refund.AsDebt = refund.AsDebt.WithCurrency(refund.Currency)
+		refunds = append(refunds, refund)
+	}
+
+	return refunds, rows.Err()
+}
+
+// GetDebts lists debts, newest first. An empty userID or status matches all.
+func (rs *RefundService) GetDebts(userID, status string, limit, offset int) ([]models.WalletDebt, error) {
+	rows, err := rs.db.Query(debtSelect+`
+		WHERE ($1 = '' OR user_id::text = $1) AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4`, userID, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var debts []models.WalletDebt
+	for rows.Next() {
+		debt, err := scanDebt(rows)
+		if err != nil {
+			return nil, err
+		}
+		debts = append(debts, *debt)
+	}
+
+	return debts, rows.Err()
+}
+
+// hasOutstandingDebt reports whether userID still owes money in currency.
+func hasOutstandingDebt(q rowQueryer, userID, currency string) (bool, error) {
+	var owes bool
+	err := q.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM wallet_debts
+			WHERE user_id = $1 AND currency = $2 AND status = 'outstanding'
+		)`, userID, normalizeCurrency(currency)).Scan(&owes)
+	return owes, err
+}
+
+// CollectDebts pays down outstanding debts from their users' available
+// balances, oldest debt first. It returns how many debts it took money for.
+func (rs *RefundService) CollectDebts() (int, error) {
+	rows, err := rs.db.Query(`
+		SELECT id FROM wallet_debts
+		WHERE status = 'outstanding'
+		ORDER BY created_at`)
+	if err != nil {
+		return 0, err
+	}
+
+	var debtIDs []string
+	for rows.Next() {
+		var debtID string
+		if err := rows.Scan(&debtID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		debtIDs = append(debtIDs, debtID)
+	}
+	rows.Close()
+
+	collected := 0
+	for _, debtID := range debtIDs {
+		paid, err := rs.collectDebt(debtID)
+		if err != nil {
+			log.Printf("Failed to collect wallet debt %s: %v", debtID, err)
+			continue
+		}
+		if paid {
+			collected++
+		}
+	}
+
+	return collected, nil
+}
+
+func (rs *RefundService) collectDebt(debtID string) (bool, error) {
+	tx, err := rs.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	debt, err := scanDebt(tx.QueryRow(debtSelect+` WHERE id = $1 AND status = 'outstanding' FOR UPDATE`, debtID))
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	available, err := rs.ledger.Balance(tx, AccountUserAvailable, debt.UserID, debt.Currency)
+	if err != nil {
+		return false, err
+	}
+	take := money.Min(available, debt.OutstandingAmount)
+	if !take.IsPositive() {
+		return false, nil
+	}
+
+	description := "Debt repayment from available balance"
+	entry := &models.LedgerEntry{
+		EntryType:     "debt_repayment",
+		Description:   &description,
+		ReferenceID:   &debt.ID,
+		ReferenceType: stringPtr("wallet_debt"),
+	}
+	err = rs.ledger.Post(tx, entry, []LedgerLeg{
+		{AccountType: AccountUserAvailable, OwnerID: debt.UserID, Amount: take.Neg()},
+		{AccountType: AccountUserDebt, OwnerID: debt.UserID, Amount: take},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	err = insertWalletTransaction(tx, &models.WalletTransaction{
+		UserID:        debt.UserID,
+		Type:          "debt_repayment",
+		Amount:        take,
+		Description:   &description,
+		ReferenceID:   &debt.ID,
+		ReferenceType: stringPtr("wallet_debt"),
+		BalanceType:   "deposit",
+		Status:        "completed",
+		LedgerEntryID: &entry.ID,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	debt.OutstandingAmount = debt.OutstandingAmount.Sub(take)
+	var settledAt *time.Time
+	if debt.OutstandingAmount.IsZero() {
+		debt.Status = "settled"
+		settledAt = &now
+	}
+	_, err = tx.Exec(`
+		UPDATE wallet_debts SET outstanding_amount = $1, status = $2, settled_at = $3, updated_at = $4
+		WHERE id = $5`, debt.OutstandingAmount, debt.Status, settledAt, now, debt.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+const debtSelect = `
+	SELECT id, user_id, refund_id, currency, amount, outstanding_amount, status, created_at, updated_at, settled_at
+	FROM wallet_debts`
+
+func scanDebt(row interface{ Scan(...interface{}) error }) (*models.WalletDebt, error) {
+	var debt models.WalletDebt
+	err := row.Scan(&debt.ID, &debt.UserID, &debt.RefundID, &debt.Currency, &debt.Amount, &debt.OutstandingAmount,
+		&debt.Status, &debt.CreatedAt, &debt.UpdatedAt, &debt.SettledAt)
+	if err != nil {
+		return nil, err
+	}
+	debt.Amount = debt.Amount.WithCurrency(debt.Currency)
+	debt.OutstandingAmount = debt.OutstandingAmount.WithCurrency(debt.Currency)
+	return &debt, nil
+}
+
+func lockWalletTransaction(tx *sql.Tx, transactionID string) (*models.WalletTransaction, error) {
+	var transaction models.WalletTransaction
+	err := tx.QueryRow(`
+		SELECT t.id, COALESCE(t.user_id, w.user_id), t.type, t.amount, t.currency, t.reference_id, t.reference_type,
+			COALESCE(t.status, 'completed'), t.ledger_entry_id, t.created_at
+		FROM wallet_transactions t
+		JOIN wallets w ON w.id = t.wallet_id
+		WHERE t.id = $1
+		FOR UPDATE OF t`, transactionID).Scan(&transaction.ID, &transaction.UserID, &transaction.Type,
+		&transaction.Amount, &transaction.Currency, &transaction.ReferenceID, &transaction.ReferenceType,
+		&transaction.Status, &transaction.LedgerEntryID, &transaction.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	transaction.Amount = transaction.Amount.WithCurrency(transaction.Currency)
+	return &transaction, nil
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"time"
+
+	"microjob-backend/models"
+	"microjob-backend/money"
+	"microjob-backend/statements"
+)
+
+// StatementFilter selects the transactions shown on a statement. Balances are
+// always computed from every transaction, so filtering never changes them. A
+// statement covers one currency balance, USD unless Currency says otherwise.
+type StatementFilter struct {
+	UserID   string
+	Currency string
+	From     time.Time
+	To       time.Time // exclusive
+	Types    []string
+	Status   string
+}
+
+// WriteStatement streams a wallet statement to out. Opening balances are
+// replayed from the history before From, then each transaction in the period
+// moves the running balances. Rows are written as they are read so the
+// statement's size does not depend on memory.
+func (ws *WalletService) WriteStatement(filter StatementFilter, out statements.Writer) error {
+	currency := normalizeCurrency(filter.Currency)
+	opening, _, err := replayTransactions(ws.db, `w.user_id = $1 AND t.currency = $2 AND t.created_at < $3`,
+		filter.UserID, currency, filter.From)
+	if err != nil {
+		return err
+	}
+	running := opening[filter.UserID]
+	if running == nil {
+		running = walletFigures{}
+	}
+	for field, amount := range running {
+		running[field] = amount.WithCurrency(currency)
+	}
+
+	err = out.Begin(statements.Header{
+		UserID:      filter.UserID,
+		From:        filter.From,
+		To:          filter.To,
+		Types:       filter.Types,
+		Status:      filter.Status,
+		Opening:     statementBalances(running),
+		GeneratedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	rows, err := ws.db.Query(`
+		SELECT t.type, COALESCE(t.balance_type, 'deposit'), COALESCE(t.status, 'completed'), `+transactionAmount+`,
+			t.description, t.reference_type, t.reference_id, t.created_at
+		FROM wallet_transactions t
+		JOIN wallets w ON w.id = t.wallet_id
+		WHERE w.user_id = $1 AND t.currency = $2 AND t.created_at >= $3 AND t.created_at < $4
+		ORDER BY t.created_at, t.id`, filter.UserID, currency, filter.From, filter.To)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	types := make(map[string]bool)
+	for _, transactionType := range filter.Types {
+		types[transactionType] = true
+	}
+
+	lines := 0
+	for rows.Next() {
+		var transaction models.WalletTransaction
+		var amount money.Money
+		err := rows.Scan(&transaction.Type, &transaction.BalanceType, &transaction.Status, &amount,
+			&transaction.Description, &transaction.ReferenceType, &transaction.ReferenceID, &transaction.CreatedAt)
+		if err != nil {
+			return err
+		}
+		amount = amount.WithCurrency(currency)
+
+		before := running[FieldBalance].Add(running[FieldPendingBalance])
+		applyTransaction(running, transaction.Type, transaction.BalanceType, transaction.Status, amount)
+
+		if len(types) > 0 && !types[transaction.Type] {
+			continue
+		}
+		if filter.Status != "" && transaction.Status != filter.Status {
+			continue
+		}
+
+		line := statements.Line{
+			Date:     transaction.CreatedAt,
+			Type:     transaction.Type,
+			Status:   transaction.Status,
+			Amount:   running[FieldBalance].Add(running[FieldPendingBalance]).Sub(before),
+			Balances: statementBalances(running),
+		}
+		if transaction.Description != nil {
+			line.Description = *transaction.Description
+		}
+		if transaction.ReferenceType != nil && transaction.ReferenceID != nil {
+			line.Reference = *transaction.ReferenceType + ":" + *transaction.ReferenceID
+		}
+		err = out.Line(line)
+		if err != nil {
+			return err
+		}
+		lines++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return out.End(statementBalances(running), lines)
+}
+
+func statementBalances(figures walletFigures) statements.Balances {
+	return statements.Balances{
+		Available: figures[FieldBalance],
+		Pending:   figures[FieldPendingBalance],
+	}
+}
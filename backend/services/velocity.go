@@ -0,0 +1,379 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"microjob-backend/cache"
+	"microjob-backend/models"
+	"microjob-backend/money"
+)
+
+var (
+	ErrVelocityLimitExceeded = errors.New("wallet outflow limit exceeded")
+	ErrInvalidVelocityLimits = errors.New("velocity limits cannot be negative")
+)
+
+// DefaultVelocitySettings apply until an admin saves their own.
+var DefaultVelocitySettings = models.VelocitySettings{
+	Default: models.VelocityLimits{
+		MaxSingleAmount: money.New(100000, money.DefaultCurrency),
+		MaxDailyAmount:  money.New(200000, money.DefaultCurrency),
+		MaxWeeklyAmount: money.New(500000, money.DefaultCurrency),
+		MaxPerHour:      10,
+	},
+	NewAccount: models.VelocityLimits{
+		MaxSingleAmount: money.New(10000, money.DefaultCurrency),
+		MaxDailyAmount:  money.New(20000, money.DefaultCurrency),
+		MaxWeeklyAmount: money.New(50000, money.DefaultCurrency),
+		MaxPerHour:      3,
+	},
+	NewAccountDays: 7,
+}
+
+// VelocityService limits how fast money can leave a wallet through chat
+// transfers and withdrawals. Usage is counted per UTC clock hour, day and
+// week starting Monday. Redis caches the counters; wallet_transactions is
+// the source of truth whenever Redis is missing a window or unavailable.
+//
+// Outflows from one wallet are checked one at a time: Check locks the user's
+// wallets row in the caller's transaction, and the caller records the outflow
+// before committing, so the next check sees it.
+type VelocityService struct {
+	db     *sql.DB
+	redis  *cache.RedisClient
+	alerts *AlertService
+}
+
+// NewVelocityService builds the service. redis may be nil, in which case every
+// check reads usage from the database.
+func NewVelocityService(db *sql.DB, redis *cache.RedisClient) *VelocityService {
+	return &VelocityService{
+		db:     db,
+		redis:  redis,
+		alerts: NewAlertService(db),
+	}
+}
+
+func (vs *VelocityService) GetSettings() (*models.VelocitySettings, error) {
+	settings := DefaultVelocitySettings
+
+	var settingsJSON string
+	err := vs.db.QueryRow(`SELECT setting_value FROM admin_settings WHERE setting_key = 'velocity_limits'`).Scan(&settingsJSON)
+	if err == sql.ErrNoRows {
+		return &settings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Fields missing from the stored settings keep their defaults
+	if err := json.Unmarshal([]byte(settingsJSON), &settings); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (vs *VelocityService) UpdateSettings(settings *models.VelocitySettings) error {
+	for _, limits := range []models.VelocityLimits{settings.Default, settings.NewAccount} {
+		if limits.MaxSingleAmount.IsNegative() || limits.MaxDailyAmount.IsNegative() ||
+			limits.MaxWeeklyAmount.IsNegative() || limits.MaxPerHour < 0 {
+			return ErrInvalidVelocityLimits
+		}
+	}
+	if settings.NewAccountDays < 0 {
+		return ErrInvalidVelocityLimits
+	}
+
+	settingsJSON, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+
+	_, err = vs.db.Exec(`
+		INSERT INTO admin_settings (setting_key, setting_value, updated_at)
+		VALUES ('velocity_limits', $1, $2)
+		ON CONFLICT (setting_key)
+		DO UPDATE SET setting_value = $1, updated_at = $2`, string(settingsJSON), time.Now())
+	return err
+}
+
+// Check returns an error wrapping ErrVelocityLimitExceeded when sending amount
+// would take userID over one of their limits, and raises an admin alert. It
+// must run in the transaction that moves the money, which holds the user's
+// wallet lock until it ends. The outflow itself is counted by Record.
+func (vs *VelocityService) Check(tx *sql.Tx, userID string, amount money.Money) error {
+	_, err := tx.Exec(`SELECT 1 FROM wallets WHERE user_id = $1 FOR UPDATE`, userID)
+	if err != nil {
+		return err
+	}
+
+	settings, err := vs.GetSettings()
+	if err != nil {
+		return err
+	}
+	limits, err := vs.limitsFor(userID, settings)
+	if err != nil {
+		return err
+	}
+	value, err := vs.inDefaultCurrency(amount)
+	if err != nil {
+		return err
+	}
+
+	if limits.MaxSingleAmount.IsPositive() && value.GreaterThan(limits.MaxSingleAmount) {
+		return vs.breach(userID, amount, "max_single_amount",
+			fmt.Sprintf("limit of %s %s per transaction", limits.MaxSingleAmount, money.DefaultCurrency), nil)
+	}
+
+	windows := velocityWindowsAt(time.Now())
+	usage, err := vs.usage(tx, userID, windows)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case limits.MaxPerHour > 0 && usage.count >= int64(limits.MaxPerHour):
+		return vs.breach(userID, amount, "max_per_hour",
+			fmt.Sprintf("limit of %d transactions per hour", limits.MaxPerHour), usage)
+	case limits.MaxDailyAmount.IsPositive() && usage.daily.Add(value).GreaterThan(limits.MaxDailyAmount):
+		return vs.breach(userID, amount, "max_daily_amount",
+			fmt.Sprintf("daily limit of %s %s", limits.MaxDailyAmount, money.DefaultCurrency), usage)
+	case limits.MaxWeeklyAmount.IsPositive() && usage.weekly.Add(value).GreaterThan(limits.MaxWeeklyAmount):
+		return vs.breach(userID, amount, "max_weekly_amount",
+			fmt.Sprintf("weekly limit of %s %s", limits.MaxWeeklyAmount, money.DefaultCurrency), usage)
+	}
+	return nil
+}
+
+// Record adds an outflow to the cached counters. Call it after Check, just
+// before committing, while the wallet is still locked. Windows that are not
+// cached are left alone: the next Check loads them from wallet_transactions,
+// which by then holds this outflow.
+func (vs *VelocityService) Record(userID string, amount money.Money) {
+	if vs.redis == nil {
+		return
+	}
+	value, err := vs.inDefaultCurrency(amount)
+	if err != nil {
+		log.Printf("Failed to record wallet outflow for user %s: %v", userID, err)
+		return
+	}
+
+	windows := velocityWindowsAt(time.Now())
+	for _, counter := range windows.counters(userID, velocityUsage{count: 1, daily: value, weekly: value}) {
+		if !vs.redis.Exists(counter.key) {
+			continue
+		}
+		if _, err := vs.redis.IncrementBy(counter.key, counter.value); err != nil {
+			log.Printf("Failed to record wallet outflow for user %s: %v", userID, err)
+			continue
+		}
+		vs.redis.SetExpiration(counter.key, time.Until(counter.expiresAt))
+	}
+}
+
+// Forget drops the user's cached counters after a recorded outflow failed to
+// commit, so the next Check reloads them from wallet_transactions.
+func (vs *VelocityService) Forget(userID string) {
+	if vs.redis == nil {
+		return
+	}
+	for _, counter := range velocityWindowsAt(time.Now()).counters(userID, velocityUsage{}) {
+		if err := vs.redis.Delete(counter.key); err != nil {
+			log.Printf("Failed to clear wallet outflow counters for user %s: %v", userID, err)
+		}
+	}
+}
+
+func (vs *VelocityService) limitsFor(userID string, settings *models.VelocitySettings) (models.VelocityLimits, error) {
+	var createdAt time.Time
+	err := vs.db.QueryRow(`SELECT created_at FROM users WHERE id = $1`, userID).Scan(&createdAt)
+	if err != nil {
+		return models.VelocityLimits{}, err
+	}
+
+	if time.Since(createdAt) < time.Duration(settings.NewAccountDays)*24*time.Hour {
+		return settings.NewAccount, nil
+	}
+	return settings.Default, nil
+}
+
+func (vs *VelocityService) inDefaultCurrency(amount money.Money) (money.Money, error) {
+	rate, err := exchangeRate(vs.db, amount.Currency)
+	if err != nil {
+		return money.Money{}, err
+	}
+	return amount.Convert(rate, money.BaseExchangeRate, money.DefaultCurrency, money.RoundUp), nil
+}
+
+func (vs *VelocityService) breach(userID string, amount money.Money, limit, description string, usage *velocityUsage) error {
+	details := map[string]interface{}{
+		"limit":    limit,
+		"amount":   amount.String(),
+		"currency": normalizeCurrency(amount.Currency),
+	}
+	if usage != nil {
+		details["transactions_this_hour"] = usage.count
+		details["sent_today"] = usage.daily.String()
+		details["sent_this_week"] = usage.weekly.String()
+	}
+
+	err := vs.alerts.Raise(&models.AdminAlert{
+		AlertType: "velocity_limit",
+		Severity:  "warning",
+		UserID:    &userID,
+		Message:   fmt.Sprintf("Wallet outflow of %s %s blocked by the %s", amount, normalizeCurrency(amount.Currency), description),
+		Details:   details,
+	})
+	if err != nil {
+		log.Printf("Failed to raise velocity alert for user %s: %v", userID, err)
+	}
+
+	return fmt.Errorf("%w: this would exceed your %s", ErrVelocityLimitExceeded, description)
+}
+
+// velocityUsage is what a user has sent in the current windows, in the
+// default currency.
+type velocityUsage struct {
+	count  int64
+	daily  money.Money
+	weekly money.Money
+}
+
+type velocityWindows struct {
+	hour, day, week time.Time
+}
+
+func velocityWindowsAt(now time.Time) velocityWindows {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return velocityWindows{
+		hour: now.Truncate(time.Hour),
+		day:  day,
+		week: day.AddDate(0, 0, -(int(day.Weekday())+6)%7),
+	}
+}
+
+type velocityCounter struct {
+	key       string
+	value     int64
+	expiresAt time.Time
+}
+
+// counters lays out usage as the Redis keys for userID's windows: the hourly
+// transfer count and the daily and weekly totals in minor units.
+func (w velocityWindows) counters(userID string, usage velocityUsage) []velocityCounter {
+	prefix := "velocity:" + userID
+	return []velocityCounter{
+		{prefix + ":count:hour:" + w.hour.Format("2006-01-02T15"), usage.count, w.hour.Add(time.Hour)},
+		{prefix + ":amount:day:" + w.day.Format("2006-01-02"), usage.daily.Minor, w.day.AddDate(0, 0, 1)},
+		{prefix + ":amount:week:" + w.week.Format("2006-01-02"), usage.weekly.Minor, w.week.AddDate(0, 0, 7)},
+	}
+}
+
+func (vs *VelocityService) usage(tx *sql.Tx, userID string, windows velocityWindows) (*velocityUsage, error) {
+	if usage, ok := vs.cachedUsage(userID, windows); ok {
+		return usage, nil
+	}
+
+	usage, err := vs.storedUsage(tx, userID, windows)
+	if err != nil {
+		return nil, err
+	}
+
+	if vs.redis != nil {
+		for _, counter := range windows.counters(userID, *usage) {
+			err := vs.redis.SetString(counter.key, strconv.FormatInt(counter.value, 10), time.Until(counter.expiresAt))
+			if err != nil {
+				log.Printf("Failed to cache wallet outflow counters for user %s: %v", userID, err)
+				break
+			}
+		}
+	}
+	return usage, nil
+}
+
+func (vs *VelocityService) cachedUsage(userID string, windows velocityWindows) (*velocityUsage, bool) {
+	if vs.redis == nil {
+		return nil, false
+	}
+
+	var values [3]int64
+	for i, counter := range windows.counters(userID, velocityUsage{}) {
+		raw, err := vs.redis.GetString(counter.key)
+		if err != nil {
+			return nil, false
+		}
+		values[i], err = strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+	}
+
+	return &velocityUsage{
+		count:  values[0],
+		daily:  money.New(values[1], money.DefaultCurrency),
+		weekly: money.New(values[2], money.DefaultCurrency),
+	}, true
+}
+
+// storedUsage sums the user's outflows from wallet_transactions. Anything not
+// failed counts, so held chat transfers and pending withdrawals are included.
+func (vs *VelocityService) storedUsage(tx *sql.Tx, userID string, windows velocityWindows) (*velocityUsage, error) {
+	rows, err := tx.Query(`
+		SELECT currency,
+			COALESCE(SUM(amount) FILTER (WHERE created_at >= $3), 0),
+			COALESCE(SUM(amount), 0),
+			COUNT(*) FILTER (WHERE created_at >= $4)
+		FROM wallet_transactions
+		WHERE user_id = $1 AND type IN ('chat_transfer_sent', 'withdrawal') AND status <> 'failed'
+		  AND created_at >= $2
+		GROUP BY currency`, userID, windows.week, windows.day, windows.hour)
+	if err != nil {
+		return nil, err
+	}
+
+	type currencyUsage struct {
+		currency      string
+		daily, weekly money.Money
+		count         int64
+	}
+	var byCurrency []currencyUsage
+	for rows.Next() {
+		var u currencyUsage
+		if err := rows.Scan(&u.currency, &u.daily, &u.weekly, &u.count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		byCurrency = append(byCurrency, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	usage := &velocityUsage{
+		daily:  money.New(0, money.DefaultCurrency),
+		weekly: money.New(0, money.DefaultCurrency),
+	}
+	for _, u := range byCurrency {
+		daily, err := vs.inDefaultCurrency(u.daily.WithCurrency(u.currency))
+		if err != nil {
+			return nil, err
+		}
+		weekly, err := vs.inDefaultCurrency(u.weekly.WithCurrency(u.currency))
+		if err != nil {
+			return nil, err
+		}
+		usage.daily = usage.daily.Add(daily)
+		usage.weekly = usage.weekly.Add(weekly)
+		usage.count += u.count
+	}
+	return usage, nil
+}
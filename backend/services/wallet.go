@@ -2,32 +2,49 @@ package services
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/google/uuid"
 	"microjob-backend/database"
 	"microjob-backend/models"
+	"microjob-backend/money"
 )
 
+// DefaultEarningsHoldHours applies until admins configure earnings_hold_settings.
+const DefaultEarningsHoldHours = 72
+
+var ErrHoldNotFound = errors.New("no held earnings found")
+
 type WalletService struct {
-	db *sql.DB
+	db     *sql.DB
+	ledger *LedgerService
+	escrow *EscrowService
+	fees   *FeeEngine
 }
 
 func NewWalletService(db *sql.DB) *WalletService {
-	return &WalletService{db: db}
+	return &WalletService{
+		db:     db,
+		ledger: NewLedgerService(db),
+		escrow: NewEscrowService(db),
+		fees:   NewFeeEngine(db),
+	}
 }
 
 func (ws *WalletService) GetWalletByUserID(userID string) (*models.Wallet, error) {
 	query := `
-		SELECT id, user_id, balance, pending_balance, total_earned, total_spent, 
+		SELECT id, user_id, balance, pending_balance, locked_balance, total_earned, total_spent, 
 			   created_at, updated_at 
 		FROM wallets 
 		WHERE user_id = $1`
 	
 	var wallet models.Wallet
 	err := ws.db.QueryRow(query, userID).Scan(
-		&wallet.ID, &wallet.UserID, &wallet.Balance, &wallet.PendingBalance,
+		&wallet.ID, &wallet.UserID, &wallet.Balance, &wallet.PendingBalance, &wallet.LockedBalance,
 		&wallet.TotalEarned, &wallet.TotalSpent, &wallet.CreatedAt, &wallet.UpdatedAt,
 	)
 	
@@ -43,10 +60,6 @@ func (ws *WalletService) CreateWallet(userID string) (*models.Wallet, error) {
 	wallet := &models.Wallet{
 		ID:             uuid.New().String(),
 		UserID:         userID,
-		Balance:        0,
-		PendingBalance: 0,
-		TotalEarned:    0,
-		TotalSpent:     0,
 		CreatedAt:      time.Now(),
 		UpdatedAt:      time.Now(),
 	}
@@ -68,56 +81,144 @@ func (ws *WalletService) AddTransaction(transaction *models.WalletTransaction) e
 	}
 	defer tx.Rollback()
 
-	// Insert transaction
-	transactionQuery := `
-		INSERT INTO wallet_transactions (id, user_id, type, amount, description, reference_id, 
-			reference_type, balance_type, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
-	
-	_, err = tx.Exec(transactionQuery, transaction.ID, transaction.UserID, transaction.Type,
-		transaction.Amount, transaction.Description, transaction.ReferenceID, transaction.ReferenceType,
-		transaction.BalanceType, transaction.Status, transaction.CreatedAt, transaction.UpdatedAt)
-	
+	err = ws.addTransactionTx(tx, transaction)
 	if err != nil {
 		return err
 	}
 
-	// Update wallet balance
-	var updateQuery string
+	return tx.Commit()
+}
+
+// addTransactionTx posts a single-user transaction against its contra account in the
+// ledger and records it in wallet_transactions, all inside the caller's transaction.
+func (ws *WalletService) addTransactionTx(tx *sql.Tx, transaction *models.WalletTransaction) error {
+	userAccount := AccountUserAvailable
+	if transaction.BalanceType == "pending" {
+		userAccount = AccountUserPending
+	}
+
+	var legs []LedgerLeg
+	var earned, spent money.Money
+	held := false
 	switch transaction.Type {
 	case "deposit", "earning", "refund":
-		if transaction.BalanceType == "pending" {
-			updateQuery = `UPDATE wallets SET pending_balance = pending_balance + $1, updated_at = $2 WHERE user_id = $3`
-		} else {
-			updateQuery = `UPDATE wallets SET balance = balance + $1, total_earned = total_earned + $1, updated_at = $2 WHERE user_id = $3`
+		contra := AccountPlatformRevenue
+		if transaction.Type == "deposit" {
+			contra = AccountExternal
+		}
+		legs = []LedgerLeg{
+			{AccountType: userAccount, OwnerID: transaction.UserID, Amount: transaction.Amount},
+			{AccountType: contra, Amount: transaction.Amount.Neg()},
 		}
-	case "withdrawal", "payment", "fee":
-		if transaction.BalanceType == "pending" {
-			updateQuery = `UPDATE wallets SET pending_balance = pending_balance - $1, updated_at = $2 WHERE user_id = $3`
-		} else {
-			updateQuery = `UPDATE wallets SET balance = balance - $1, total_spent = total_spent + $1, updated_at = $2 WHERE user_id = $3`
+		// Earnings count towards total_earned when credited, even while held
+		earned = transaction.Amount
+		held = userAccount == AccountUserPending
+	case "withdrawal", "payment", "fee", "deposit_refund":
+		contra := AccountPlatformRevenue
+		switch transaction.Type {
+		case "withdrawal", "deposit_refund":
+			contra = AccountExternal
+		case "fee":
+			contra = AccountPlatformFees
+		}
+		legs = []LedgerLeg{
+			{AccountType: userAccount, OwnerID: transaction.UserID, Amount: transaction.Amount.Neg()},
+			{AccountType: contra, Amount: transaction.Amount},
+		}
+		// Withdrawing or returning a deposit is not spending
+		if userAccount == AccountUserAvailable && transaction.Type != "withdrawal" && transaction.Type != "deposit_refund" {
+			spent = transaction.Amount
 		}
 	case "transfer_pending_to_available":
 		// Move from pending to available balance
-		_, err = tx.Exec(`UPDATE wallets SET pending_balance = pending_balance - $1, balance = balance + $1, updated_at = $2 WHERE user_id = $3`,
-			transaction.Amount, time.Now(), transaction.UserID)
+		legs = []LedgerLeg{
+			{AccountType: AccountUserPending, OwnerID: transaction.UserID, Amount: transaction.Amount.Neg()},
+			{AccountType: AccountUserAvailable, OwnerID: transaction.UserID, Amount: transaction.Amount},
+		}
+	default:
+		return fmt.Errorf("unsupported transaction type: %s", transaction.Type)
+	}
+
+	entry := &models.LedgerEntry{
+		EntryType:     transaction.Type,
+		Description:   transaction.Description,
+		ReferenceID:   transaction.ReferenceID,
+		ReferenceType: transaction.ReferenceType,
+	}
+	err := ws.ledger.Post(tx, entry, legs)
+	if err != nil {
+		return err
+	}
+
+	transaction.LedgerEntryID = &entry.ID
+	err = insertWalletTransaction(tx, transaction)
+	if err != nil {
+		return err
+	}
+
+	if held {
+		err = holdEarning(tx, transaction)
 		if err != nil {
 			return err
 		}
-		return tx.Commit()
 	}
 
-	_, err = tx.Exec(updateQuery, transaction.Amount, time.Now(), transaction.UserID)
-	if err != nil {
+	return updateWalletTotals(tx, transaction.UserID, earned, spent)
+}
+
+func insertWalletTransaction(tx *sql.Tx, transaction *models.WalletTransaction) error {
+	if transaction.ID == "" {
+		transaction.ID = uuid.New().String()
+	}
+	if transaction.CreatedAt.IsZero() {
+		transaction.CreatedAt = time.Now()
+	}
+	transaction.UpdatedAt = time.Now()
+	transaction.Currency = normalizeCurrency(transaction.Amount.Currency)
+
+	query := `
+		INSERT INTO wallet_transactions (id, wallet_id, user_id, type, amount, currency, description, reference_id,
+			reference_type, balance_type, status, ledger_entry_id, created_at, updated_at)
+		VALUES ($1, (SELECT id FROM wallets WHERE user_id = $2), $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+
+	_, err := tx.Exec(query, transaction.ID, transaction.UserID, transaction.Type,
+		transaction.Amount, transaction.Currency, transaction.Description, transaction.ReferenceID,
+		transaction.ReferenceType, transaction.BalanceType, transaction.Status, transaction.LedgerEntryID,
+		transaction.CreatedAt, transaction.UpdatedAt)
+
+	return err
+}
+
+// updateWalletTotals maintains the lifetime earned/spent counters; balances come from the ledger.
+// Totals in other currencies are kept on the user's balance in that currency.
+func updateWalletTotals(tx *sql.Tx, userID string, earned, spent money.Money) error {
+	if earned.IsZero() && spent.IsZero() {
+		return nil
+	}
+
+	currency := normalizeCurrency(earned.Add(spent).Currency)
+	if currency == money.DefaultCurrency {
+		_, err := tx.Exec(`
+			UPDATE wallets
+			SET total_earned = total_earned + $1, total_spent = total_spent + $2, updated_at = $3
+			WHERE user_id = $4`, earned, spent, time.Now(), userID)
 		return err
 	}
 
-	return tx.Commit()
+	now := time.Now()
+	_, err := tx.Exec(`
+		INSERT INTO wallet_balances (id, user_id, currency, total_earned, total_spent, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+		ON CONFLICT (user_id, currency) DO UPDATE
+		SET total_earned = wallet_balances.total_earned + EXCLUDED.total_earned,
+			total_spent = wallet_balances.total_spent + EXCLUDED.total_spent,
+			updated_at = EXCLUDED.updated_at`, uuid.New().String(), userID, currency, earned, spent, now)
+	return err
 }
 
 func (ws *WalletService) GetTransactionsByUserID(userID string, limit, offset int) ([]models.WalletTransaction, error) {
 	query := `
-		SELECT id, user_id, type, amount, description, reference_id, reference_type, 
+		SELECT id, user_id, type, amount, currency, description, reference_id, reference_type, 
 			   balance_type, status, created_at, updated_at
 		FROM wallet_transactions 
 		WHERE user_id = $1 
@@ -134,33 +235,99 @@ func (ws *WalletService) GetTransactionsByUserID(userID string, limit, offset in
 	for rows.Next() {
 		var transaction models.WalletTransaction
 		err := rows.Scan(&transaction.ID, &transaction.UserID, &transaction.Type, &transaction.Amount,
-			&transaction.Description, &transaction.ReferenceID, &transaction.ReferenceType,
+			&transaction.Currency, &transaction.Description, &transaction.ReferenceID, &transaction.ReferenceType,
 			&transaction.BalanceType, &transaction.Status, &transaction.CreatedAt, &transaction.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
+		transaction.Amount = transaction.Amount.WithCurrency(transaction.Currency)
 		transactions = append(transactions, transaction)
 	}
 
 	return transactions, nil
 }
 
-func (ws *WalletService) ProcessPayment(payerID, payeeID string, amount float64, description, referenceID, referenceType string) error {
+func (ws *WalletService) ProcessPayment(payerID, payeeID string, amount money.Money, description, referenceID, referenceType string) error {
 	tx, err := ws.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	// Check payer balance
-	var payerBalance float64
-	err = tx.QueryRow("SELECT balance FROM wallets WHERE user_id = $1", payerID).Scan(&payerBalance)
+	err = ws.processPaymentTx(tx, payerID, payeeID, NoFee(amount), description, referenceID, referenceType)
 	if err != nil {
-		return fmt.Errorf("failed to get payer balance: %w", err)
+		return err
 	}
 
-	if payerBalance < amount {
-		return fmt.Errorf("insufficient balance")
+	return tx.Commit()
+}
+
+// PayForWork pays a worker for approved work out of the job's escrow, less
+// the job payout fee. Jobs posted before escrow existed are paid from the
+// employer's balance instead.
+func (ws *WalletService) PayForWork(jobID, employerID, workerID string, amount money.Money, description, referenceID, referenceType string) error {
+	tx, err := ws.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = ws.payForWorkTx(tx, jobID, employerID, workerID, amount, description, referenceID, referenceType)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (ws *WalletService) payForWorkTx(tx *sql.Tx, jobID, employerID, workerID string, amount money.Money, description, referenceID, referenceType string) error {
+	// Work is paid in the currency the job was posted in
+	var currency string
+	err := tx.QueryRow(`SELECT currency FROM jobs WHERE id = $1`, jobID).Scan(&currency)
+	if err != nil {
+		return err
+	}
+	amount = amount.WithCurrency(currency)
+
+	released := ws.escrow.Release(tx, jobID, workerID, amount, description, referenceID, referenceType)
+	if released != ErrNoEscrow && released != ErrEscrowRefunded {
+		return released
+	}
+
+	// Jobs that were never escrowed are paid from the employer's balance. So
+	// is work paid after the escrow was refunded, e.g. a dispute won once the
+	// job expired, since the employer got that money back.
+	fee, err := ws.escrow.PayoutFee(tx, jobID, employerID, workerID, amount)
+	if err != nil {
+		return err
+	}
+	err = ws.processPaymentTx(tx, employerID, workerID, fee, description, referenceID, referenceType)
+	if err == ErrInsufficientBalance && released == ErrEscrowRefunded {
+		return ErrRefundedEscrowUnpaid
+	}
+	return err
+}
+
+func (ws *WalletService) processPaymentTx(tx *sql.Tx, payerID, payeeID string, fee *FeeBreakdown, description, referenceID, referenceType string) error {
+	// Debit the payer and hold the earning in the payee's pending balance,
+	// with any fee going to platform fees
+	legs := []LedgerLeg{
+		{AccountType: AccountUserAvailable, OwnerID: payerID, Amount: fee.PayerPays.Neg()},
+		{AccountType: AccountUserPending, OwnerID: payeeID, Amount: fee.PayeeReceives},
+	}
+	if fee.TotalFee.IsPositive() {
+		legs = append(legs, LedgerLeg{AccountType: AccountPlatformFees, Amount: fee.TotalFee})
+	}
+
+	entry := &models.LedgerEntry{
+		EntryType:     "payment",
+		Description:   &description,
+		ReferenceID:   &referenceID,
+		ReferenceType: &referenceType,
+	}
+	err := ws.ledger.Post(tx, entry, legs)
+	if err != nil {
+		return err
 	}
 
 	// Create payment transaction for payer
@@ -168,12 +335,13 @@ func (ws *WalletService) ProcessPayment(payerID, payeeID string, amount float64,
 		ID:            uuid.New().String(),
 		UserID:        payerID,
 		Type:          "payment",
-		Amount:        amount,
-		Description:   description,
+		Amount:        fee.PayerPays,
+		Description:   &description,
 		ReferenceID:   &referenceID,
 		ReferenceType: &referenceType,
 		BalanceType:   "deposit",
 		Status:        "completed",
+		LedgerEntryID: &entry.ID,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
@@ -183,50 +351,247 @@ func (ws *WalletService) ProcessPayment(payerID, payeeID string, amount float64,
 		ID:            uuid.New().String(),
 		UserID:        payeeID,
 		Type:          "earning",
-		Amount:        amount,
-		Description:   description,
+		Amount:        fee.PayeeReceives,
+		Description:   &description,
 		ReferenceID:   &referenceID,
 		ReferenceType: &referenceType,
 		BalanceType:   "pending", // Earnings go to pending first
 		Status:        "completed",
+		LedgerEntryID: &entry.ID,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
 
-	// Insert both transactions
-	transactionQuery := `
-		INSERT INTO wallet_transactions (id, user_id, type, amount, description, reference_id, 
-			reference_type, balance_type, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	for _, transaction := range []*models.WalletTransaction{paymentTransaction, earningTransaction} {
+		err = insertWalletTransaction(tx, transaction)
+		if err != nil {
+			return err
+		}
+	}
 
-	_, err = tx.Exec(transactionQuery, paymentTransaction.ID, paymentTransaction.UserID, paymentTransaction.Type,
-		paymentTransaction.Amount, paymentTransaction.Description, paymentTransaction.ReferenceID,
-		paymentTransaction.ReferenceType, paymentTransaction.BalanceType, paymentTransaction.Status,
-		paymentTransaction.CreatedAt, paymentTransaction.UpdatedAt)
+	err = holdEarning(tx, earningTransaction)
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.Exec(transactionQuery, earningTransaction.ID, earningTransaction.UserID, earningTransaction.Type,
-		earningTransaction.Amount, earningTransaction.Description, earningTransaction.ReferenceID,
-		earningTransaction.ReferenceType, earningTransaction.BalanceType, earningTransaction.Status,
-		earningTransaction.CreatedAt, earningTransaction.UpdatedAt)
+	err = updateWalletTotals(tx, payerID, money.Money{}, fee.PayerPays)
 	if err != nil {
 		return err
 	}
 
-	// Update balances
-	_, err = tx.Exec("UPDATE wallets SET balance = balance - $1, total_spent = total_spent + $1, updated_at = $2 WHERE user_id = $3",
-		amount, time.Now(), payerID)
+	return updateWalletTotals(tx, payeeID, fee.PayeeReceives, money.Money{})
+}
+
+// holdEarning keeps an earning credited to pending balance on hold until the
+// configured hold period has passed.
+func holdEarning(tx *sql.Tx, transaction *models.WalletTransaction) error {
+	period, err := earningsHoldPeriod(tx)
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.Exec("UPDATE wallets SET pending_balance = pending_balance + $1, total_earned = total_earned + $1, updated_at = $2 WHERE user_id = $3",
-		amount, time.Now(), payeeID)
+	now := time.Now()
+	_, err = tx.Exec(`
+		INSERT INTO earning_holds (id, user_id, transaction_id, amount, currency, reference_id, reference_type,
+			status, release_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, 'held', $8, $9, $9)`,
+		uuid.New().String(), transaction.UserID, transaction.ID, transaction.Amount,
+		normalizeCurrency(transaction.Amount.Currency), transaction.ReferenceID, transaction.ReferenceType,
+		now.Add(period), now)
+	return err
+}
+
+func earningsHoldPeriod(tx *sql.Tx) (time.Duration, error) {
+	var settingsJSON string
+	err := tx.QueryRow(`SELECT setting_value FROM admin_settings WHERE setting_key = 'earnings_hold_settings'`).Scan(&settingsJSON)
+	if err == sql.ErrNoRows {
+		return DefaultEarningsHoldHours * time.Hour, nil
+	}
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return tx.Commit()
+	var settings struct {
+		HoldPeriodHours *float64 `json:"holdPeriodHours"`
+	}
+	if err := json.Unmarshal([]byte(settingsJSON), &settings); err != nil {
+		return 0, err
+	}
+	if settings.HoldPeriodHours == nil || *settings.HoldPeriodHours < 0 {
+		return DefaultEarningsHoldHours * time.Hour, nil
+	}
+
+	return time.Duration(*settings.HoldPeriodHours * float64(time.Hour)), nil
+}
+
+// ReleaseMaturedEarnings moves every earning whose hold period has ended from
+// pending to available balance, one ledger entry per hold.
+func (ws *WalletService) ReleaseMaturedEarnings() (int, error) {
+	holdIDs, err := ws.heldEarningIDs(`
+		SELECT id FROM earning_holds
+		WHERE status = 'held' AND release_at <= NOW()
+		ORDER BY release_at`)
+	if err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for _, holdID := range holdIDs {
+		_, err := ws.releaseHold(holdID, "")
+		if err == ErrHoldNotFound {
+			// Released by an admin in the meantime
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to release earning hold %s: %v", holdID, err)
+			continue
+		}
+		released++
+	}
+
+	return released, nil
+}
+
+// ForceReleaseEarnings releases a user's held earnings before their hold period
+// ends. An empty holdID releases everything the user has on hold. The amounts
+// released are totalled per currency.
+func (ws *WalletService) ForceReleaseEarnings(userID, holdID, adminID string) (int, map[string]money.Money, error) {
+	holdIDs, err := ws.heldEarningIDs(`
+		SELECT id FROM earning_holds
+		WHERE user_id = $1 AND status = 'held' AND ($2 = '' OR id::text = $2)
+		ORDER BY release_at`, userID, holdID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(holdIDs) == 0 {
+		return 0, nil, ErrHoldNotFound
+	}
+
+	totals := make(map[string]money.Money)
+	for i, id := range holdIDs {
+		amount, err := ws.releaseHold(id, adminID)
+		if err != nil {
+			return i, totals, err
+		}
+		totals[amount.Currency] = totals[amount.Currency].Add(amount)
+	}
+
+	return len(holdIDs), totals, nil
+}
+
+func (ws *WalletService) heldEarningIDs(query string, args ...interface{}) ([]string, error) {
+	rows, err := ws.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func (ws *WalletService) releaseHold(holdID, adminID string) (money.Money, error) {
+	tx, err := ws.db.Begin()
+	if err != nil {
+		return money.Money{}, err
+	}
+	defer tx.Rollback()
+
+	var hold models.EarningHold
+	err = tx.QueryRow(`
+		SELECT id, user_id, amount, currency FROM earning_holds
+		WHERE id = $1 AND status = 'held'
+		FOR UPDATE`, holdID).Scan(&hold.ID, &hold.UserID, &hold.Amount, &hold.Currency)
+	if err == sql.ErrNoRows {
+		return money.Money{}, ErrHoldNotFound
+	}
+	if err != nil {
+		return money.Money{}, err
+	}
+	hold.Amount = hold.Amount.WithCurrency(hold.Currency)
+
+	description := "Earnings released from hold"
+	var releasedBy *string
+	if adminID != "" {
+		description = "Earnings released early by admin"
+		releasedBy = &adminID
+	}
+
+	entry := &models.LedgerEntry{
+		EntryType:     "transfer_pending_to_available",
+		Description:   &description,
+		ReferenceID:   &hold.ID,
+		ReferenceType: stringPtr("earning_hold"),
+	}
+	err = ws.ledger.Post(tx, entry, []LedgerLeg{
+		{AccountType: AccountUserPending, OwnerID: hold.UserID, Amount: hold.Amount.Neg()},
+		{AccountType: AccountUserAvailable, OwnerID: hold.UserID, Amount: hold.Amount},
+	})
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	err = insertWalletTransaction(tx, &models.WalletTransaction{
+		UserID:        hold.UserID,
+		Type:          "transfer_pending_to_available",
+		Amount:        hold.Amount,
+		Description:   &description,
+		ReferenceID:   &hold.ID,
+		ReferenceType: stringPtr("earning_hold"),
+		BalanceType:   "deposit",
+		Status:        "completed",
+		LedgerEntryID: &entry.ID,
+	})
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		UPDATE earning_holds
+		SET status = 'released', released_at = $1, released_by = $2, ledger_entry_id = $3, updated_at = $1
+		WHERE id = $4`, now, releasedBy, entry.ID, hold.ID)
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	return hold.Amount, tx.Commit()
+}
+
+func (ws *WalletService) GetEarningHolds(userID, status string) ([]models.EarningHold, error) {
+	query := `
+		SELECT id, user_id, transaction_id, amount, currency, reference_id, reference_type, status,
+			   release_at, released_at, released_by, ledger_entry_id, created_at, updated_at
+		FROM earning_holds
+		WHERE user_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY release_at`
+
+	rows, err := ws.db.Query(query, userID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var holds []models.EarningHold
+	for rows.Next() {
+		var hold models.EarningHold
+		err := rows.Scan(&hold.ID, &hold.UserID, &hold.TransactionID, &hold.Amount, &hold.Currency,
+			&hold.ReferenceID, &hold.ReferenceType, &hold.Status, &hold.ReleaseAt, &hold.ReleasedAt,
+			&hold.ReleasedBy, &hold.LedgerEntryID, &hold.CreatedAt, &hold.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		hold.Amount = hold.Amount.WithCurrency(hold.Currency)
+		holds = append(holds, hold)
+	}
+
+	return holds, nil
 }
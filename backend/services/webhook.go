@@ -0,0 +1,294 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"microjob-backend/models"
+	"microjob-backend/payments"
+)
+
+// webhookRetryDelays is the wait before each retry of a failed event. An event
+// that fails once more after the last delay is marked failed for an admin.
+var webhookRetryDelays = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+const (
+	webhookBatchSize = 50
+	// webhookLease is how long a worker owns an event before another may take it over
+	webhookLease = 10 * time.Minute
+)
+
+var (
+	ErrWebhookDuplicate       = errors.New("webhook event was already received")
+	ErrWebhookOutOfOrder      = errors.New("a newer event for this payment was already received")
+	ErrWebhookEventNotFound   = errors.New("webhook event not found")
+	ErrWebhookEventNotRetried = errors.New("only failed webhook events can be retried")
+)
+
+// WebhookService stores verified gateway webhooks and applies them to deposits
+// in the background, so a slow or failing handler never makes the provider
+// wait or redeliver.
+type WebhookService struct {
+	db             *sql.DB
+	gateways       *payments.Registry
+	depositService *DepositService
+}
+
+func NewWebhookService(db *sql.DB, gateways *payments.Registry, depositService *DepositService) *WebhookService {
+	return &WebhookService{
+		db:             db,
+		gateways:       gateways,
+		depositService: depositService,
+	}
+}
+
+// Receive verifies a webhook and queues it for processing. Redelivered events
+// return ErrWebhookDuplicate, and an event older than one already received for
+// the same payment is stored as rejected and returns ErrWebhookOutOfOrder.
+func (ws *WebhookService) Receive(provider string, payload []byte, headers http.Header) (*models.PaymentWebhookEvent, error) {
+	gateway, err := ws.gateways.Get(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	verified, err := gateway.VerifyWebhook(payload, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &models.PaymentWebhookEvent{
+		ID:                uuid.New().String(),
+		Provider:          provider,
+		EventID:           verified.EventID,
+		EventType:         verified.Type,
+		ProviderReference: verified.ProviderReference,
+		Amount:            verified.Amount,
+		Payload:           string(payload),
+		OccurredAt:        verified.OccurredAt,
+		Status:            "pending",
+		ReceivedAt:        time.Now(),
+	}
+
+	var newer bool
+	err = ws.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM payment_webhook_events
+			WHERE provider = $1 AND provider_reference = $2 AND occurred_at > $3 AND status <> 'rejected'
+		)`, provider, event.ProviderReference, event.OccurredAt).Scan(&newer)
+	if err != nil {
+		return nil, err
+	}
+	if newer {
+		event.Status = "rejected"
+		event.LastError = stringPtr(ErrWebhookOutOfOrder.Error())
+	}
+
+	result, err := ws.db.Exec(`
+		INSERT INTO payment_webhook_events (id, provider, event_id, event_type, provider_reference, amount,
+			currency, payload, occurred_at, status, last_error, next_attempt_at, received_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $12)
+		ON CONFLICT (provider, event_id) DO NOTHING`,
+		event.ID, event.Provider, event.EventID, event.EventType, event.ProviderReference, event.Amount,
+		event.Amount.Currency, event.Payload, event.OccurredAt, event.Status, event.LastError, event.ReceivedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing *models.PaymentWebhookEvent
+	if inserted, _ := result.RowsAffected(); inserted == 0 {
+		existing, err = scanWebhookEvent(ws.db.QueryRow(webhookEventSelect+`
+			WHERE provider = $1 AND event_id = $2`, provider, event.EventID))
+		if err != nil {
+			return nil, err
+		}
+	} else if event.Status == "rejected" {
+		log.Printf("Rejected out-of-order %s webhook %s for %s", provider, event.EventID, event.ProviderReference)
+	}
+
+	return receivedWebhookEvent(event, existing)
+}
+
+// receivedWebhookEvent is what Receive reports for a new event, or for the
+// event already stored under the same ID when existing is not nil.
+func receivedWebhookEvent(event, existing *models.PaymentWebhookEvent) (*models.PaymentWebhookEvent, error) {
+	if existing != nil {
+		if existing.Status == "rejected" {
+			return existing, ErrWebhookOutOfOrder
+		}
+		return existing, ErrWebhookDuplicate
+	}
+	if event.Status == "rejected" {
+		return event, ErrWebhookOutOfOrder
+	}
+	return event, nil
+}
+
+// ProcessDueEvents applies queued events that are due, oldest first. An event
+// waits while an earlier one for the same payment is still queued, so a refund
+// is never applied before the payment it reverses.
+func (ws *WebhookService) ProcessDueEvents() (int, error) {
+	rows, err := ws.db.Query(`
+		UPDATE payment_webhook_events
+		SET status = 'processing', attempts = attempts + 1, next_attempt_at = $1
+		WHERE id IN (
+			SELECT e.id FROM payment_webhook_events e
+			WHERE e.status IN ('pending', 'processing') AND e.next_attempt_at <= NOW()
+				AND NOT EXISTS (
+					SELECT 1 FROM payment_webhook_events earlier
+					WHERE earlier.provider = e.provider AND earlier.provider_reference = e.provider_reference
+						AND earlier.occurred_at < e.occurred_at AND earlier.status IN ('pending', 'processing')
+				)
+			ORDER BY e.occurred_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+webhookEventColumns, time.Now().Add(webhookLease), webhookBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var events []*models.PaymentWebhookEvent
+	for rows.Next() {
+		event, err := scanWebhookEvent(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, event)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for _, event := range events {
+		if err := ws.processEvent(event); err != nil {
+			log.Printf("Failed to process %s webhook %s (attempt %d): %v", event.Provider, event.EventID, event.Attempts, err)
+			continue
+		}
+		processed++
+	}
+
+	return processed, nil
+}
+
+// processEvent applies one claimed event and records the outcome. Applying an
+// event twice is harmless because deposits only move forward from the state
+// the event expects.
+func (ws *WebhookService) processEvent(event *models.PaymentWebhookEvent) error {
+	applyErr := ws.depositService.ApplyEvent(event.Provider, &payments.WebhookEvent{
+		EventID:           event.EventID,
+		Type:              event.EventType,
+		ProviderReference: event.ProviderReference,
+		Amount:            event.Amount,
+		OccurredAt:        event.OccurredAt,
+	})
+
+	if applyErr == nil {
+		_, err := ws.db.Exec(`
+			UPDATE payment_webhook_events
+			SET status = 'processed', last_error = NULL, next_attempt_at = NULL, processed_at = $1
+			WHERE id = $2`, time.Now(), event.ID)
+		return err
+	}
+
+	retryAt := webhookRetryAt(applyErr, event.Attempts, time.Now())
+	if retryAt == nil {
+		_, err := ws.db.Exec(`
+			UPDATE payment_webhook_events SET status = 'failed', last_error = $1, next_attempt_at = NULL
+			WHERE id = $2`, applyErr.Error(), event.ID)
+		if err != nil {
+			return err
+		}
+		return applyErr
+	}
+
+	_, err := ws.db.Exec(`
+		UPDATE payment_webhook_events SET status = 'pending', last_error = $1, next_attempt_at = $2
+		WHERE id = $3`, applyErr.Error(), *retryAt, event.ID)
+	if err != nil {
+		return err
+	}
+	return applyErr
+}
+
+// webhookRetryAt returns when an event whose attempts-th attempt failed with
+// applyErr is tried again, or nil when it is marked failed instead. A
+// mismatched amount will not fix itself, everything else is retried.
+func webhookRetryAt(applyErr error, attempts int, now time.Time) *time.Time {
+	if errors.Is(applyErr, ErrDepositAmountMismatch) || attempts < 1 || attempts > len(webhookRetryDelays) {
+		return nil
+	}
+	retryAt := now.Add(webhookRetryDelays[attempts-1])
+	return &retryAt
+}
+
+// RetryEvent queues a failed event for another round of attempts.
+func (ws *WebhookService) RetryEvent(eventID string) error {
+	result, err := ws.db.Exec(`
+		UPDATE payment_webhook_events SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+		WHERE id = $1 AND status = 'failed'`, eventID)
+	if err != nil {
+		return err
+	}
+
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		var exists bool
+		err = ws.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM payment_webhook_events WHERE id = $1)`, eventID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrWebhookEventNotFound
+		}
+		return ErrWebhookEventNotRetried
+	}
+
+	return nil
+}
+
+func (ws *WebhookService) GetEvents(status string, limit, offset int) ([]models.PaymentWebhookEvent, error) {
+	rows, err := ws.db.Query(webhookEventSelect+`
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY received_at DESC
+		LIMIT $2 OFFSET $3`, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.PaymentWebhookEvent
+	for rows.Next() {
+		event, err := scanWebhookEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *event)
+	}
+
+	return events, rows.Err()
+}
+
+const webhookEventColumns = `
+	id, provider, event_id, event_type, provider_reference, amount, currency, payload, occurred_at,
+	status, attempts, next_attempt_at, last_error, received_at, processed_at`
+
+const webhookEventSelect = `SELECT` + webhookEventColumns + ` FROM payment_webhook_events`
+
+func scanWebhookEvent(row interface{ Scan(...interface{}) error }) (*models.PaymentWebhookEvent, error) {
+	var event models.PaymentWebhookEvent
+	var currency string
+	err := row.Scan(&event.ID, &event.Provider, &event.EventID, &event.EventType, &event.ProviderReference,
+		&event.Amount, &currency, &event.Payload, &event.OccurredAt, &event.Status, &event.Attempts,
+		&event.NextAttemptAt, &event.LastError, &event.ReceivedAt, &event.ProcessedAt)
+	if err != nil {
+		return nil, err
+	}
+	event.Amount = event.Amount.WithCurrency(currency)
+	return &event, nil
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"microjob-backend/models"
+)
+
+func TestReceivedWebhookEvent(t *testing.T) {
+	event := func(id, status string) *models.PaymentWebhookEvent {
+		return &models.PaymentWebhookEvent{ID: id, Status: status}
+	}
+
+	tests := []struct {
+		name     string
+		event    *models.PaymentWebhookEvent
+		existing *models.PaymentWebhookEvent
+		wantID   string
+		wantErr  error
+	}{
+		{"new event is queued", event("new", "pending"), nil, "new", nil},
+		{"new event older than one received", event("new", "rejected"), nil, "new", ErrWebhookOutOfOrder},
+		{"redelivered while queued", event("new", "pending"), event("old", "pending"), "old", ErrWebhookDuplicate},
+		{"redelivered while processing", event("new", "pending"), event("old", "processing"), "old", ErrWebhookDuplicate},
+		{"redelivered once processed", event("new", "pending"), event("old", "processed"), "old", ErrWebhookDuplicate},
+		{"redelivered after failing", event("new", "pending"), event("old", "failed"), "old", ErrWebhookDuplicate},
+		{"redelivered after being rejected", event("new", "pending"), event("old", "rejected"), "old",
+			ErrWebhookOutOfOrder},
+		{"redelivered late after being queued", event("new", "rejected"), event("old", "pending"), "old",
+			ErrWebhookDuplicate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := receivedWebhookEvent(tt.event, tt.existing)
+			if err != tt.wantErr {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got == nil || got.ID != tt.wantID {
+				t.Errorf("got event %+v, want %s", got, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestWebhookRetryAt(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	failed := errors.New("deposit not found")
+
+	tests := []struct {
+		name     string
+		applyErr error
+		attempts int
+		want     time.Duration
+		wantFail bool
+	}{
+		{"first failure", failed, 1, time.Minute, false},
+		{"second failure", failed, 2, 5 * time.Minute, false},
+		{"last retry", failed, 3, 15 * time.Minute, false},
+		{"out of retries", failed, 4, 0, true},
+		{"amount mismatch is not retried", ErrDepositAmountMismatch, 1, 0, true},
+		{"wrapped amount mismatch is not retried", fmt.Errorf("apply: %w", ErrDepositAmountMismatch), 1, 0, true},
+		{"never attempted", failed, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := webhookRetryAt(tt.applyErr, tt.attempts, now)
+			if tt.wantFail {
+				if got != nil {
+					t.Errorf("retried at %v, want the event failed", got)
+				}
+				return
+			}
+			if got == nil || !got.Equal(now.Add(tt.want)) {
+				t.Errorf("retried at %v, want %v", got, now.Add(tt.want))
+			}
+		})
+	}
+}
@@ -0,0 +1,613 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"microjob-backend/models"
+	"microjob-backend/money"
+)
+
+// DefaultMinimumWithdrawal applies until admins configure withdrawal_settings.
+var DefaultMinimumWithdrawal = money.New(1000, money.DefaultCurrency)
+
+var (
+	ErrWithdrawalBelowMinimum  = errors.New("withdrawal amount is below the minimum")
+	ErrWithdrawalFeeTooHigh    = errors.New("withdrawal amount does not cover the fee")
+	ErrWithdrawalNotFound      = errors.New("withdrawal not found")
+	ErrWithdrawalInvalidStatus = errors.New("withdrawal cannot be changed in its current status")
+	ErrPayoutBatchNotFound     = errors.New("payout batch not found")
+	ErrPayoutBatchCompleted    = errors.New("payout batch is already completed")
+	ErrNoApprovedWithdrawals   = errors.New("no approved withdrawals to batch")
+)
+
+type WithdrawalService struct {
+	db           *sql.DB
+	ledger       *LedgerService
+	adminService *AdminService
+	velocity     *VelocityService
+	fees         *FeeEngine
+}
+
+func NewWithdrawalService(db *sql.DB, adminService *AdminService, velocity *VelocityService) *WithdrawalService {
+	return &WithdrawalService{
+		db:           db,
+		ledger:       NewLedgerService(db),
+		adminService: adminService,
+		velocity:     velocity,
+		fees:         NewFeeEngine(db),
+	}
+}
+
+type WithdrawalQuote struct {
+	Amount        money.Money `json:"amount"`
+	FeeAmount     money.Money `json:"fee_amount"`
+	NetAmount     money.Money `json:"net_amount"`
+	MinimumAmount money.Money `json:"minimum_amount"`
+	FeeItems      []FeeItem   `json:"fee_items"`
+}
+
+// PayoutResult is the outcome of one payout as reported back by finance.
+type PayoutResult struct {
+	WithdrawalID string `json:"withdrawalId"`
+	Status       string `json:"status"` // "paid" or "failed"
+	Reference    string `json:"reference"`
+	Reason       string `json:"reason"`
+}
+
+// QuoteWithdrawal applies the minimum amount and the withdrawal fee. Amount
+// is what leaves the wallet: when the fee is charged on top it is the
+// requested amount plus the fee, otherwise the fee comes out of the payout.
+// Withdrawals are paid in the currency of the amount, with the minimum
+// converted from USD at the current rate.
+func (ws *WithdrawalService) QuoteWithdrawal(userID string, amount money.Money) (*WithdrawalQuote, error) {
+	currency := normalizeCurrency(amount.Currency)
+	rate, err := exchangeRate(ws.db, currency)
+	if err != nil {
+		return nil, err
+	}
+	amount = amount.WithCurrency(currency)
+
+	minimum, err := ws.minimumWithdrawal()
+	if err != nil {
+		return nil, err
+	}
+	minimum = minimum.Convert(money.BaseExchangeRate, rate, currency, money.RoundUp)
+	quote := &WithdrawalQuote{Amount: amount, MinimumAmount: minimum}
+	if amount.LessThan(minimum) {
+		return quote, ErrWithdrawalBelowMinimum
+	}
+
+	fee, err := ws.fees.Calculate(FeeWithdrawal, amount, userID, "")
+	if err != nil && err != ErrFeeExceedsAmount {
+		return nil, err
+	}
+
+	quote.Amount = fee.PayerPays
+	quote.FeeAmount = fee.TotalFee
+	quote.NetAmount = fee.PayeeReceives
+	quote.FeeItems = fee.Items
+	if err == ErrFeeExceedsAmount {
+		return quote, ErrWithdrawalFeeTooHigh
+	}
+
+	return quote, nil
+}
+
+func (ws *WithdrawalService) minimumWithdrawal() (money.Money, error) {
+	settings, err := ws.adminService.GetWithdrawalSettings()
+	if err != nil {
+		return money.Money{}, err
+	}
+	minimum, ok := settings["minimumAmount"].(float64)
+	if !ok {
+		return DefaultMinimumWithdrawal, nil
+	}
+	return money.FromFloat(minimum, money.DefaultCurrency), nil
+}
+
+// RequestWithdrawal locks the amount in the user's wallet and queues the
+// request for admin review.
+func (ws *WithdrawalService) RequestWithdrawal(userID string, amount money.Money, payoutMethod, payoutDetails string) (*models.WithdrawalRequest, error) {
+	quote, err := ws.QuoteWithdrawal(userID, amount)
+	if err != nil {
+		return nil, err
+	}
+	owes, err := hasOutstandingDebt(ws.db, userID, quote.Amount.Currency)
+	if err != nil {
+		return nil, err
+	}
+	if owes {
+		return nil, ErrOutstandingDebt
+	}
+
+	now := time.Now()
+	withdrawal := &models.WithdrawalRequest{
+		ID:            uuid.New().String(),
+		UserID:        userID,
+		Currency:      normalizeCurrency(quote.Amount.Currency),
+		Amount:        quote.Amount,
+		FeeAmount:     quote.FeeAmount,
+		NetAmount:     quote.NetAmount,
+		PayoutMethod:  payoutMethod,
+		PayoutDetails: payoutDetails,
+		Status:        "pending",
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = ws.velocity.Check(tx, userID, withdrawal.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	description := fmt.Sprintf("Withdrawal via %s", payoutMethod)
+	entry := &models.LedgerEntry{
+		EntryType:     "withdrawal_lock",
+		Description:   &description,
+		ReferenceID:   &withdrawal.ID,
+		ReferenceType: stringPtr("withdrawal"),
+	}
+	err = ws.ledger.Post(tx, entry, []LedgerLeg{
+		{AccountType: AccountUserAvailable, OwnerID: userID, Amount: withdrawal.Amount.Neg()},
+		{AccountType: AccountUserLocked, OwnerID: userID, Amount: withdrawal.Amount},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO withdrawal_requests (id, user_id, currency, amount, fee_amount, net_amount, payout_method,
+			payout_details, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		withdrawal.ID, withdrawal.UserID, withdrawal.Currency, withdrawal.Amount, withdrawal.FeeAmount, withdrawal.NetAmount,
+		withdrawal.PayoutMethod, withdrawal.PayoutDetails, withdrawal.Status, withdrawal.CreatedAt, withdrawal.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// The user's history shows one withdrawal that completes or fails with the payout
+	err = insertWalletTransaction(tx, &models.WalletTransaction{
+		UserID:        userID,
+		Type:          "withdrawal",
+		Amount:        withdrawal.Amount,
+		Description:   &description,
+		ReferenceID:   &withdrawal.ID,
+		ReferenceType: stringPtr("withdrawal"),
+		BalanceType:   "deposit",
+		Status:        "pending",
+		LedgerEntryID: &entry.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ws.velocity.Record(userID, withdrawal.Amount)
+	err = tx.Commit()
+	if err != nil {
+		ws.velocity.Forget(userID)
+		return nil, err
+	}
+
+	return withdrawal, nil
+}
+
+func (ws *WithdrawalService) GetUserWithdrawals(userID string, limit, offset int) ([]models.WithdrawalRequest, error) {
+	rows, err := ws.db.Query(withdrawalSelect+`
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3`, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanWithdrawals(rows)
+}
+
+// GetWithdrawals lists withdrawals for the admin review queue, oldest first.
+func (ws *WithdrawalService) GetWithdrawals(status string, limit, offset int) ([]models.WithdrawalRequest, int, error) {
+	var total int
+	err := ws.db.QueryRow(`SELECT COUNT(*) FROM withdrawal_requests WHERE ($1 = '' OR status = $1)`, status).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := ws.db.Query(withdrawalSelect+`
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY created_at
+		LIMIT $2 OFFSET $3`, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	withdrawals, err := scanWithdrawals(rows)
+	return withdrawals, total, err
+}
+
+func (ws *WithdrawalService) ApproveWithdrawal(withdrawalID, adminID string) error {
+	tx, err := ws.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	withdrawal, err := lockWithdrawal(tx, withdrawalID)
+	if err != nil {
+		return err
+	}
+	if withdrawal.Status != "pending" {
+		return ErrWithdrawalInvalidStatus
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		UPDATE withdrawal_requests
+		SET status = 'approved', reviewed_by = $1, reviewed_at = $2, updated_at = $2
+		WHERE id = $3`, adminID, now, withdrawalID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RejectWithdrawal returns the locked amount to the user's available balance.
+func (ws *WithdrawalService) RejectWithdrawal(withdrawalID, adminID, reason string) error {
+	tx, err := ws.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	withdrawal, err := lockWithdrawal(tx, withdrawalID)
+	if err != nil {
+		return err
+	}
+	if withdrawal.Status != "pending" && withdrawal.Status != "approved" {
+		return ErrWithdrawalInvalidStatus
+	}
+
+	err = ws.unlock(tx, withdrawal, "withdrawal_rejected", "Withdrawal rejected: "+reason)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		UPDATE withdrawal_requests
+		SET status = 'rejected', rejection_reason = $1, reviewed_by = $2, reviewed_at = $3, updated_at = $3,
+			completed_at = $3
+		WHERE id = $4`, reason, adminID, now, withdrawalID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CreatePayoutBatch moves every approved withdrawal in currency into a new
+// batch for export. Each currency is paid out in its own batches.
+func (ws *WithdrawalService) CreatePayoutBatch(adminID, currency string) (*models.PayoutBatch, error) {
+	currency = normalizeCurrency(currency)
+
+	tx, err := ws.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, net_amount FROM withdrawal_requests
+		WHERE status = 'approved' AND batch_id IS NULL AND currency = $1
+		ORDER BY created_at
+		FOR UPDATE`, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	var withdrawalIDs []string
+	total := money.New(0, currency)
+	for rows.Next() {
+		var id string
+		var netAmount money.Money
+		if err := rows.Scan(&id, &netAmount); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		withdrawalIDs = append(withdrawalIDs, id)
+		total = total.Add(netAmount.WithCurrency(currency))
+	}
+	rows.Close()
+
+	if len(withdrawalIDs) == 0 {
+		return nil, ErrNoApprovedWithdrawals
+	}
+
+	batch := &models.PayoutBatch{
+		ID:              uuid.New().String(),
+		Status:          "exported",
+		Currency:        currency,
+		WithdrawalCount: len(withdrawalIDs),
+		TotalAmount:     total,
+		CreatedBy:       adminID,
+		CreatedAt:       time.Now(),
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO payout_batches (id, status, currency, withdrawal_count, total_amount, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		batch.ID, batch.Status, batch.Currency, batch.WithdrawalCount, batch.TotalAmount, batch.CreatedBy, batch.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range withdrawalIDs {
+		_, err = tx.Exec(`
+			UPDATE withdrawal_requests SET status = 'processing', batch_id = $1, updated_at = $2
+			WHERE id = $3`, batch.ID, batch.CreatedAt, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return batch, tx.Commit()
+}
+
+func (ws *WithdrawalService) GetPayoutBatches(limit, offset int) ([]models.PayoutBatch, error) {
+	rows, err := ws.db.Query(`
+		SELECT id, status, currency, withdrawal_count, total_amount, created_by, created_at, completed_at
+		FROM payout_batches
+		ORDER BY created_at DESC
+		LIMIT $1 OFFSET $2`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batches []models.PayoutBatch
+	for rows.Next() {
+		var batch models.PayoutBatch
+		err := rows.Scan(&batch.ID, &batch.Status, &batch.Currency, &batch.WithdrawalCount, &batch.TotalAmount,
+			&batch.CreatedBy, &batch.CreatedAt, &batch.CompletedAt)
+		if err != nil {
+			return nil, err
+		}
+		batch.TotalAmount = batch.TotalAmount.WithCurrency(batch.Currency)
+		batches = append(batches, batch)
+	}
+
+	return batches, nil
+}
+
+// ExportPayoutBatchCSV writes one row per withdrawal in the batch for the finance team.
+func (ws *WithdrawalService) ExportPayoutBatchCSV(batchID string, w io.Writer) error {
+	var exists bool
+	err := ws.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM payout_batches WHERE id = $1)`, batchID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrPayoutBatchNotFound
+	}
+
+	rows, err := ws.db.Query(`
+		SELECT w.id, w.user_id, u.email, w.payout_method, w.payout_details, w.currency, w.amount,
+			   w.fee_amount, w.net_amount, w.status
+		FROM withdrawal_requests w
+		JOIN users u ON w.user_id = u.id
+		WHERE w.batch_id = $1
+		ORDER BY w.created_at`, batchID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"withdrawal_id", "user_id", "email", "payout_method", "payout_details",
+		"amount", "fee", "net_amount", "currency", "status"})
+
+	for rows.Next() {
+		var id, userID, email, method, details, currency, status string
+		var amount, fee, net money.Money
+		err := rows.Scan(&id, &userID, &email, &method, &details, &currency, &amount, &fee, &net, &status)
+		if err != nil {
+			return err
+		}
+		amount, fee, net = amount.WithCurrency(currency), fee.WithCurrency(currency), net.WithCurrency(currency)
+		out.Write([]string{id, userID, email, method, details,
+			amount.String(), fee.String(), net.String(), net.Currency, status})
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// RecordPayoutResults settles paid withdrawals and returns failed ones to the
+// user's available balance. The batch completes once nothing is left processing.
+func (ws *WithdrawalService) RecordPayoutResults(batchID string, results []PayoutResult) (paid, failed int, err error) {
+	tx, err := ws.db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	var batchStatus string
+	err = tx.QueryRow(`SELECT status FROM payout_batches WHERE id = $1 FOR UPDATE`, batchID).Scan(&batchStatus)
+	if err == sql.ErrNoRows {
+		return 0, 0, ErrPayoutBatchNotFound
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	if batchStatus == "completed" {
+		return 0, 0, ErrPayoutBatchCompleted
+	}
+
+	now := time.Now()
+	for _, result := range results {
+		withdrawal, err := lockWithdrawal(tx, result.WithdrawalID)
+		if err != nil {
+			return 0, 0, fmt.Errorf("withdrawal %s: %w", result.WithdrawalID, err)
+		}
+		if withdrawal.Status != "processing" || withdrawal.BatchID == nil || *withdrawal.BatchID != batchID {
+			return 0, 0, fmt.Errorf("withdrawal %s: %w", result.WithdrawalID, ErrWithdrawalInvalidStatus)
+		}
+
+		switch result.Status {
+		case "paid":
+			err = ws.settle(tx, withdrawal)
+			if err != nil {
+				return 0, 0, err
+			}
+			_, err = tx.Exec(`
+				UPDATE withdrawal_requests
+				SET status = 'paid', payout_reference = $1, updated_at = $2, completed_at = $2
+				WHERE id = $3`, result.Reference, now, withdrawal.ID)
+			paid++
+		case "failed":
+			err = ws.unlock(tx, withdrawal, "withdrawal_failed", "Withdrawal payout failed: "+result.Reason)
+			if err != nil {
+				return 0, 0, err
+			}
+			_, err = tx.Exec(`
+				UPDATE withdrawal_requests
+				SET status = 'failed', failure_reason = $1, updated_at = $2, completed_at = $2
+				WHERE id = $3`, result.Reason, now, withdrawal.ID)
+			failed++
+		default:
+			return 0, 0, fmt.Errorf("withdrawal %s: unknown payout status %q", result.WithdrawalID, result.Status)
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE payout_batches SET status = 'completed', completed_at = $1
+		WHERE id = $2 AND NOT EXISTS (
+			SELECT 1 FROM withdrawal_requests WHERE batch_id = $2 AND status = 'processing'
+		)`, now, batchID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return paid, failed, tx.Commit()
+}
+
+// settle pays the net amount out of the locked balance and books the fee.
+func (ws *WithdrawalService) settle(tx *sql.Tx, withdrawal *models.WithdrawalRequest) error {
+	description := fmt.Sprintf("Withdrawal paid via %s", withdrawal.PayoutMethod)
+	legs := []LedgerLeg{
+		{AccountType: AccountUserLocked, OwnerID: withdrawal.UserID, Amount: withdrawal.Amount.Neg()},
+		{AccountType: AccountExternal, Amount: withdrawal.NetAmount},
+	}
+	if withdrawal.FeeAmount.IsPositive() {
+		legs = append(legs, LedgerLeg{AccountType: AccountPlatformFees, Amount: withdrawal.FeeAmount})
+	}
+
+	entry := &models.LedgerEntry{
+		EntryType:     "withdrawal",
+		Description:   &description,
+		ReferenceID:   &withdrawal.ID,
+		ReferenceType: stringPtr("withdrawal"),
+	}
+	err := ws.ledger.Post(tx, entry, legs)
+	if err != nil {
+		return err
+	}
+
+	return setWithdrawalTransactionStatus(tx, withdrawal.ID, "completed")
+}
+
+// unlock returns a withdrawal's locked amount to the user's available balance.
+func (ws *WithdrawalService) unlock(tx *sql.Tx, withdrawal *models.WithdrawalRequest, entryType, description string) error {
+	entry := &models.LedgerEntry{
+		EntryType:     entryType,
+		Description:   &description,
+		ReferenceID:   &withdrawal.ID,
+		ReferenceType: stringPtr("withdrawal"),
+	}
+	err := ws.ledger.Post(tx, entry, []LedgerLeg{
+		{AccountType: AccountUserLocked, OwnerID: withdrawal.UserID, Amount: withdrawal.Amount.Neg()},
+		{AccountType: AccountUserAvailable, OwnerID: withdrawal.UserID, Amount: withdrawal.Amount},
+	})
+	if err != nil {
+		return err
+	}
+
+	return setWithdrawalTransactionStatus(tx, withdrawal.ID, "failed")
+}
+
+func setWithdrawalTransactionStatus(tx *sql.Tx, withdrawalID, status string) error {
+	_, err := tx.Exec(`
+		UPDATE wallet_transactions SET status = $1, updated_at = $2
+		WHERE reference_type = 'withdrawal' AND reference_id = $3 AND type = 'withdrawal'`,
+		status, time.Now(), withdrawalID)
+	return err
+}
+
+const withdrawalSelect = `
+	SELECT id, user_id, currency, amount, fee_amount, net_amount, payout_method, payout_details, status, batch_id,
+		reviewed_by, reviewed_at, rejection_reason, payout_reference, failure_reason,
+		created_at, updated_at, completed_at
+	FROM withdrawal_requests`
+
+func lockWithdrawal(tx *sql.Tx, withdrawalID string) (*models.WithdrawalRequest, error) {
+	var withdrawal models.WithdrawalRequest
+	err := tx.QueryRow(withdrawalSelect+` WHERE id = $1 FOR UPDATE`, withdrawalID).Scan(
+		&withdrawal.ID, &withdrawal.UserID, &withdrawal.Currency, &withdrawal.Amount, &withdrawal.FeeAmount,
+		&withdrawal.NetAmount, &withdrawal.PayoutMethod, &withdrawal.PayoutDetails, &withdrawal.Status,
+		&withdrawal.BatchID, &withdrawal.ReviewedBy, &withdrawal.ReviewedAt, &withdrawal.RejectionReason,
+		&withdrawal.PayoutReference, &withdrawal.FailureReason, &withdrawal.CreatedAt, &withdrawal.UpdatedAt,
+		&withdrawal.CompletedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrWithdrawalNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	relabelWithdrawal(&withdrawal)
+	return &withdrawal, nil
+}
+
+func scanWithdrawals(rows *sql.Rows) ([]models.WithdrawalRequest, error) {
+	var withdrawals []models.WithdrawalRequest
+	for rows.Next() {
+		var withdrawal models.WithdrawalRequest
+		err := rows.Scan(&withdrawal.ID, &withdrawal.UserID, &withdrawal.Currency, &withdrawal.Amount,
+			&withdrawal.FeeAmount, &withdrawal.NetAmount, &withdrawal.PayoutMethod, &withdrawal.PayoutDetails, &withdrawal.Status,
+			&withdrawal.BatchID, &withdrawal.ReviewedBy, &withdrawal.ReviewedAt, &withdrawal.RejectionReason,
+			&withdrawal.PayoutReference, &withdrawal.FailureReason, &withdrawal.CreatedAt, &withdrawal.UpdatedAt,
+			&withdrawal.CompletedAt)
+		if err != nil {
+			return nil, err
+		}
+		relabelWithdrawal(&withdrawal)
+		withdrawals = append(withdrawals, withdrawal)
+	}
+	return withdrawals, rows.Err()
+}
+
+// relabelWithdrawal puts the scanned amounts in the withdrawal's currency.
+func relabelWithdrawal(withdrawal *models.WithdrawalRequest) {
+	withdrawal.Amount = withdrawal.Amount.WithCurrency(withdrawal.Currency)
+	withdrawal.FeeAmount = withdrawal.FeeAmount.WithCurrency(withdrawal.Currency)
+	withdrawal.NetAmount = withdrawal.NetAmount.WithCurrency(withdrawal.Currency)
+}
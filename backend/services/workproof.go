@@ -3,41 +3,154 @@ package services
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 	"microjob-backend/models"
+	"microjob-backend/money"
+)
+
+// DefaultMaxRevisionRequests applies when the revision settings do not set
+// maxRevisionRequests.
+const DefaultMaxRevisionRequests = 2
+
+var (
+	ErrRevisionLimitReached = errors.New("this work proof has reached the maximum number of revisions")
+	ErrJobNotAcceptingWork  = errors.New("this job is no longer accepting work")
+	ErrJobFull              = errors.New("this job already has all the workers it needs")
+	ErrAlreadySubmittedWork = errors.New("you already have a work proof for this job")
 )
 
 type WorkProofService struct {
-	db *sql.DB
+	db           *sql.DB
+	adminService *AdminService
 }
 
-func NewWorkProofService(db *sql.DB) *WorkProofService {
-	return &WorkProofService{db: db}
+func NewWorkProofService(db *sql.DB, adminService *AdminService) *WorkProofService {
+	return &WorkProofService{db: db, adminService: adminService}
 }
 
-func (wps *WorkProofService) CreateWorkProof(workProof *models.WorkProof) error {
+// CreateWorkProof stores a new submission, records its submit event and runs
+// the job's approval policy on it. A proof the policy approves is paid in the
+// same transaction, so a failed payment leaves nothing behind. The payment is
+// the job's per-worker budget, whatever the proof carried, and only open jobs
+// with a free worker slot take submissions.
+func (wps *WorkProofService) CreateWorkProof(workProof *models.WorkProof, walletService *WalletService) error {
 	// Convert file arrays to JSON
 	proofFilesJSON, _ := json.Marshal(workProof.ProofFiles)
 	proofLinksJSON, _ := json.Marshal(workProof.ProofLinks)
 	screenshotsJSON, _ := json.Marshal(workProof.Screenshots)
 	attachmentsJSON, _ := json.Marshal(workProof.Attachments)
 
+	tx, err := wps.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = claimWorkerSlot(tx, workProof)
+	if err != nil {
+		return err
+	}
+
+	workProof.Status = WorkProofSubmitted
 	query := `
 		INSERT INTO work_proofs (id, job_id, application_id, worker_id, employer_id, title,
 			description, submission_text, proof_files, proof_links, screenshots, attachments,
 			status, submitted_at, payment_amount, submission_number, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)`
-	
-	_, err := wps.db.Exec(query, workProof.ID, workProof.JobID, workProof.ApplicationID,
+
+	_, err = tx.Exec(query, workProof.ID, workProof.JobID, workProof.ApplicationID,
 		workProof.WorkerID, workProof.EmployerID, workProof.Title, workProof.Description,
 		workProof.SubmissionText, proofFilesJSON, proofLinksJSON, screenshotsJSON, attachmentsJSON,
 		workProof.Status, workProof.SubmittedAt, workProof.PaymentAmount, workProof.SubmissionNumber,
 		workProof.CreatedAt, workProof.UpdatedAt)
-	
-	return err
+	if err != nil {
+		return err
+	}
+
+	err = insertWorkProofSubmission(tx, &models.WorkProofSubmission{
+		WorkProofID:      workProof.ID,
+		SubmissionNumber: workProof.SubmissionNumber,
+		Description:      workProof.Description,
+		SubmissionText:   workProof.SubmissionText,
+		ProofFiles:       workProof.ProofFiles,
+		ProofLinks:       workProof.ProofLinks,
+		Screenshots:      workProof.Screenshots,
+		Attachments:      workProof.Attachments,
+		SubmittedAt:      workProof.SubmittedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = recordWorkProofEvent(tx, workProof.ID, WorkProofActionSubmit, nil, WorkProofSubmitted,
+		workProof.WorkerID, WorkProofActorWorker, "", workProof.SubmittedAt)
+	if err != nil {
+		return err
+	}
+
+	candidate, err := scanApprovalCandidate(tx.QueryRow(approvalCandidateSelect+` AND wp.id = $1`, workProof.ID))
+	if err != nil {
+		return err
+	}
+	decision, err := wps.applyApprovalPolicy(tx, candidate, "submission", walletService)
+	if err != nil {
+		return err
+	}
+	if decision.autoApprove {
+		now := time.Now()
+		workProof.Status = WorkProofAutoApproved
+		workProof.ReviewedAt = &now
+	}
+
+	return tx.Commit()
+}
+
+// claimWorkerSlot locks the job a proof is submitted to, checks that it takes
+// more work from this worker and sets the proof's payment to what the job
+// escrowed per worker. Proofs that were withdrawn or finally rejected free
+// their slot.
+func claimWorkerSlot(tx *sql.Tx, workProof *models.WorkProof) error {
+	var status, currency string
+	var budget money.Money
+	var requiredWorkers int
+	err := tx.QueryRow(`
+		SELECT status, budget_max, currency, COALESCE(required_workers, 1)
+		FROM jobs
+		WHERE id = $1
+		FOR UPDATE`, workProof.JobID).Scan(&status, &budget, &currency, &requiredWorkers)
+	if err != nil {
+		return err
+	}
+	if status != "open" && status != "in_progress" {
+		return ErrJobNotAcceptingWork
+	}
+	if requiredWorkers < 1 {
+		requiredWorkers = 1
+	}
+
+	var taken, byWorker int
+	err = tx.QueryRow(`
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE worker_id = $2)
+		FROM work_proofs
+		WHERE job_id = $1 AND status NOT IN ($3, $4, $5)`,
+		workProof.JobID, workProof.WorkerID, WorkProofCancelledByWorker, WorkProofRejectedAccepted,
+		WorkProofRejectionUpheld).Scan(&taken, &byWorker)
+	if err != nil {
+		return err
+	}
+	if byWorker > 0 {
+		return ErrAlreadySubmittedWork
+	}
+	if taken >= requiredWorkers {
+		return ErrJobFull
+	}
+
+	workProof.PaymentAmount = budget.WithCurrency(currency)
+	return nil
 }
 
 func (wps *WorkProofService) GetWorkProofByID(proofID string) (*models.WorkProof, error) {
@@ -47,17 +160,19 @@ func (wps *WorkProofService) GetWorkProofByID(proofID string) (*models.WorkProof
 			   wp.attachments, wp.status, wp.submitted_at, wp.reviewed_at, wp.review_feedback,
 			   wp.payment_amount, wp.submission_number, wp.revision_count, wp.revision_deadline,
 			   wp.rejection_deadline, wp.worker_response, wp.worker_response_at, wp.dispute_reason,
-			   wp.dispute_evidence, wp.dispute_requested_action, wp.created_at, wp.updated_at,
-			   u1.first_name, u1.last_name, u1.username, u1.avatar,
-			   u2.first_name, u2.last_name, u2.username, u2.avatar
+			   wp.dispute_evidence, wp.dispute_requested_action, wp.possible_duplicate, wp.created_at,
+			   wp.updated_at, u1.first_name, u1.last_name, u1.username, u1.avatar,
+			   u2.first_name, u2.last_name, u2.username, u2.avatar, COALESCE(j.currency, 'USD')
 		FROM work_proofs wp
 		LEFT JOIN users u1 ON wp.worker_id = u1.id
 		LEFT JOIN users u2 ON wp.employer_id = u2.id
+		LEFT JOIN jobs j ON wp.job_id = j.id
 		WHERE wp.id = $1`
 	
 	var workProof models.WorkProof
 	var worker, employer models.User
 	var proofFilesJSON, proofLinksJSON, screenshotsJSON, attachmentsJSON []byte
+	var currency string
 	
 	err := wps.db.QueryRow(query, proofID).Scan(
 		&workProof.ID, &workProof.JobID, &workProof.ApplicationID, &workProof.WorkerID,
@@ -67,15 +182,18 @@ func (wps *WorkProofService) GetWorkProofByID(proofID string) (*models.WorkProof
 		&workProof.PaymentAmount, &workProof.SubmissionNumber, &workProof.RevisionCount,
 		&workProof.RevisionDeadline, &workProof.RejectionDeadline, &workProof.WorkerResponse,
 		&workProof.WorkerResponseAt, &workProof.DisputeReason, &workProof.DisputeEvidence,
-		&workProof.DisputeRequestedAction, &workProof.CreatedAt, &workProof.UpdatedAt,
-		&worker.FirstName, &worker.LastName, &worker.Username, &worker.Avatar,
-		&employer.FirstName, &employer.LastName, &employer.Username, &employer.Avatar,
+		&workProof.DisputeRequestedAction, &workProof.PossibleDuplicate, &workProof.CreatedAt,
+		&workProof.UpdatedAt, &worker.FirstName, &worker.LastName, &worker.Username, &worker.Avatar,
+		&employer.FirstName, &employer.LastName, &employer.Username, &employer.Avatar, &currency,
 	)
 	
 	if err != nil {
 		return nil, err
 	}
 
+	// Proofs are paid in the job's currency
+	workProof.PaymentAmount = workProof.PaymentAmount.WithCurrency(currency)
+
 	// Parse JSON arrays
 	json.Unmarshal(proofFilesJSON, &workProof.ProofFiles)
 	json.Unmarshal(proofLinksJSON, &workProof.ProofLinks)
@@ -88,70 +206,236 @@ func (wps *WorkProofService) GetWorkProofByID(proofID string) (*models.WorkProof
 	return &workProof, nil
 }
 
-func (wps *WorkProofService) ApproveWorkProof(proofID, reviewNotes string, walletService *WalletService) error {
-	tx, err := wps.db.Begin()
+// ApproveWorkProof lets the employer approve a submitted proof, paying the
+// worker from the job's escrow.
+func (wps *WorkProofService) ApproveWorkProof(proofID, employerID, reviewNotes string, walletService *WalletService) error {
+	return wps.transition(proofID, WorkProofActionApprove, employerID, reviewNotes,
+		wps.approve(reviewNotes, walletService))
+}
+
+// approve is the side effect of both approval actions.
+func (wps *WorkProofService) approve(reviewNotes string, walletService *WalletService) workProofEffect {
+	return func(tx *sql.Tx, proof *models.WorkProof, now time.Time) error {
+		_, err := tx.Exec(`
+			UPDATE work_proofs
+			SET reviewed_at = $1, review_feedback = $2, approved_at = $1
+			WHERE id = $3`, now, reviewNotes, proof.ID)
+		if err != nil {
+			return err
+		}
+
+		// Pay the worker from the job's escrow within the same transaction
+		return walletService.payForWorkTx(tx,
+			proof.JobID,
+			proof.EmployerID,
+			proof.WorkerID,
+			proof.PaymentAmount,
+			fmt.Sprintf("Payment for approved work: %s", proof.Title),
+			proof.ID,
+			"work_proof_payment",
+		)
+	}
+}
+
+func (wps *WorkProofService) RejectWorkProof(proofID, employerID, rejectionReason string, timeoutHours int) error {
+	return wps.transition(proofID, WorkProofActionReject, employerID, rejectionReason,
+		func(tx *sql.Tx, proof *models.WorkProof, now time.Time) error {
+			rejectionDeadline := now.Add(time.Duration(timeoutHours) * time.Hour)
+			_, err := tx.Exec(`
+				UPDATE work_proofs
+				SET reviewed_at = $1, review_feedback = $2, rejection_deadline = $3, rejected_at = $1
+				WHERE id = $4`, now, rejectionReason, rejectionDeadline, proof.ID)
+			return err
+		})
+}
+
+// RequestRevision sends a proof back to the worker for changes. A proof that
+// has already had the maximum number of revisions must be approved or
+// rejected instead.
+func (wps *WorkProofService) RequestRevision(proofID, employerID, revisionNotes string, timeoutHours int) error {
+	maxRevisions, err := wps.maxRevisionRequests()
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
 
-	// Get work proof details
-	workProof, err := wps.GetWorkProofByID(proofID)
+	return wps.transition(proofID, WorkProofActionRequestRevision, employerID, revisionNotes,
+		func(tx *sql.Tx, proof *models.WorkProof, now time.Time) error {
+			if proof.RevisionCount >= maxRevisions {
+				return ErrRevisionLimitReached
+			}
+
+			revisionDeadline := now.Add(time.Duration(timeoutHours) * time.Hour)
+			_, err := tx.Exec(`
+				UPDATE work_proofs
+				SET reviewed_at = $1, review_feedback = $2, revision_deadline = $3,
+					revision_count = COALESCE(revision_count, 0) + 1
+				WHERE id = $4`, now, revisionNotes, revisionDeadline, proof.ID)
+			return err
+		})
+}
+
+// ResubmitWorkProof answers a revision request with a new version of the
+// proof's content. The proof goes back to submitted with a fresh submitted_at,
+// so the employer's approval deadline starts over.
+func (wps *WorkProofService) ResubmitWorkProof(proofID, workerID string, submission *models.WorkProofSubmission) error {
+	maxRevisions, err := wps.maxRevisionRequests()
 	if err != nil {
 		return err
 	}
 
-	// Update work proof status
-	query := `
-		UPDATE work_proofs 
-		SET status = 'approved', reviewed_at = $1, review_feedback = $2, updated_at = $1
-		WHERE id = $3`
-	
-	_, err = tx.Exec(query, time.Now(), reviewNotes, proofID)
+	return wps.transition(proofID, WorkProofActionResubmit, workerID, "",
+		func(tx *sql.Tx, proof *models.WorkProof, now time.Time) error {
+			// RequestRevision enforces the limit; this only guards against a
+			// limit lowered while a revision was outstanding. Each resubmission
+			// answers one revision, so the proof has used submission_number - 1
+			// of them so far.
+			if proof.SubmissionNumber > maxRevisions {
+				return ErrRevisionLimitReached
+			}
+
+			var previousID string
+			err := tx.QueryRow(`
+				SELECT id FROM work_proof_submissions
+				WHERE work_proof_id = $1
+				ORDER BY submission_number DESC
+				LIMIT 1`, proof.ID).Scan(&previousID)
+			if err != nil {
+				return err
+			}
+
+			submission.WorkProofID = proof.ID
+			submission.SubmissionNumber = proof.SubmissionNumber + 1
+			submission.PreviousSubmissionID = &previousID
+			submission.SubmittedAt = now
+			if err := insertWorkProofSubmission(tx, submission); err != nil {
+				return err
+			}
+
+			// The proof row always carries the latest version
+			proofFilesJSON, _ := json.Marshal(submission.ProofFiles)
+			proofLinksJSON, _ := json.Marshal(submission.ProofLinks)
+			screenshotsJSON, _ := json.Marshal(submission.Screenshots)
+			attachmentsJSON, _ := json.Marshal(submission.Attachments)
+			_, err = tx.Exec(`
+				UPDATE work_proofs
+				SET description = $1, submission_text = $2, proof_files = $3, proof_links = $4,
+					screenshots = $5, attachments = $6, submission_number = $7, submitted_at = $8,
+					reviewed_at = NULL, review_feedback = NULL, revision_deadline = NULL,
+					fingerprinted_at = NULL
+				WHERE id = $9`,
+				submission.Description, submission.SubmissionText, proofFilesJSON, proofLinksJSON,
+				screenshotsJSON, attachmentsJSON, submission.SubmissionNumber, now, proof.ID)
+			return err
+		})
+}
+
+func (wps *WorkProofService) maxRevisionRequests() (int, error) {
+	settings, err := wps.adminService.GetRevisionSettings()
 	if err != nil {
-		return err
+		return 0, err
+	}
+	maxRevisions, ok := settings["maxRevisionRequests"].(float64)
+	if !ok {
+		return DefaultMaxRevisionRequests, nil
 	}
+	return int(maxRevisions), nil
+}
 
-	// Process payment from employer to worker
-	err = walletService.ProcessPayment(
-		workProof.EmployerID,
-		workProof.WorkerID,
-		workProof.PaymentAmount,
-		fmt.Sprintf("Payment for approved work: %s", workProof.Title),
-		proofID,
-		"work_proof_payment",
-	)
+func insertWorkProofSubmission(tx *sql.Tx, submission *models.WorkProofSubmission) error {
+	proofFilesJSON, _ := json.Marshal(submission.ProofFiles)
+	proofLinksJSON, _ := json.Marshal(submission.ProofLinks)
+	screenshotsJSON, _ := json.Marshal(submission.Screenshots)
+	attachmentsJSON, _ := json.Marshal(submission.Attachments)
+
+	return tx.QueryRow(`
+		INSERT INTO work_proof_submissions (work_proof_id, submission_number, previous_submission_id,
+			description, submission_text, proof_files, proof_links, screenshots, attachments, submitted_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id`,
+		submission.WorkProofID, submission.SubmissionNumber, submission.PreviousSubmissionID,
+		submission.Description, submission.SubmissionText, proofFilesJSON, proofLinksJSON,
+		screenshotsJSON, attachmentsJSON, submission.SubmittedAt).Scan(&submission.ID)
+}
+
+// GetWorkProofSubmissions returns every version of a proof, oldest first.
+func (wps *WorkProofService) GetWorkProofSubmissions(proofID string) ([]models.WorkProofSubmission, error) {
+	rows, err := wps.db.Query(`
+		SELECT id, work_proof_id, submission_number, previous_submission_id, description,
+			COALESCE(submission_text, ''), proof_files, proof_links, screenshots, attachments, submitted_at
+		FROM work_proof_submissions
+		WHERE work_proof_id = $1
+		ORDER BY submission_number`, proofID)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	return tx.Commit()
+	var submissions []models.WorkProofSubmission
+	for rows.Next() {
+		var submission models.WorkProofSubmission
+		var proofFilesJSON, proofLinksJSON, screenshotsJSON, attachmentsJSON []byte
+		err := rows.Scan(&submission.ID, &submission.WorkProofID, &submission.SubmissionNumber,
+			&submission.PreviousSubmissionID, &submission.Description, &submission.SubmissionText,
+			&proofFilesJSON, &proofLinksJSON, &screenshotsJSON, &attachmentsJSON, &submission.SubmittedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		json.Unmarshal(proofFilesJSON, &submission.ProofFiles)
+		json.Unmarshal(proofLinksJSON, &submission.ProofLinks)
+		json.Unmarshal(screenshotsJSON, &submission.Screenshots)
+		json.Unmarshal(attachmentsJSON, &submission.Attachments)
+
+		submissions = append(submissions, submission)
+	}
+
+	return submissions, rows.Err()
 }
 
-func (wps *WorkProofService) RejectWorkProof(proofID, rejectionReason string, timeoutHours int) error {
-	rejectionDeadline := time.Now().Add(time.Duration(timeoutHours) * time.Hour)
-	
-	query := `
-		UPDATE work_proofs 
-		SET status = 'rejected', reviewed_at = $1, review_feedback = $2, 
-			rejection_deadline = $3, updated_at = $1
-		WHERE id = $4`
-	
-	_, err := wps.db.Exec(query, time.Now(), rejectionReason, rejectionDeadline, proofID)
-	return err
+// AcceptRejection closes a rejected proof. An empty workerID means the
+// rejection deadline passed without a response.
+func (wps *WorkProofService) AcceptRejection(proofID, workerID, reason string) error {
+	return wps.transition(proofID, WorkProofActionAcceptRejection, workerID, reason, workerResponse("accepted"))
 }
 
-func (wps *WorkProofService) RequestRevision(proofID, revisionNotes string, timeoutHours int) error {
-	revisionDeadline := time.Now().Add(time.Duration(timeoutHours) * time.Hour)
-	
-	query := `
-		UPDATE work_proofs 
-		SET status = 'revision_requested', reviewed_at = $1, review_feedback = $2,
-			revision_deadline = $3, revision_count = COALESCE(revision_count, 0) + 1, updated_at = $1
-		WHERE id = $4`
-	
-	_, err := wps.db.Exec(query, time.Now(), revisionNotes, revisionDeadline, proofID)
-	return err
+// CancelRevision closes a proof the worker will not revise. An empty workerID
+// means the revision deadline passed without a resubmission.
+func (wps *WorkProofService) CancelRevision(proofID, workerID, reason string) error {
+	return wps.transition(proofID, WorkProofActionCancelRevision, workerID, reason, workerResponse("cancelled"))
+}
+
+func workerResponse(response string) workProofEffect {
+	return func(tx *sql.Tx, proof *models.WorkProof, now time.Time) error {
+		_, err := tx.Exec(`
+			UPDATE work_proofs SET worker_response = $1, worker_response_at = $2 WHERE id = $3`,
+			response, now, proof.ID)
+		return err
+	}
+}
+
+func (wps *WorkProofService) GetWorkProofEvents(proofID string) ([]models.WorkProofEvent, error) {
+	rows, err := wps.db.Query(`
+		SELECT id, work_proof_id, action, from_status, to_status, actor_id, actor_role, reason, created_at
+		FROM work_proof_events
+		WHERE work_proof_id = $1
+		ORDER BY created_at, id`, proofID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.WorkProofEvent
+	for rows.Next() {
+		var event models.WorkProofEvent
+		err := rows.Scan(&event.ID, &event.WorkProofID, &event.Action, &event.FromStatus, &event.ToStatus,
+			&event.ActorID, &event.ActorRole, &event.Reason, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
 }
 
 func (wps *WorkProofService) GetWorkProofsByJobID(jobID string) ([]models.WorkProof, error) {
@@ -159,10 +443,11 @@ func (wps *WorkProofService) GetWorkProofsByJobID(jobID string) ([]models.WorkPr
 		SELECT wp.id, wp.job_id, wp.application_id, wp.worker_id, wp.employer_id, wp.title,
 			   wp.description, wp.submission_text, wp.proof_files, wp.proof_links, wp.screenshots,
 			   wp.attachments, wp.status, wp.submitted_at, wp.reviewed_at, wp.review_feedback,
-			   wp.payment_amount, wp.submission_number, wp.created_at, wp.updated_at,
-			   u.first_name, u.last_name, u.username, u.avatar
+			   wp.payment_amount, wp.submission_number, wp.possible_duplicate, wp.created_at,
+			   wp.updated_at, u.first_name, u.last_name, u.username, u.avatar, COALESCE(j.currency, 'USD')
 		FROM work_proofs wp
 		LEFT JOIN users u ON wp.worker_id = u.id
+		LEFT JOIN jobs j ON wp.job_id = j.id
 		WHERE wp.job_id = $1
 		ORDER BY wp.created_at DESC`
 	
@@ -177,19 +462,23 @@ func (wps *WorkProofService) GetWorkProofsByJobID(jobID string) ([]models.WorkPr
 		var workProof models.WorkProof
 		var worker models.User
 		var proofFilesJSON, proofLinksJSON, screenshotsJSON, attachmentsJSON []byte
+		var currency string
 		
 		err := rows.Scan(&workProof.ID, &workProof.JobID, &workProof.ApplicationID,
 			&workProof.WorkerID, &workProof.EmployerID, &workProof.Title, &workProof.Description,
 			&workProof.SubmissionText, &proofFilesJSON, &proofLinksJSON, &screenshotsJSON,
 			&attachmentsJSON, &workProof.Status, &workProof.SubmittedAt, &workProof.ReviewedAt,
 			&workProof.ReviewFeedback, &workProof.PaymentAmount, &workProof.SubmissionNumber,
-			&workProof.CreatedAt, &workProof.UpdatedAt,
-			&worker.FirstName, &worker.LastName, &worker.Username, &worker.Avatar)
+			&workProof.PossibleDuplicate, &workProof.CreatedAt, &workProof.UpdatedAt,
+			&worker.FirstName, &worker.LastName, &worker.Username, &worker.Avatar, &currency)
 		
 		if err != nil {
 			return nil, err
 		}
 
+		// Proofs are paid in the job's currency
+		workProof.PaymentAmount = workProof.PaymentAmount.WithCurrency(currency)
+
 		// Parse JSON arrays
 		json.Unmarshal(proofFilesJSON, &workProof.ProofFiles)
 		json.Unmarshal(proofLinksJSON, &workProof.ProofLinks)
@@ -207,65 +496,56 @@ func (wps *WorkProofService) ProcessExpiredDeadlines(walletService *WalletServic
 	now := time.Now()
 	processedCount := 0
 
-	// Auto-approve expired submitted work proofs
-	query := `
-		SELECT wp.id, wp.job_id, wp.submitted_at, j.manual_approval_days
-		FROM work_proofs wp
-		JOIN jobs j ON wp.job_id = j.id
-		WHERE wp.status = 'submitted' AND j.approval_type = 'manual'`
-	
-	rows, err := wps.db.Query(query)
+	// Auto-approve submitted work proofs whose approval policy now applies,
+	// including those past the manual approval deadline
+	approved, err := wps.sweepApprovalPolicies(walletService)
 	if err != nil {
 		return 0, err
 	}
-	defer rows.Close()
+	processedCount += approved
 
-	for rows.Next() {
-		var proofID, jobID string
-		var submittedAt time.Time
-		var approvalDays int
-		
-		err := rows.Scan(&proofID, &jobID, &submittedAt, &approvalDays)
-		if err != nil {
-			continue
+	// Process expired rejection deadlines
+	rejections, err := wps.expiredProofIDs(`
+		SELECT id FROM work_proofs WHERE status = 'rejected' AND rejection_deadline < $1`, now)
+	if err != nil {
+		return processedCount, err
+	}
+	for _, proofID := range rejections {
+		if wps.AcceptRejection(proofID, "", "Rejection deadline expired") == nil {
+			processedCount++
 		}
+	}
 
-		approvalDeadline := submittedAt.Add(time.Duration(approvalDays) * 24 * time.Hour)
-		if now.After(approvalDeadline) {
-			err = wps.ApproveWorkProof(proofID, "Automatically approved due to deadline expiration", walletService)
-			if err == nil {
-				processedCount++
-			}
+	// Process expired revision deadlines (auto-cancel)
+	revisions, err := wps.expiredProofIDs(`
+		SELECT id FROM work_proofs WHERE status = 'revision_requested' AND revision_deadline < $1`, now)
+	if err != nil {
+		return processedCount, err
+	}
+	for _, proofID := range revisions {
+		if wps.CancelRevision(proofID, "", "Revision deadline expired") == nil {
+			processedCount++
 		}
 	}
 
-	// Process expired rejection deadlines
-	rejectionQuery := `
-		UPDATE work_proofs 
-		SET status = 'rejected_accepted', worker_response = 'accepted', 
-			worker_response_at = $1, updated_at = $1
-		WHERE status = 'rejected' AND rejection_deadline < $1`
-	
-	result, err := wps.db.Exec(rejectionQuery, now)
-	if err == nil {
-		if affected, _ := result.RowsAffected(); affected > 0 {
-			processedCount += int(affected)
-		}
+	return processedCount, nil
+}
+
+func (wps *WorkProofService) expiredProofIDs(query string, now time.Time) ([]string, error) {
+	rows, err := wps.db.Query(query, now)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	// Process expired revision deadlines (auto-cancel)
-	revisionQuery := `
-		UPDATE work_proofs 
-		SET status = 'cancelled_by_worker', worker_response = 'cancelled',
-			worker_response_at = $1, updated_at = $1
-		WHERE status = 'revision_requested' AND revision_deadline < $1`
-	
-	result, err = wps.db.Exec(revisionQuery, now)
-	if err == nil {
-		if affected, _ := result.RowsAffected(); affected > 0 {
-			processedCount += int(affected)
+	var proofIDs []string
+	for rows.Next() {
+		var proofID string
+		if err := rows.Scan(&proofID); err != nil {
+			return nil, err
 		}
+		proofIDs = append(proofIDs, proofID)
 	}
 
-	return processedCount, nil
+	return proofIDs, rows.Err()
 }
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"errors"
+	"time"
+)
+
+// MaxBulkReviewProofs caps how many proofs one bulk review may touch.
+const MaxBulkReviewProofs = 200
+
+var (
+	ErrInvalidReviewAction = errors.New("action must be approve, reject or request_revision")
+	ErrTooManyProofs       = errors.New("too many work proofs for one bulk review")
+)
+
+// WorkProofReviewResult is the outcome of one proof in a bulk review.
+type WorkProofReviewResult struct {
+	ProofID string `json:"proofId"`
+	Success bool   `json:"success"`
+	Status  string `json:"status,omitempty"` // the proof's status after a successful review
+	Error   string `json:"error,omitempty"`
+}
+
+// WorkProofReviewFilter selects the submitted proofs of an employer's jobs to
+// review in bulk. Empty fields do not filter.
+type WorkProofReviewFilter struct {
+	JobID           string
+	SubmittedBefore *time.Time
+}
+
+// BulkReview applies one review action to each proof. Every proof is its own
+// transition, so an approval's payout commits or fails on its own and one bad
+// proof does not hold back the rest.
+func (wps *WorkProofService) BulkReview(employerID, action string, proofIDs []string, notes string, timeoutHours int, walletService *WalletService) ([]WorkProofReviewResult, error) {
+	var review func(proofID string) error
+	switch action {
+	case WorkProofActionApprove:
+		review = func(proofID string) error {
+			return wps.ApproveWorkProof(proofID, employerID, notes, walletService)
+		}
+	case WorkProofActionReject:
+		review = func(proofID string) error {
+			return wps.RejectWorkProof(proofID, employerID, notes, timeoutHours)
+		}
+	case WorkProofActionRequestRevision:
+		review = func(proofID string) error {
+			return wps.RequestRevision(proofID, employerID, notes, timeoutHours)
+		}
+	default:
+		return nil, ErrInvalidReviewAction
+	}
+	if len(proofIDs) > MaxBulkReviewProofs {
+		return nil, ErrTooManyProofs
+	}
+
+	seen := make(map[string]bool, len(proofIDs))
+	results := make([]WorkProofReviewResult, 0, len(proofIDs))
+	for _, proofID := range proofIDs {
+		if seen[proofID] {
+			continue
+		}
+		seen[proofID] = true
+
+		result := WorkProofReviewResult{ProofID: proofID}
+		if err := review(proofID); err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Success = true
+			result.Status = workProofTransitions[action].to
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// FindReviewableProofs returns the submitted proofs of the employer's jobs
+// that match filter, oldest first, up to MaxBulkReviewProofs. more reports
+// whether further proofs matched beyond that.
+func (wps *WorkProofService) FindReviewableProofs(employerID string, filter WorkProofReviewFilter) (proofIDs []string, more bool, err error) {
+	rows, err := wps.db.Query(`
+		SELECT id
+		FROM work_proofs
+		WHERE employer_id = $1 AND status = 'submitted'
+		  AND ($2 = '' OR job_id::text = $2)
+		  AND ($3::timestamptz IS NULL OR submitted_at < $3)
+		ORDER BY submitted_at, id
+		LIMIT $4`, employerID, filter.JobID, filter.SubmittedBefore, MaxBulkReviewProofs+1)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var proofID string
+		if err := rows.Scan(&proofID); err != nil {
+			return nil, false, err
+		}
+		proofIDs = append(proofIDs, proofID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	if len(proofIDs) > MaxBulkReviewProofs {
+		return proofIDs[:MaxBulkReviewProofs], true, nil
+	}
+	return proofIDs, false, nil
+}
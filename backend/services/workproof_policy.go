@@ -0,0 +1,232 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"time"
+
+	"microjob-backend/models"
+)
+
+// Approval policy rules, as recorded in work_proof_policy_decisions
+const (
+	PolicyRuleNever             = "never"
+	PolicyRuleTrustedWorker     = "trusted_worker"
+	PolicyRuleEvidence          = "evidence"
+	PolicyRuleInstant           = "instant"
+	PolicyRuleDeadline          = "deadline"
+	PolicyRuleSampledForReview  = "sampled_for_review"
+	PolicyRuleSampleAutoApprove = "sample_auto_approve"
+	PolicyRuleManualReview      = "manual_review"
+)
+
+var ErrInvalidApprovalPolicy = errors.New("minWorkerRating must be between 0 and 5, sampleReviewPercent between 0 and 100 and minimums not negative")
+
+// approvalCandidate is what a policy looks at to decide a submitted proof.
+type approvalCandidate struct {
+	proofID        string
+	submittedAt    time.Time
+	screenshots    int
+	links          int
+	workerRating   float64
+	approvalType   string
+	instantEnabled bool
+	approvalDays   int
+	policy         models.JobApprovalPolicy
+}
+
+type approvalDecision struct {
+	rule        string
+	autoApprove bool
+	detail      string
+}
+
+// decideApproval runs the job's rules in order and returns the first that
+// applies. The manual approval deadline is only checked by the sweep, and
+// ahead of sampling so sampled proofs still time out.
+func decideApproval(c *approvalCandidate, sweep bool, now time.Time) approvalDecision {
+	p := c.policy
+	if p.NeverAutoApprove {
+		return approvalDecision{PolicyRuleNever, false, "job never auto-approves"}
+	}
+	if p.MinWorkerRating != nil && c.workerRating >= *p.MinWorkerRating {
+		return approvalDecision{PolicyRuleTrustedWorker, true,
+			fmt.Sprintf("worker rating %.2f is at least %.2f", c.workerRating, *p.MinWorkerRating)}
+	}
+	if (p.MinScreenshots > 0 || p.MinLinks > 0) && c.screenshots >= p.MinScreenshots && c.links >= p.MinLinks {
+		return approvalDecision{PolicyRuleEvidence, true,
+			fmt.Sprintf("%d screenshots and %d links meet %d and %d", c.screenshots, c.links, p.MinScreenshots, p.MinLinks)}
+	}
+	if c.approvalType == "instant" && c.instantEnabled {
+		return approvalDecision{PolicyRuleInstant, true, "job approves instantly"}
+	}
+	if sweep && c.approvalType == "manual" {
+		deadline := c.submittedAt.Add(time.Duration(c.approvalDays) * 24 * time.Hour)
+		if now.After(deadline) {
+			return approvalDecision{PolicyRuleDeadline, true,
+				fmt.Sprintf("not reviewed within %d days", c.approvalDays)}
+		}
+	}
+	if p.SampleReviewPercent != nil {
+		// The bucket comes from the proof ID so a proof lands on the same
+		// side of the sample every time it is evaluated
+		bucket := int(crc32.ChecksumIEEE([]byte(c.proofID)) % 100)
+		if bucket < *p.SampleReviewPercent {
+			return approvalDecision{PolicyRuleSampledForReview, false,
+				fmt.Sprintf("sampled for review at %d%%", *p.SampleReviewPercent)}
+		}
+		return approvalDecision{PolicyRuleSampleAutoApprove, true,
+			fmt.Sprintf("not sampled for review at %d%%", *p.SampleReviewPercent)}
+	}
+	return approvalDecision{PolicyRuleManualReview, false, "waiting for the employer"}
+}
+
+const approvalCandidateSelect = `
+	SELECT wp.id, wp.submitted_at, COALESCE(jsonb_array_length(wp.screenshots), 0),
+		COALESCE(jsonb_array_length(wp.proof_links), 0), COALESCE(u.rating, 0), j.approval_type,
+		j.instant_approval_enabled, j.manual_approval_days, COALESCE(p.never_auto_approve, FALSE),
+		p.min_worker_rating, COALESCE(p.min_screenshots, 0), COALESCE(p.min_links, 0), p.sample_review_percent
+	FROM work_proofs wp
+	JOIN jobs j ON j.id = wp.job_id
+	LEFT JOIN users u ON u.id = wp.worker_id
+	LEFT JOIN job_approval_policies p ON p.job_id = wp.job_id
+	WHERE wp.status = 'submitted'`
+
+func scanApprovalCandidate(row interface{ Scan(...interface{}) error }) (*approvalCandidate, error) {
+	var c approvalCandidate
+	err := row.Scan(&c.proofID, &c.submittedAt, &c.screenshots, &c.links, &c.workerRating, &c.approvalType,
+		&c.instantEnabled, &c.approvalDays, &c.policy.NeverAutoApprove, &c.policy.MinWorkerRating,
+		&c.policy.MinScreenshots, &c.policy.MinLinks, &c.policy.SampleReviewPercent)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// applyApprovalPolicy decides a submitted proof inside tx, records the
+// decision and auto-approves the proof when a rule says so.
+func (wps *WorkProofService) applyApprovalPolicy(tx *sql.Tx, c *approvalCandidate, stage string, walletService *WalletService) (approvalDecision, error) {
+	decision := decideApproval(c, stage == "sweep", time.Now())
+
+	_, err := tx.Exec(`
+		INSERT INTO work_proof_policy_decisions (work_proof_id, stage, rule, auto_approved, detail)
+		VALUES ($1, $2, $3, $4, $5)`, c.proofID, stage, decision.rule, decision.autoApprove, decision.detail)
+	if err != nil {
+		return decision, err
+	}
+	log.Printf("[APPROVAL] Work proof %s at %s: rule %s (%s)", c.proofID, stage, decision.rule, decision.detail)
+
+	if !decision.autoApprove {
+		return decision, nil
+	}
+	reason := fmt.Sprintf("Automatically approved by the %s rule: %s", decision.rule, decision.detail)
+	err = wps.transitionTx(tx, c.proofID, WorkProofActionAutoApprove, "", reason,
+		wps.approve(reason, walletService))
+	return decision, err
+}
+
+// sweepApprovalPolicies re-evaluates every submitted proof, approving those
+// whose rules now apply, such as a passed deadline or a worker who has since
+// become trusted. Decisions are only recorded for proofs found due.
+func (wps *WorkProofService) sweepApprovalPolicies(walletService *WalletService) (int, error) {
+	rows, err := wps.db.Query(approvalCandidateSelect)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	var due []*approvalCandidate
+	for rows.Next() {
+		c, err := scanApprovalCandidate(rows)
+		if err != nil {
+			return 0, err
+		}
+		if decideApproval(c, true, now).autoApprove {
+			due = append(due, c)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	approved := 0
+	for _, c := range due {
+		if err := wps.sweepApprovalPolicy(c, walletService); err != nil {
+			log.Printf("[APPROVAL] Failed to auto-approve work proof %s: %v", c.proofID, err)
+			continue
+		}
+		approved++
+	}
+	return approved, nil
+}
+
+func (wps *WorkProofService) sweepApprovalPolicy(c *approvalCandidate, walletService *WalletService) error {
+	tx, err := wps.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := wps.applyApprovalPolicy(tx, c, "sweep", walletService); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetApprovalPolicy returns a job's policy, or an empty one that leaves the
+// job's approval type in charge when none was set.
+func (wps *WorkProofService) GetApprovalPolicy(jobID string) (*models.JobApprovalPolicy, error) {
+	policy := models.JobApprovalPolicy{JobID: jobID}
+	err := wps.db.QueryRow(`
+		SELECT never_auto_approve, min_worker_rating, min_screenshots, min_links, sample_review_percent,
+			created_at, updated_at
+		FROM job_approval_policies
+		WHERE job_id = $1`, jobID).Scan(&policy.NeverAutoApprove, &policy.MinWorkerRating,
+		&policy.MinScreenshots, &policy.MinLinks, &policy.SampleReviewPercent, &policy.CreatedAt,
+		&policy.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// SetApprovalPolicy replaces a job's policy. Only the job's owner may set it.
+func (wps *WorkProofService) SetApprovalPolicy(jobID, employerID string, policy *models.JobApprovalPolicy) error {
+	if (policy.MinWorkerRating != nil && (*policy.MinWorkerRating < 0 || *policy.MinWorkerRating > 5)) ||
+		(policy.SampleReviewPercent != nil && (*policy.SampleReviewPercent < 0 || *policy.SampleReviewPercent > 100)) ||
+		policy.MinScreenshots < 0 || policy.MinLinks < 0 {
+		return ErrInvalidApprovalPolicy
+	}
+
+	var ownerID string
+	err := wps.db.QueryRow(`SELECT user_id FROM jobs WHERE id = $1`, jobID).Scan(&ownerID)
+	if err != nil {
+		return err
+	}
+	if ownerID != employerID {
+		return ErrNotJobOwner
+	}
+
+	now := time.Now()
+	policy.JobID = jobID
+	policy.UpdatedAt = now
+	return wps.db.QueryRow(`
+		INSERT INTO job_approval_policies (job_id, never_auto_approve, min_worker_rating, min_screenshots,
+			min_links, sample_review_percent, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		ON CONFLICT (job_id) DO UPDATE SET
+			never_auto_approve = EXCLUDED.never_auto_approve,
+			min_worker_rating = EXCLUDED.min_worker_rating,
+			min_screenshots = EXCLUDED.min_screenshots,
+			min_links = EXCLUDED.min_links,
+			sample_review_percent = EXCLUDED.sample_review_percent,
+			updated_at = EXCLUDED.updated_at
+		RETURNING created_at`,
+		jobID, policy.NeverAutoApprove, policy.MinWorkerRating, policy.MinScreenshots, policy.MinLinks,
+		policy.SampleReviewPercent, now).Scan(&policy.CreatedAt)
+}
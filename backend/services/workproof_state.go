@@ -0,0 +1,233 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"microjob-backend/models"
+)
+
+// Work proof statuses
+const (
+	WorkProofSubmitted         = "submitted"
+	WorkProofAutoApproved      = "auto_approved"
+	WorkProofApproved          = "approved"
+	WorkProofRejected          = "rejected"
+	WorkProofRevisionRequested = "revision_requested"
+	WorkProofRejectedAccepted  = "rejected_accepted"
+	WorkProofCancelledByWorker = "cancelled_by_worker"
+	WorkProofDisputed          = "disputed"
+	WorkProofPartiallyPaid     = "partially_paid"
+	WorkProofRejectionUpheld   = "rejection_upheld"
+)
+
+// Work proof actions, as recorded in work_proof_events
+const (
+	WorkProofActionSubmit          = "submit"
+	WorkProofActionApprove         = "approve"
+	WorkProofActionAutoApprove     = "auto_approve"
+	WorkProofActionReject          = "reject"
+	WorkProofActionRequestRevision = "request_revision"
+	WorkProofActionResubmit        = "resubmit"
+	WorkProofActionAcceptRejection = "accept_rejection"
+	WorkProofActionCancelRevision  = "cancel_revision"
+	WorkProofActionOpenDispute     = "open_dispute"
+	WorkProofActionDisputePayFull  = "dispute_pay_full"
+	WorkProofActionDisputePartial  = "dispute_partial_pay"
+	WorkProofActionDisputeUphold   = "dispute_uphold_rejection"
+)
+
+// Roles an actor can hold towards a work proof
+const (
+	WorkProofActorWorker   = "worker"
+	WorkProofActorEmployer = "employer"
+	WorkProofActorSystem   = "system"
+	WorkProofActorAdmin    = "admin"
+)
+
+var (
+	ErrWorkProofNotFound          = errors.New("work proof not found")
+	ErrWorkProofForbidden         = errors.New("you are not allowed to perform this action on this work proof")
+	ErrIllegalWorkProofTransition = errors.New("illegal work proof transition")
+)
+
+// WorkProofTransitionError reports an action that the proof's current status
+// does not allow, such as approving a proof that was already rejected.
+type WorkProofTransitionError struct {
+	ProofID string
+	Action  string
+	Status  string
+}
+
+func (e *WorkProofTransitionError) Error() string {
+	return fmt.Sprintf("cannot %s a work proof that is %s",
+		strings.ReplaceAll(e.Action, "_", " "), strings.ReplaceAll(e.Status, "_", " "))
+}
+
+func (e *WorkProofTransitionError) Unwrap() error {
+	return ErrIllegalWorkProofTransition
+}
+
+type workProofTransition struct {
+	from   string
+	to     string
+	actors []string
+}
+
+// Every status change a work proof may go through. A rejected proof is either
+// accepted or disputed, and an admin settles the dispute. Approvals, accepted
+// or upheld rejections, partial payments and cancelled revisions are final.
+var workProofTransitions = map[string]workProofTransition{
+	WorkProofActionApprove:         {WorkProofSubmitted, WorkProofApproved, []string{WorkProofActorEmployer}},
+	WorkProofActionAutoApprove:     {WorkProofSubmitted, WorkProofAutoApproved, []string{WorkProofActorSystem}},
+	WorkProofActionReject:          {WorkProofSubmitted, WorkProofRejected, []string{WorkProofActorEmployer}},
+	WorkProofActionRequestRevision: {WorkProofSubmitted, WorkProofRevisionRequested, []string{WorkProofActorEmployer}},
+	WorkProofActionResubmit:        {WorkProofRevisionRequested, WorkProofSubmitted, []string{WorkProofActorWorker}},
+	WorkProofActionAcceptRejection: {WorkProofRejected, WorkProofRejectedAccepted, []string{WorkProofActorWorker, WorkProofActorSystem}},
+	WorkProofActionCancelRevision:  {WorkProofRevisionRequested, WorkProofCancelledByWorker, []string{WorkProofActorWorker, WorkProofActorSystem}},
+	WorkProofActionOpenDispute:     {WorkProofRejected, WorkProofDisputed, []string{WorkProofActorWorker}},
+	WorkProofActionDisputePayFull:  {WorkProofDisputed, WorkProofApproved, []string{WorkProofActorAdmin}},
+	WorkProofActionDisputePartial:  {WorkProofDisputed, WorkProofPartiallyPaid, []string{WorkProofActorAdmin}},
+	WorkProofActionDisputeUphold:   {WorkProofDisputed, WorkProofRejectionUpheld, []string{WorkProofActorAdmin}},
+}
+
+// workProofEffect runs inside the transition's transaction once the move has
+// been validated, so a failed payment or update leaves the status untouched.
+type workProofEffect func(tx *sql.Tx, proof *models.WorkProof, now time.Time) error
+
+// transition moves a work proof through action in its own transaction.
+func (wps *WorkProofService) transition(proofID, action, actorID, reason string, effect workProofEffect) error {
+	tx, err := wps.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := wps.transitionTx(tx, proofID, action, actorID, reason, effect); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// transitionTx locks the proof, checks that actorID may take action from the
+// current status, applies the status and effect, and records the event. An
+// empty actorID stands for the system.
+func (wps *WorkProofService) transitionTx(tx *sql.Tx, proofID, action, actorID, reason string, effect workProofEffect) error {
+	return wps.applyTransition(tx, proofID, action, actorID, "", reason, effect)
+}
+
+// applyTransition is transitionTx for a caller that already knows the actor's
+// role, such as an admin. An empty role is worked out from the proof.
+func (wps *WorkProofService) applyTransition(tx *sql.Tx, proofID, action, actorID, role, reason string, effect workProofEffect) error {
+	if _, ok := workProofTransitions[action]; !ok {
+		return fmt.Errorf("unknown work proof action %q", action)
+	}
+
+	proof, err := lockWorkProof(tx, proofID)
+	if err != nil {
+		return err
+	}
+
+	if role == "" {
+		role, err = workProofActorRole(proof, actorID)
+		if err != nil {
+			return err
+		}
+	}
+	t, err := workProofMove(proof, action, role)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(`UPDATE work_proofs SET status = $1, updated_at = $2 WHERE id = $3`, t.to, now, proof.ID)
+	if err != nil {
+		return err
+	}
+	from := proof.Status
+	proof.Status = t.to
+
+	if effect != nil {
+		if err := effect(tx, proof, now); err != nil {
+			return err
+		}
+	}
+
+	return recordWorkProofEvent(tx, proof.ID, action, &from, t.to, actorID, role, reason, now)
+}
+
+// workProofMove returns the transition action makes from the proof's current
+// status, if role may take it.
+func workProofMove(proof *models.WorkProof, action, role string) (workProofTransition, error) {
+	t, ok := workProofTransitions[action]
+	if !ok {
+		return workProofTransition{}, fmt.Errorf("unknown work proof action %q", action)
+	}
+	if !containsString(t.actors, role) {
+		return workProofTransition{}, ErrWorkProofForbidden
+	}
+	if proof.Status != t.from {
+		return workProofTransition{}, &WorkProofTransitionError{ProofID: proof.ID, Action: action, Status: proof.Status}
+	}
+	return t, nil
+}
+
+func recordWorkProofEvent(tx *sql.Tx, proofID, action string, from *string, to, actorID, role, reason string, at time.Time) error {
+	var actor, note *string
+	if actorID != "" {
+		actor = &actorID
+	}
+	if reason != "" {
+		note = &reason
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO work_proof_events (work_proof_id, action, from_status, to_status, actor_id, actor_role,
+			reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		proofID, action, from, to, actor, role, note, at)
+	return err
+}
+
+func workProofActorRole(proof *models.WorkProof, actorID string) (string, error) {
+	switch actorID {
+	case "":
+		return WorkProofActorSystem, nil
+	case proof.EmployerID:
+		return WorkProofActorEmployer, nil
+	case proof.WorkerID:
+		return WorkProofActorWorker, nil
+	}
+	return "", ErrWorkProofForbidden
+}
+
+func lockWorkProof(tx *sql.Tx, proofID string) (*models.WorkProof, error) {
+	var proof models.WorkProof
+	err := tx.QueryRow(`
+		SELECT id, job_id, worker_id, employer_id, title, status, payment_amount, submission_number,
+			revision_count, rejection_deadline
+		FROM work_proofs
+		WHERE id = $1
+		FOR UPDATE`, proofID).Scan(&proof.ID, &proof.JobID, &proof.WorkerID, &proof.EmployerID,
+		&proof.Title, &proof.Status, &proof.PaymentAmount, &proof.SubmissionNumber, &proof.RevisionCount,
+		&proof.RejectionDeadline)
+	if err == sql.ErrNoRows {
+		return nil, ErrWorkProofNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &proof, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
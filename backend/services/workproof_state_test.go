@@ -0,0 +1,147 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"microjob-backend/models"
+)
+
+func TestWorkProofMove(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      string
+		action      string
+		role        string
+		want        string
+		wantErr     error
+		wantIllegal bool
+	}{
+		{"employer approves", WorkProofSubmitted, WorkProofActionApprove, WorkProofActorEmployer, WorkProofApproved, nil, false},
+		{"system auto-approves", WorkProofSubmitted, WorkProofActionAutoApprove, WorkProofActorSystem, WorkProofAutoApproved, nil, false},
+		{"employer rejects", WorkProofSubmitted, WorkProofActionReject, WorkProofActorEmployer, WorkProofRejected, nil, false},
+		{"employer asks for a revision", WorkProofSubmitted, WorkProofActionRequestRevision, WorkProofActorEmployer,
+			WorkProofRevisionRequested, nil, false},
+		{"worker resubmits", WorkProofRevisionRequested, WorkProofActionResubmit, WorkProofActorWorker, WorkProofSubmitted, nil, false},
+		{"worker accepts a rejection", WorkProofRejected, WorkProofActionAcceptRejection, WorkProofActorWorker,
+			WorkProofRejectedAccepted, nil, false},
+		{"system accepts an unanswered rejection", WorkProofRejected, WorkProofActionAcceptRejection, WorkProofActorSystem,
+			WorkProofRejectedAccepted, nil, false},
+		{"worker cancels a revision", WorkProofRevisionRequested, WorkProofActionCancelRevision, WorkProofActorWorker,
+			WorkProofCancelledByWorker, nil, false},
+		{"worker disputes a rejection", WorkProofRejected, WorkProofActionOpenDispute, WorkProofActorWorker, WorkProofDisputed, nil, false},
+		{"admin pays in full", WorkProofDisputed, WorkProofActionDisputePayFull, WorkProofActorAdmin, WorkProofApproved, nil, false},
+		{"admin pays in part", WorkProofDisputed, WorkProofActionDisputePartial, WorkProofActorAdmin, WorkProofPartiallyPaid, nil, false},
+		{"admin upholds the rejection", WorkProofDisputed, WorkProofActionDisputeUphold, WorkProofActorAdmin,
+			WorkProofRejectionUpheld, nil, false},
+
+		{"worker cannot approve", WorkProofSubmitted, WorkProofActionApprove, WorkProofActorWorker, "", ErrWorkProofForbidden, false},
+		{"admin cannot approve", WorkProofSubmitted, WorkProofActionApprove, WorkProofActorAdmin, "", ErrWorkProofForbidden, false},
+		{"employer cannot dispute", WorkProofRejected, WorkProofActionOpenDispute, WorkProofActorEmployer, "", ErrWorkProofForbidden, false},
+		{"employer cannot settle a dispute", WorkProofDisputed, WorkProofActionDisputePayFull, WorkProofActorEmployer, "",
+			ErrWorkProofForbidden, false},
+		{"forbidden whatever the status", WorkProofApproved, WorkProofActionResubmit, WorkProofActorEmployer, "",
+			ErrWorkProofForbidden, false},
+
+		{"approve a rejected proof", WorkProofRejected, WorkProofActionApprove, WorkProofActorEmployer, "", nil, true},
+		{"reject an approved proof", WorkProofApproved, WorkProofActionReject, WorkProofActorEmployer, "", nil, true},
+		{"approve twice", WorkProofAutoApproved, WorkProofActionApprove, WorkProofActorEmployer, "", nil, true},
+		{"resubmit a submitted proof", WorkProofSubmitted, WorkProofActionResubmit, WorkProofActorWorker, "", nil, true},
+		{"dispute an accepted rejection", WorkProofRejectedAccepted, WorkProofActionOpenDispute, WorkProofActorWorker, "", nil, true},
+		{"dispute twice", WorkProofDisputed, WorkProofActionOpenDispute, WorkProofActorWorker, "", nil, true},
+		{"settle a settled dispute", WorkProofPartiallyPaid, WorkProofActionDisputePayFull, WorkProofActorAdmin, "", nil, true},
+		{"cancel a cancelled revision", WorkProofCancelledByWorker, WorkProofActionCancelRevision, WorkProofActorSystem, "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proof := &models.WorkProof{ID: "proof-1", Status: tt.status}
+			got, err := workProofMove(proof, tt.action, tt.role)
+
+			if tt.wantIllegal {
+				var transitionErr *WorkProofTransitionError
+				if !errors.As(err, &transitionErr) || !errors.Is(err, ErrIllegalWorkProofTransition) {
+					t.Fatalf("err = %v, want a %T", err, transitionErr)
+				}
+				if transitionErr.ProofID != proof.ID || transitionErr.Action != tt.action || transitionErr.Status != tt.status {
+					t.Errorf("error describes %+v", transitionErr)
+				}
+				return
+			}
+			if err != tt.wantErr {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (got.from != tt.status || got.to != tt.want) {
+				t.Errorf("moved %s -> %s, want %s -> %s", got.from, got.to, tt.status, tt.want)
+			}
+		})
+	}
+
+	if _, err := workProofMove(&models.WorkProof{Status: WorkProofSubmitted}, "sparkle", WorkProofActorAdmin); err == nil {
+		t.Error("an unknown action was allowed")
+	}
+}
+
+func TestWorkProofTransitionsAreFinal(t *testing.T) {
+	final := []string{
+		WorkProofApproved, WorkProofAutoApproved, WorkProofRejectedAccepted, WorkProofRejectionUpheld,
+		WorkProofPartiallyPaid, WorkProofCancelledByWorker,
+	}
+
+	for action, transition := range workProofTransitions {
+		if containsString(final, transition.from) {
+			t.Errorf("%s leaves the final status %s", action, transition.from)
+		}
+		if len(transition.actors) == 0 {
+			t.Errorf("nobody may %s", action)
+		}
+	}
+}
+
+func TestWorkProofTransitionError(t *testing.T) {
+	tests := []struct {
+		action string
+		status string
+		want   string
+	}{
+		{WorkProofActionApprove, WorkProofRejected, "cannot approve a work proof that is rejected"},
+		{WorkProofActionRequestRevision, WorkProofAutoApproved, "cannot request revision a work proof that is auto approved"},
+		{WorkProofActionOpenDispute, WorkProofRejectedAccepted, "cannot open dispute a work proof that is rejected accepted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			err := &WorkProofTransitionError{ProofID: "proof-1", Action: tt.action, Status: tt.status}
+			if err.Error() != tt.want {
+				t.Errorf("Error() = %q, want %q", err.Error(), tt.want)
+			}
+			if !errors.Is(err, ErrIllegalWorkProofTransition) {
+				t.Errorf("%v does not wrap %v", err, ErrIllegalWorkProofTransition)
+			}
+		})
+	}
+}
+
+func TestWorkProofActorRole(t *testing.T) {
+	proof := &models.WorkProof{WorkerID: "worker", EmployerID: "employer"}
+
+	tests := []struct {
+		actorID string
+		want    string
+		wantErr error
+	}{
+		{"", WorkProofActorSystem, nil},
+		{"employer", WorkProofActorEmployer, nil},
+		{"worker", WorkProofActorWorker, nil},
+		{"stranger", "", ErrWorkProofForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.actorID, func(t *testing.T) {
+			got, err := workProofActorRole(proof, tt.actorID)
+			if got != tt.want || err != tt.wantErr {
+				t.Errorf("workProofActorRole(%q) = %q, %v, want %q, %v", tt.actorID, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
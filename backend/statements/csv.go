@@ -0,0 +1,36 @@
+package statements
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+// NewCSVWriter writes one row per transaction. The opening balance comes first
+// and the closing balance last so the file sums up in a spreadsheet.
+func NewCSVWriter(w io.Writer) Writer {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (cw *csvWriter) Begin(header Header) error {
+	cw.w.Write([]string{"date", "type", "description", "reference", "status", "amount", "available_balance", "pending_balance"})
+	return cw.w.Write([]string{header.From.Format(time.RFC3339), "opening_balance", "", "", "", "",
+		header.Opening.Available.String(), header.Opening.Pending.String()})
+}
+
+func (cw *csvWriter) Line(line Line) error {
+	return cw.w.Write([]string{line.Date.Format(time.RFC3339), line.Type, line.Description, line.Reference,
+		line.Status, line.Amount.String(), line.Balances.Available.String(), line.Balances.Pending.String()})
+}
+
+func (cw *csvWriter) End(closing Balances, lines int) error {
+	cw.w.Write([]string{"", "closing_balance", strconv.Itoa(lines) + " transactions", "", "", "",
+		closing.Available.String(), closing.Pending.String()})
+	cw.w.Flush()
+	return cw.w.Error()
+}
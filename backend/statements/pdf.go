@@ -0,0 +1,235 @@
+package statements
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Landscape A4 in points, with a monospaced font so columns line up without
+// measuring text.
+const (
+	pageWidth  = 842
+	pageHeight = 595
+	pageMargin = 40
+	fontSize   = 8
+	lineHeight = 11
+)
+
+// Object numbers fixed up front; pages are numbered from firstPageObject.
+const (
+	catalogObject   = 1
+	pagesObject     = 2
+	fontObject      = 3
+	boldFontObject  = 4
+	firstPageObject = 5
+)
+
+var pdfColumns = []struct {
+	title string
+	width int
+	right bool
+}{
+	{"Date", 17, false},
+	{"Type", 24, false},
+	{"Description", 44, false},
+	{"Reference", 14, false},
+	{"Status", 10, false},
+	{"Amount", 14, true},
+	{"Available", 14, true},
+	{"Pending", 14, true},
+}
+
+// pdfWriter writes a plain PDF one page at a time. Only the current page and
+// the object offsets are kept in memory.
+type pdfWriter struct {
+	w       io.Writer
+	written int64
+	err     error
+
+	offsets []int64 // by object number, 0 for objects not yet written
+	pages   []int   // page object numbers
+
+	title string
+	page  bytes.Buffer
+	y     int
+}
+
+// NewPDFWriter writes the statement as a simple text PDF.
+func NewPDFWriter(w io.Writer) Writer {
+	return &pdfWriter{w: w}
+}
+
+func (pw *pdfWriter) Begin(header Header) error {
+	pw.raw("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	pw.object(catalogObject, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObject))
+	pw.object(fontObject, "<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	pw.object(boldFontObject, "<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >>")
+
+	period := header.From.Format("2 Jan 2006") + " - " + header.To.AddDate(0, 0, -1).Format("2 Jan 2006")
+	pw.title = "Wallet statement " + period
+
+	pw.newPage()
+	pw.text("F2", 12, pw.title)
+	pw.text("F1", fontSize, "Account: "+header.UserID)
+	if len(header.Types) > 0 || header.Status != "" {
+		pw.text("F1", fontSize, fmt.Sprintf("Filtered by type: %s  status: %s",
+			orAll(strings.Join(header.Types, ", ")), orAll(header.Status)))
+	}
+	pw.text("F1", fontSize, "Generated "+header.GeneratedAt.Format("2 Jan 2006 15:04 MST"))
+	pw.y -= lineHeight
+	pw.text("F2", fontSize, fmt.Sprintf("Opening balance  available %s  pending %s  (%s)",
+		header.Opening.Available, header.Opening.Pending, header.Opening.Available.Currency))
+	pw.y -= lineHeight
+	pw.columnHeaders()
+
+	return pw.err
+}
+
+func (pw *pdfWriter) Line(line Line) error {
+	if pw.y < pageMargin {
+		pw.endPage()
+		pw.newPage()
+		pw.text("F2", fontSize, pw.title+" (continued)")
+		pw.y -= lineHeight
+		pw.columnHeaders()
+	}
+
+	description := line.Description
+	if description == "" {
+		description = "-"
+	}
+	pw.text("F1", fontSize, row(
+		line.Date.Format("2006-01-02 15:04"), line.Type, description, line.Reference, line.Status,
+		line.Amount.String(), line.Balances.Available.String(), line.Balances.Pending.String()))
+
+	return pw.err
+}
+
+func (pw *pdfWriter) End(closing Balances, lines int) error {
+	if pw.y < pageMargin+2*lineHeight {
+		pw.endPage()
+		pw.newPage()
+	}
+	pw.y -= lineHeight
+	pw.text("F2", fontSize, fmt.Sprintf("Closing balance  available %s  pending %s  (%d transactions)",
+		closing.Available, closing.Pending, lines))
+	pw.endPage()
+
+	kids := make([]string, len(pw.pages))
+	for i, page := range pw.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", page)
+	}
+	pw.object(pagesObject, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pw.pages)))
+
+	xref := pw.written
+	pw.raw(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", len(pw.offsets)))
+	for _, offset := range pw.offsets[1:] {
+		pw.raw(fmt.Sprintf("%010d 00000 n \n", offset))
+	}
+	pw.raw(fmt.Sprintf("trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(pw.offsets), catalogObject, xref))
+
+	return pw.err
+}
+
+func (pw *pdfWriter) columnHeaders() {
+	titles := make([]string, len(pdfColumns))
+	for i, column := range pdfColumns {
+		titles[i] = column.title
+	}
+	pw.text("F2", fontSize, row(titles...))
+}
+
+func (pw *pdfWriter) newPage() {
+	pw.page.Reset()
+	pw.y = pageHeight - pageMargin
+}
+
+// endPage writes the page's content stream followed by the page object.
+func (pw *pdfWriter) endPage() {
+	contents := pw.nextObject()
+	pw.object(contents, fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", pw.page.Len(), pw.page.String()))
+
+	page := pw.nextObject()
+	pw.object(page, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] "+
+		"/Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> >> /Contents %d 0 R >>",
+		pagesObject, pageWidth, pageHeight, fontObject, boldFontObject, contents))
+	pw.pages = append(pw.pages, page)
+	pw.page.Reset()
+}
+
+func (pw *pdfWriter) text(font string, size int, s string) {
+	fmt.Fprintf(&pw.page, "BT /%s %d Tf %d %d Td (%s) Tj ET\n", font, size, pageMargin, pw.y, escapePDF(s))
+	pw.y -= lineHeight
+}
+
+func (pw *pdfWriter) nextObject() int {
+	if len(pw.offsets) < firstPageObject {
+		return firstPageObject
+	}
+	return len(pw.offsets)
+}
+
+func (pw *pdfWriter) object(number int, body string) {
+	for len(pw.offsets) <= number {
+		pw.offsets = append(pw.offsets, 0)
+	}
+	pw.offsets[number] = pw.written
+	pw.raw(fmt.Sprintf("%d 0 obj\n%s\nendobj\n", number, body))
+}
+
+func (pw *pdfWriter) raw(s string) {
+	if pw.err != nil {
+		return
+	}
+	n, err := io.WriteString(pw.w, s)
+	pw.written += int64(n)
+	pw.err = err
+}
+
+// row lays values out in the PDF columns, cutting any that are too long.
+func row(values ...string) string {
+	var b strings.Builder
+	for i, column := range pdfColumns {
+		value := values[i]
+		if len(value) > column.width-1 {
+			value = value[:column.width-2] + "~"
+		}
+		format := "%-*s"
+		if column.right {
+			format = "%*s "
+		}
+		fmt.Fprintf(&b, format, column.width-1, value)
+		if !column.right {
+			b.WriteByte(' ')
+		}
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+// escapePDF makes s safe inside a PDF string. Characters outside ASCII are
+// replaced, as the standard fonts cannot be relied on to have them.
+func escapePDF(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32 || r > 126:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func orAll(s string) string {
+	if s == "" {
+		return "all"
+	}
+	return s
+}
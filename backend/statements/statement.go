@@ -0,0 +1,44 @@
+// Package statements renders wallet statements. Writers receive the statement
+// one line at a time so a long history never has to be held in memory.
+package statements
+
+import (
+	"time"
+
+	"microjob-backend/money"
+)
+
+// Balances are the wallet balances at a point in the statement.
+type Balances struct {
+	Available money.Money
+	Pending   money.Money
+}
+
+// Header opens a statement. To is exclusive.
+type Header struct {
+	UserID      string
+	From        time.Time
+	To          time.Time
+	Types       []string // empty when not filtered
+	Status      string   // empty when not filtered
+	Opening     Balances
+	GeneratedAt time.Time
+}
+
+// Line is one transaction with the balances after it.
+type Line struct {
+	Date        time.Time
+	Type        string
+	Description string
+	Reference   string
+	Status      string
+	Amount      money.Money // change to available plus pending balance
+	Balances    Balances
+}
+
+// Writer renders a statement: Begin once, Line per transaction, then End.
+type Writer interface {
+	Begin(header Header) error
+	Line(line Line) error
+	End(closing Balances, lines int) error
+}
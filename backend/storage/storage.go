@@ -0,0 +1,78 @@
+// Package storage defines where uploaded files are kept and the backends that
+// keep them, on local disk or in an S3-compatible bucket.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"microjob-backend/config"
+)
+
+var (
+	ErrNotFound       = errors.New("file not found in storage")
+	ErrInvalidKey     = errors.New("invalid storage key")
+	ErrUnknownBackend = errors.New("unknown storage backend")
+)
+
+// MaxSignedURLExpiry is the longest a signed URL may stay valid, the limit
+// S3 puts on presigned requests.
+const MaxSignedURLExpiry = 7 * 24 * time.Hour
+
+// Storage keeps file contents under keys such as "chat/2024/05/<id>.png".
+// Keys are chosen by the caller and never come from user input.
+type Storage interface {
+	Name() string
+	// Put stores size bytes read from r under key, replacing any existing file.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open returns the file's contents. Missing keys fail with ErrNotFound.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a URL anyone holding it can download the file from
+	// until expiry has passed.
+	SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
+
+// NewFromConfig returns the backend selected by cfg.StorageBackend.
+func NewFromConfig(cfg *config.Config) (Storage, error) {
+	switch cfg.StorageBackend {
+	case "local":
+		return NewLocalStorage(cfg.StorageLocalDir, strings.TrimRight(cfg.PublicURL, "/")+"/api/files/content",
+			[]byte(cfg.StorageSigningSecret))
+	case "s3":
+		return NewS3Storage(S3Config{
+			Endpoint:     cfg.S3Endpoint,
+			Region:       cfg.S3Region,
+			Bucket:       cfg.S3Bucket,
+			AccessKey:    cfg.S3AccessKey,
+			SecretKey:    cfg.S3SecretKey,
+			UsePathStyle: cfg.S3UsePathStyle,
+		})
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownBackend, cfg.StorageBackend)
+}
+
+// validKey rejects keys that could escape the storage root or be read
+// differently by the backends.
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+func checkExpiry(expiry time.Duration) error {
+	if expiry <= 0 || expiry > MaxSignedURLExpiry {
+		return fmt.Errorf("signed URL expiry must be between 0 and %s", MaxSignedURLExpiry)
+	}
+	return nil
+}